@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 
+	"github.com/buildpulse/test-reporter/internal/cmd/agent"
+	"github.com/buildpulse/test-reporter/internal/cmd/doctor"
+	"github.com/buildpulse/test-reporter/internal/cmd/env"
+	"github.com/buildpulse/test-reporter/internal/cmd/execute"
+	"github.com/buildpulse/test-reporter/internal/cmd/initialize"
+	"github.com/buildpulse/test-reporter/internal/cmd/prune"
+	"github.com/buildpulse/test-reporter/internal/cmd/schema"
 	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/cmd/telemetry"
+	"github.com/buildpulse/test-reporter/internal/cmd/update"
+	"github.com/buildpulse/test-reporter/internal/cmd/validate"
 	"github.com/buildpulse/test-reporter/internal/logger"
 	"github.com/buildpulse/test-reporter/internal/metadata"
 )
@@ -18,19 +34,224 @@ var (
 	Commit  = "unknown"
 )
 
+// Organization-specific defaults, optionally set at buildtime via ldflags
+// (e.g., -X main.DefaultAccountID=42), so that engineers can submit without
+// copying the IDs into each repository's CI configuration
+var (
+	DefaultAccountID    string
+	DefaultRepositoryID string
+	DefaultBucket       string
+)
+
 var usage = strings.ReplaceAll(`
 CLI to submit test results to BuildPulse
 
 USAGE
 	$ %s submit TEST_RESULTS_PATH --account-id=ACCOUNT_ID --repository-id=REPOSITORY_ID
+	$ %s env [--format=FORMAT]
+	$ %s agent --socket=PATH [--idle-timeout=DURATION]
+	$ %s schema print [SCHEMA]
+	$ %s telemetry show
+	$ %s prune [--older-than=AGE] [--dry-run]
+	$ %s validate TEST_RESULTS_PATH
+	$ %s doctor [--repository-dir=PATH]
+	$ %s update [--version=VERSION] [--check] [--require-signature]
+	$ %s init [--account-id=ACCOUNT_ID] [--repository-id=REPOSITORY_ID] [--provider=PROVIDER]
+	$ %s exec [--retries=N --retry-command=TEMPLATE] TEST_RESULTS_PATH [flags] -- COMMAND [ARGS...]
 
 FLAGS
-  --account-id      (required) BuildPulse account ID for the account that owns the repository
-  --repository-id   (required) BuildPulse repository ID for the repository that produced the test results
-  --repository-dir  Path to local git clone of the repository (default: ".")
+  --account-id      (required unless embedded at buildtime) BuildPulse account ID for the account that owns the repository
+  --repository-id   (required unless embedded at buildtime) BuildPulse repository ID for the repository that produced the test results
+  --config          Path to a YAML file of flag values, overridden by the flags given (default: .buildpulse.yml or
+                    buildpulse.config.yml in the working directory, if present)
+  --repository-dir  Path to local git or Mercurial clone of the repository (default: ".")
   --tree            SHA-1 hash of the git tree that produced the test results (for use only if a local git clone does not exist)
+  --hash-tree       Compute the tree SHA by hashing the files in --repository-dir (as git would) if it isn't a git repository
+  --deepen-shallow-clone  Fetch the rest of the repository's history (using the git CLI) if the commit is missing
+                    from a shallow clone, instead of failing
+  --strict-commit-resolution  Fail if the commit can't be looked up, instead of submitting the test results
+                    without the commit's metadata (overrides BUILDPULSE_STRICT_COMMIT_RESOLUTION)
+  --fail-on-empty   Fail if TEST_RESULTS_PATH is a directory without XML reports, instead of submitting zero
+                    reports (overrides BUILDPULSE_FAIL_ON_EMPTY)
+  --resolve-base-branch  Record the base branch and merge base of the commit, finding the base branch in the
+                    repository when the CI provider doesn't report it
   --coverage-files  Paths to coverage files or directories containing coverage files (space-separated)
+                    Globs may use ** to match any number of directories (e.g., 'coverage/**/lcov.info')
+  --convert-simplecov  Convert SimpleCov result sets (.resultset.json) to a single LCOV file, merging the results
+                    of parallel workers, instead of submitting them as is
+  --convert-excoveralls  Convert excoveralls JSON reports (excoveralls.json) to a single LCOV file, merging the
+                    results of test partitions, instead of submitting them as is
+  --coverage-baseline  (experimental) Directory of baseline coverage files to upload the coverage files as deltas
+                    against (requires --coverage-baseline-ref)
+  --coverage-baseline-ref  (experimental) Identifier of the submission whose coverage files are the baseline
 	--tags            Tags to apply to the build (space-separated)
+                    Tags of the form dim:value (e.g., os:linux ruby:3.3) are also recorded as dimensions
+  --tag             Tag to apply to the build, in addition to --tags (repeatable; comma-separated values are
+                    split, e.g., --tag os:linux,ruby:3.3)
+  --project         Name of the subproject (e.g., a package in a monorepo) that produced the test results
+  --paths-from      Path to a file listing paths to reports, one per line, in addition to TEST_RESULTS_PATH
+                    (- to read the list from stdin)
+  --path            Path to reports in the given format, as format=path (e.g., --path gotest=unit.json)
+                    Supported formats: junit, trx, exunit, gotest, karma, vitest; repeat the flag for each path
+                    TEST_RESULTS_PATH may be omitted if --path is given
+  --timestamp-override  RFC 3339 timestamp to record for the submission instead of the current time (e.g., when
+                    replaying a submission that was recorded earlier)
+  --backfill        Mark the submission as a re-submission of historical test results, recording the original
+                    timestamp (requires --backfill-from or --timestamp-override) and skipping the check for stale reports
+  --backfill-from   Path to the receipt or buildpulse.yml of the original submission, for --backfill
+  --meta            User-defined metadata to attach to the build, as key=value (e.g., --meta browser=chrome)
+                    Repeat the flag for each key
+  --framework       Test framework conventions to use when discovering and processing reports (supported: dotnet, pest, phpunit)
+                    With "dotnet", TRX reports are converted to JUnit XML, and TEST_RESULTS_PATH may be
+                    omitted to find the TRX reports in every TestResults directory in the repository
+                    With "pest" or "phpunit", file paths in the reports are made relative to the repository
+  --exclude-hidden  Skip hidden directories (e.g., .cache, .venv) when searching TEST_RESULTS_PATH for reports (default: true)
+                    Use --exclude-hidden=false to include them
+  --follow-symlinks  Follow symbolic links to directories when searching TEST_RESULTS_PATH for reports
+                    Each directory is searched once, so links that form a cycle are safe to follow
+  --strict-path-vars  Fail if a variable in TEST_RESULTS_PATH (e.g., 'reports/${SHARD}/*.xml') isn't set
+                    By default, variables that aren't set are treated as empty
+  --unwrap-nested-reports  Submit the JUnit reports embedded in the text (e.g., a CDATA section) of a JUnit report
+                    instead of the wrapper report itself; without it, a warning is logged for such reports
+  --truncate-failures  Keep only the first and last this many KB of each failure and error that's longer than twice
+                    that (default: 64; 0 disables truncation)
+  --sign-key        Path to a PEM-encoded ECDSA or Ed25519 private key to sign the bundle's manifest with
+  --sign-keyless    Sign the bundle's manifest keylessly with cosign, using the CI provider's OIDC identity
+  --output          What to print (supported: text, json; default: text)
+                    With "json", a JSON object describing the submission (key, bundle_bytes, reports,
+                    coverage_files, ci_provider) is all that's printed on success
+  --quiet           Print nothing but the key of the uploaded object (errors are still printed)
+  --verbose         Print the details of the submission, such as each file added to the bundle
+  --log-level       Minimum level of the log entries to print (supported: debug, info, warn, error; default: info)
+                    --quiet is the same as --log-level=error, and --verbose the same as --log-level=debug
+  --dry-run         Prepare the bundle and print what would be uploaded (files, sizes, and metadata), without
+                    contacting BuildPulse or S3 (credentials aren't required)
+  --timeout         Maximum time to spend submitting the test results (e.g., 5m), including bundling and uploading
+                    them; fails (or, with --best-effort-exit, gives up) once it passes (default: no limit)
+  --bundle-timeout  Maximum time to spend gathering the metadata and bundling the test results (default: no limit)
+  --upload-timeout  Maximum time to spend uploading the bundle to S3, including retries (default: no limit)
+  --dedupe-attempts  How to handle reports from retried attempts of a CI step (supported: all, latest)
+                    With "all", every attempt's reports are submitted and numbered by attempt (default)
+                    With "latest", only the latest attempt's reports are submitted
+  --key-scheme      How to name the uploaded object (supported: uuid, digest)
+                    With "uuid", each upload gets a random name (default)
+                    With "digest", identical results for the same commit and check share a name
+  --include-env     Environment variables that may be captured in provider metadata (comma-separated names
+                    or patterns, e.g., GITHUB_RUN_*); others are left out. Overrides BUILDPULSE_INCLUDE_ENV
+  --exclude-env     Environment variables to leave out of provider metadata (comma-separated names or
+                    patterns, e.g., GITHUB_EVENT_*). Overrides BUILDPULSE_EXCLUDE_ENV
+  --repo-name-with-owner  Repository name-with-owner (e.g., some-owner/some-repo) to use instead of deriving it from the environment
+                    Overrides the BUILDPULSE_REPO_NAME_WITH_OWNER environment variable
+  --commit          SHA-1 hash of the commit to record instead of deriving it from the environment (e.g., when building
+                    from an exported tarball). Overrides BUILDPULSE_COMMIT_SHA
+  --branch          Branch to record instead of deriving it from the environment. Overrides BUILDPULSE_BRANCH
+  --check-name      Name of the check to record instead of deriving it from the CI provider (e.g., a matrix job's
+                    name); whitespace is trimmed and collapsed. Overrides BUILDPULSE_CHECK_NAME
+  --enrichers       Optional metadata integrations to enable (comma-separated; supported: runner)
+                    Defaults to "runner"; use --enrichers= to disable them all
+  --provider        CI provider to use instead of detecting it from the environment (e.g., jenkins)
+                    Overrides the BUILDPULSE_PROVIDER environment variable
+  --version-check   Check whether this version of the reporter is outdated or unsupported before submitting: warns if
+                    it's significantly outdated and fails if it's no longer supported. Overrides BUILDPULSE_VERSION_CHECK
+  --no-version-check  Skip the version check, even if BUILDPULSE_VERSION_CHECK is set
+  --shard-index     Index of the test shard that produced the test results, starting from 0 (requires --shard-total)
+  --shard-total     Total number of test shards (requires --shard-index)
+  --shard-pattern   Regular expression whose first capture group identifies the shard that produced each report,
+                    from its path (e.g., 'shard-(\d+)/'); warns if the shards' durations are badly imbalanced
+  --shard-timing-file  Path to write the shards' durations and a suggested rebalancing of the test suites to, as JSON
+  --s3-accelerate   Upload via S3 Transfer Acceleration (overrides BUILDPULSE_S3_ACCELERATE)
+                    Falls back to the regular endpoint if the bucket doesn't support acceleration
+  --s3-dualstack    Upload via the dual-stack (IPv4 and IPv6) S3 endpoint (overrides BUILDPULSE_S3_DUALSTACK)
+  --network-audit   Path to write a JSON record of every outbound request (method, host, path, bytes, duration, and
+                    status) made while submitting, such as for a security review
+  --best-effort-exit  Log a warning and exit successfully if the test results can't be submitted, instead
+                    of failing the build (overrides BUILDPULSE_SOFT_FAIL)
+  --soft-fail       Alias for --best-effort-exit
+  --receipt         Path to write a JSON description of an ignored failure to when using --best-effort-exit
+                    (default: buildpulse-receipt.json)
+  --format          Format of the JSON reports named in TEST_RESULTS_PATH (supported: exunit, gotest, karma, vitest)
+                    By default, and for reports found in directories or by globs, the format is detected from the contents
+
+ENV FLAGS
+	The env subcommand prints the metadata detected from the build environment (e.g., the CI provider, branch,
+	commit, and build URL) without submitting anything, and requires neither test results nor credentials
+
+  --format          Output format (supported: json, yaml; default: yaml)
+  --repository-dir  Path to local git or Mercurial clone of the repository (default: "."); optional for env
+
+AGENT FLAGS
+	The agent subcommand (experimental) runs in the background and submits test results on behalf of submit
+	commands run with BUILDPULSE_AGENT_SOCKET set, reusing its connections to BuildPulse across submissions
+
+  --socket          (required) Path of the unix socket to listen on
+  --idle-timeout    How long to wait for a submission before exiting (default: 10m)
+
+PRUNE FLAGS
+	The prune subcommand deletes the temporary files (buildpulse-*) that submissions leave in the temporary
+	directory, which otherwise accumulate on self-hosted runners that aren't wiped between jobs
+
+  --older-than      Minimum age of the files to delete, in days (e.g., 7d) or as a duration (e.g., 12h) (default: 7d)
+  --dry-run         List the files that would be deleted, without deleting them
+  --dir             Directory to prune (default: $TMPDIR, or /tmp)
+
+VALIDATE FLAGS
+	The validate subcommand parses the XML reports found at TEST_RESULTS_PATH (as submit would find them) as
+	JUnit XML, printing the number of test suites and test cases in each, and exits nonzero if any is malformed,
+	so that the configuration can be checked locally before wiring it up in CI
+
+  --exclude-hidden  Skip hidden directories (e.g., .cache, .venv) when searching TEST_RESULTS_PATH for reports (default: true)
+  --follow-symlinks  Follow symbolic links to directories when searching TEST_RESULTS_PATH for reports
+
+DOCTOR FLAGS
+	The doctor subcommand checks the credentials, CI provider, repository, commit, and S3 bucket that submit
+	depends on, printing how to fix each problem it finds, and exits nonzero if any check fails
+
+  --repository-dir  Path to local git or Mercurial clone of the repository (default: ".")
+
+INIT FLAGS
+	The init subcommand asks for any of the following that aren't given, writes the account and repository IDs
+	to .buildpulse.yml, and prints a CI step that submits the test results (for the CI provider detected from
+	the repository's files, unless another is chosen)
+
+  --account-id      BuildPulse account ID for the account that owns the repository
+  --repository-id   BuildPulse repository ID for the repository
+  --provider        CI provider to print the step for (e.g., github-actions, circleci, jenkins, other)
+  --test-results-path  Path to the test reports, as given to submit (e.g., test/reports)
+  --repository-dir  Path to local clone of the repository, where the config file is written (default: ".")
+  --force           Overwrite the config file if it exists
+
+EXEC FLAGS
+	The exec subcommand runs COMMAND, then submits the test results in TEST_RESULTS_PATH (taking the same flags
+	as submit) along with COMMAND's exit status and duration. It exits with COMMAND's exit status (or that of
+	the last retry), or 1 if COMMAND succeeded and the submission failed. The flags and credentials are checked
+	before COMMAND runs. The following go before TEST_RESULTS_PATH
+
+  --retries         Number of times to rerun the tests that failed in TEST_RESULTS_PATH's reports, until they
+                    pass (default: 1 with --retry-command, else 0)
+  --retry-command   Go template of the shell command that reruns the failed tests, given .Tests (each with
+                    .Name, .Classname, and .File) and .Retry (e.g., 'rspec {{files .Tests | quoteAll | join " "}}')
+
+UPDATE FLAGS
+	The update subcommand replaces this binary with a release from GitHub, after verifying the release's checksum
+	(and its cosign signature, if the release is signed and cosign is installed)
+
+  --version         Release to install (default: the latest release)
+  --check           Report whether an update is available, without installing it
+  --require-signature  Fail unless the release's signature can be verified with cosign
+
+SCHEMA ACTIONS
+	The schema subcommand prints the JSON Schemas of the files that describe an uploaded bundle, so that tools
+	that consume bundles can validate them
+
+  print [SCHEMA]    Print the named schema (supported: metadata, coverage-delta, manifest; default: metadata)
+                    "metadata" describes buildpulse.yml; "coverage-delta" describes coverage/*.delta.json;
+                    "manifest" describes manifest.json
+
+TELEMETRY ACTIONS
+	The telemetry subcommand shows the anonymous health ping that submit sends when telemetry is on (see
+	BUILDPULSE_TELEMETRY)
+
+  show              Print the ping that a successful submission from this environment would send (as JSON)
 
 ENVIRONMENT VARIABLES
 	Set the following environment variables:
@@ -39,6 +260,79 @@ ENVIRONMENT VARIABLES
 
 	BUILDPULSE_SECRET_ACCESS_KEY  BuildPulse secret access key for the account that owns the repository
 
+	When rotating access keys, optionally set the following environment variables to the new key. If the
+	upload is rejected with the current key, it's retried with the new key:
+
+	BUILDPULSE_ACCESS_KEY_ID_NEXT      BuildPulse access key ID to fall back to
+
+	BUILDPULSE_SECRET_ACCESS_KEY_NEXT  BuildPulse secret access key to fall back to
+
+	Optionally, set the following environment variables to submit to a BuildPulse environment other than production:
+
+	BUILDPULSE_ENV          BuildPulse environment whose defaults to use (supported: production, staging)
+
+	BUILDPULSE_URL          Base URL of the BuildPulse API (e.g., https://buildpulse.example.com)
+
+	BUILDPULSE_BUCKET       Name of the bucket to upload test results to
+
+	BUILDPULSE_S3_ENDPOINT  Endpoint of an S3-compatible object store to upload test results to
+
+	Optionally, set the following environment variables to "true" to change how the upload reaches S3:
+
+	BUILDPULSE_S3_ACCELERATE  Upload via S3 Transfer Acceleration; falls back if the bucket doesn't support it
+
+	BUILDPULSE_S3_DUALSTACK   Upload via the dual-stack (IPv4 and IPv6) S3 endpoint
+
+	Optionally, set the following environment variable to look up commits that are missing from the local clone
+	(e.g., a shallow clone) via the GitHub API:
+
+	BUILDPULSE_GITHUB_TOKEN  GitHub token that can read the repository given by GITHUB_REPOSITORY
+
+	Optionally, set the following environment variable to use a specific CI provider instead of detecting it:
+
+	BUILDPULSE_PROVIDER  CI provider to use (e.g., jenkins); fails if the provider's required variables are missing
+
+	Optionally, set the following environment variable to define a CI provider that isn't supported natively:
+
+	BUILDPULSE_PROVIDER_CONFIG  Path to a YAML or JSON file mapping the provider's environment variables to metadata fields
+
+	Optionally, set the following environment variable to "true" to never fail the build because the test
+	results can't be submitted (see --best-effort-exit):
+
+	BUILDPULSE_SOFT_FAIL  Log a warning and write a receipt instead of failing
+
+	Optionally, set the following environment variable to submit test results via a running agent:
+
+	BUILDPULSE_AGENT_SOCKET  Path of the agent's unix socket; submits directly if no agent is listening
+
+	Optionally, set the following environment variables (as RFC 3339 timestamps or seconds since the epoch) to
+	record when the CI job was enqueued and started, for CI providers that don't report it to the job:
+
+	BUILDPULSE_JOB_QUEUED_AT   Time the CI job was enqueued
+
+	BUILDPULSE_JOB_STARTED_AT  Time the CI job started
+
+	If set (e.g., by a CI observability tool), the following W3C trace context environment variables are
+	recorded and sent with each outbound request, so that the submission joins the pipeline's trace:
+
+	TRACEPARENT  Trace context of the CI job (e.g., 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01)
+
+	TRACESTATE   Vendor-specific trace state to go along with TRACEPARENT
+
+	Optionally, set the following environment variable to "on" to help the maintainers prioritize fixes by
+	sending an anonymous ping after each submission (the reporter version, OS, CI provider, whether and at which
+	stage the submission failed, and its durations; nothing that identifies the account, repository, or build):
+
+	BUILDPULSE_TELEMETRY  Send anonymous health pings (supported: on, off; default: off)
+
+	Optionally, set the following environment variables to forward the log to a centralized logging service:
+
+	BUILDPULSE_LOG_SYSLOG_ADDR        Address of a syslog server (e.g., udp://logs.example.com:514)
+
+	BUILDPULSE_LOG_CLOUDWATCH_GROUP   AWS CloudWatch Logs group (uses AWS_REGION and the standard AWS credentials)
+
+	BUILDPULSE_LOG_CLOUDWATCH_STREAM  AWS CloudWatch Logs stream (default: buildpulse-test-reporter)
+
 EXAMPLE
 	$ %s submit test/reports/*.xml --account-id 42 --repository-id 8675309 --coverage-files coverage/coverage.xml coverage/coverage2.xml
 `, "\t", "  ")
@@ -48,7 +342,7 @@ func main() {
 	version := flag.Bool("version", false, "")
 	flag.Usage = func() {
 		binaryName := os.Args[0]
-		fmt.Fprintf(flag.CommandLine.Output(), usage, binaryName, binaryName)
+		fmt.Fprintf(flag.CommandLine.Output(), usage, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
 	}
 	flag.Parse()
 
@@ -63,21 +357,207 @@ func main() {
 		flag.Usage()
 	case *version || os.Args[1] == "version":
 		fmt.Print(getVersion().String())
-	case os.Args[1] == "submit" && len(os.Args) > 2:
+	case os.Args[1] == "env":
+		// Keep the log out of the output, unless something goes wrong
+		log := logger.New()
+		e := env.NewEnv(getVersion(), log)
+		if err := e.Init(os.Args[2:], toMap(os.Environ())); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := e.Run(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n%s\n", log.Text(), err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "schema":
+		s := schema.NewSchema()
+		if err := s.Init(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := s.Run(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "telemetry":
+		// Keep the log out of the output, unless something goes wrong
+		log := logger.New()
+		t := telemetry.NewTelemetry(getVersion(), log)
+		if err := t.Init(os.Args[2:], toMap(os.Environ())); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := t.Run(os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n%s\n", log.Text(), err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "prune":
+		log := logger.New(os.Stderr)
+		p := prune.NewPrune(log)
+		if err := p.Init(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := p.Run(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "validate":
+		v := validate.NewValidate()
+		if err := v.Init(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := v.Run(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "doctor":
+		defaults, err := getDefaults()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		// Keep the log out of the output, unless something goes wrong
+		log := logger.New()
+		d := doctor.NewDoctor(getVersion(), log)
+		d.SetDefaults(defaults)
+		if err := d.Init(os.Args[2:], toMap(os.Environ())); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := d.Run(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "init":
+		// Keep the log out of the prompts, unless something goes wrong
+		log := logger.New()
+		i := initialize.NewInitialize(log)
+		if err := i.Init(os.Args[2:], toMap(os.Environ())); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := i.Run(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n%s\n", log.Text(), err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "exec":
+		defaults, err := getDefaults()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
 		log := logger.New(os.Stdout)
-		c := submit.NewSubmit(getVersion(), log)
+		x := execute.NewExecute(getVersion(), log)
+		x.SetDefaults(defaults)
+		if err := x.Init(os.Args[2:], toMap(os.Environ()), submit.NewCommitResolverFactory(log)); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+
+		code, err := x.Run(os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(code)
+	case os.Args[1] == "update":
+		log := logger.New(os.Stderr)
+		u := update.NewUpdate(getVersion(), log)
+		if err := u.Init(os.Args[2:], toMap(os.Environ())); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+		if err := u.Run(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "agent":
+		defaults, err := getDefaults()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		log := logger.New(os.Stdout)
+		a := agent.NewAgent(getVersion(), log)
+		a.SetDefaults(defaults)
+		if err := a.Init(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err = a.Run(ctx)
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case os.Args[1] == "submit" && len(os.Args) > 2:
 		envs := toMap(os.Environ())
+		sinks, err := logger.NewSinksFromEnv(envs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			os.Exit(1)
+		}
+
+		// Forward both the log and any fatal error to the sinks, redacted since
+		// they leave the runner (including the log streamed back by an agent)
+		stdout := []io.Writer{os.Stdout}
+		stderr := []io.Writer{os.Stderr}
+		for _, s := range sinks {
+			w := logger.NewRedactingWriter(s, envs)
+			stdout = append(stdout, w)
+			stderr = append(stderr, w)
+		}
+		errOut := io.MultiWriter(stderr...)
+
+		// The agent can't read this process's stdin, so a list of paths given on
+		// stdin is submitted directly
+		if socket := envs["BUILDPULSE_AGENT_SOCKET"]; socket != "" && !readsStdin(os.Args[2:]) {
+			_, err := agent.Submit(socket, os.Args[2:], envs, io.MultiWriter(stdout...))
+			if err == nil {
+				flushSinks(sinks)
+				break
+			}
+			if !errors.Is(err, agent.ErrUnavailable) {
+				fmt.Fprintln(errOut, err)
+				flushSinks(sinks)
+				os.Exit(1)
+			}
+			fmt.Fprintf(io.MultiWriter(stdout...), "Unable to reach agent at %s (%v); submitting directly\n", socket, err)
+		}
+
+		defaults, err := getDefaults()
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			flushSinks(sinks)
+			os.Exit(1)
+		}
+
+		log := logger.New(stdout...)
+		c := submit.NewSubmit(getVersion(), log)
+		c.SetDefaults(defaults)
 
 		// validate args + env vars
 		if err := c.Init(os.Args[2:], envs, submit.NewCommitResolverFactory(log)); err != nil {
-			fmt.Fprintf(os.Stderr, "\n%s\n\nSee more help with --help\n", err)
+			fmt.Fprintf(errOut, "\n%s\n\nSee more help with --help\n", err)
+			flushSinks(sinks)
 			os.Exit(1)
 		}
-		_, err := c.Run()
+		_, err = c.Run()
+		if err == nil {
+			err = c.WriteResult(os.Stdout)
+		}
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(errOut, err)
+			flushSinks(sinks)
 			os.Exit(1)
 		}
+		flushSinks(sinks)
 	default:
 		flag.Usage()
 		os.Exit(1)
@@ -86,6 +566,16 @@ func main() {
 	os.Exit(0)
 }
 
+// flushSinks delivers the buffered log records to each sink. A failure to
+// deliver the log doesn't affect the outcome of the command.
+func flushSinks(sinks []logger.Sink) {
+	for _, s := range sinks {
+		if err := s.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to forward log: %v\n", err)
+		}
+	}
+}
+
 func toMap(pairs []string) map[string]string {
 	m := map[string]string{}
 	for _, s := range pairs {
@@ -95,10 +585,50 @@ func toMap(pairs []string) map[string]string {
 	return m
 }
 
+// readsStdin returns true if args give the paths of the reports on stdin (i.e.,
+// with --paths-from -).
+func readsStdin(args []string) bool {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == "paths-from=-" || (name == "paths-from" && i+1 < len(args) && args[i+1] == "-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getDefaults parses the organization-specific defaults set at buildtime.
+func getDefaults() (submit.Defaults, error) {
+	d := submit.Defaults{Bucket: DefaultBucket}
+
+	for _, v := range []struct {
+		name  string
+		value string
+		dst   *uint64
+	}{
+		{"DefaultAccountID", DefaultAccountID, &d.AccountID},
+		{"DefaultRepositoryID", DefaultRepositoryID, &d.RepositoryID},
+	} {
+		if v.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseUint(v.value, 10, 64)
+		if err != nil {
+			return submit.Defaults{}, fmt.Errorf("invalid value \"%s\" for embedded default %s: should be a positive integer", v.value, v.name)
+		}
+		*v.dst = n
+	}
+
+	return d, nil
+}
+
 func getVersion() *metadata.Version {
 	return &metadata.Version{
 		Commit:    Commit,
 		GoOS:      runtime.GOOS,
+		GoArch:    runtime.GOARCH,
 		GoVersion: runtime.Version(),
 		Number:    Version,
 	}
@@ -78,6 +78,18 @@ func TestCLI(t *testing.T) {
 			errMsg: "exit status 1",
 			out:    `no XML reports found at TEST_RESULTS_PATH`,
 		},
+		{
+			name:   "env subcommand with invalid format",
+			args:   "env --format xml",
+			errMsg: "exit status 1",
+			out:    `invalid value "xml" for flag -format: supported values are: json, yaml`,
+		},
+		{
+			name:   "agent subcommand without socket",
+			args:   "agent",
+			errMsg: "exit status 1",
+			out:    "missing required flag: -socket",
+		},
 		{
 			name:   "unsupported subcommand",
 			args:   "bogus",
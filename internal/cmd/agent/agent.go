@@ -0,0 +1,307 @@
+// Package agent implements an experimental long-running process that submits
+// test results on behalf of the CLI.
+//
+// Builds that submit many small sets of test results (e.g., one per package in
+// a monorepo) otherwise pay for a new TLS connection to BuildPulse and S3 with
+// each submission. The agent keeps its connections open between submissions,
+// and the CLI hands each submission to the agent over a unix socket.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+)
+
+// connTimeout bounds the time for a client to send a submission and for the
+// agent to process it and respond.
+const connTimeout = 10 * time.Minute
+
+// ErrUnavailable is returned by Submit if no agent is listening on the socket.
+var ErrUnavailable = errors.New("agent unavailable")
+
+// A request is a submission sent by the CLI: the arguments and environment of
+// the `submit` command, along with the directory in which it was run (which
+// relative paths in the arguments are resolved against).
+type request struct {
+	Args []string          `json:"args"`
+	Envs map[string]string `json:"envs"`
+	Dir  string            `json:"dir"`
+}
+
+// A response is the outcome of a submission, along with the log produced while
+// processing it.
+type response struct {
+	Key   string `json:"key,omitempty"`
+	Log   string `json:"log"`
+	Error string `json:"error,omitempty"`
+}
+
+// Agent represents the task of accepting submissions over a unix socket and
+// processing them with a shared set of connections.
+type Agent struct {
+	client  *http.Client
+	fs      *flag.FlagSet
+	logger  logger.Logger
+	version *metadata.Version
+
+	socket      string
+	idleTimeout time.Duration
+	defaults    submit.Defaults
+
+	// mu serializes the submissions, each of which runs in the client's
+	// working directory
+	mu sync.Mutex
+}
+
+// NewAgent creates a new Agent instance.
+func NewAgent(version *metadata.Version, log logger.Logger) *Agent {
+	a := &Agent{
+		fs:      flag.NewFlagSet("agent", flag.ContinueOnError),
+		logger:  log,
+		version: version,
+	}
+
+	a.fs.StringVar(&a.socket, "socket", "", "Path of the unix socket to listen on (required)")
+	a.fs.DurationVar(&a.idleTimeout, "idle-timeout", 10*time.Minute, "How long to wait for a submission before exiting")
+	a.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return a
+}
+
+// SetDefaults sets the defaults for the submissions processed by the agent (see
+// submit.Submit.SetDefaults).
+func (a *Agent) SetDefaults(d submit.Defaults) {
+	a.defaults = d
+}
+
+// Init populates a from args. It returns an error if the args are missing or
+// malformed.
+func (a *Agent) Init(args []string) error {
+	if err := a.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if a.fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", a.fs.Arg(0))
+	}
+
+	if a.socket == "" {
+		return fmt.Errorf("missing required flag: -socket")
+	}
+
+	if a.idleTimeout <= 0 {
+		return fmt.Errorf("invalid value \"%s\" for flag -idle-timeout: should be a positive duration", a.idleTimeout)
+	}
+
+	// Keep idle connections open for as long as the agent itself waits
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.IdleConnTimeout = a.idleTimeout
+	transport.MaxIdleConnsPerHost = 8
+	a.client = &http.Client{Transport: transport}
+
+	return nil
+}
+
+// Run accepts and processes submissions until ctx is done or no submission
+// arrives within the idle timeout. Submissions are processed one at a time.
+func (a *Agent) Run(ctx context.Context) error {
+	l, err := a.listen()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(a.socket)
+
+	var mu sync.Mutex
+	var stopped bool
+	stop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		l.Close()
+	}
+
+	idle := time.AfterFunc(a.idleTimeout, func() {
+		a.logger.Printf("No submissions for %s; exiting", a.idleTimeout)
+		stop()
+	})
+	defer idle.Stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	a.logger.Printf("Listening for submissions on %s", a.socket)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped {
+				return nil
+			}
+			return err
+		}
+
+		idle.Stop()
+		a.serve(conn)
+		idle.Reset(a.idleTimeout)
+	}
+}
+
+// listen creates the socket, replacing a stale socket file left behind by an
+// agent that didn't exit cleanly. Any other file at the path is left alone
+// (e.g., if -socket names the wrong file by mistake). Only the current user can
+// connect to the socket.
+func (a *Agent) listen() (net.Listener, error) {
+	if info, err := os.Lstat(a.socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("invalid value \"%s\" for flag -socket: path exists and is not a socket", a.socket)
+		}
+		if conn, err := net.Dial("unix", a.socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("an agent is already listening on %s", a.socket)
+		}
+		if err := os.Remove(a.socket); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", a.socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(a.socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (a *Agent) serve(conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		a.logger.Printf("Unable to set connection deadline: %v", err)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		a.logger.Printf("Unable to read submission: %v", err)
+		return
+	}
+
+	resp := a.handle(&req)
+	if resp.Error != "" {
+		a.logger.Printf("Submission failed: %s", resp.Error)
+	} else {
+		a.logger.Printf("Submitted %s", resp.Key)
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		a.logger.Printf("Unable to send response: %v", err)
+	}
+}
+
+// handle processes a submission in the client's working directory.
+func (a *Agent) handle(req *request) *response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The response carries only the entries at the level requested by the
+	// submission's flags (e.g., -verbose), as if the client had logged them
+	var out bytes.Buffer
+	log := logger.New(&out)
+	resp := &response{}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	if err := os.Chdir(req.Dir); err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	defer os.Chdir(wd)
+
+	s := submit.NewSubmit(a.version, log)
+	s.SetClient(a.client)
+	s.SetDefaults(a.defaults)
+
+	if err := s.Init(req.Args, req.Envs, submit.NewCommitResolverFactory(log)); err != nil {
+		resp.Log = out.String()
+		resp.Error = err.Error()
+		return resp
+	}
+
+	key, err := s.Run()
+	if err == nil {
+		err = s.WriteResult(&out)
+	}
+	resp.Log = out.String()
+	resp.Key = key
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	return resp
+}
+
+// Submit sends the submission described by the arguments and environment of
+// the `submit` command to the agent listening on socket, writes the log of the
+// submission to w, and returns the key that uniquely identifies the uploaded
+// object. It returns an error wrapping ErrUnavailable if no agent is
+// listening, in which case the caller can submit the test results itself.
+func Submit(socket string, args []string, envs map[string]string, w io.Writer) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		return "", err
+	}
+
+	req := request{Args: args, Envs: envs, Dir: dir}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return "", fmt.Errorf("unable to send submission to agent: %v", err)
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return "", fmt.Errorf("unable to read response from agent: %v", err)
+	}
+
+	if _, err := io.WriteString(w, resp.Log); err != nil {
+		return "", err
+	}
+	if resp.Error != "" {
+		return "", errors.New(strings.TrimSpace(resp.Error))
+	}
+
+	return resp.Key, nil
+}
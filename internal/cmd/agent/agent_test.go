@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_Init(t *testing.T) {
+	a := NewAgent(&metadata.Version{}, logger.New())
+	err := a.Init([]string{"--socket", "/tmp/bp.sock", "--idle-timeout", "30s"})
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/bp.sock", a.socket)
+	assert.Equal(t, 30*time.Second, a.idleTimeout)
+	assert.NotNil(t, a.client)
+}
+
+func TestAgent_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{
+			name:   "MissingSocket",
+			args:   []string{},
+			errMsg: "missing required flag: -socket",
+		},
+		{
+			name:   "NonPositiveIdleTimeout",
+			args:   []string{"--socket", "/tmp/bp.sock", "--idle-timeout", "0s"},
+			errMsg: `invalid value "0s" for flag -idle-timeout: should be a positive duration`,
+		},
+		{
+			name:   "UnexpectedArgument",
+			args:   []string{"--socket", "/tmp/bp.sock", "bogus"},
+			errMsg: "unexpected argument: bogus",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAgent(&metadata.Version{}, logger.New())
+			err := a.Init(tt.args)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+// startAgent runs an agent listening on a socket in a temporary directory, and
+// returns the path of the socket along with a function that stops the agent.
+func startAgent(t *testing.T, log logger.Logger, args ...string) (string, func() error) {
+	socket := filepath.Join(t.TempDir(), "bp.sock")
+
+	a := NewAgent(&metadata.Version{Number: "development"}, log)
+	require.NoError(t, a.Init(append([]string{"--socket", socket}, args...)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(socket)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return socket, func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func TestAgent_Run(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	log := logger.New()
+	socket, stop := startAgent(t, log)
+
+	envs := map[string]string{
+		"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+		"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+		"BUILDPULSE_S3_ENDPOINT":       server.URL,
+		"GITHUB_ACTIONS":               "true",
+		"GITHUB_SHA":                   "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+	args := []string{
+		"testdata/example-reports-dir/example-1.xml",
+		"--account-id", "42",
+		"--repository-id", "8675309",
+		"--tree", "ccccccccccccccccccccdddddddddddddddddddd",
+		"--no-version-check",
+	}
+
+	// Submit from the submit package's directory, so that the relative path of
+	// the report is resolved against it
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("../submit"))
+	defer os.Chdir(wd)
+
+	var keys []string
+	for i := 0; i < 2; i++ {
+		var out strings.Builder
+		key, err := Submit(socket, args, envs, &out)
+		require.NoError(t, err)
+		assert.Regexp(t, `^42/8675309/buildpulse-[0-9a-f-]+\.gz$`, key)
+		assert.Contains(t, out.String(), "Gathering metadata to describe the build")
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, keys, server.Objects("buildpulse-uploads"))
+
+	var out strings.Builder
+	_, err = Submit(socket, []string{"some-non-existent-path"}, envs, &out)
+	assert.ErrorContains(t, err, "no XML reports found at TEST_RESULTS_PATH: some-non-existent-path")
+
+	require.NoError(t, stop())
+	assert.Contains(t, log.Text(), "Listening for submissions on "+socket)
+	assert.Contains(t, log.Text(), "Submitted "+keys[0])
+
+	// The socket is removed when the agent exits
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAgent_Run_idleTimeout(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "bp.sock")
+
+	log := logger.New()
+	a := NewAgent(&metadata.Version{}, log)
+	require.NoError(t, a.Init([]string{"--socket", socket, "--idle-timeout", "50ms"}))
+
+	// Run returns on its own once the idle timeout elapses
+	require.NoError(t, a.Run(context.Background()))
+	assert.Contains(t, log.Text(), "No submissions for 50ms; exiting")
+}
+
+func TestAgent_Run_alreadyListening(t *testing.T) {
+	socket, stop := startAgent(t, logger.New())
+	defer stop()
+
+	a := NewAgent(&metadata.Version{}, logger.New())
+	require.NoError(t, a.Init([]string{"--socket", socket}))
+	err := a.Run(context.Background())
+	assert.EqualError(t, err, "an agent is already listening on "+socket)
+}
+
+func TestAgent_Run_notASocket(t *testing.T) {
+	// A file that isn't a socket isn't taken for a stale socket and removed
+	path := filepath.Join(t.TempDir(), ".bashrc")
+	require.NoError(t, os.WriteFile(path, []byte("export PATH\n"), 0644))
+
+	a := NewAgent(&metadata.Version{}, logger.New())
+	require.NoError(t, a.Init([]string{"--socket", path}))
+	err := a.Run(context.Background())
+	assert.EqualError(t, err, `invalid value "`+path+`" for flag -socket: path exists and is not a socket`)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "export PATH\n", string(data))
+}
+
+func TestSubmit_unavailable(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "bp.sock")
+
+	var out strings.Builder
+	_, err := Submit(socket, nil, nil, &out)
+	assert.True(t, errors.Is(err, ErrUnavailable))
+}
@@ -0,0 +1,285 @@
+// Package doctor implements a command that diagnoses the environment in which
+// test results would be submitted, so that a misconfigured build can be fixed
+// before its first submission fails.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+)
+
+// The outcomes of a check.
+const (
+	statusOK   = "✅"
+	statusWarn = "⚠️ "
+	statusFail = "❌"
+)
+
+// A check is the outcome of one of the doctor's checks: a status, a detail
+// describing what was found, and (unless the check passed) what to do about
+// it.
+type check struct {
+	name   string
+	status string
+	detail string
+	remedy string
+}
+
+// Doctor represents the task of checking the credentials, CI provider, git
+// repository, and S3 connectivity that a submission depends on, and printing
+// how to fix any problems it finds.
+type Doctor struct {
+	client  *http.Client
+	fs      *flag.FlagSet
+	logger  logger.Logger
+	version *metadata.Version
+
+	envs           map[string]string
+	defaults       submit.Defaults
+	repositoryPath string
+}
+
+// NewDoctor creates a new Doctor instance.
+func NewDoctor(version *metadata.Version, log logger.Logger) *Doctor {
+	d := &Doctor{
+		fs:      flag.NewFlagSet("doctor", flag.ContinueOnError),
+		logger:  log,
+		version: version,
+	}
+
+	d.fs.StringVar(&d.repositoryPath, "repository-dir", ".", "Path to local clone of repository")
+	d.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return d
+}
+
+// SetClient sets the HTTP client used to contact S3.
+func (d *Doctor) SetClient(client *http.Client) {
+	d.client = client
+}
+
+// SetDefaults sets the defaults embedded at buildtime (see
+// submit.Submit.SetDefaults), so that the bucket checked is the one that
+// submissions would use.
+func (d *Doctor) SetDefaults(defaults submit.Defaults) {
+	d.defaults = defaults
+}
+
+// Init populates d from args and envs. It returns an error if the args are
+// malformed.
+func (d *Doctor) Init(args []string, envs map[string]string) error {
+	if err := d.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if d.fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", d.fs.Arg(0))
+	}
+
+	d.envs = envs
+
+	return nil
+}
+
+// Run performs each check, writing its outcome to w, and returns an error if
+// any check failed. Warnings don't fail the run.
+func (d *Doctor) Run(w io.Writer) error {
+	credentialsCheck := d.checkCredentials()
+	resolver, repoCheck := d.checkRepository()
+	checks := []check{
+		credentialsCheck,
+		d.checkProvider(),
+		repoCheck,
+		d.checkCommit(resolver),
+		d.checkBucket(credentialsCheck.status != statusFail),
+	}
+
+	var failed, warned int
+	for _, c := range checks {
+		fmt.Fprintf(w, "%s %s: %s\n", c.status, c.name, c.detail)
+		if c.remedy != "" {
+			fmt.Fprintf(w, "   → %s\n", c.remedy)
+		}
+
+		switch c.status {
+		case statusFail:
+			failed++
+		case statusWarn:
+			warned++
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d checks: %d passed, %d warnings, %d failed\n", len(checks), len(checks)-failed-warned, warned, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+
+	return nil
+}
+
+// checkCredentials checks that the environment has the S3 credentials that
+// submit requires.
+func (d *Doctor) checkCredentials() check {
+	c := check{name: "Credentials"}
+
+	var missing []string
+	for _, name := range []string{"BUILDPULSE_ACCESS_KEY_ID", "BUILDPULSE_SECRET_ACCESS_KEY"} {
+		if d.envs[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		c.status = statusFail
+		c.detail = fmt.Sprintf("missing environment variables: %v", missing)
+		c.remedy = "Set BUILDPULSE_ACCESS_KEY_ID and BUILDPULSE_SECRET_ACCESS_KEY from your CI provider's secrets (see the BuildPulse repository settings for their values)"
+		return c
+	}
+
+	nextID, nextKey := d.envs["BUILDPULSE_ACCESS_KEY_ID_NEXT"], d.envs["BUILDPULSE_SECRET_ACCESS_KEY_NEXT"]
+	switch {
+	case (nextID == "") != (nextKey == ""):
+		c.status = statusFail
+		c.detail = "only one of BUILDPULSE_ACCESS_KEY_ID_NEXT and BUILDPULSE_SECRET_ACCESS_KEY_NEXT is set"
+		c.remedy = "Set both of the fallback credentials, or neither"
+	case nextID != "":
+		c.status = statusOK
+		c.detail = fmt.Sprintf("found access key %s, with fallback access key %s", d.envs["BUILDPULSE_ACCESS_KEY_ID"], nextID)
+	default:
+		c.status = statusOK
+		c.detail = fmt.Sprintf("found access key %s", d.envs["BUILDPULSE_ACCESS_KEY_ID"])
+	}
+
+	return c
+}
+
+// checkProvider checks that the CI provider can be detected from the
+// environment.
+func (d *Doctor) checkProvider() check {
+	c := check{name: "CI provider"}
+
+	provider, err := metadata.DetectProvider(d.envs, d.logger)
+	if err != nil {
+		c.status = statusFail
+		c.detail = err.Error()
+		c.remedy = "Run in a supported CI provider, or describe the build with BUILDPULSE_PROVIDER_CONFIG (see the README)"
+		return c
+	}
+
+	c.status = statusOK
+	c.detail = provider
+	return c
+}
+
+// checkRepository checks that there's a git (or Mercurial) repository at the
+// -repository-dir path. It returns the repository's resolver, or nil if there's
+// no repository.
+func (d *Doctor) checkRepository() (metadata.CommitResolver, check) {
+	c := check{name: "Repository"}
+
+	resolver, err := submit.NewCommitResolverFactory(d.logger).NewFromRepository(d.repositoryPath, false)
+	if err != nil {
+		c.status = statusFail
+		c.detail = fmt.Sprintf("no repository found at %s: %v", d.repositoryPath, err)
+		c.remedy = "Pass the path of the clone with --repository-dir, or pass submit the commit's tree with --tree if there's no clone"
+		return nil, c
+	}
+
+	c.status = statusOK
+	c.detail = fmt.Sprintf("found repository at %s", d.repositoryPath)
+	return resolver, c
+}
+
+// checkCommit checks that the build's commit can be looked up in the
+// repository, as submit would look it up.
+func (d *Doctor) checkCommit(resolver metadata.CommitResolver) check {
+	c := check{name: "Commit"}
+
+	if resolver == nil {
+		c.status = statusWarn
+		c.detail = "skipped, since there's no repository to look the commit up in"
+		return c
+	}
+
+	meta, err := metadata.NewMetadata(context.Background(), d.version, d.envs, nil, "", resolver, time.Now, d.logger)
+	if err != nil {
+		c.status = statusFail
+		c.detail = err.Error()
+		c.remedy = "Check the CI provider's environment variables, which the commit is taken from"
+		return c
+	}
+
+	if err := meta.CommitLookupError(); err != nil {
+		c.status = statusFail
+		c.detail = fmt.Sprintf("unable to resolve commit %s: %v", meta.CommitSHA, err)
+		c.remedy = "Fetch the commit (e.g., actions/checkout with fetch-depth: 0), pass submit --deepen-shallow-clone, or set BUILDPULSE_GITHUB_TOKEN to look the commit up via the GitHub API"
+		return c
+	}
+
+	c.status = statusOK
+	c.detail = fmt.Sprintf("resolved commit %s", meta.CommitSHA)
+	return c
+}
+
+// checkBucket checks that the bucket that test results would be uploaded to is
+// reachable with the credentials in the environment. It's skipped unless
+// haveCredentials is true.
+func (d *Doctor) checkBucket(haveCredentials bool) check {
+	c := check{name: "S3"}
+
+	if !haveCredentials {
+		c.status = statusWarn
+		c.detail = "skipped, since there are no credentials to reach the bucket with"
+		return c
+	}
+
+	s := submit.NewSubmit(d.version, d.logger)
+	s.SetDefaults(d.defaults)
+	if d.client != nil {
+		s.SetClient(d.client)
+	}
+
+	bucket, err := s.CheckBucket(d.envs)
+	if err == nil {
+		c.status = statusOK
+		c.detail = fmt.Sprintf("reached bucket %s", bucket)
+		return c
+	}
+
+	var rerr awserr.RequestFailure
+	switch {
+	case bucket == "":
+		c.status = statusFail
+		c.detail = err.Error()
+		c.remedy = "Fix the BUILDPULSE_ENV, BUILDPULSE_URL, or BUILDPULSE_BUCKET environment variable"
+	case errors.As(err, &rerr) && rerr.StatusCode() == http.StatusForbidden:
+		// Checking the bucket requires s3:ListBucket, which credentials that are
+		// only allowed to upload don't have, so this isn't necessarily a problem
+		c.status = statusWarn
+		c.detail = fmt.Sprintf("access to bucket %s was denied: %v", bucket, err)
+		c.remedy = "If submissions fail too, check that the credentials are current and allowed to s3:PutObject in the bucket"
+	case errors.As(err, &rerr) && rerr.StatusCode() == http.StatusNotFound:
+		c.status = statusFail
+		c.detail = fmt.Sprintf("bucket %s doesn't exist: %v", bucket, err)
+		c.remedy = "Check BUILDPULSE_BUCKET (or BUILDPULSE_ENV) and BUILDPULSE_S3_ENDPOINT"
+	case errors.As(err, &rerr):
+		c.status = statusFail
+		c.detail = fmt.Sprintf("unable to reach bucket %s: %v", bucket, err)
+		c.remedy = "Check the credentials and the bucket's policy"
+	default:
+		c.status = statusFail
+		c.detail = fmt.Sprintf("unable to reach bucket %s: %v", bucket, err)
+		c.remedy = "Check that the build can reach S3 (e.g., through its proxy or firewall), or set BUILDPULSE_S3_ENDPOINT"
+	}
+
+	return c
+}
@@ -0,0 +1,186 @@
+package doctor
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRepository creates a git repository with a single commit, and returns its
+// path and the commit's SHA.
+func newRepository(t *testing.T) (string, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Some Author", "GIT_AUTHOR_EMAIL=author@example.com",
+			"GIT_COMMITTER_NAME=Some Committer", "GIT_COMMITTER_EMAIL=committer@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+	git("commit", "--quiet", "--allow-empty", "-m", "Initial commit")
+
+	return dir, git("rev-parse", "HEAD")
+}
+
+func exampleEnv(server *s3test.Server, sha string) map[string]string {
+	return map[string]string{
+		"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+		"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+		"BUILDPULSE_BUCKET":            "buildpulse-uploads",
+		"BUILDPULSE_S3_ENDPOINT":       server.URL,
+		"GITHUB_ACTIONS":               "true",
+		"GITHUB_REF":                   "refs/heads/some-branch",
+		"GITHUB_REPOSITORY":            "some-owner/some-repo",
+		"GITHUB_RUN_ID":                "42",
+		"GITHUB_SERVER_URL":            "https://github.com",
+		"GITHUB_SHA":                   sha,
+	}
+}
+
+func TestDoctor_Run(t *testing.T) {
+	dir, sha := newRepository(t)
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	d := NewDoctor(&metadata.Version{}, logger.New())
+	d.SetClient(server.Client())
+	require.NoError(t, d.Init([]string{"--repository-dir", dir}, exampleEnv(server, sha)))
+
+	var out bytes.Buffer
+	require.NoError(t, d.Run(&out))
+	assert.Contains(t, out.String(), "✅ Credentials: found access key some-access-key-id")
+	assert.Contains(t, out.String(), "✅ CI provider: github-actions")
+	assert.Contains(t, out.String(), "✅ Repository: found repository at "+dir)
+	assert.Contains(t, out.String(), "✅ Commit: resolved commit "+sha)
+	assert.Contains(t, out.String(), "✅ S3: reached bucket buildpulse-uploads")
+	assert.Contains(t, out.String(), "5 checks: 5 passed, 0 warnings, 0 failed")
+	assert.NotContains(t, out.String(), "→")
+}
+
+func TestDoctor_Run_problems(t *testing.T) {
+	dir, sha := newRepository(t)
+
+	tests := []struct {
+		name    string
+		args    []string
+		envs    func(envs map[string]string, server *s3test.Server)
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "MissingCredentials",
+			envs: func(envs map[string]string, server *s3test.Server) {
+				delete(envs, "BUILDPULSE_SECRET_ACCESS_KEY")
+			},
+			want: []string{
+				"❌ Credentials: missing environment variables: [BUILDPULSE_SECRET_ACCESS_KEY]",
+				"→ Set BUILDPULSE_ACCESS_KEY_ID and BUILDPULSE_SECRET_ACCESS_KEY",
+				"⚠️  S3: skipped",
+			},
+			wantErr: "1 of 5 checks failed",
+		},
+		{
+			name: "UnpairedNextCredentials",
+			envs: func(envs map[string]string, server *s3test.Server) {
+				envs["BUILDPULSE_ACCESS_KEY_ID_NEXT"] = "some-next-access-key-id"
+			},
+			want:    []string{"❌ Credentials: only one of BUILDPULSE_ACCESS_KEY_ID_NEXT and BUILDPULSE_SECRET_ACCESS_KEY_NEXT is set"},
+			wantErr: "1 of 5 checks failed",
+		},
+		{
+			name: "NoRepository",
+			args: []string{"--repository-dir", t.TempDir()},
+			want: []string{
+				"❌ Repository: no repository found at",
+				"→ Pass the path of the clone with --repository-dir",
+				"⚠️  Commit: skipped",
+			},
+			wantErr: "1 of 5 checks failed",
+		},
+		{
+			name: "UnresolvableCommit",
+			envs: func(envs map[string]string, server *s3test.Server) {
+				envs["GITHUB_SHA"] = "0000000000000000000000000000000000000000"
+			},
+			want: []string{
+				"❌ Commit: unable to resolve commit 0000000000000000000000000000000000000000",
+				"→ Fetch the commit (e.g., actions/checkout with fetch-depth: 0)",
+			},
+			wantErr: "1 of 5 checks failed",
+		},
+		{
+			name: "NoBucket",
+			envs: func(envs map[string]string, server *s3test.Server) {
+				envs["BUILDPULSE_BUCKET"] = "some-other-bucket"
+			},
+			want: []string{
+				"❌ S3: bucket some-other-bucket doesn't exist",
+				"→ Check BUILDPULSE_BUCKET",
+			},
+			wantErr: "1 of 5 checks failed",
+		},
+		{
+			name: "AccessDenied",
+			envs: func(envs map[string]string, server *s3test.Server) {
+				server.FailNext(1, 403, "AccessDenied")
+			},
+			want: []string{
+				"⚠️  S3: access to bucket buildpulse-uploads was denied",
+				"5 checks: 4 passed, 1 warnings, 0 failed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := s3test.NewServer("buildpulse-uploads")
+			defer server.Close()
+
+			envs := exampleEnv(server, sha)
+			if tt.envs != nil {
+				tt.envs(envs, server)
+			}
+			args := tt.args
+			if args == nil {
+				args = []string{"--repository-dir", dir}
+			}
+
+			d := NewDoctor(&metadata.Version{}, logger.New())
+			d.SetClient(server.Client())
+			require.NoError(t, d.Init(args, envs))
+
+			var out bytes.Buffer
+			err := d.Run(&out)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, want := range tt.want {
+				assert.Contains(t, out.String(), want)
+			}
+		})
+	}
+}
+
+func TestDoctor_Init_unexpectedArgument(t *testing.T) {
+	d := NewDoctor(&metadata.Version{}, logger.New())
+	err := d.Init([]string{"some-path"}, map[string]string{})
+	assert.EqualError(t, err, "unexpected argument: some-path")
+}
@@ -0,0 +1,177 @@
+package env
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// The output formats supported by the -format flag.
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+var supportedFormats = []string{formatJSON, formatYAML}
+
+// Env represents the task of detecting the build environment and printing the
+// resulting metadata, as it would be submitted to BuildPulse.
+type Env struct {
+	fs      *flag.FlagSet
+	logger  logger.Logger
+	version *metadata.Version
+
+	envs           map[string]string
+	format         string
+	repositoryPath string
+	commitResolver metadata.CommitResolver
+}
+
+// NewEnv creates a new Env instance.
+func NewEnv(version *metadata.Version, log logger.Logger) *Env {
+	e := &Env{
+		fs:      flag.NewFlagSet("env", flag.ContinueOnError),
+		logger:  log,
+		version: version,
+	}
+
+	e.fs.StringVar(&e.format, "format", formatYAML, "Output format (supported: json, yaml)")
+	e.fs.StringVar(&e.repositoryPath, "repository-dir", ".", "Path to local clone of repository")
+	e.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return e
+}
+
+// Init populates e from args and envs. It returns an error if the args are
+// malformed. Unlike `submit`, it requires neither test results nor
+// credentials, and the git repository is optional.
+func (e *Env) Init(args []string, envs map[string]string) error {
+	if err := e.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if e.fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", e.fs.Arg(0))
+	}
+
+	if !contains(supportedFormats, e.format) {
+		return fmt.Errorf("invalid value \"%s\" for flag -format: supported values are: %s", e.format, strings.Join(supportedFormats, ", "))
+	}
+
+	e.envs = envs
+
+	resolver, err := metadata.NewRepositoryCommitResolver(e.repositoryPath, false, e.logger)
+	if err != nil && metadata.HasMercurialRepository(e.repositoryPath) {
+		resolver, err = metadata.NewMercurialCommitResolver(e.repositoryPath, e.logger)
+	}
+	if err != nil {
+		e.logger.Printf("Unable to open repository at %s (%v); using commit SHA from the environment", e.repositoryPath, err)
+		resolver = metadata.NewStaticCommitResolver(&metadata.Commit{}, e.logger)
+	}
+	e.commitResolver = resolver
+
+	return nil
+}
+
+// Run detects the build environment and writes the resulting metadata to w.
+// The fields are named as in the metadata submitted to BuildPulse, without the
+// leading colon.
+func (e *Env) Run(w io.Writer) error {
+	meta, err := metadata.NewMetadata(context.Background(), e.version, e.envs, nil, "", e.commitResolver, time.Now, e.logger)
+	if err != nil {
+		return err
+	}
+
+	if err := meta.Enrich(context.Background(), metadata.DefaultEnrichers); err != nil {
+		return err
+	}
+
+	data, err := meta.MarshalYAML()
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("unexpected metadata document")
+	}
+	fields := doc.Content[0]
+	for i := 0; i < len(fields.Content); i += 2 {
+		fields.Content[i].Value = strings.TrimPrefix(fields.Content[i].Value, ":")
+	}
+
+	switch e.format {
+	case formatJSON:
+		out, err := toJSON(fields)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	default:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(fields); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+}
+
+// toJSON renders the YAML mapping node as an indented JSON object, preserving
+// the order of its fields.
+func toJSON(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i := 0; i < len(node.Content); i += 2 {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		var value interface{}
+		if err := node.Content[i+1].Decode(&value); err != nil {
+			return nil, err
+		}
+
+		k, err := json.Marshal(node.Content[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s:%s", k, v)
+	}
+	buf.WriteString("}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
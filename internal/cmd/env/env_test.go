@@ -0,0 +1,98 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var exampleEnv = map[string]string{
+	"GITHUB_ACTIONS":    "true",
+	"GITHUB_REF":        "refs/heads/some-branch",
+	"GITHUB_REPOSITORY": "some-owner/some-repo",
+	"GITHUB_RUN_ID":     "42",
+	"GITHUB_SERVER_URL": "https://github.com",
+	"GITHUB_SHA":        "1f192ff735f887dd7a25229b2ece0422d17931f5",
+}
+
+func TestEnv_Run(t *testing.T) {
+	tests := []struct {
+		format    string
+		unmarshal func([]byte, interface{}) error
+	}{
+		{format: "yaml", unmarshal: yaml.Unmarshal},
+		{format: "json", unmarshal: json.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			e := NewEnv(&metadata.Version{Number: "v1.2.3"}, logger.New())
+			err := e.Init([]string{"--format", tt.format, "--repository-dir", t.TempDir()}, exampleEnv)
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			require.NoError(t, e.Run(&out))
+
+			var fields map[string]interface{}
+			require.NoError(t, tt.unmarshal(out.Bytes(), &fields))
+			assert.Equal(t, "some-branch", fields["branch"])
+			assert.Equal(t, "https://github.com/some-owner/some-repo/actions/runs/42/attempts/0", fields["build_url"])
+			assert.Equal(t, "github-actions", fields["ci_provider"])
+			assert.Equal(t, "1f192ff735f887dd7a25229b2ece0422d17931f5", fields["commit"])
+			assert.Equal(t, "some-owner/some-repo", fields["repo_name_with_owner"])
+			assert.Equal(t, "v1.2.3", fields["reporter_version"])
+			assert.Equal(t, "refs/heads/some-branch", fields["github_ref"])
+			assert.NotContains(t, out.String(), ":branch")
+		})
+	}
+}
+
+func TestEnv_Run_detectionError(t *testing.T) {
+	e := NewEnv(&metadata.Version{}, logger.New())
+	require.NoError(t, e.Init([]string{"--repository-dir", t.TempDir()}, map[string]string{}))
+
+	err := e.Run(&bytes.Buffer{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `environment variable "GIT_COMMIT" should not be empty`)
+	}
+}
+
+func TestEnv_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{
+			name:   "UnsupportedFormat",
+			args:   []string{"--format", "xml"},
+			errMsg: `invalid value "xml" for flag -format: supported values are: json, yaml`,
+		},
+		{
+			name:   "UnexpectedArgument",
+			args:   []string{"some-path"},
+			errMsg: `unexpected argument: some-path`,
+		},
+		{
+			name:   "UnsupportedFlag",
+			args:   []string{"--account-id", "42"},
+			errMsg: `flag provided but not defined: -account-id`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEnv(&metadata.Version{}, logger.New())
+			err := e.Init(tt.args, exampleEnv)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.errMsg, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Package execute implements a command that runs the test command and then
+// submits its test results, so that a CI job needs a single step to do both,
+// and the results are submitted even when the tests fail. Optionally, the tests
+// that failed are retried first, and the retries are submitted along with the
+// first run.
+package execute
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+)
+
+// Execute represents the task of running the test command, then submitting
+// the test results it produced along with its exit status and wall time.
+type Execute struct {
+	fs     *flag.FlagSet
+	logger logger.Logger
+	submit *submit.Submit
+
+	// stdin, stdout, and stderr are given to the test command
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	envs                  map[string]string
+	commitResolverFactory submit.CommitResolverFactory
+	submitArgs            []string
+	command               []string
+	retries               uint
+	retryCommand          string
+	retryTemplate         *template.Template
+
+	// interrupted is set once a signal has been forwarded to the test command
+	// (e.g., because the CI job was canceled), after which nothing is retried
+	interrupted atomic.Bool
+}
+
+// NewExecute creates a new Execute instance.
+func NewExecute(version *metadata.Version, log logger.Logger) *Execute {
+	e := &Execute{
+		fs:     flag.NewFlagSet("exec", flag.ContinueOnError),
+		logger: log,
+		submit: submit.NewSubmit(version, log),
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+
+	e.fs.UintVar(&e.retries, "retries", 0, "Number of times to rerun the tests that failed, until they pass (default 1 with -retry-command)")
+	e.fs.StringVar(&e.retryCommand, "retry-command", "", "Template of the shell command that reruns the tests that failed")
+	e.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return e
+}
+
+// SetClient sets the HTTP client used to contact BuildPulse and S3.
+func (e *Execute) SetClient(client *http.Client) {
+	e.submit.SetClient(client)
+}
+
+// SetDefaults sets the defaults embedded at buildtime (see
+// submit.Submit.SetDefaults). It must be called before Init.
+func (e *Execute) SetDefaults(d submit.Defaults) {
+	e.submit.SetDefaults(d)
+}
+
+// Init populates e from args and envs: the flags of `exec`, the arguments of
+// `submit` (i.e., TEST_RESULTS_PATH and any flags), then "--" and the test
+// command. It returns an error if there's no test command, or the flags of
+// `exec` or the arguments of `submit` are malformed, so that the tests don't
+// run only for their results to go unsubmitted. The test results themselves are
+// only found once the test command has run, since they don't exist before then.
+func (e *Execute) Init(args []string, envs map[string]string, commitResolverFactory submit.CommitResolverFactory) error {
+	var command []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, command = args[:i], args[i+1:]
+			break
+		}
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("missing test command: give it after \"--\" (e.g., test-reporter exec test/reports --account-id 42 --repository-id 8675309 -- make test)")
+	}
+
+	execArgs, submitArgs := e.splitArgs(args)
+	if err := e.fs.Parse(execArgs); err != nil {
+		return err
+	}
+	e.submitArgs, e.command = submitArgs, command
+
+	flagset := make(map[string]bool)
+	e.fs.Visit(func(f *flag.Flag) { flagset[f.Name] = true })
+
+	if e.retryCommand != "" {
+		t, err := template.New("retry-command").Funcs(retryFuncs).Option("missingkey=error").Parse(e.retryCommand)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -retry-command: %v", e.retryCommand, err)
+		}
+		e.retryTemplate = t
+
+		if !flagset["retries"] {
+			e.retries = 1
+		}
+	}
+	if e.retries > 0 && e.retryTemplate == nil {
+		return fmt.Errorf("missing required flag: -retry-command (required with -retries)")
+	}
+	if e.retries > 0 && len(e.reportArgs()) == 0 {
+		return fmt.Errorf("missing TEST_RESULTS_PATH: required with -retries, to find the tests that failed")
+	}
+
+	if err := e.submit.CheckArgs(e.submitArgs, envs, commitResolverFactory); err != nil {
+		return err
+	}
+	e.envs = envs
+	e.commitResolverFactory = commitResolverFactory
+
+	return nil
+}
+
+// Run runs the test command (and, with -retries, reruns the tests that failed),
+// then submits the test results, writing the result of the submission to w. It
+// returns the exit status to exit with: that of the test command's last run, so
+// that the CI job fails if the tests do, or else 1 if the submission fails. It
+// returns an error if the test command can't be run or the submission fails.
+func (e *Execute) Run(w io.Writer) (int, error) {
+	e.logger.Printf("Running test command: %s", strings.Join(e.command, " "))
+
+	start := time.Now()
+	status, err := e.run(exec.Command(e.command[0], e.command[1:]...))
+	if err != nil {
+		return 1, fmt.Errorf("unable to run test command: %v", err)
+	}
+	duration := time.Since(start)
+	e.logger.Printf("Test command exited with status %d after %s", status, duration.Round(time.Millisecond))
+	e.submit.SetTestCommandResult(status, duration)
+
+	if status != 0 && e.retries > 0 {
+		if e.interrupted.Load() {
+			e.logger.Printf("Not retrying the failed tests: the test command was interrupted")
+		} else {
+			dir, err := os.MkdirTemp("", "buildpulse-retries-")
+			if err != nil {
+				e.logger.Printf("⚠️ Unable to retry the failed tests: %v", err)
+			} else {
+				defer os.RemoveAll(dir)
+				status = e.retryFailedTests(dir, status)
+			}
+		}
+	}
+
+	err = e.submit.Init(e.submitArgs, e.envs, e.commitResolverFactory)
+	if err == nil {
+		_, err = e.submit.Run()
+	}
+	if err == nil {
+		err = e.submit.WriteResult(w)
+	}
+
+	switch {
+	case status != 0:
+		return status, err
+	case err != nil:
+		return 1, err
+	default:
+		return 0, nil
+	}
+}
+
+// run runs cmd with the standard streams of the test command, and returns its
+// exit status. It returns an error if cmd can't be run.
+func (e *Execute) run(cmd *exec.Cmd) (int, error) {
+	cmd.Stdin = e.stdin
+	cmd.Stdout = e.stdout
+	cmd.Stderr = e.stderr
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	// The test command gets the signals that would otherwise stop the reporter
+	// (e.g., when the CI job is canceled), so that the results it has produced
+	// by the time it exits can still be submitted
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				e.interrupted.Store(true)
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(signals)
+	close(done)
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitStatus(exitErr), nil
+	case err != nil:
+		return 0, err
+	default:
+		return 0, nil
+	}
+}
+
+// splitArgs returns the leading args that are flags of `exec`, and the rest,
+// which are the arguments of `submit`.
+func (e *Execute) splitArgs(args []string) ([]string, []string) {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if e.fs.Lookup(name) == nil {
+			break
+		}
+		i++
+		if !hasValue {
+			i++
+		}
+	}
+	i = min(i, len(args))
+
+	return args[:i], args[i:]
+}
+
+// reportArgs returns the paths of the test reports given to `submit` (i.e.,
+// TEST_RESULTS_PATH), which precede its flags.
+func (e *Execute) reportArgs() []string {
+	for i, arg := range e.submitArgs {
+		if strings.HasPrefix(arg, "-") {
+			return e.submitArgs[:i]
+		}
+	}
+
+	return e.submitArgs
+}
+
+// exitStatus returns the exit status of the process that exited with err, as
+// a shell would report it: 128 plus the signal number if the process was
+// killed by a signal.
+func exitStatus(err *exec.ExitError) int {
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+
+	return err.ExitCode()
+}
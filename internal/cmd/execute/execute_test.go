@@ -0,0 +1,236 @@
+package execute
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// credentialEnv holds the credentials that `submit` requires, for tests that
+// only call Init.
+var credentialEnv = map[string]string{
+	"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+	"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+}
+
+// submitArgs are the flags that `submit` requires, for tests that only call
+// Init.
+var submitArgs = []string{"--account-id", "42", "--repository-id", "8675309", "--tree", "ccccccccccccccccccccdddddddddddddddddddd"}
+
+func exampleEnv(server *s3test.Server) map[string]string {
+	return map[string]string{
+		"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+		"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+		"BUILDPULSE_BUCKET":            "buildpulse-uploads",
+		"BUILDPULSE_S3_ENDPOINT":       server.URL,
+		"GITHUB_ACTIONS":               "true",
+		"GITHUB_REF":                   "refs/heads/some-branch",
+		"GITHUB_REPOSITORY":            "some-owner/some-repo",
+		"GITHUB_RUN_ID":                "42",
+		"GITHUB_SERVER_URL":            "https://github.com",
+		"GITHUB_SHA":                   "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+}
+
+// newExecute returns an Execute that runs script with sh, after which the
+// reports in the directory of $REPORT are submitted to server. The flags of
+// `exec` are given by execArgs, and any flags of `submit` beyond those it
+// requires by submitArgs.
+func newExecute(t *testing.T, server *s3test.Server, execArgs []string, script string, submitArgs ...string) (*Execute, logger.Logger, *bytes.Buffer) {
+	dir := t.TempDir()
+	t.Setenv("REPORT", filepath.Join(dir, "report.xml"))
+	log := logger.New()
+
+	var out bytes.Buffer
+	e := NewExecute(&metadata.Version{Number: "v1.2.3"}, log)
+	e.SetClient(server.Client())
+	e.stdin = &bytes.Buffer{}
+	e.stdout = &out
+	e.stderr = &out
+
+	args := append(execArgs, dir, "--account-id", "42", "--repository-id", "8675309", "--tree", "ccccccccccccccccccccdddddddddddddddddddd")
+	args = append(args, submitArgs...)
+	args = append(args, "--", "sh", "-c", script)
+	require.NoError(t, e.Init(args, exampleEnv(server), submit.NewCommitResolverFactory(log)))
+
+	return e, log, &out
+}
+
+const writeReport = `echo running tests; echo '<testsuite name="suite" tests="1"><testcase name="test"/></testsuite>' > "$REPORT"`
+
+// writeFailingReport writes a report in which two of three tests fail.
+const writeFailingReport = `echo '<testsuite name="suite"><testcase name="a"><failure/></testcase><testcase name="b c"><failure/></testcase><testcase name="d"/></testsuite>' > "$REPORT"`
+
+// bundledFiles returns the contents of the files in the only object uploaded
+// to server, keyed by path in the tarball.
+func bundledFiles(t *testing.T, server *s3test.Server) map[string]string {
+	keys := server.Objects("buildpulse-uploads")
+	require.Len(t, keys, 1)
+
+	gz, err := gzip.NewReader(bytes.NewReader(server.Object("buildpulse-uploads", keys[0]).Body))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	r := tar.NewReader(gz)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[hdr.Name] = string(data)
+	}
+}
+
+// bundledMetadata returns the buildpulse.yml in the only object uploaded to
+// server.
+func bundledMetadata(t *testing.T, server *s3test.Server) string {
+	return bundledFiles(t, server)["buildpulse.yml"]
+}
+
+func TestExecute_Run(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	e, log, out := newExecute(t, server, nil, writeReport)
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "running tests\n", out.String())
+	assert.Contains(t, log.Text(), "Test command exited with status 0")
+	assert.Contains(t, bundledMetadata(t, server), ":test_command_exit_status: 0\n")
+}
+
+func TestExecute_Run_failingTests(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	e, log, _ := newExecute(t, server, nil, writeReport+"; exit 3")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 3, status)
+	assert.Contains(t, log.Text(), "Test command exited with status 3")
+	assert.Contains(t, log.Text(), "Delivered test results to BuildPulse")
+
+	meta := bundledMetadata(t, server)
+	assert.Contains(t, meta, ":test_command_exit_status: 3\n")
+	assert.Contains(t, meta, ":test_command_seconds: ")
+}
+
+func TestExecute_Run_killedTests(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	e, _, _ := newExecute(t, server, nil, writeReport+"; kill -TERM $$")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 143, status)
+	assert.Contains(t, bundledMetadata(t, server), ":test_command_exit_status: 143\n")
+}
+
+func TestExecute_Run_submissionFails(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+	server.FailNext(10, 403, "AccessDenied")
+
+	t.Run("TestsPass", func(t *testing.T) {
+		e, _, _ := newExecute(t, server, nil, writeReport)
+		status, err := e.Run(io.Discard)
+		assert.ErrorContains(t, err, "access to bucket buildpulse-uploads was denied")
+		assert.Equal(t, 1, status)
+	})
+
+	t.Run("TestsFail", func(t *testing.T) {
+		e, _, _ := newExecute(t, server, nil, writeReport+"; exit 3")
+		status, err := e.Run(io.Discard)
+		assert.ErrorContains(t, err, "access to bucket buildpulse-uploads was denied")
+		assert.Equal(t, 3, status)
+	})
+
+	t.Run("NoReports", func(t *testing.T) {
+		e, _, _ := newExecute(t, server, nil, "exit 2")
+		status, err := e.Run(io.Discard)
+		assert.Error(t, err)
+		assert.Equal(t, 2, status)
+	})
+}
+
+func TestExecute_Run_missingCommand(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	e, _, _ := newExecute(t, server, nil, "")
+	e.command = []string{"/nonexistent/test-command"}
+	status, err := e.Run(io.Discard)
+	assert.ErrorContains(t, err, "unable to run test command")
+	assert.Equal(t, 1, status)
+	assert.Empty(t, server.Objects("buildpulse-uploads"))
+}
+
+func TestExecute_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "NoSeparator", args: []string{"test/reports", "--account-id", "42", "make", "test"}},
+		{name: "NoCommand", args: []string{"test/reports", "--account-id", "42", "--"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecute(&metadata.Version{}, logger.New())
+			err := e.Init(tt.args, map[string]string{}, submit.NewCommitResolverFactory(logger.New()))
+			assert.ErrorContains(t, err, `missing test command: give it after "--"`)
+		})
+	}
+}
+
+func TestExecute_Init_invalidSubmitArgs(t *testing.T) {
+	// The arguments of `submit` are checked before the test command runs, though
+	// the reports (which the test command writes) aren't looked for yet
+	tests := []struct {
+		name   string
+		args   []string
+		envs   map[string]string
+		errMsg string
+	}{
+		{
+			name:   "MissingCredentials",
+			args:   append([]string{"test/reports"}, submitArgs...),
+			envs:   map[string]string{},
+			errMsg: "missing required environment variable: BUILDPULSE_ACCESS_KEY_ID",
+		},
+		{
+			name:   "MissingRepositoryID",
+			args:   []string{"test/reports", "--account-id", "42"},
+			envs:   credentialEnv,
+			errMsg: "missing required flag: -repository-id",
+		},
+		{
+			name:   "UnknownFlag",
+			args:   append([]string{"test/reports", "--bogus"}, submitArgs...),
+			envs:   credentialEnv,
+			errMsg: "flag provided but not defined: -bogus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecute(&metadata.Version{}, logger.New())
+			err := e.Init(append(tt.args, "--", "make", "test"), tt.envs, submit.NewCommitResolverFactory(logger.New()))
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
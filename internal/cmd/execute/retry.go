@@ -0,0 +1,307 @@
+package execute
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/report"
+)
+
+// retryData is given to the -retry-command template.
+type retryData struct {
+	// Tests lists the tests that failed in the previous run
+	Tests []report.FailedTest
+
+	// Retry is the number of the retry, from 1
+	Retry int
+}
+
+// retryFuncs are the functions available to the -retry-command template, so
+// that it can build the arguments a test runner expects, e.g.:
+//
+//	bundle exec rspec {{files .Tests | quoteAll | join " "}}
+//	go test ./... -run '^({{names .Tests | join "|"}})$'
+//	mvn test -Dtest={{range $i, $t := .Tests}}{{if $i}},{{end}}{{$t.Classname}}#{{$t.Name}}{{end}}
+var retryFuncs = template.FuncMap{
+	"names": func(tests []report.FailedTest) []string {
+		return distinct(tests, func(t report.FailedTest) string { return t.Name })
+	},
+	"classnames": func(tests []report.FailedTest) []string {
+		return distinct(tests, func(t report.FailedTest) string { return t.Classname })
+	},
+	"files": func(tests []report.FailedTest) []string {
+		return distinct(tests, func(t report.FailedTest) string { return t.File })
+	},
+	"join":  func(sep string, values []string) string { return strings.Join(values, sep) },
+	"quote": shellQuote,
+	"quoteAll": func(values []string) []string {
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = shellQuote(v)
+		}
+		return quoted
+	},
+}
+
+// distinct returns the non-empty values of field for tests, in order and
+// without duplicates.
+func distinct(tests []report.FailedTest, field func(report.FailedTest) string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, t := range tests {
+		v := field(t)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+
+	return values
+}
+
+// retryFailedTests reruns the tests that failed, up to -retries times or until
+// they pass, recording each retry for submission. The reports that a retry
+// writes are moved to a directory beneath dir, and the reports of the first run
+// are put back, so that TEST_RESULTS_PATH holds the first run's reports when
+// they're submitted. It returns the exit status of the last run. A problem
+// stops the retries without failing, since the test results are still worth
+// submitting.
+func (e *Execute) retryFailedTests(dir string, status int) int {
+	snapshot, err := e.snapshotReports(filepath.Join(dir, "original"))
+	if err != nil {
+		e.logger.Printf("⚠️ Unable to retry the failed tests: %v", err)
+		return status
+	}
+
+	// The reports to find the tests that failed in, from the previous run
+	var reports []string
+	for _, p := range snapshot.paths {
+		reports = append(reports, snapshot.copies[p])
+	}
+
+	for retry := 1; retry <= int(e.retries); retry++ {
+		failed, err := failedTests(reports)
+		if err != nil {
+			e.logger.Printf("⚠️ Unable to retry the failed tests: %v", err)
+			return status
+		}
+		if len(failed) == 0 {
+			e.logger.Printf("Not retrying: no failed tests found in the test reports")
+			return status
+		}
+
+		var script strings.Builder
+		if err := e.retryTemplate.Execute(&script, retryData{Tests: failed, Retry: retry}); err != nil {
+			e.logger.Printf("⚠️ Unable to retry the failed tests: %v", err)
+			return status
+		}
+
+		e.logger.Printf("Retrying %d failed tests (retry %d of %d): %s", len(failed), retry, e.retries, script.String())
+		start := time.Now()
+		retryStatus, err := e.run(shellCommand(script.String()))
+		if err != nil {
+			e.logger.Printf("⚠️ Unable to retry the failed tests: %v", err)
+			return status
+		}
+		e.logger.Printf("Retry %d exited with status %d after %s", retry, retryStatus, time.Since(start).Round(time.Millisecond))
+
+		written, err := snapshot.collect(filepath.Join(dir, fmt.Sprintf("retry-%d", retry)))
+		if err != nil {
+			e.logger.Printf("⚠️ Unable to collect the reports of retry %d: %v", retry, err)
+			return status
+		}
+		e.submit.AddTestRetry(retryStatus, written)
+
+		status = retryStatus
+		if status == 0 || e.interrupted.Load() {
+			break
+		}
+
+		// Sorted, so that the failed tests are given to the next retry in the
+		// same order every time, rather than in the map's random order
+		reports = reports[:0]
+		for copied := range written {
+			reports = append(reports, copied)
+		}
+		sort.Strings(reports)
+	}
+
+	return status
+}
+
+// failedTests returns the tests that failed in the given reports, without
+// duplicates. Malformed reports are skipped, as they can't be retried from.
+func failedTests(paths []string) ([]report.FailedTest, error) {
+	var failed []report.FailedTest
+	seen := make(map[report.FailedTest]bool)
+
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		tests, err := report.FailedTests(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, t := range tests {
+			if !seen[t] {
+				seen[t] = true
+				failed = append(failed, t)
+			}
+		}
+	}
+
+	return failed, nil
+}
+
+// A reportSnapshot holds copies of the reports that the test command wrote to
+// TEST_RESULTS_PATH, so that the reports written by a retry can be told apart
+// from them, and the originals put back.
+type reportSnapshot struct {
+	find   func(args []string) ([]string, error) // the reports in args, as `submit` finds them
+	args   []string                              // TEST_RESULTS_PATH
+	paths  []string                              // of the reports, in the order found
+	infos  map[string]os.FileInfo                // of each report, keyed by path
+	copies map[string]string                     // path of the copy of each report, keyed by path
+}
+
+// snapshotReports copies the reports in TEST_RESULTS_PATH to dir.
+func (e *Execute) snapshotReports(dir string) (*reportSnapshot, error) {
+	// The reports are found as `submit` will find them
+	paths, err := e.submit.ReportPaths(e.reportArgs())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &reportSnapshot{
+		find:   e.submit.ReportPaths,
+		args:   e.reportArgs(),
+		paths:  paths,
+		infos:  make(map[string]os.FileInfo),
+		copies: make(map[string]string),
+	}
+	for i, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		copied := filepath.Join(dir, strconv.Itoa(i), filepath.Base(p))
+		if err := copyFile(p, copied, info); err != nil {
+			return nil, err
+		}
+		s.infos[p], s.copies[p] = info, copied
+	}
+
+	return s, nil
+}
+
+// collect moves the reports in TEST_RESULTS_PATH that were written since the
+// snapshot to dir, and puts back the reports they replaced (or that were
+// removed). It returns the paths of the moved reports, keyed to the paths they
+// were written to.
+func (s *reportSnapshot) collect(dir string) (map[string]string, error) {
+	paths, err := s.find(s.args)
+	if err != nil {
+		return nil, err
+	}
+
+	written := make(map[string]string)
+	found := make(map[string]bool)
+	for i, p := range paths {
+		found[p] = true
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		orig, ok := s.infos[p]
+		if ok && info.Size() == orig.Size() && info.ModTime().Equal(orig.ModTime()) {
+			continue
+		}
+
+		copied := filepath.Join(dir, strconv.Itoa(i), filepath.Base(p))
+		if err := copyFile(p, copied, info); err != nil {
+			return nil, err
+		}
+		written[copied] = p
+
+		if ok {
+			err = copyFile(s.copies[p], p, orig)
+		} else {
+			err = os.Remove(p)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range s.paths {
+		if !found[p] {
+			if err := copyFile(s.copies[p], p, s.infos[p]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// copyFile copies src to dst, creating the directories it's in, and gives it
+// the modification time in info (i.e., src's), since that may date the report.
+func copyFile(src string, dst string, info os.FileInfo) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// shellCommand returns a command that runs script with the platform's shell.
+func shellCommand(script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", script)
+	}
+
+	return exec.Command("sh", "-c", script)
+}
+
+// shellQuote returns s quoted for a POSIX shell if it has characters that the
+// shell would otherwise interpret.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n*?[]$'\"\\;&|<>()`#~{}!") {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
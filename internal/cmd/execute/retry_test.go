@@ -0,0 +1,234 @@
+package execute
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/report"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// writePassingReport overwrites the report with one in which every test passes.
+const writePassingReport = `echo '<testsuite name="suite"><testcase name="a"/><testcase name="b c"/></testsuite>' > "$REPORT"`
+
+// bundledRetries returns the :test_retry_exit_statuses and :test_retry_reports
+// recorded in the metadata uploaded to server.
+func bundledRetries(t *testing.T, server *s3test.Server) ([]int, map[string]int) {
+	var meta struct {
+		ExitStatuses []int          `yaml:":test_retry_exit_statuses"`
+		Reports      map[string]int `yaml:":test_retry_reports"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(bundledMetadata(t, server)), &meta))
+
+	return meta.ExitStatuses, meta.Reports
+}
+
+func TestExecute_Run_retries(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	e, log, out := newExecute(t, server, []string{"--retry-command", `printf '<%s>' {{names .Tests | quoteAll | join " "}}; ` + writePassingReport}, writeFailingReport+"; exit 1")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "<a><b c>", out.String())
+	assert.Contains(t, log.Text(), "Retrying 2 failed tests (retry 1 of 1)")
+	assert.Contains(t, log.Text(), "Retry 1 exited with status 0")
+
+	// The first run's report is put back, and submitted along with the retry's
+	path := os.Getenv("REPORT")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<failure/>")
+
+	files := bundledFiles(t, server)
+	assert.Contains(t, files["test_results/"+path], "<failure/>")
+	assert.NotContains(t, files["test_results/retry-1/"+path], "<failure/>")
+	assert.Contains(t, files["buildpulse.yml"], ":test_command_exit_status: 1\n")
+
+	statuses, reports := bundledRetries(t, server)
+	assert.Equal(t, []int{0}, statuses)
+	assert.Equal(t, map[string]int{"test_results/retry-1/" + path: 1}, reports)
+}
+
+func TestExecute_Run_retriesExhausted(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	// Each retry writes a new report of the failing test alongside the first
+	// run's, and the last one fails with a different status
+	retry := `echo '<testsuite name="suite"><testcase name="{{index (names .Tests) 0}}"><failure/></testcase></testsuite>' > "$(dirname "$REPORT")/retry.xml"; exit {{if eq .Retry 1}}1{{else}}4{{end}}`
+	e, log, _ := newExecute(t, server, []string{"--retries", "2", "--retry-command", retry}, writeFailingReport+"; exit 1")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 4, status)
+	assert.Contains(t, log.Text(), "Retrying 2 failed tests (retry 1 of 2)")
+	assert.Contains(t, log.Text(), "Retrying 1 failed tests (retry 2 of 2)")
+
+	// The reports that the retries wrote are taken out of TEST_RESULTS_PATH
+	retryPath := filepath.Join(filepath.Dir(os.Getenv("REPORT")), "retry.xml")
+	assert.NoFileExists(t, retryPath)
+
+	statuses, reports := bundledRetries(t, server)
+	assert.Equal(t, []int{1, 4}, statuses)
+	assert.Equal(t, map[string]int{
+		"test_results/retry-1/" + retryPath: 1,
+		"test_results/retry-2/" + retryPath: 2,
+	}, reports)
+}
+
+func TestExecute_Run_retriesInOrder(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	// The second retry gets the tests that failed in the first retry's reports
+	// in the order of the reports, whatever order they're collected in
+	writeReports := `dir=$(dirname "$REPORT"); ` +
+		`echo '<testsuite name="suite"><testcase name="z"><failure/></testcase></testsuite>' > "$dir/z.xml"; ` +
+		`echo '<testsuite name="suite"><testcase name="a"><failure/></testcase></testsuite>' > "$dir/a.xml"; exit 1`
+	retry := `{{if eq .Retry 1}}` + writeReports + `{{else}}printf '<%s>' {{names .Tests | join " "}}{{end}}`
+	e, _, out := newExecute(t, server, []string{"--retries", "2", "--retry-command", retry}, writeFailingReport+"; exit 1")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "<a><z>", out.String())
+}
+
+func TestExecute_Run_retriesWithHiddenReports(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	// The failed tests are found where `submit` finds the reports, as given by
+	// its flags
+	hidden := `mkdir -p "$(dirname "$REPORT")/.cache" && echo '<testsuite name="suite"><testcase name="a"><failure/></testcase></testsuite>' > "$(dirname "$REPORT")/.cache/report.xml"`
+	e, log, out := newExecute(t, server, []string{"--retry-command", `printf '<%s>' {{names .Tests | join " "}}`}, hidden+"; exit 1", "--exclude-hidden=false")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "<a>", out.String())
+	assert.Contains(t, log.Text(), "Retrying 1 failed tests (retry 1 of 1)")
+}
+
+func TestExecute_Run_noFailedTests(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	// E.g., the tests didn't compile, so there's nothing to retry
+	e, log, out := newExecute(t, server, []string{"--retry-command", "echo retrying"}, writeReport+"; exit 2")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status)
+	assert.NotContains(t, out.String(), "retrying")
+	assert.Contains(t, log.Text(), "Not retrying: no failed tests found in the test reports")
+
+	statuses, reports := bundledRetries(t, server)
+	assert.Empty(t, statuses)
+	assert.Empty(t, reports)
+}
+
+func TestExecute_Run_retryCommandFails(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	// A template that fails to render stops the retries, but not the submission
+	e, log, _ := newExecute(t, server, []string{"--retry-command", "echo {{.Missing}}"}, writeFailingReport+"; exit 1")
+	status, err := e.Run(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status)
+	assert.Contains(t, log.Text(), "⚠️ Unable to retry the failed tests")
+	assert.Len(t, server.Objects("buildpulse-uploads"), 1)
+}
+
+func TestExecute_Init_retryArgs(t *testing.T) {
+	t.Run("WithRetries", func(t *testing.T) {
+		e := NewExecute(&metadata.Version{}, logger.New())
+		args := append([]string{"--retries", "3", "--retry-command=rspec {{files .Tests}}", "test/reports"}, submitArgs...)
+		err := e.Init(append(args, "--", "rspec"), credentialEnv, submit.NewCommitResolverFactory(logger.New()))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, e.retries)
+		assert.Equal(t, append([]string{"test/reports"}, submitArgs...), e.submitArgs)
+		assert.Equal(t, []string{"rspec"}, e.command)
+	})
+
+	t.Run("WithRetryCommandOnly", func(t *testing.T) {
+		e := NewExecute(&metadata.Version{}, logger.New())
+		args := append([]string{"--retry-command", "rspec {{files .Tests}}", "test/reports"}, submitArgs...)
+		err := e.Init(append(args, "--", "rspec"), credentialEnv, submit.NewCommitResolverFactory(logger.New()))
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, e.retries)
+	})
+
+	t.Run("WithoutRetries", func(t *testing.T) {
+		// The flags of `submit` may come first when the reports are given by -path
+		e := NewExecute(&metadata.Version{}, logger.New())
+		args := append([]string{"--path", "gotest=test.json"}, submitArgs...)
+		err := e.Init(append(args, "--", "go", "test", "-json"), credentialEnv, submit.NewCommitResolverFactory(logger.New()))
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, e.retries)
+		assert.Equal(t, append([]string{"--path", "gotest=test.json"}, submitArgs...), e.submitArgs)
+	})
+}
+
+func TestExecute_Init_invalidRetryArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{
+			name:   "RetriesWithoutCommand",
+			args:   []string{"--retries", "2", "test/reports"},
+			errMsg: "missing required flag: -retry-command (required with -retries)",
+		},
+		{
+			name:   "MalformedTemplate",
+			args:   []string{"--retry-command", "rspec {{files .Tests", "test/reports"},
+			errMsg: `invalid value "rspec {{files .Tests" for flag -retry-command: template: retry-command:1: unclosed action`,
+		},
+		{
+			name:   "MissingTestResultsPath",
+			args:   []string{"--retry-command", "rspec", "--account-id", "42"},
+			errMsg: "missing TEST_RESULTS_PATH: required with -retries, to find the tests that failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExecute(&metadata.Version{}, logger.New())
+			err := e.Init(append(tt.args, "--", "make", "test"), map[string]string{}, submit.NewCommitResolverFactory(logger.New()))
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestRetryFuncs(t *testing.T) {
+	tests := []report.FailedTest{
+		{Name: "a", Classname: "spec.user_spec", File: "./spec/user_spec.rb"},
+		{Name: "b c", Classname: "spec.user_spec", File: "./spec/it's_spec.rb"},
+		{Name: "d", Classname: "spec.post_spec"},
+	}
+
+	for template, want := range map[string]string{
+		`rspec {{files .Tests | quoteAll | join " "}}`:                                                   `rspec ./spec/user_spec.rb './spec/it'\''s_spec.rb'`,
+		`go test -run '^({{names .Tests | join "|"}})$'`:                                                 `go test -run '^(a|b c|d)$'`,
+		`mvn test -Dtest={{range $i, $t := .Tests}}{{if $i}},{{end}}{{$t.Classname}}#{{$t.Name}}{{end}}`: `mvn test -Dtest=spec.user_spec#a,spec.user_spec#b c,spec.post_spec#d`,
+		`{{classnames .Tests | join ","}} {{quote "x y"}}`:                                               `spec.user_spec,spec.post_spec 'x y'`,
+	} {
+		e := NewExecute(&metadata.Version{}, logger.New())
+		args := append([]string{"--retry-command", template, "test/reports"}, submitArgs...)
+		require.NoError(t, e.Init(append(args, "--", "true"), credentialEnv, submit.NewCommitResolverFactory(logger.New())))
+
+		var got strings.Builder
+		require.NoError(t, e.retryTemplate.Execute(&got, retryData{Tests: tests, Retry: 1}))
+		assert.Equal(t, want, got.String())
+	}
+}
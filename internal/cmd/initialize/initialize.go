@@ -0,0 +1,356 @@
+// Package initialize implements a command that interactively sets up a
+// repository to submit its test results, writing the config file that submit
+// reads and printing a CI step to run it, so that onboarding doesn't start from
+// the README's list of flags.
+package initialize
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+)
+
+// configFileName is the name of the config file written when the repository
+// doesn't have one yet.
+const configFileName = ".buildpulse.yml"
+
+// otherProvider is the choice of CI provider for which a generic shell step is
+// printed.
+const otherProvider = "other"
+
+// A provider is a CI provider that the wizard can detect from the files in the
+// repository.
+type provider struct {
+	// name is the provider's name, as reported by `env`
+	name string
+
+	// marker is the path of the file or directory that configures the provider,
+	// relative to the root of the repository
+	marker string
+
+	// snippet is the format of the step that submits the test results, given
+	// the path of the reports, or empty if the generic shell step applies
+	snippet string
+}
+
+// providers lists the CI providers that can be detected, in the order they're
+// checked.
+var providers = []provider{
+	{name: "github-actions", marker: ".github/workflows", snippet: githubSnippet},
+	{name: "circleci", marker: ".circleci/config.yml", snippet: circleSnippet},
+	{name: "jenkins", marker: "Jenkinsfile", snippet: jenkinsSnippet},
+	{name: "buildkite", marker: ".buildkite"},
+	{name: "semaphore", marker: ".semaphore"},
+	{name: "travis-ci", marker: ".travis.yml"},
+	{name: "bitbucket.org", marker: "bitbucket-pipelines.yml"},
+	{name: "azure-pipelines", marker: "azure-pipelines.yml"},
+	{name: "cirrus-ci", marker: ".cirrus.yml"},
+	{name: "appveyor", marker: "appveyor.yml"},
+}
+
+// download fetches the latest Linux binary, for the CI snippets.
+const download = "curl -fsSL https://github.com/buildpulse/test-reporter/releases/latest/download/test-reporter-linux-amd64 > ./buildpulse-test-reporter\nchmod +x ./buildpulse-test-reporter"
+
+var githubSnippet = `      - name: Upload test results to BuildPulse
+        if: '!cancelled()' # Run even if the tests fail
+        env:
+          BUILDPULSE_ACCESS_KEY_ID: ${{ secrets.BUILDPULSE_ACCESS_KEY_ID }}
+          BUILDPULSE_SECRET_ACCESS_KEY: ${{ secrets.BUILDPULSE_SECRET_ACCESS_KEY }}
+        run: |
+` + indent(download, 10) + `
+          ./buildpulse-test-reporter submit %s
+`
+
+var circleSnippet = `      # Set BUILDPULSE_ACCESS_KEY_ID and BUILDPULSE_SECRET_ACCESS_KEY in the project's environment variables
+      - run:
+          name: Upload test results to BuildPulse
+          when: always # Run even if the tests fail
+          command: |
+` + indent(download, 12) + `
+            ./buildpulse-test-reporter submit %s
+`
+
+var jenkinsSnippet = `    post {
+        always { // Run even if the tests fail
+            withCredentials([
+                string(credentialsId: 'buildpulse-access-key-id', variable: 'BUILDPULSE_ACCESS_KEY_ID'),
+                string(credentialsId: 'buildpulse-secret-access-key', variable: 'BUILDPULSE_SECRET_ACCESS_KEY'),
+            ]) {
+                sh '''
+` + indent(download, 20) + `
+                    ./buildpulse-test-reporter submit %s
+                '''
+            }
+        }
+    }
+`
+
+var shellSnippet = `# Run after the tests, even if they fail, with BUILDPULSE_ACCESS_KEY_ID and
+# BUILDPULSE_SECRET_ACCESS_KEY set from the CI provider's secrets
+` + download + `
+./buildpulse-test-reporter submit %s
+`
+
+// indent returns s with each line indented by n spaces.
+func indent(s string, n int) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// Initialize represents the task of asking for the account and repository IDs,
+// the CI provider, and the path of the test reports, then writing the config
+// file and printing the CI step that submits the reports.
+type Initialize struct {
+	fs     *flag.FlagSet
+	logger logger.Logger
+	stdin  io.Reader // read for the answers to the prompts
+
+	envs            map[string]string
+	accountID       uint64
+	repositoryID    uint64
+	provider        string
+	testResultsPath string
+	repositoryPath  string
+	force           bool
+}
+
+// NewInitialize creates a new Initialize instance.
+func NewInitialize(log logger.Logger) *Initialize {
+	i := &Initialize{
+		fs:     flag.NewFlagSet("init", flag.ContinueOnError),
+		logger: log,
+		stdin:  os.Stdin,
+	}
+
+	i.fs.Uint64Var(&i.accountID, "account-id", 0, "BuildPulse account ID (asked for if not given)")
+	i.fs.Uint64Var(&i.repositoryID, "repository-id", 0, "BuildPulse repository ID (asked for if not given)")
+	i.fs.StringVar(&i.provider, "provider", "", "CI provider to print the step for (asked for if not given)")
+	i.fs.StringVar(&i.testResultsPath, "test-results-path", "", "Path to the test reports, as given to submit (asked for if not given)")
+	i.fs.StringVar(&i.repositoryPath, "repository-dir", ".", "Path to local clone of repository, where the config file is written")
+	i.fs.BoolVar(&i.force, "force", false, "Overwrite the config file if it exists")
+	i.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return i
+}
+
+// Init populates i from args and envs. It returns an error if the args are
+// malformed.
+func (i *Initialize) Init(args []string, envs map[string]string) error {
+	if err := i.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if i.fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", i.fs.Arg(0))
+	}
+
+	if i.provider != "" && !isProvider(i.provider) {
+		return fmt.Errorf("invalid value \"%s\" for flag -provider: supported values are %s", i.provider, strings.Join(providerNames(), ", "))
+	}
+
+	i.envs = envs
+
+	return nil
+}
+
+// Run asks for the values that weren't given as flags, writing the prompts to
+// w, then writes the config file and prints the CI step. It returns an error if
+// the config file already exists (unless -force is given) or the input ends
+// before a required value is given.
+func (i *Initialize) Run(w io.Writer) error {
+	configPath, err := submit.DefaultConfigPath(i.repositoryPath)
+	if err != nil {
+		return err
+	}
+	if configPath != "" && !i.force {
+		return fmt.Errorf("config file %s already exists: use -force to overwrite it", configPath)
+	}
+	if configPath == "" {
+		configPath = filepath.Join(i.repositoryPath, configFileName)
+	}
+
+	p := &prompter{r: bufio.NewReader(i.stdin), w: w}
+
+	if i.accountID == 0 {
+		answer, err := p.ask("BuildPulse account ID", "", parseID)
+		if err != nil {
+			return err
+		}
+		i.accountID, _ = strconv.ParseUint(answer, 10, 64)
+	}
+
+	if i.repositoryID == 0 {
+		answer, err := p.ask("BuildPulse repository ID", "", parseID)
+		if err != nil {
+			return err
+		}
+		i.repositoryID, _ = strconv.ParseUint(answer, 10, 64)
+	}
+
+	if i.provider == "" {
+		detected := i.detectProvider()
+		if detected != otherProvider {
+			fmt.Fprintf(w, "Detected CI provider: %s\n", detected)
+		}
+
+		i.provider, err = p.ask(fmt.Sprintf("CI provider (%s)", strings.Join(providerNames(), ", ")), detected, func(s string) error {
+			if !isProvider(s) {
+				return fmt.Errorf("unsupported CI provider %q", s)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if i.testResultsPath == "" {
+		i.testResultsPath, err = p.ask("Path to the test reports (e.g., test/reports or 'reports/**/*.xml')", "", nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	config := fmt.Sprintf("# Flags for `test-reporter submit` (see https://github.com/buildpulse/test-reporter#config-file)\naccount-id: %d\nrepository-id: %d\n", i.accountID, i.repositoryID)
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		return fmt.Errorf("unable to write config file: %v", err)
+	}
+	fmt.Fprintf(w, "\nWrote %s\n\n", configPath)
+
+	snippet, location := shellSnippet, ""
+	for _, pr := range providers {
+		if pr.name == i.provider {
+			location = fmt.Sprintf(" (in %s)", pr.marker)
+			if pr.snippet != "" {
+				snippet = pr.snippet
+			}
+		}
+	}
+	// submit looks for the config file only in its working directory, so a
+	// config file elsewhere is named in the step
+	args := shellQuote(i.testResultsPath)
+	if filepath.Dir(configPath) != "." {
+		args = fmt.Sprintf("--config %s %s", shellQuote(configPath), args)
+	}
+
+	fmt.Fprintf(w, "Add the following to the CI job that runs the tests%s, after the tests:\n\n", location)
+	fmt.Fprintf(w, snippet, args)
+	fmt.Fprintf(w, "\nThen store BUILDPULSE_ACCESS_KEY_ID and BUILDPULSE_SECRET_ACCESS_KEY (from the BuildPulse repository settings) in the CI provider's secrets, and commit %s.\n", configPath)
+
+	return nil
+}
+
+// detectProvider returns the CI provider that the environment (when run in CI)
+// or the files in the repository point to, or otherProvider if none does.
+func (i *Initialize) detectProvider() string {
+	if name, err := metadata.DetectProvider(i.envs, i.logger); err == nil && isProvider(name) {
+		return name
+	}
+
+	for _, pr := range providers {
+		if _, err := os.Stat(filepath.Join(i.repositoryPath, filepath.FromSlash(pr.marker))); err == nil {
+			return pr.name
+		}
+	}
+
+	return otherProvider
+}
+
+// isProvider returns true if name is one of the choices of CI provider.
+func isProvider(name string) bool {
+	for _, n := range providerNames() {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// providerNames returns the choices of CI provider.
+func providerNames() []string {
+	var names []string
+	for _, pr := range providers {
+		names = append(names, pr.name)
+	}
+
+	return append(names, otherProvider)
+}
+
+// parseID returns an error unless s is a BuildPulse account or repository ID.
+func parseID(s string) error {
+	if id, err := strconv.ParseUint(s, 10, 64); err != nil || id == 0 {
+		return fmt.Errorf("%q isn't an ID: expected a positive number", s)
+	}
+
+	return nil
+}
+
+// shellQuote returns s quoted for a shell if it has characters that the shell
+// would otherwise interpret (e.g., a glob, which submit expands itself).
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, " *?[]$'\"\\") {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// A prompter asks questions on w and reads the answers from r, one per line.
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// ask asks the question until it's answered with a value that passes valid
+// (if not nil), returning the answer, or def if the answer is blank and def
+// isn't. It returns an error if the input ends without an answer.
+func (p *prompter) ask(question string, def string, valid func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.w, "%s: ", question)
+		}
+
+		line, err := p.r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		eof := err != nil
+		if eof {
+			// Finish the prompt's line, since the input didn't
+			fmt.Fprintln(p.w)
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+
+		if answer != "" {
+			if valid == nil {
+				return answer, nil
+			}
+			err := valid(answer)
+			if err == nil {
+				return answer, nil
+			}
+			fmt.Fprintf(p.w, "⚠️ %v\n", err)
+		}
+
+		if eof {
+			return "", fmt.Errorf("no answer given for %q", question)
+		}
+	}
+}
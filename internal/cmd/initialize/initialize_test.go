@@ -0,0 +1,134 @@
+package initialize
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newInitialize returns an Initialize for a temporary repository, reading the
+// answers to its prompts from input.
+func newInitialize(t *testing.T, input string, args ...string) (*Initialize, string) {
+	dir := t.TempDir()
+
+	i := NewInitialize(logger.New())
+	i.stdin = strings.NewReader(input)
+	require.NoError(t, i.Init(append([]string{"--repository-dir", dir}, args...), map[string]string{}))
+
+	return i, dir
+}
+
+func TestInitialize_Run(t *testing.T) {
+	i, dir := newInitialize(t, "42\n8675309\n\ntest/reports\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".github", "workflows"), 0755))
+
+	var out bytes.Buffer
+	require.NoError(t, i.Run(&out))
+	assert.Contains(t, out.String(), "Detected CI provider: github-actions")
+	assert.Contains(t, out.String(), "CI provider (github-actions, circleci, jenkins, buildkite, semaphore, travis-ci, bitbucket.org, azure-pipelines, cirrus-ci, appveyor, other) [github-actions]: ")
+	assert.Contains(t, out.String(), "Add the following to the CI job that runs the tests (in .github/workflows), after the tests:")
+	assert.Contains(t, out.String(), "BUILDPULSE_ACCESS_KEY_ID: ${{ secrets.BUILDPULSE_ACCESS_KEY_ID }}")
+	assert.Contains(t, out.String(), "          ./buildpulse-test-reporter submit --config "+filepath.Join(dir, ".buildpulse.yml")+" test/reports\n")
+
+	config, err := os.ReadFile(filepath.Join(dir, ".buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(config), "account-id: 42\nrepository-id: 8675309\n")
+}
+
+func TestInitialize_Run_invalidAnswers(t *testing.T) {
+	i, dir := newInitialize(t, "abc\n0\n42\n8675309\ngitlab\nother\nreports/**/*.xml\n")
+
+	var out bytes.Buffer
+	require.NoError(t, i.Run(&out))
+	assert.Contains(t, out.String(), `⚠️ "abc" isn't an ID: expected a positive number`)
+	assert.Contains(t, out.String(), `⚠️ "0" isn't an ID: expected a positive number`)
+	assert.Contains(t, out.String(), `⚠️ unsupported CI provider "gitlab"`)
+	assert.NotContains(t, out.String(), "Detected CI provider")
+	assert.Contains(t, out.String(), "Add the following to the CI job that runs the tests, after the tests:")
+	assert.Contains(t, out.String(), "\n./buildpulse-test-reporter submit --config "+filepath.Join(dir, ".buildpulse.yml")+" 'reports/**/*.xml'\n")
+}
+
+func TestInitialize_Run_flags(t *testing.T) {
+	i, dir := newInitialize(t, "", "--account-id", "42", "--repository-id", "8675309", "--provider", "jenkins", "--test-results-path", "build/test-results")
+
+	var out bytes.Buffer
+	require.NoError(t, i.Run(&out))
+	assert.NotContains(t, out.String(), "BuildPulse account ID")
+	assert.Contains(t, out.String(), "credentialsId: 'buildpulse-access-key-id'")
+	assert.Contains(t, out.String(), "./buildpulse-test-reporter submit --config "+filepath.Join(dir, ".buildpulse.yml")+" build/test-results\n")
+
+	config, err := os.ReadFile(filepath.Join(dir, ".buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(config), "account-id: 42\nrepository-id: 8675309\n")
+}
+
+func TestInitialize_Run_workingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	i := NewInitialize(logger.New())
+	require.NoError(t, i.Init([]string{"--account-id", "42", "--repository-id", "8675309", "--provider", "other", "--test-results-path", "reports"}, map[string]string{}))
+
+	// submit finds the config file in its working directory, so the step
+	// doesn't name it
+	var out bytes.Buffer
+	require.NoError(t, i.Run(&out))
+	assert.Contains(t, out.String(), "\n./buildpulse-test-reporter submit reports\n")
+	assert.FileExists(t, filepath.Join(dir, ".buildpulse.yml"))
+}
+
+func TestInitialize_Run_existingConfig(t *testing.T) {
+	i, dir := newInitialize(t, "", "--account-id", "42", "--repository-id", "8675309", "--provider", "other", "--test-results-path", "reports")
+	path := filepath.Join(dir, "buildpulse.config.yml")
+	require.NoError(t, os.WriteFile(path, []byte("account-id: 1\n"), 0644))
+
+	err := i.Run(&bytes.Buffer{})
+	assert.EqualError(t, err, "config file "+path+" already exists: use -force to overwrite it")
+
+	i.force = true
+	require.NoError(t, i.Run(&bytes.Buffer{}))
+	config, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(config), "account-id: 42\n")
+}
+
+func TestInitialize_Run_noAnswer(t *testing.T) {
+	i, dir := newInitialize(t, "42\n")
+
+	err := i.Run(&bytes.Buffer{})
+	assert.EqualError(t, err, `no answer given for "BuildPulse repository ID"`)
+	assert.NoFileExists(t, filepath.Join(dir, ".buildpulse.yml"))
+}
+
+func TestInitialize_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{
+			args:   []string{"--provider", "gitlab"},
+			errMsg: `invalid value "gitlab" for flag -provider: supported values are github-actions, circleci, jenkins, buildkite, semaphore, travis-ci, bitbucket.org, azure-pipelines, cirrus-ci, appveyor, other`,
+		},
+		{
+			args:   []string{"reports"},
+			errMsg: "unexpected argument: reports",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			i := NewInitialize(logger.New())
+			err := i.Init(tt.args, map[string]string{})
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package prune
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+)
+
+// filePattern matches the names of the temporary files that submit leaves
+// behind (e.g., the bundle, its metadata, and converted reports).
+const filePattern = "buildpulse-*"
+
+// Prune represents the task of deleting the files that earlier runs of the
+// reporter left in the temporary directory, which otherwise accumulate on
+// self-hosted runners that aren't wiped between jobs.
+type Prune struct {
+	fs     *flag.FlagSet
+	logger logger.Logger
+	now    func() time.Time
+
+	dir       string
+	olderThan age
+	dryRun    bool
+}
+
+// NewPrune creates a new Prune instance.
+func NewPrune(log logger.Logger) *Prune {
+	p := &Prune{
+		fs:        flag.NewFlagSet("prune", flag.ContinueOnError),
+		logger:    log,
+		now:       time.Now,
+		olderThan: age(7 * 24 * time.Hour),
+	}
+	p.fs.StringVar(&p.dir, "dir", os.TempDir(), "Directory to prune")
+	p.fs.Var(&p.olderThan, "older-than", "Minimum age of the files to delete (e.g., 7d or 12h)")
+	p.fs.BoolVar(&p.dryRun, "dry-run", false, "List the files that would be deleted, without deleting them")
+	p.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return p
+}
+
+// Init populates p from args. It returns an error if the args are malformed.
+func (p *Prune) Init(args []string) error {
+	if err := p.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if p.fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", p.fs.Arg(0))
+	}
+
+	info, err := os.Stat(p.dir)
+	if err != nil {
+		return fmt.Errorf("invalid value \"%s\" for flag -dir: %v", p.dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid value \"%s\" for flag -dir: not a directory", p.dir)
+	}
+
+	return nil
+}
+
+// Run deletes the reporter's files in the directory that were last modified
+// longer ago than the -older-than age (or, with -dry-run, only lists them), and
+// writes a line for each file and a summary to w. Files that can't be deleted
+// are logged and skipped.
+func (p *Prune) Run(w io.Writer) error {
+	paths, err := filepath.Glob(filepath.Join(p.dir, filePattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	cutoff := p.now().Add(-time.Duration(p.olderThan))
+	var count int
+	var size int64
+	for _, path := range paths {
+		// Only regular files are the reporter's; anything else (e.g., the
+		// agent's socket) is left alone
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		if p.dryRun {
+			fmt.Fprintf(w, "Would delete %s (%s, modified %s)\n", path, formatSize(info.Size()), info.ModTime().UTC().Format(time.RFC3339))
+		} else {
+			if err := os.Remove(path); err != nil {
+				p.logger.Printf("⚠️ Unable to delete %s: %v", path, err)
+				continue
+			}
+			fmt.Fprintf(w, "Deleted %s (%s, modified %s)\n", path, formatSize(info.Size()), info.ModTime().UTC().Format(time.RFC3339))
+		}
+		count++
+		size += info.Size()
+	}
+
+	verb := "Deleted"
+	if p.dryRun {
+		verb = "Would delete"
+	}
+	fmt.Fprintf(w, "%s %d files (%s) older than %s in %s\n", verb, count, formatSize(size), p.olderThan.String(), p.dir)
+
+	return nil
+}
+
+// formatSize returns n bytes in human-readable form (e.g., 1.5 MB).
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// age is a duration given as a number of days (e.g., 7d) or as a Go duration
+// (e.g., 12h).
+type age time.Duration
+
+func (a *age) String() string {
+	d := time.Duration(*a)
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+
+	return d.String()
+}
+
+func (a *age) Set(value string) error {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return fmt.Errorf("should be a number of days (e.g., 7d) or a duration (e.g., 12h)")
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		d, err = time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("should be a number of days (e.g., 7d) or a duration (e.g., 12h)")
+		}
+	}
+
+	if d < 0 {
+		return fmt.Errorf("should not be negative")
+	}
+	*a = age(d)
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package prune
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2020, 7, 11, 0, 0, 0, 0, time.UTC)
+
+// setup creates a directory with files of the reporter modified the given
+// number of days before now, and a file of something else.
+func setup(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, days := range map[string]int{
+		"buildpulse-111.tar": 10,
+		"buildpulse-222.gz":  8,
+		"buildpulse-333.yml": 1,
+		"other-444.tar":      30,
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, make([]byte, 2048), 0644))
+		mtime := now.Add(-time.Duration(days) * 24 * time.Hour)
+		require.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+
+	return dir
+}
+
+func TestPrune_Run(t *testing.T) {
+	dir := setup(t)
+
+	p := NewPrune(logger.New())
+	p.now = func() time.Time { return now }
+	require.NoError(t, p.Init([]string{"--dir", dir}))
+
+	var out bytes.Buffer
+	require.NoError(t, p.Run(&out))
+	assert.Contains(t, out.String(), "Deleted "+filepath.Join(dir, "buildpulse-111.tar")+" (2.0 KB, modified 2020-07-01T00:00:00Z)\n")
+	assert.Contains(t, out.String(), "Deleted 2 files (4.0 KB) older than 7d in "+dir+"\n")
+
+	assert.NoFileExists(t, filepath.Join(dir, "buildpulse-111.tar"))
+	assert.NoFileExists(t, filepath.Join(dir, "buildpulse-222.gz"))
+	assert.FileExists(t, filepath.Join(dir, "buildpulse-333.yml"))
+	assert.FileExists(t, filepath.Join(dir, "other-444.tar"))
+}
+
+func TestPrune_Run_dryRun(t *testing.T) {
+	dir := setup(t)
+
+	p := NewPrune(logger.New())
+	p.now = func() time.Time { return now }
+	require.NoError(t, p.Init([]string{"--dir", dir, "--older-than", "12h", "--dry-run"}))
+
+	var out bytes.Buffer
+	require.NoError(t, p.Run(&out))
+	assert.Contains(t, out.String(), "Would delete "+filepath.Join(dir, "buildpulse-333.yml"))
+	assert.Contains(t, out.String(), "Would delete 3 files (6.0 KB) older than 12h0m0s in "+dir+"\n")
+
+	// Nothing is deleted
+	assert.FileExists(t, filepath.Join(dir, "buildpulse-111.tar"))
+	assert.FileExists(t, filepath.Join(dir, "buildpulse-333.yml"))
+}
+
+func TestPrune_Run_skipsSockets(t *testing.T) {
+	// Unix socket paths are limited in length, so use a short directory
+	dir, err := os.MkdirTemp("", "prune")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "buildpulse-agent.sock"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	p := NewPrune(logger.New())
+	p.now = func() time.Time { return time.Now().Add(time.Hour) }
+	require.NoError(t, p.Init([]string{"--dir", dir, "--older-than", "0s"}))
+
+	var out bytes.Buffer
+	require.NoError(t, p.Run(&out))
+	assert.Contains(t, out.String(), "Deleted 0 files")
+	assert.FileExists(t, filepath.Join(dir, "buildpulse-agent.sock"))
+}
+
+func TestPrune_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{
+			name:   "MalformedOlderThan",
+			args:   []string{"--older-than", "a week"},
+			errMsg: `invalid value "a week" for flag -older-than: should be a number of days (e.g., 7d) or a duration (e.g., 12h)`,
+		},
+		{
+			name:   "NegativeOlderThan",
+			args:   []string{"--older-than", "-1d"},
+			errMsg: `invalid value "-1d" for flag -older-than: should not be negative`,
+		},
+		{
+			name:   "MissingDir",
+			args:   []string{"--dir", "testdata/missing"},
+			errMsg: `invalid value "testdata/missing" for flag -dir: stat testdata/missing: no such file or directory`,
+		},
+		{
+			name:   "UnexpectedArgument",
+			args:   []string{"spool"},
+			errMsg: `unexpected argument: spool`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPrune(logger.New()).Init(tt.args)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/buildpulse/test-reporter/internal/schema"
+)
+
+// Schema represents the task of printing the JSON Schema of one of the files
+// that describe an uploaded bundle (e.g., buildpulse.yml).
+type Schema struct {
+	fs   *flag.FlagSet
+	name string
+}
+
+// NewSchema creates a new Schema instance.
+func NewSchema() *Schema {
+	s := &Schema{fs: flag.NewFlagSet("schema", flag.ContinueOnError)}
+	s.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return s
+}
+
+// Init populates s from args, which are the action (print) and, optionally,
+// the name of the schema to print (metadata by default). It returns an error
+// if the args are malformed.
+func (s *Schema) Init(args []string) error {
+	if err := s.fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch s.fs.NArg() {
+	case 0:
+		return fmt.Errorf("missing action: supported values are: print")
+	case 1, 2:
+	default:
+		return fmt.Errorf("unexpected argument: %s", s.fs.Arg(2))
+	}
+
+	if action := s.fs.Arg(0); action != "print" {
+		return fmt.Errorf("invalid value \"%s\" for action: supported values are: print", action)
+	}
+
+	s.name = schema.Metadata
+	if s.fs.NArg() == 2 {
+		s.name = s.fs.Arg(1)
+	}
+	if _, err := schema.Get(s.name); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Run writes the schema to w.
+func (s *Schema) Run(w io.Writer) error {
+	data, err := schema.Get(s.name)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema_Run(t *testing.T) {
+	tests := []struct {
+		args  string
+		title string
+	}{
+		{args: "print", title: "BuildPulse bundle metadata"},
+		{args: "print metadata", title: "BuildPulse bundle metadata"},
+		{args: "print coverage-delta", title: "BuildPulse coverage delta"},
+		{args: "print manifest", title: "BuildPulse bundle manifest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			s := NewSchema()
+			require.NoError(t, s.Init(strings.Split(tt.args, " ")))
+
+			var out bytes.Buffer
+			require.NoError(t, s.Run(&out))
+
+			var doc map[string]interface{}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+			assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", doc["$schema"])
+			assert.Equal(t, tt.title, doc["title"])
+		})
+	}
+}
+
+func TestSchema_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{args: nil, errMsg: "missing action: supported values are: print"},
+		{args: []string{"show"}, errMsg: `invalid value "show" for action: supported values are: print`},
+		{args: []string{"print", "receipt"}, errMsg: `invalid value "receipt" for schema name: supported values are: metadata, coverage-delta, manifest`},
+		{args: []string{"print", "metadata", "extra"}, errMsg: "unexpected argument: extra"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			err := NewSchema().Init(tt.args)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package submit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// An auditEntry describes an outbound HTTP request, as recorded in the file
+// given by the -network-audit flag. The query string is left out, since it
+// may carry request signatures.
+type auditEntry struct {
+	Time          time.Time `json:"time"`
+	Method        string    `json:"method"`
+	Host          string    `json:"host"`
+	Path          string    `json:"path"`
+	Status        int       `json:"status,omitempty"` // zero if no response was received
+	BytesSent     int64     `json:"bytes_sent"`
+	BytesReceived int64     `json:"bytes_received"`
+	DurationMS    int64     `json:"duration_ms"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// auditTransport records each request made through it, for the security
+// review of what the reporter talks to. The entries are recorded once the
+// response body is closed (or the request fails), so that they account for
+// the whole exchange.
+type auditTransport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	entries []auditEntry
+}
+
+func newAuditTransport(next http.RoundTripper) *auditTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &auditTransport{next: next}
+}
+
+func (a *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := auditEntry{
+		Time:   time.Now().UTC(),
+		Method: req.Method,
+		Host:   req.URL.Host,
+		Path:   req.URL.Path,
+	}
+
+	// Count the bytes of the request body as the transport reads them, since
+	// the content length isn't always known up front
+	sent := &countingReader{}
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		sent.r = req.Body
+		req.Body = sent
+	}
+
+	start := time.Now()
+	resp, err := a.next.RoundTrip(req)
+	if err != nil {
+		entry.BytesSent = sent.n.Load()
+		entry.DurationMS = time.Since(start).Milliseconds()
+		entry.Error = err.Error()
+		a.record(entry)
+		return nil, err
+	}
+
+	entry.Status = resp.StatusCode
+	resp.Body = &auditBody{
+		countingReader: countingReader{r: resp.Body},
+		done: func(received int64) {
+			entry.BytesSent = sent.n.Load()
+			entry.BytesReceived = received
+			entry.DurationMS = time.Since(start).Milliseconds()
+			a.record(entry)
+		},
+	}
+
+	return resp, nil
+}
+
+func (a *auditTransport) record(entry auditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = append(a.entries, entry)
+}
+
+// writeFile writes the recorded requests to the named file as a JSON array.
+func (a *auditTransport) writeFile(name string) error {
+	a.mu.Lock()
+	entries := append([]auditEntry{}, a.entries...)
+	a.mu.Unlock()
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, append(data, '\n'), 0644)
+}
+
+// countingReader counts the bytes read from r. The count is safe to read while
+// another goroutine (e.g., the transport writing a request body) reads from r.
+type countingReader struct {
+	r io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.r.Close()
+}
+
+// auditBody is a response body that reports the number of bytes read from it
+// when it's closed.
+type auditBody struct {
+	countingReader
+	once sync.Once
+	done func(received int64)
+}
+
+func (b *auditBody) Close() error {
+	err := b.countingReader.Close()
+	b.once.Do(func() { b.done(b.n.Load()) })
+	return err
+}
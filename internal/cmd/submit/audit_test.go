@@ -0,0 +1,111 @@
+package submit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmit_Run_withNetworkAudit(t *testing.T) {
+	t.Setenv("AWS_CA_BUNDLE", "")
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	// The first request fails, so that the audit records both outcomes
+	server.FailNext(1, http.StatusInternalServerError, "InternalError")
+
+	auditPath := filepath.Join(t.TempDir(), "audit.json")
+	audit := newAuditTransport(nil)
+
+	log := logger.New()
+	s := &Submit{
+		client:           &http.Client{Transport: audit},
+		endpoint:         server.URL,
+		idgen:            func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:           log,
+		version:          &metadata.Version{Number: "v1.2.3"},
+		commitResolver:   metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:             map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:            []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:           "buildpulse-uploads",
+		accountID:        42,
+		repositoryID:     8675309,
+		networkAuditPath: auditPath,
+		audit:            audit,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+
+	var entries []auditEntry
+	require.NoError(t, json.Unmarshal(data, &entries))
+	require.Len(t, entries, 2)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	for i, status := range []int{http.StatusInternalServerError, http.StatusOK} {
+		e := entries[i]
+		assert.Equal(t, http.MethodPut, e.Method)
+		assert.Equal(t, u.Host, e.Host)
+		assert.Equal(t, "/buildpulse-uploads/"+key, e.Path)
+		assert.Equal(t, status, e.Status)
+		assert.Equal(t, int64(len(server.Object("buildpulse-uploads", key).Body)), e.BytesSent)
+		assert.Empty(t, e.Error)
+	}
+	assert.Greater(t, entries[0].BytesReceived, int64(0))
+}
+
+func TestSubmit_Run_withNetworkAuditAndCABundle(t *testing.T) {
+	server := s3test.NewTLSServer("buildpulse-uploads")
+	defer server.Close()
+
+	auditPath := filepath.Join(t.TempDir(), "audit.json")
+	s := newCABundleSubmit(t, server, nil, "--network-audit", auditPath)
+
+	_, err := s.Run()
+	require.NoError(t, err)
+	assert.Len(t, server.Objects("buildpulse-uploads"), 1)
+
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	var entries []auditEntry
+	require.NoError(t, json.Unmarshal(data, &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+}
+
+func Test_auditTransport_requestError(t *testing.T) {
+	audit := newAuditTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, assert.AnError
+	}))
+	client := &http.Client{Transport: audit}
+
+	_, err := client.Get("https://buildpulse.example.com/reporter/versions.json?token=secret")
+	require.Error(t, err)
+
+	require.Len(t, audit.entries, 1)
+	e := audit.entries[0]
+	assert.Equal(t, http.MethodGet, e.Method)
+	assert.Equal(t, "buildpulse.example.com", e.Host)
+	assert.Equal(t, "/reporter/versions.json", e.Path)
+	assert.Zero(t, e.Status)
+	assert.Equal(t, assert.AnError.Error(), e.Error)
+}
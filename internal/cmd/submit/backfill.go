@@ -0,0 +1,87 @@
+package submit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// initBackfill validates the -backfill flags and, with -backfill-from, sets the
+// timestamp of the submission to that of the original submission. A backfill
+// needs the original timestamp (from -backfill-from or -timestamp-override), so
+// that its test results are attributed to the period they came from instead of
+// skewing the current one.
+func (s *Submit) initBackfill(flagset map[string]bool) error {
+	if flagset["backfill-from"] && !s.backfill {
+		return fmt.Errorf("invalid use of flag -backfill-from without flag -backfill")
+	}
+	if !s.backfill {
+		return nil
+	}
+
+	switch {
+	case flagset["backfill-from"] && flagset["timestamp-override"]:
+		return fmt.Errorf("invalid use of flag -backfill-from with flag -timestamp-override: use one or the other, but not both")
+	case flagset["backfill-from"]:
+		ts, err := originalTimestamp(s.backfillFrom)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -backfill-from: %v", s.backfillFrom, err)
+		}
+		s.timestampOverride = ts
+	case !flagset["timestamp-override"]:
+		return fmt.Errorf("missing flag -backfill-from or -timestamp-override: a backfill needs the timestamp of the original submission")
+	}
+
+	return nil
+}
+
+// originalTimestamp returns the timestamp of the submission described by the
+// file at path, for -backfill-from: either a receipt written by
+// -best-effort-exit (a .json file), whose time is when the submission was
+// attempted, or the buildpulse.yml of a bundle, whose timestamp is kept in its
+// original zone.
+func originalTimestamp(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var r receipt
+		if err := json.Unmarshal(data, &r); err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse receipt: %v", err)
+		}
+		if r.Time.IsZero() {
+			return time.Time{}, fmt.Errorf("receipt has no time")
+		}
+		return r.Time, nil
+	}
+
+	data, err = metadata.UpgradeYAML(data)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse metadata: %v", err)
+	}
+
+	var m struct {
+		Timestamp     time.Time `yaml:":timestamp"`
+		TimestampZone string    `yaml:":timestamp_zone"`
+	}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse metadata: %v", err)
+	}
+	if m.Timestamp.IsZero() {
+		return time.Time{}, fmt.Errorf("metadata has no :timestamp")
+	}
+
+	if zone, err := time.Parse("-07:00", m.TimestampZone); err == nil {
+		_, offset := zone.Zone()
+		return m.Timestamp.In(time.FixedZone("", offset)), nil
+	}
+	return m.Timestamp, nil
+}
@@ -0,0 +1,27 @@
+package submit
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// CheckBucket checks that the bucket that test results would be uploaded to
+// (given envs, as for Init) exists and is accessible with the credentials in
+// envs, by sending it a HEAD request. It returns the name of the bucket. It's
+// for `doctor`, which diagnoses the environment without submitting anything.
+func (s *Submit) CheckBucket(envs map[string]string) (string, error) {
+	if err := s.initBackend(envs); err != nil {
+		return "", err
+	}
+	if err := s.initCredentials(envs); err != nil {
+		return s.bucket, err
+	}
+
+	sess, err := s.newS3Session(s.credentials, false)
+	if err != nil {
+		return s.bucket, err
+	}
+
+	_, err = s3.New(sess).HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return s.bucket, err
+}
@@ -0,0 +1,191 @@
+package submit
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames lists the names of the config files that are used by
+// default, when found in the working directory.
+var configFileNames = []string{".buildpulse.yml", "buildpulse.config.yml"}
+
+// repeatableFlags lists the flags that can be given more than once. In a config
+// file, each item of a list (or each key of a mapping, for -meta) is given as a
+// separate flag; lists for the other flags are joined with spaces (e.g., for
+// -tags).
+var repeatableFlags = []string{"meta", "path", "tag"}
+
+// flagEnvs maps the flags that override an environment variable to the name of
+// the variable.
+var flagEnvs = map[string]string{
+	"best-effort-exit":         "BUILDPULSE_SOFT_FAIL",
+	"branch":                   "BUILDPULSE_BRANCH",
+	"check-name":               "BUILDPULSE_CHECK_NAME",
+	"commit":                   "BUILDPULSE_COMMIT_SHA",
+	"exclude-env":              "BUILDPULSE_EXCLUDE_ENV",
+	"fail-on-empty":            "BUILDPULSE_FAIL_ON_EMPTY",
+	"include-env":              "BUILDPULSE_INCLUDE_ENV",
+	"provider":                 "BUILDPULSE_PROVIDER",
+	"repo-name-with-owner":     "BUILDPULSE_REPO_NAME_WITH_OWNER",
+	"s3-accelerate":            "BUILDPULSE_S3_ACCELERATE",
+	"s3-dualstack":             "BUILDPULSE_S3_DUALSTACK",
+	"soft-fail":                "BUILDPULSE_SOFT_FAIL",
+	"strict-commit-resolution": "BUILDPULSE_STRICT_COMMIT_RESOLUTION",
+	"version-check":            "BUILDPULSE_VERSION_CHECK",
+}
+
+// DefaultConfigPath returns the path of the config file in dir that submit
+// would use by default, for commands that write or inspect it (e.g., `init`).
+func DefaultConfigPath(dir string) (string, error) {
+	return defaultConfigPath(dir)
+}
+
+// defaultConfigPath returns the path of the config file in dir, or an empty
+// string if there's none. It returns an error if there's more than one.
+func defaultConfigPath(dir string) (string, error) {
+	var found []string
+	for _, name := range configFileNames {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			found = append(found, p)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	if len(found) > 1 {
+		return "", fmt.Errorf("found config files %s: use one or the other, but not both", strings.Join(found, " and "))
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+
+	return found[0], nil
+}
+
+// loadConfig sets the flags that aren't given on the command line to their
+// values in the config file: the file given by -config, or else the default
+// config file in dir, if any. The config file is a YAML mapping of flag names
+// (without dashes) to values, e.g.:
+//
+//	account-id: 42
+//	repository-id: 8675309
+//	tags: [unit, linux]
+//	meta:
+//	  browser: chrome
+//
+// A flag that overrides an environment variable (see flagEnvs) is set from the
+// config file only if the variable isn't set either, so that the command line
+// takes precedence over the environment, which takes precedence over the
+// config file.
+func (s *Submit) loadConfig(dir string, envs map[string]string) error {
+	path := s.configPath
+	if path == "" {
+		var err error
+		path, err = defaultConfigPath(dir)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %v", err)
+	}
+
+	var config map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("unable to parse config file %s: %v", path, err)
+	}
+	s.logger.Printf("Using config file: %s", path)
+
+	given := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var used []string
+	for _, name := range names {
+		if s.fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown flag \"%s\" in config file %s", name, path)
+		}
+		if given[name] {
+			continue
+		}
+		if env, ok := flagEnvs[name]; ok && envs[env] != "" {
+			s.logger.Printf("Ignoring flag -%s in config file %s, since %s is set", name, path, env)
+			continue
+		}
+
+		node := config[name]
+		values, err := configValues(name, &node)
+		if err != nil {
+			return fmt.Errorf("invalid value for flag -%s in config file %s: %v", name, path, err)
+		}
+		for _, v := range values {
+			if err := s.fs.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value \"%s\" for flag -%s in config file %s: %v", v, name, path, err)
+			}
+		}
+		used = append(used, name)
+	}
+
+	if len(used) > 0 {
+		s.logger.Printf("Using flags from config file: %s", strings.Join(used, ", "))
+	}
+
+	return nil
+}
+
+// configValues returns the values to set the named flag to for the value of
+// the flag in a config file.
+func configValues(name string, node *yaml.Node) ([]string, error) {
+	repeatable := contains(repeatableFlags, name)
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("list items should be strings, numbers, or booleans")
+			}
+			items = append(items, item.Value)
+		}
+		if repeatable {
+			return items, nil
+		}
+		return []string{strings.Join(items, " ")}, nil
+	case yaml.MappingNode:
+		if name != "meta" {
+			return nil, fmt.Errorf("should be a string, number, boolean, or list")
+		}
+		var pairs []string
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if v.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("values should be strings, numbers, or booleans")
+			}
+			pairs = append(pairs, k.Value+"="+v.Value)
+		}
+		return pairs, nil
+	default:
+		return nil, fmt.Errorf("should be a string, number, boolean, or list")
+	}
+}
@@ -0,0 +1,71 @@
+package submit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/buildpulse/test-reporter/internal/delta"
+)
+
+// coverageTarPath returns the path in the tarball of the coverage file at p.
+func coverageTarPath(p string) string {
+	return fmt.Sprintf("coverage/%s", p)
+}
+
+// coverageDeltaTarPath returns the path in the tarball of the delta that
+// stands in for the coverage file at p.
+func coverageDeltaTarPath(p string) string {
+	return coverageTarPath(p) + ".delta.json"
+}
+
+// coverageDeltas computes the delta of each of the coverage files at paths
+// against the file at the same path in the -coverage-baseline directory, and
+// returns the paths of the files holding the deltas (as JSON), keyed by the
+// path of the coverage file. Files without a baseline, and files whose delta
+// is no smaller than the file itself, are left out, so they're uploaded whole.
+func (s *Submit) coverageDeltas(paths []string) (map[string]string, error) {
+	deltas := make(map[string]string)
+	for _, p := range paths {
+		baseline, err := os.ReadFile(filepath.Join(s.coverageBaseline, p))
+		if os.IsNotExist(err) {
+			s.logger.Debugf("No baseline for coverage file %s; uploading it whole", p)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		target, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(delta.Compute(baseline, target))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) >= len(target) {
+			s.logger.Debugf("Delta of coverage file %s against its baseline isn't smaller than the file; uploading it whole", p)
+			continue
+		}
+
+		f, err := os.CreateTemp("", "buildpulse-*.delta.json")
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.logger.Debugf("Uploading coverage file %s as a delta against its baseline (%d bytes instead of %d)", p, len(data), len(target))
+		deltas[p] = f.Name()
+	}
+
+	return deltas, nil
+}
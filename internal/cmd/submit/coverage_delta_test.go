@@ -0,0 +1,92 @@
+package submit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/delta"
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/mholt/archiver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBaseline writes content as the baseline of the coverage file at p in
+// the baseline directory dir.
+func writeBaseline(t *testing.T, dir string, p string, content string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, p), []byte(content), 0644))
+}
+
+func Test_bundle_coverageDelta(t *testing.T) {
+	coveragePath := "testdata/example-coverage/coverage.xml"
+	unchangedPath := "testdata/example-reports-dir/coverage/report.xml"
+
+	content, err := os.ReadFile(coveragePath)
+	require.NoError(t, err)
+	baseline := strings.Replace(string(content), `<line number="8" hits="0"/>`, `<line number="8" hits="1"/>`, 1)
+	require.NotEqual(t, string(content), baseline)
+
+	baselineDir := t.TempDir()
+	writeBaseline(t, baselineDir, coveragePath, baseline)
+
+	log := logger.New()
+	s := &Submit{
+		logger:              log,
+		version:             &metadata.Version{Number: "v1.2.3"},
+		commitResolver:      metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:               []string{"testdata/example-reports-dir/example-1.xml"},
+		coveragePaths:       []string{coveragePath, unchangedPath},
+		coverageBaseline:    baselineDir,
+		coverageBaselineRef: "eeeeeeeeeeeeeeeeeeeeffffffffffffffffffff",
+		bucket:              "buildpulse-uploads",
+		accountID:           42,
+		repositoryID:        8675309,
+	}
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(path, unzipDir))
+
+	// The coverage file with a baseline is replaced by its delta
+	assert.NoFileExists(t, filepath.Join(unzipDir, "coverage", coveragePath))
+	data, err := os.ReadFile(filepath.Join(unzipDir, "coverage", coveragePath+".delta.json"))
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(content))
+
+	var d delta.Delta
+	require.NoError(t, json.Unmarshal(data, &d))
+	got, err := d.Apply([]byte(baseline))
+	require.NoError(t, err)
+	assert.Equal(t, string(content), string(got))
+
+	// The coverage file without a baseline is uploaded whole
+	assert.FileExists(t, filepath.Join(unzipDir, "coverage", unchangedPath))
+
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":coverage_baseline: eeeeeeeeeeeeeeeeeeeeffffffffffffffffffff\n")
+	assert.Contains(t, string(yaml), ":coverage_deltas:\n    coverage/"+coveragePath+".delta.json: coverage/"+coveragePath+"\n")
+}
+
+func TestSubmit_coverageDeltas_notSmaller(t *testing.T) {
+	coveragePath := "testdata/example-coverage/coverage.xml"
+
+	baselineDir := t.TempDir()
+	writeBaseline(t, baselineDir, coveragePath, "something else entirely\n")
+
+	s := &Submit{logger: logger.New(), coverageBaseline: baselineDir}
+	deltas, err := s.coverageDeltas([]string{coveragePath})
+	require.NoError(t, err)
+	assert.Empty(t, deltas)
+}
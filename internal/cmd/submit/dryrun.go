@@ -0,0 +1,51 @@
+package submit
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// describeBundle logs what a dry run (-dry-run) would have uploaded: the S3
+// object, the size of the gzipped bundle at zippath, the files in the tarball
+// at tarpath, and the metadata.
+func (s *Submit) describeBundle(tarpath string, zippath string) error {
+	info, err := os.Stat(zippath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(tarpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var files []string
+	var meta []byte
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read bundle: %v", err)
+		}
+
+		files = append(files, fmt.Sprintf("- %s (%d bytes)", h.Name, h.Size))
+		if h.Name == "buildpulse.yml" {
+			if meta, err = io.ReadAll(r); err != nil {
+				return fmt.Errorf("unable to read bundle: %v", err)
+			}
+		}
+	}
+
+	s.logger.Printf("Dry run (-dry-run): not uploading %s (%d bytes) to s3://%s/%s", zippath, info.Size(), s.bucket, s.objectKey())
+	s.logger.Printf("The bundle contains %d files:\n%s", len(files), strings.Join(files, "\n"))
+	s.logger.Printf("buildpulse.yml:\n%s", meta)
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package submit
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// glob returns the names of all files matching pattern, like filepath.Glob,
+// but also supports "**" as a path segment (e.g., reports/**/*.xml), matching
+// zero or more directories. As in shells with globstar, "**" doesn't match
+// hidden directories (i.e., those whose names begin with "."), unless the
+// pattern names them explicitly. Symbolic links to directories are followed
+// when matching "**" if followSymlinks is true.
+func glob(pattern string, followSymlinks bool) ([]string, error) {
+	if !hasDoubleStar(pattern) {
+		return filepath.Glob(pattern)
+	}
+
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	for _, seg := range segments {
+		if _, err := filepath.Match(seg, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// Walk from the deepest directory that the pattern names literally
+	n := 0
+	for n < len(segments) && !hasMeta(segments[n]) {
+		n++
+	}
+	root := strings.Join(segments[:n], "/")
+	switch {
+	case root == "" && n > 0:
+		root = "/"
+	case root == "":
+		root = "."
+	}
+
+	// Only descend into hidden directories if the pattern might name them
+	hidden := false
+	for _, seg := range segments[n:] {
+		hidden = hidden || strings.HasPrefix(seg, ".")
+	}
+
+	var matches []string
+	rootDir := filepath.Clean(filepath.FromSlash(root))
+	err := walk(rootDir, followSymlinks, func(path string, info os.FileInfo, err error) error {
+		isRoot := path == rootDir
+		if err != nil {
+			// Like filepath.Glob, ignore the directories that can't be read
+			if !isRoot && info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isRoot && !hidden && info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		name := append([]string{}, segments[:n]...)
+		if rel != "." {
+			name = append(name, strings.Split(filepath.ToSlash(rel), "/")...)
+		}
+
+		if matchSegments(segments, name) {
+			matches = append(matches, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// matchSegments returns true if the path segments in name match the pattern
+// segments, in which "**" matches zero or more non-hidden segments.
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+				if i < len(name) && strings.HasPrefix(name[i], ".") {
+					return false
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// hasDoubleStar returns true if pattern has a "**" path segment.
+func hasDoubleStar(pattern string) bool {
+	for _, seg := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if seg == "**" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasMeta returns true if path contains any of the characters that are special
+// to filepath.Match.
+func hasMeta(path string) bool {
+	magicChars := `*?[`
+	if runtime.GOOS != "windows" {
+		magicChars = `*?[\`
+	}
+
+	return strings.ContainsAny(path, magicChars)
+}
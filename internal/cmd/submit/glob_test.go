@@ -0,0 +1,80 @@
+package submit
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_glob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{
+			pattern: "testdata/example-reports-dir/**/*.xml",
+			want: []string{
+				"testdata/example-reports-dir/coverage/report-2.xml",
+				"testdata/example-reports-dir/coverage/report.xml",
+				"testdata/example-reports-dir/dir-with-xml-files/browserstack/example-1.xml",
+				"testdata/example-reports-dir/dir-with-xml-files/browserstack/example-2.xml",
+				"testdata/example-reports-dir/dir-with-xml-files/browsertest/example-3.xml",
+				"testdata/example-reports-dir/example-1.xml",
+			},
+		},
+		{
+			pattern: "./testdata/example-reports-dir/dir-with-xml-files/**/example-1.xml",
+			want:    []string{"testdata/example-reports-dir/dir-with-xml-files/browserstack/example-1.xml"},
+		},
+		{
+			pattern: "testdata/example-*-dir/**/browser*/*-2.xml",
+			want:    []string{"testdata/example-reports-dir/dir-with-xml-files/browserstack/example-2.xml"},
+		},
+		{
+			pattern: "testdata/example-reports-dir/**/.cache/*.xml",
+			want:    []string{"testdata/example-reports-dir/.cache/junk.xml"},
+		},
+		{
+			pattern: "testdata/example-reports-dir/coverage/**",
+			want: []string{
+				"testdata/example-reports-dir/coverage",
+				"testdata/example-reports-dir/coverage/report-2.xml",
+				"testdata/example-reports-dir/coverage/report.xml",
+			},
+		},
+		{
+			pattern: "testdata/no-such-dir/**/*.xml",
+			want:    nil,
+		},
+		{
+			// Without "**", the pattern is matched by filepath.Glob, whose "*"
+			// matches hidden directories
+			pattern: "testdata/example-reports-dir/*/*.xml",
+			want: []string{
+				"testdata/example-reports-dir/.cache/junk.xml",
+				"testdata/example-reports-dir/coverage/report-2.xml",
+				"testdata/example-reports-dir/coverage/report.xml",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := glob(filepath.FromSlash(tt.pattern), false)
+			require.NoError(t, err)
+
+			var want []string
+			for _, p := range tt.want {
+				want = append(want, filepath.FromSlash(p))
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_glob_badPattern(t *testing.T) {
+	_, err := glob("testdata/**/[.xml", false)
+	assert.Equal(t, filepath.ErrBadPattern, err)
+}
@@ -0,0 +1,94 @@
+package submit
+
+import (
+	"io"
+	"os"
+
+	"github.com/buildpulse/test-reporter/internal/coverage"
+)
+
+// An lcovConversion converts coverage files in a format that BuildPulse doesn't
+// accept natively into a single LCOV file in the tarball.
+type lcovConversion struct {
+	name    string // e.g., SimpleCov result sets
+	tarPath string
+	match   func(path string) bool
+	merge   func(c *coverage.Coverage, r io.Reader) error
+}
+
+// simplecovConversion converts SimpleCov result sets with -convert-simplecov.
+var simplecovConversion = lcovConversion{
+	name:    "SimpleCov result sets",
+	tarPath: "coverage/simplecov.lcov",
+	match:   coverage.IsSimpleCovResultSet,
+	merge:   (*coverage.Coverage).MergeSimpleCov,
+}
+
+// excoverallsConversion converts excoveralls JSON reports with
+// -convert-excoveralls.
+var excoverallsConversion = lcovConversion{
+	name:    "excoveralls reports",
+	tarPath: "coverage/excoveralls.lcov",
+	match:   coverage.IsExcoverallsReport,
+	merge:   (*coverage.Coverage).MergeExcoveralls,
+}
+
+// simplecovToLCOV merges the SimpleCov result sets among the coverage files at
+// paths (e.g., one per parallel CI job) into a single LCOV file. See toLCOV.
+func (s *Submit) simplecovToLCOV(paths []string) ([]string, string, error) {
+	return s.toLCOV(paths, simplecovConversion)
+}
+
+// excoverallsToLCOV merges the excoveralls JSON reports among the coverage
+// files at paths (e.g., one per test partition) into a single LCOV file. See
+// toLCOV.
+func (s *Submit) excoverallsToLCOV(paths []string) ([]string, string, error) {
+	return s.toLCOV(paths, excoverallsConversion)
+}
+
+// toLCOV merges the coverage files at paths that conv matches into a single
+// LCOV file. It returns the remaining paths, and the path of the LCOV file,
+// which is empty if no files match. If any matching file can't be parsed, it
+// logs a warning and returns paths, so the files are submitted as is.
+func (s *Submit) toLCOV(paths []string, conv lcovConversion) ([]string, string, error) {
+	var rest, matches []string
+	for _, p := range paths {
+		if conv.match(p) {
+			matches = append(matches, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	if len(matches) == 0 {
+		return paths, "", nil
+	}
+
+	c := coverage.New()
+	for _, p := range matches {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, "", err
+		}
+		err = conv.merge(c, f)
+		f.Close()
+		if err != nil {
+			s.logger.Printf("⚠️ Unable to convert %s to LCOV: %v; submitting the %s as is", p, err, conv.name)
+			return paths, "", nil
+		}
+	}
+
+	f, err := os.CreateTemp("", "buildpulse-*.lcov")
+	if err != nil {
+		return nil, "", err
+	}
+	err = c.WriteLCOV(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Printf("Converted %d %s (%d source files) to %s", len(matches), conv.name, len(c.Files), conv.tarPath)
+	return rest, f.Name(), nil
+}
@@ -0,0 +1,51 @@
+package submit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+)
+
+const (
+	// outputText prints the log, for people reading the CI job's output.
+	outputText = "text"
+
+	// outputJSON prints only a JSON description of the submission (a result),
+	// for later steps of the pipeline to consume.
+	outputJSON = "json"
+)
+
+var supportedOutputs = []string{outputText, outputJSON}
+
+// A result describes a submission, as printed by -output json.
+type result struct {
+	Key           string `json:"key"`               // empty for a dry run
+	BundleBytes   int64  `json:"bundle_bytes"`      // of the gzipped bundle
+	Reports       int    `json:"reports"`           // test reports in the bundle
+	CoverageFiles int    `json:"coverage_files"`    // coverage files in the bundle
+	CIProvider    string `json:"ci_provider"`       // e.g., github-actions
+	DryRun        bool   `json:"dry_run,omitempty"` // whether the bundle wasn't uploaded
+}
+
+// WriteResult writes what a successful run prints besides the log to w: with
+// -output json, a JSON description of the submission; with -quiet, the key of
+// the uploaded object. Otherwise, it writes nothing.
+func (s *Submit) WriteResult(w io.Writer) error {
+	if s.output == outputJSON {
+		data, err := json.Marshal(s.result)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	if s.result.Key != "" && s.logger.Level() >= logger.LevelError {
+		_, err := fmt.Fprintln(w, s.result.Key)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package submit
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmit_Run_outputJSON(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	log := logger.New()
+	s := &Submit{
+		client:         http.DefaultClient,
+		endpoint:       server.URL,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:          []string{"testdata/example-reports-dir/example-1.xml", "testdata/example-reports-dir/example-2.XML"},
+		coveragePaths:  []string{"testdata/example-reports-dir/coverage/report.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+		output:         outputJSON,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, s.WriteResult(&out))
+	size := len(server.Object("buildpulse-uploads", key).Body)
+	assert.JSONEq(t, fmt.Sprintf(`{
+		"key": "42/8675309/buildpulse-00000000-0000-0000-0000-000000000000.gz",
+		"bundle_bytes": %d,
+		"reports": 2,
+		"coverage_files": 1,
+		"ci_provider": "github-actions"
+	}`, size), out.String())
+}
+
+func TestSubmit_WriteResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		level  logger.Level
+		want   string
+	}{
+		{name: "text", output: outputText, level: logger.LevelInfo, want: ""},
+		{name: "quiet", output: outputText, level: logger.LevelError, want: "some-key\n"},
+		{name: "json", output: outputJSON, level: logger.LevelError, want: `{"key":"some-key","bundle_bytes":123,"reports":1,"coverage_files":0,"ci_provider":"circleci"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New()
+			log.SetLevel(tt.level)
+			s := &Submit{
+				logger: log,
+				output: tt.output,
+				result: result{Key: "some-key", BundleBytes: 123, Reports: 1, CIProvider: "circleci"},
+			}
+
+			var out bytes.Buffer
+			require.NoError(t, s.WriteResult(&out))
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package submit
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+)
+
+// A receipt describes a submission that failed but was ignored because of the
+// -best-effort-exit flag, as recorded in the file given by the -receipt flag.
+// It's for inspecting the failure after the fact, since the build carries on
+// as if the submission succeeded.
+type receipt struct {
+	Time            time.Time `json:"time"`
+	ReporterVersion string    `json:"reporter_version"`
+	AccountID       uint64    `json:"account_id"`
+	RepositoryID    uint64    `json:"repository_id"`
+	Paths           []string  `json:"paths"`
+	Error           string    `json:"error"`
+	Log             string    `json:"log"`
+}
+
+// ignoreFailure logs err as a warning and writes a receipt describing it. A
+// failure to write the receipt is logged too, but otherwise ignored.
+func (s *Submit) ignoreFailure(err error) {
+	s.logger.Printf("⚠️ Unable to submit test results to BuildPulse: %v", err)
+	s.logger.Printf("⚠️ Ignoring the failure because of -best-effort-exit; see %s for details", s.receiptPath)
+
+	r := receipt{
+		Time:            time.Now().UTC(),
+		ReporterVersion: s.version.Number,
+		AccountID:       s.accountID,
+		RepositoryID:    s.repositoryID,
+		Paths:           s.paths,
+		Error:           err.Error(),
+		Log:             logger.Redact(s.logger.Text(), s.envs),
+	}
+	if werr := r.writeFile(s.receiptPath); werr != nil {
+		s.logger.Printf("⚠️ Unable to write receipt: %v", werr)
+	}
+}
+
+// writeFile writes the receipt to the named file as JSON.
+func (r *receipt) writeFile(name string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, append(data, '\n'), 0644)
+}
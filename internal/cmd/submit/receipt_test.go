@@ -0,0 +1,97 @@
+package submit
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmit_Run_withBestEffortExit(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+	server.AllowAccessKeys("some-other-access-key-id")
+
+	receiptPath := filepath.Join(t.TempDir(), "receipt.json")
+
+	log := logger.New()
+	s := &Submit{
+		client:         http.DefaultClient,
+		endpoint:       server.URL,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+		bestEffortExit: true,
+		receiptPath:    receiptPath,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+	assert.Empty(t, key)
+	assert.Empty(t, server.Objects("buildpulse-uploads"))
+	assert.Contains(t, log.Text(), "⚠️ Unable to submit test results to BuildPulse: ")
+
+	data, err := os.ReadFile(receiptPath)
+	require.NoError(t, err)
+
+	var r receipt
+	require.NoError(t, json.Unmarshal(data, &r))
+	assert.Equal(t, "v1.2.3", r.ReporterVersion)
+	assert.Equal(t, uint64(42), r.AccountID)
+	assert.Equal(t, uint64(8675309), r.RepositoryID)
+	assert.Equal(t, []string{"testdata/example-reports-dir/example-1.xml"}, r.Paths)
+	assert.Contains(t, r.Error, "InvalidAccessKeyId")
+	assert.Contains(t, r.Log, "Sending ")
+	assert.False(t, r.Time.IsZero())
+}
+
+func TestSubmit_Run_withBestEffortExitAndSuccess(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	receiptPath := filepath.Join(t.TempDir(), "receipt.json")
+
+	log := logger.New()
+	s := &Submit{
+		client:         http.DefaultClient,
+		endpoint:       server.URL,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+		bestEffortExit: true,
+		receiptPath:    receiptPath,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+	assert.Equal(t, "42/8675309/buildpulse-00000000-0000-0000-0000-000000000000.gz", key)
+	assert.NoFileExists(t, receiptPath)
+}
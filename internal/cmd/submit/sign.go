@@ -0,0 +1,205 @@
+package submit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// manifestTarPath is the path of the manifest in the bundle.
+	manifestTarPath = "manifest.json"
+
+	// manifestSignatureTarPath is the path of the manifest's signature in the
+	// bundle, encoded in base64 as by `cosign sign-blob`.
+	manifestSignatureTarPath = "manifest.json.sig"
+
+	// manifestCertificateTarPath is the path in the bundle of the certificate
+	// that Sigstore issued for a keyless signature.
+	manifestCertificateTarPath = "manifest.json.pem"
+)
+
+// A manifest lists the files in a bundle, so that a signature of the manifest
+// attests to all of them.
+type manifest struct {
+	Files []manifestFile `json:"files"`
+}
+
+// A manifestFile describes a file in a bundle.
+type manifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// add adds the file at src to the manifest as the file at internalPath in the
+// bundle.
+func (m *manifest) add(src string, internalPath string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	m.Files = append(m.Files, manifestFile{Path: internalPath, SHA256: hex.EncodeToString(h.Sum(nil)), Size: n})
+	return nil
+}
+
+// initSigning validates the -sign-key and -sign-keyless flags, loading the key
+// or checking that cosign is installed, so that a misconfigured signature
+// fails before the bundle is prepared.
+func (s *Submit) initSigning() error {
+	switch {
+	case s.signKeyPath != "" && s.signKeyless:
+		return fmt.Errorf("invalid use of flag -sign-key with flag -sign-keyless: use one or the other, but not both")
+	case s.signKeyPath != "":
+		signer, err := loadSigningKey(s.signKeyPath)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -sign-key: %v", s.signKeyPath, err)
+		}
+		s.signer = signer
+		s.logger.Printf("Signing the bundle's manifest with the key at %s", s.signKeyPath)
+	case s.signKeyless:
+		if _, err := exec.LookPath(s.cosign); err != nil {
+			return fmt.Errorf("invalid use of flag -sign-keyless: unable to find cosign: %v", err)
+		}
+		s.logger.Printf("Signing the bundle's manifest keylessly with cosign")
+	}
+
+	return nil
+}
+
+// loadSigningKey returns the signer for the PEM-encoded private key in the
+// named file: an ECDSA key (e.g., from `openssl ecparam -genkey`) or an
+// Ed25519 key, in PKCS #8 or SEC 1 form. Encrypted keys aren't supported.
+func loadSigningKey(name string) (crypto.Signer, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM-encoded key found")
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") || block.Headers["Proc-Type"] != "" {
+		return nil, fmt.Errorf("encrypted keys aren't supported")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("should be an ECDSA or Ed25519 private key in PKCS #8 or SEC 1 form")
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T: should be an ECDSA or Ed25519 private key", key)
+	}
+}
+
+// signManifest writes the manifest to a temporary file and signs it, with the
+// key given by -sign-key or keylessly with cosign (-sign-keyless). It returns
+// the paths of the manifest, its signature, and its certificate (for a keyless
+// signature; otherwise, an empty string), and records the manifest's digest and
+// signature for the object metadata.
+func (s *Submit) signManifest(m *manifest) (string, string, string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", "", "", err
+	}
+	data = append(data, '\n')
+
+	manifestPath, err := writeTemp("buildpulse-*.json", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	sigPath, err := writeTemp("buildpulse-*.sig", nil)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var certPath string
+	if s.signKeyless {
+		if certPath, err = writeTemp("buildpulse-*.pem", nil); err != nil {
+			return "", "", "", err
+		}
+		cmd := exec.Command(s.cosign, "sign-blob", "--yes", "--output-signature", sigPath, "--output-certificate", certPath, manifestPath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", "", "", fmt.Errorf("unable to sign manifest with cosign: %v\n%s", err, out)
+		}
+	} else {
+		sig, err := signBytes(s.signer, data)
+		if err != nil {
+			return "", "", "", fmt.Errorf("unable to sign manifest: %v", err)
+		}
+		if err := os.WriteFile(sigPath, []byte(sig), 0644); err != nil {
+			return "", "", "", err
+		}
+	}
+
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return "", "", "", err
+	}
+	digest := sha256.Sum256(data)
+	s.manifestDigest = hex.EncodeToString(digest[:])
+	s.manifestSignature = strings.TrimSpace(string(sig))
+
+	return manifestPath, sigPath, certPath, nil
+}
+
+// writeTemp writes data to a new temporary file whose name matches pattern, and
+// returns the file's path.
+func writeTemp(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return f.Name(), err
+}
+
+// signBytes returns the base64-encoded signature of data by signer, in the
+// form that `cosign verify-blob --key` expects: an ASN.1 ECDSA signature of
+// the SHA-256 digest of data, or an Ed25519 signature of data itself.
+func signBytes(signer crypto.Signer, data []byte) (string, error) {
+	var sig []byte
+	var err error
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		sig, err = signer.Sign(rand.Reader, data, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(data)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
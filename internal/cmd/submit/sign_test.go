@@ -0,0 +1,256 @@
+package submit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/buildpulse/test-reporter/internal/schema"
+	"github.com/google/uuid"
+	"github.com/mholt/archiver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey writes the given key to a PEM file in a temporary directory and
+// returns the file's path.
+func writeKey(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// newSigningSubmit returns a Submit that bundles a single report.
+func newSigningSubmit() *Submit {
+	log := logger.New()
+	return &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:                        []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+}
+
+func Test_bundle_signed(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	s := newSigningSubmit()
+	s.signKeyPath = writeKey(t, "PRIVATE KEY", der)
+	require.NoError(t, s.initSigning())
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(path, unzipDir))
+
+	// Verify the manifest lists every other file in the bundle, with its digest
+	data, err := os.ReadFile(filepath.Join(unzipDir, "manifest.json"))
+	require.NoError(t, err)
+	var m manifest
+	require.NoError(t, json.Unmarshal(data, &m))
+
+	var paths []string
+	for _, f := range m.Files {
+		paths = append(paths, f.Path)
+		content, err := os.ReadFile(filepath.Join(unzipDir, f.Path))
+		require.NoError(t, err)
+		digest := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(digest[:]), f.SHA256, f.Path)
+		assert.Equal(t, int64(len(content)), f.Size, f.Path)
+	}
+	assert.Equal(t, []string{"test_results/testdata/example-reports-dir/example-1.xml", "buildpulse.yml", "buildpulse.log"}, paths)
+
+	// Verify the signature is of the manifest, by the key
+	sig, err := os.ReadFile(filepath.Join(unzipDir, "manifest.json.sig"))
+	require.NoError(t, err)
+	rawSig, err := base64.StdEncoding.DecodeString(string(sig))
+	require.NoError(t, err)
+	digest := sha256.Sum256(data)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], rawSig))
+	assert.NoFileExists(t, filepath.Join(unzipDir, "manifest.json.pem"))
+
+	assert.Equal(t, string(sig), s.manifestSignature)
+	assert.Equal(t, hex.EncodeToString(digest[:]), s.manifestDigest)
+}
+
+func Test_bundle_signedKeyless(t *testing.T) {
+	// Stand in for cosign, writing a fake signature and certificate
+	cosign := filepath.Join(t.TempDir(), "cosign")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --output-signature) printf 'c2lnbmF0dXJl' > "$2"; shift ;;
+    --output-certificate) printf '%s\n' '-----BEGIN CERTIFICATE-----' > "$2"; shift ;;
+  esac
+  shift
+done
+`
+	require.NoError(t, os.WriteFile(cosign, []byte(script), 0755))
+
+	s := newSigningSubmit()
+	s.signKeyless = true
+	s.cosign = cosign
+	require.NoError(t, s.initSigning())
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(path, unzipDir))
+
+	assert.FileExists(t, filepath.Join(unzipDir, "manifest.json"))
+	sig, err := os.ReadFile(filepath.Join(unzipDir, "manifest.json.sig"))
+	require.NoError(t, err)
+	assert.Equal(t, "c2lnbmF0dXJl", string(sig))
+	cert, err := os.ReadFile(filepath.Join(unzipDir, "manifest.json.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\n", string(cert))
+	assert.Equal(t, "c2lnbmF0dXJl", s.manifestSignature)
+}
+
+func Test_bundle_signedKeylessFailure(t *testing.T) {
+	cosign := filepath.Join(t.TempDir(), "cosign")
+	require.NoError(t, os.WriteFile(cosign, []byte("#!/bin/sh\necho 'no OIDC token available' >&2\nexit 1\n"), 0755))
+
+	s := newSigningSubmit()
+	s.signKeyless = true
+	s.cosign = cosign
+
+	_, err := s.bundle(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to sign manifest with cosign: exit status 1\nno OIDC token available")
+}
+
+func Test_manifestSchema(t *testing.T) {
+	// The schema of manifest.json describes each field of the manifest
+	type property struct {
+		Type       string               `json:"type"`
+		Properties map[string]*property `json:"properties"`
+		Items      *property            `json:"items"`
+		Required   []string             `json:"required"`
+	}
+	data, err := schema.Get(schema.Manifest)
+	require.NoError(t, err)
+	var s property
+	require.NoError(t, json.Unmarshal(data, &s))
+
+	m := manifest{}
+	require.NoError(t, m.add("testdata/example-reports-dir/example-1.xml", "test_results/example-1.xml"))
+	doc, err := json.Marshal(m)
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(doc, &got))
+
+	assert.ElementsMatch(t, []string{"files"}, s.Required)
+	require.Contains(t, s.Properties, "files")
+	assert.Len(t, s.Properties, len(got))
+	assert.Equal(t, "array", s.Properties["files"].Type)
+
+	items := s.Properties["files"].Items
+	require.NotNil(t, items)
+	file := got["files"].([]interface{})[0].(map[string]interface{})
+	assert.Len(t, items.Properties, len(file))
+	for name, value := range file {
+		prop, ok := items.Properties[name]
+		if !assert.True(t, ok, "schema is missing field %s", name) {
+			continue
+		}
+		want := "string"
+		if _, ok := value.(float64); ok {
+			want = "integer"
+		}
+		assert.Equal(t, want, prop.Type, "type of field %s", name)
+		assert.Contains(t, items.Required, name)
+	}
+}
+
+func Test_loadSigningKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsaDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+
+	signer, err := loadSigningKey(writeKey(t, "EC PRIVATE KEY", sec1))
+	require.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, signer)
+
+	signer, err = loadSigningKey(writeKey(t, "PRIVATE KEY", pkcs8))
+	require.NoError(t, err)
+	sig, err := signBytes(signer, []byte("some data"))
+	require.NoError(t, err)
+	rawSig, err := base64.StdEncoding.DecodeString(sig)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(edKey.Public().(ed25519.PublicKey), []byte("some data"), rawSig))
+
+	_, err = loadSigningKey(writeKey(t, "PRIVATE KEY", rsaDER))
+	assert.EqualError(t, err, "unsupported key type *rsa.PrivateKey: should be an ECDSA or Ed25519 private key")
+
+	_, err = loadSigningKey(writeKey(t, "ENCRYPTED SIGSTORE PRIVATE KEY", []byte("some encrypted key")))
+	assert.EqualError(t, err, "encrypted keys aren't supported")
+
+	_, err = loadSigningKey("testdata/example-reports-dir/example-1.xml")
+	assert.EqualError(t, err, "no PEM-encoded key found")
+}
+
+func Test_upload_manifestSignature(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	s := &Submit{
+		client:            server.Client(),
+		endpoint:          server.URL,
+		idgen:             func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:            logger.New(),
+		version:           &metadata.Version{Number: "v1.2.3"},
+		bucket:            "buildpulse-uploads",
+		accountID:         42,
+		repositoryID:      8675309,
+		manifestDigest:    "some-digest",
+		manifestSignature: "c2lnbmF0dXJl",
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+	key, err := s.upload(context.Background(), "testdata/example-test-results.tar.gz")
+	require.NoError(t, err)
+
+	obj := server.Object("buildpulse-uploads", key)
+	require.NotNil(t, obj)
+	assert.Equal(t, "some-digest", obj.Metadata()["manifest-sha256"])
+	assert.Equal(t, "c2lnbmF0dXJl", obj.Metadata()["manifest-signature"])
+}
@@ -0,0 +1,85 @@
+package submit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The misbehaviors supported by the (undocumented) -simulate flag, which lets
+// platform teams check how their pipelines cope (e.g., with retries,
+// -best-effort-exit, or alerting) when reporting to BuildPulse goes wrong,
+// without breaking the network for real.
+const (
+	// simulateUploadFailure fails the upload without sending it.
+	simulateUploadFailure = "upload-failure"
+
+	// simulateSlowNetwork delays each outbound request by simulatedLatency.
+	simulateSlowNetwork = "slow-network"
+
+	// simulatePartialBundle uploads only the first half of the bundle, as if
+	// the upload was cut short.
+	simulatePartialBundle = "partial-bundle"
+)
+
+var supportedSimulations = []string{simulateUploadFailure, simulateSlowNetwork, simulatePartialBundle}
+
+// simulatedLatency is the delay added to each outbound request with
+// -simulate slow-network.
+const simulatedLatency = 5 * time.Second
+
+var errSimulatedUploadFailure = errors.New("simulated upload failure (-simulate upload-failure)")
+
+// slowTransport delays each request made through it, unless the request's
+// context is done first.
+type slowTransport struct {
+	next  http.RoundTripper
+	delay time.Duration
+}
+
+func newSlowTransport(next http.RoundTripper, delay time.Duration) *slowTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &slowTransport{next: next, delay: delay}
+}
+
+func (t *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timer := time.NewTimer(t.delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// truncateBundle returns the path of a copy of the first half of the named
+// file (src).
+func truncateBundle(src string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.CreateTemp("", "buildpulse-*.partial.gz")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	_, err = io.CopyN(out, in, info.Size()/2)
+	return out.Name(), err
+}
@@ -0,0 +1,115 @@
+package submit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSimulationSubmit(server *s3test.Server, simulate string) *Submit {
+	log := logger.New()
+	return &Submit{
+		client:                       &http.Client{},
+		endpoint:                     server.URL,
+		idgen:                        func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:                        []string{"testdata/example-reports-dir/example-1.xml"},
+		disableCoverageAutoDiscovery: true,
+		simulate:                     simulate,
+		bucket:                       "buildpulse-uploads",
+		accountID:                    42,
+		repositoryID:                 8675309,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+}
+
+func TestSubmit_Run_simulateUploadFailure(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	s := newSimulationSubmit(server, simulateUploadFailure)
+	_, err := s.Run()
+	assert.ErrorIs(t, err, errSimulatedUploadFailure)
+	assert.Empty(t, server.Objects("buildpulse-uploads"))
+}
+
+func TestSubmit_Run_simulatePartialBundle(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	s := newSimulationSubmit(server, simulatePartialBundle)
+	key, err := s.Run()
+	require.NoError(t, err)
+
+	obj := server.Object("buildpulse-uploads", key)
+	require.NotNil(t, obj)
+	require.NotEmpty(t, obj.Body)
+
+	r, err := gzip.NewReader(bytes.NewReader(obj.Body))
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestSubmit_Init_simulateSlowNetworkWithCABundle(t *testing.T) {
+	server := s3test.NewTLSServer("buildpulse-uploads")
+	defer server.Close()
+
+	s := newCABundleSubmit(t, server, nil, "--simulate", "slow-network")
+
+	// The delay wraps the transport that trusts the bundle, which the SDK
+	// then accepts as is
+	slow, ok := s.client.Transport.(*slowTransport)
+	require.True(t, ok)
+	next, ok := slow.next.(*http.Transport)
+	require.True(t, ok)
+	assert.NotNil(t, next.TLSClientConfig.RootCAs)
+
+	_, err := s.newS3Session(s.credentials, false)
+	assert.NoError(t, err)
+}
+
+func Test_slowTransport(t *testing.T) {
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+
+	t.Run("Delays", func(t *testing.T) {
+		client := &http.Client{Transport: newSlowTransport(next, 50*time.Millisecond)}
+
+		start := time.Now()
+		resp, err := client.Get("https://buildpulse.example.com/reporter/versions.json")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		client := &http.Client{Transport: newSlowTransport(next, time.Hour)}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://buildpulse.example.com/reporter/versions.json", nil)
+		require.NoError(t, err)
+
+		_, err = client.Do(req)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
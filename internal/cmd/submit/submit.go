@@ -1,38 +1,125 @@
 package submit
 
 import (
-	"bytes"
+	"bufio"
 	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	awscreds "github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/buildpulse/test-reporter/internal/logger"
 	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/report"
 	"github.com/buildpulse/test-reporter/internal/tar"
+	"github.com/buildpulse/test-reporter/internal/telemetry"
 	"github.com/google/uuid"
 )
 
+// The test frameworks whose reporting conventions are supported by the
+// -framework flag.
+const (
+	// frameworkDotnet identifies the conventions used by `dotnet test`, which
+	// writes TRX reports to a TestResults directory in each test project.
+	frameworkDotnet = "dotnet"
+
+	// frameworkPest and frameworkPHPUnit identify PHP test frameworks, whose
+	// JUnit reports contain absolute file paths.
+	frameworkPest    = "pest"
+	frameworkPHPUnit = "phpunit"
+)
+
+var supportedFrameworks = []string{frameworkDotnet, frameworkPest, frameworkPHPUnit}
+
+// The ways of handling the reports produced by successive attempts of a
+// retried CI step, as given by the -dedupe-attempts flag.
+const (
+	// dedupeAttemptsAll keeps the reports from every attempt, recording the
+	// attempt that produced each one in the metadata.
+	dedupeAttemptsAll = "all"
+
+	// dedupeAttemptsLatest keeps only the report from the latest attempt.
+	dedupeAttemptsLatest = "latest"
+)
+
+var supportedDedupeAttempts = []string{dedupeAttemptsAll, dedupeAttemptsLatest}
+
+// The ways of naming the uploaded object, as given by the -key-scheme flag.
+const (
+	// keySchemeUUID names each object with a random UUID.
+	keySchemeUUID = "uuid"
+
+	// keySchemeDigest names each object with the SHA-256 digest of its
+	// content, so that a retried CI job that produces the same test results
+	// overwrites the object uploaded by the earlier attempt.
+	keySchemeDigest = "digest"
+)
+
+var supportedKeySchemes = []string{keySchemeUUID, keySchemeDigest}
+
+// bundleLayoutVersion identifies the layout of the uploaded bundle (i.e., the
+// buildpulse.yml, buildpulse.log, test_results, and coverage entries). It's
+// recorded on the S3 object as schema-version so that ingestion can route the
+// object without downloading it first. It's versioned apart from the content of
+// buildpulse.yml (see metadata.SchemaVersion), which can change without
+// changing the layout.
+const bundleLayoutVersion = "1"
+
+// A backend is a BuildPulse environment to which test results can be
+// submitted, as selected by the BUILDPULSE_ENV environment variable.
+type backend struct {
+	apiURL string // base URL of the BuildPulse API
+	bucket string
+}
+
+var backends = map[string]backend{
+	"production": {apiURL: "https://buildpulse.io", bucket: "buildpulse-uploads"},
+	"staging":    {apiURL: "https://staging.buildpulse.io", bucket: "buildpulse-uploads-staging"},
+}
+
+var supportedBackends = []string{"production", "staging"}
+
 type credentials struct {
 	AccessKeyID     string
 	SecretAccessKey string
 }
 
+// Defaults holds the values to use when the corresponding flags and
+// environment variables aren't given, as embedded in an organization-specific
+// build of the reporter. Zero values are ignored.
+type Defaults struct {
+	AccountID    uint64
+	RepositoryID uint64
+	Bucket       string
+}
+
 // A CommitResolverFactory provides methods for creating a
 // metadata.CommitResolver.
 type CommitResolverFactory interface {
-	NewFromRepository(path string) (metadata.CommitResolver, error)
+	NewFromRepository(path string, deepen bool) (metadata.CommitResolver, error)
 	NewFromStaticValue(commit *metadata.Commit) metadata.CommitResolver
 }
 
@@ -47,9 +134,17 @@ func NewCommitResolverFactory(logger logger.Logger) CommitResolverFactory {
 }
 
 // NewFromRepository returns a CommitResolver for looking up commits in the
-// repository located at path.
-func (d *defaultCommitResolverFactory) NewFromRepository(path string) (metadata.CommitResolver, error) {
-	return metadata.NewRepositoryCommitResolver(path, d.logger)
+// repository located at path, deepening it if it's a shallow clone that's
+// missing the commit and deepen is true. If there's no git repository at path
+// but there's a Mercurial repository, it looks up changesets in that instead.
+func (d *defaultCommitResolverFactory) NewFromRepository(path string, deepen bool) (metadata.CommitResolver, error) {
+	r, err := metadata.NewRepositoryCommitResolver(path, deepen, d.logger)
+	if err != nil && metadata.HasMercurialRepository(path) {
+		d.logger.Printf("No git repository found at %s; using Mercurial repository", path)
+		return metadata.NewMercurialCommitResolver(path, d.logger)
+	}
+
+	return r, err
 }
 
 // NewFromStaticValue returns a CommitResolver whose Lookup method always
@@ -61,26 +156,116 @@ func (d *defaultCommitResolverFactory) NewFromStaticValue(commit *metadata.Commi
 // Submit represents the task of preparing and sending a set of test results to
 // BuildPulse.
 type Submit struct {
-	client  *http.Client
-	fs      *flag.FlagSet
-	idgen   func() uuid.UUID
-	logger  logger.Logger
-	version *metadata.Version
+	client   *http.Client
+	endpoint string // S3 endpoint; the default AWS endpoint is used if empty
+	apiURL   string // base URL of the BuildPulse API
+	fs       *flag.FlagSet
+	idgen    func() uuid.UUID
+	logger   logger.Logger
+	stdin    io.Reader // read by -paths-from -
+	version  *metadata.Version
+
+	// checkOnly has Init check the args without finding the reports (see
+	// CheckArgs)
+	checkOnly bool
+
+	// checkedWalkOptions are the walkOptions given by the args checked by
+	// CheckArgs (see ReportPaths)
+	checkedWalkOptions walkOptions
 
 	envs                         map[string]string
 	paths                        []string
+	pathsFrom                    string
 	coveragePathsString          string
 	coveragePaths                []string
+	coverageBaseline             string
+	coverageBaselineRef          string
+	convertSimpleCov             bool
+	convertExcoveralls           bool
 	tagsString                   string
+	tagArgs                      tagFlag
+	meta                         metaFlag
+	framework                    string
+	format                       string
+	jsonFormats                  map[string]string
+	pathArgs                     pathFlag
+	pathFormats                  map[string]string    // formats given by -path, keyed by report path
+	reportTimes                  map[string]time.Time // earliest suite timestamp, keyed by report path
 	bucket                       string
+	defaults                     Defaults
 	accountID                    uint64
 	repositoryID                 uint64
 	repositoryPath               string
+	deepenShallowClone           bool
+	strictCommitResolution       bool
+	failOnEmpty                  bool
+	resolveBaseBranch            bool
+	hashTree                     bool
 	tree                         string
 	quotaID                      string
+	project                      string
+	configPath                   string
+	dryRun                       bool
+	quiet                        bool
+	output                       string
+	result                       result // set by Run, for -output json
+	verbose                      bool
+	logLevel                     string
+	truncateFailuresKB           int
+	timeout                      time.Duration
+	bundleTimeout                time.Duration
+	uploadTimeout                time.Duration
+	timestampOverrideString      string
+	timestampOverride            time.Time
+	backfill                     bool
+	backfillFrom                 string
 	disableCoverageAutoDiscovery bool
+	excludeHidden                bool
+	followSymlinks               bool
+	strictPathVars               bool
+	unwrapNestedReports          bool
+	dedupeAttempts               string
+	keyScheme                    string
+	digest                       string // of the bundle's content, set by bundle
+	signKeyPath                  string
+	signKeyless                  bool
+	signer                       crypto.Signer
+	cosign                       string // command for -sign-keyless
+	manifestDigest               string // set by bundle, when signing
+	manifestSignature            string // set by bundle, when signing
+	provider                     string
+	repoNameWithOwner            string
+	checkName                    string
+	commitSHA                    string
+	branch                       string
+	testExitStatus               *int          // set by SetTestCommandResult
+	testDuration                 time.Duration // set by SetTestCommandResult
+	testRetries                  []testRetry   // set by AddTestRetry
+	retryReports                 map[string]string
+	includeEnv                   string
+	excludeEnv                   string
+	enrichersString              string
+	versionCheck                 bool
+	noVersionCheck               bool
+	s3Accelerate                 bool
+	s3DualStack                  bool
+	shardPattern                 string
+	shardRegex                   *regexp.Regexp
+	shardTimingFile              string
+	shardIndex                   uint
+	shardTotal                   uint
+	networkAuditPath             string
+	audit                        *auditTransport
+	bestEffortExit               bool
+	simulate                     string
+	telemetry                    bool
+	receiptPath                  string
+	enrichers                    []string
+	attempts                     map[string]int
 	credentials                  credentials
+	nextCredentials              *credentials
 	commitResolver               metadata.CommitResolver
+	ciProvider                   string
 }
 
 // NewSubmit creates a new Submit instance.
@@ -90,101 +275,517 @@ func NewSubmit(version *metadata.Version, log logger.Logger) *Submit {
 		fs:      flag.NewFlagSet("submit", flag.ContinueOnError),
 		idgen:   uuid.New,
 		logger:  log,
+		stdin:   os.Stdin,
 		version: version,
+		cosign:  "cosign",
 	}
 
 	s.fs.Uint64Var(&s.accountID, "account-id", 0, "BuildPulse account ID (required)")
 	s.fs.Uint64Var(&s.repositoryID, "repository-id", 0, "BuildPulse repository ID (required)")
 	s.fs.StringVar(&s.repositoryPath, "repository-dir", ".", "Path to local clone of repository")
 	s.fs.StringVar(&s.tree, "tree", "", "SHA-1 hash of git tree")
+	s.fs.BoolVar(&s.hashTree, "hash-tree", false, "Compute the tree SHA from the files in -repository-dir if it isn't a git repository")
+	s.fs.BoolVar(&s.deepenShallowClone, "deepen-shallow-clone", false, "Fetch the rest of the repository's history if the commit is missing from a shallow clone")
+	s.fs.BoolVar(&s.strictCommitResolution, "strict-commit-resolution", false, "Fail if the commit can't be looked up, instead of submitting the test results without the commit's metadata (overrides BUILDPULSE_STRICT_COMMIT_RESOLUTION)")
+	s.fs.BoolVar(&s.failOnEmpty, "fail-on-empty", false, "Fail if TEST_RESULTS_PATH is a directory without reports, instead of submitting zero reports (overrides BUILDPULSE_FAIL_ON_EMPTY)")
+	s.fs.BoolVar(&s.resolveBaseBranch, "resolve-base-branch", false, "Use the repository in -repository-dir to find the base branch and merge base of the commit when the CI provider doesn't report the base branch")
 	s.fs.StringVar(&s.coveragePathsString, "coverage-files", "", "Paths to coverage files (space-separated)")
 	s.fs.StringVar(&s.quotaID, "quota-id", "", "Quota ID to submit against")
+	s.fs.IntVar(&s.truncateFailuresKB, "truncate-failures", 64, "Truncate the text and message of each failure and error that's longer than twice this many KB, keeping this many KB at each end (0 disables truncation)")
+	s.fs.DurationVar(&s.timeout, "timeout", 0, "Maximum time to spend submitting the test results (e.g., 5m), including bundling and uploading them (0 means no limit)")
+	s.fs.DurationVar(&s.bundleTimeout, "bundle-timeout", 0, "Maximum time to spend gathering the metadata and bundling the test results (0 means no limit)")
+	s.fs.DurationVar(&s.uploadTimeout, "upload-timeout", 0, "Maximum time to spend uploading the bundle to S3, including retries (0 means no limit)")
+	s.fs.BoolVar(&s.dryRun, "dry-run", false, "Prepare the bundle and print what would be uploaded (files, size, and metadata), without contacting BuildPulse or S3")
+	s.fs.StringVar(&s.configPath, "config", "", "Path to a YAML file of flag values to use for the flags that aren't given (default: .buildpulse.yml or buildpulse.config.yml in the working directory, if present)")
+	s.fs.StringVar(&s.timestampOverrideString, "timestamp-override", "", "RFC 3339 timestamp to record for the submission instead of the current time")
+	s.fs.BoolVar(&s.backfill, "backfill", false, "Mark the submission as a re-submission of historical test results (e.g., after an outage), skipping the check for stale reports (requires -backfill-from or -timestamp-override)")
+	s.fs.StringVar(&s.backfillFrom, "backfill-from", "", "Path to the receipt or buildpulse.yml of the original submission, whose timestamp is recorded for the backfill (requires -backfill)")
 	s.fs.BoolVar(&s.disableCoverageAutoDiscovery, "disable-coverage-auto", false, "Disables coverage file autodiscovery")
+	s.fs.StringVar(&s.coverageBaseline, "coverage-baseline", "", "(experimental) Directory of baseline coverage files (e.g., restored from a cache) to upload the coverage files as deltas against (requires -coverage-baseline-ref)")
+	s.fs.BoolVar(&s.convertSimpleCov, "convert-simplecov", false, "Convert SimpleCov result sets (.resultset.json) to a single LCOV file, merging the results of parallel workers")
+	s.fs.BoolVar(&s.convertExcoveralls, "convert-excoveralls", false, "Convert excoveralls JSON reports (excoveralls.json) to a single LCOV file, merging the results of test partitions")
+	s.fs.StringVar(&s.coverageBaselineRef, "coverage-baseline-ref", "", "(experimental) Identifier of the submission whose coverage files are in -coverage-baseline (e.g., its commit SHA)")
 	s.fs.StringVar(&s.tagsString, "tags", "", "Tags to apply to the build (space-separated)")
+	s.fs.Var(&s.tagArgs, "tag", "Tag to apply to the build (repeatable; comma-separated values are split)")
+	s.fs.StringVar(&s.project, "project", "", "Name of the subproject (e.g., a package in a monorepo) that produced the test results")
+	s.fs.Var(&s.meta, "meta", "User-defined metadata to attach to the build, as key=value (repeatable)")
+	s.fs.StringVar(&s.pathsFrom, "paths-from", "", "Path to a file listing paths to test reports, one per line, in addition to TEST_RESULTS_PATH (- for stdin)")
+	s.fs.Var(&s.pathArgs, "path", "Path to test reports in the given format, as format=path (repeatable)")
+	s.fs.StringVar(&s.framework, "framework", "", "Test framework conventions to use for discovering and processing reports (supported: dotnet, pest, phpunit)")
+	s.fs.BoolVar(&s.excludeHidden, "exclude-hidden", true, "Skip hidden directories (e.g., .cache) when looking for reports in a directory")
+	s.fs.BoolVar(&s.followSymlinks, "follow-symlinks", false, "Follow symbolic links to directories when looking for reports in a directory")
+	s.fs.BoolVar(&s.strictPathVars, "strict-path-vars", false, "Fail if a variable in TEST_RESULTS_PATH (e.g., ${BUILDKITE_PARALLEL_JOB}) isn't set")
+	s.fs.BoolVar(&s.unwrapNestedReports, "unwrap-nested-reports", false, "Submit the JUnit reports embedded in the text (e.g., a CDATA section) of a JUnit report instead of the report itself")
+	s.fs.StringVar(&s.dedupeAttempts, "dedupe-attempts", dedupeAttemptsAll, "How to handle reports from retried attempts of a CI step (supported: all, latest)")
+	s.fs.StringVar(&s.keyScheme, "key-scheme", keySchemeUUID, "How to name the uploaded object (supported: uuid, digest)")
+	s.fs.StringVar(&s.provider, "provider", "", "CI provider to use instead of detecting it from the environment (overrides BUILDPULSE_PROVIDER)")
+	s.fs.StringVar(&s.includeEnv, "include-env", "", "Environment variables (comma-separated names or patterns, e.g., GITHUB_RUN_*) that may be captured in provider metadata; others are left out (overrides BUILDPULSE_INCLUDE_ENV)")
+	s.fs.StringVar(&s.excludeEnv, "exclude-env", "", "Environment variables (comma-separated names or patterns, e.g., GITHUB_EVENT_*) to leave out of provider metadata (overrides BUILDPULSE_EXCLUDE_ENV)")
+	s.fs.StringVar(&s.repoNameWithOwner, "repo-name-with-owner", "", "Repository name-with-owner (e.g., some-owner/some-repo) to use instead of deriving it from the environment (overrides BUILDPULSE_REPO_NAME_WITH_OWNER)")
+	s.fs.StringVar(&s.commitSHA, "commit", "", "SHA-1 hash of the commit that produced the test results, to record instead of deriving it from the environment (overrides BUILDPULSE_COMMIT_SHA)")
+	s.fs.StringVar(&s.branch, "branch", "", "Branch that produced the test results, to record instead of deriving it from the environment (overrides BUILDPULSE_BRANCH)")
+	s.fs.StringVar(&s.checkName, "check-name", "", "Name of the check (e.g., a matrix job's name) to record instead of deriving it from the CI provider (overrides BUILDPULSE_CHECK_NAME)")
+	s.fs.StringVar(&s.enrichersString, "enrichers", strings.Join(metadata.DefaultEnrichers, ","), "Optional metadata integrations to enable (comma-separated; supported: "+strings.Join(metadata.Enrichers(), ", ")+")")
+	s.fs.BoolVar(&s.versionCheck, "version-check", false, "Check whether this version of the reporter is outdated or unsupported before submitting (overrides BUILDPULSE_VERSION_CHECK)")
+	s.fs.BoolVar(&s.noVersionCheck, "no-version-check", false, "Skip the version check, even if BUILDPULSE_VERSION_CHECK is set (e.g., for a single job in air-gapped environments)")
+	s.fs.StringVar(&s.shardPattern, "shard-pattern", "", "Regular expression whose first capture group identifies the shard that produced each report, from its path (e.g., 'shard-(\\d+)/')")
+	s.fs.UintVar(&s.shardIndex, "shard-index", 0, "Index of the test shard that produced the test results, starting from 0 (requires -shard-total)")
+	s.fs.UintVar(&s.shardTotal, "shard-total", 0, "Total number of test shards (requires -shard-index)")
+	s.fs.StringVar(&s.shardTimingFile, "shard-timing-file", "", "Path to write the per-shard timings and a suggested rebalancing of the test suites to, as JSON (requires -shard-pattern)")
+	s.fs.BoolVar(&s.s3Accelerate, "s3-accelerate", false, "Upload via S3 Transfer Acceleration, falling back to the regular endpoint if the bucket doesn't support it (overrides BUILDPULSE_S3_ACCELERATE)")
+	s.fs.BoolVar(&s.s3DualStack, "s3-dualstack", false, "Upload via the dual-stack (IPv4 and IPv6) S3 endpoint (overrides BUILDPULSE_S3_DUALSTACK)")
+	s.fs.StringVar(&s.networkAuditPath, "network-audit", "", "Path to write a JSON record of every outbound request (method, host, path, bytes, duration, and status) to")
+	s.fs.BoolVar(&s.bestEffortExit, "best-effort-exit", false, "Log a warning and write a receipt instead of failing if the test results can't be submitted (overrides BUILDPULSE_SOFT_FAIL)")
+	s.fs.BoolVar(&s.bestEffortExit, "soft-fail", false, "Alias for -best-effort-exit")
+	s.fs.StringVar(&s.receiptPath, "receipt", "buildpulse-receipt.json", "Path to write a JSON description of the failure to when -best-effort-exit ignores one")
+	s.fs.StringVar(&s.simulate, "simulate", "", "Simulate a problem with reporting to BuildPulse, for testing how the pipeline copes (supported: "+strings.Join(supportedSimulations, ", ")+")")
+	s.fs.StringVar(&s.format, "format", "", "Format of the JSON test reports named explicitly (supported: "+strings.Join(report.Formats, ", ")+"); detected from each report by default")
+	s.fs.StringVar(&s.signKeyPath, "sign-key", "", "Path to a PEM-encoded ECDSA or Ed25519 private key to sign the bundle's manifest with")
+	s.fs.BoolVar(&s.signKeyless, "sign-keyless", false, "Sign the bundle's manifest keylessly with cosign, using the CI provider's OIDC identity")
+	s.fs.StringVar(&s.output, "output", outputText, "What to print (supported: text, json); with json, a JSON description of the submission is all that's printed")
+	s.fs.BoolVar(&s.quiet, "quiet", false, "Log nothing but the key of the uploaded object (errors are still reported)")
+	s.fs.BoolVar(&s.verbose, "verbose", false, "Log the details of the submission, such as each file added to the bundle")
+	s.fs.StringVar(&s.logLevel, "log-level", "", "Minimum level of the log entries to print (supported: debug, info, warn, error; default: info)")
 	s.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
 
-	s.logger.Printf("Current version: %s", s.version.String())
-	s.logger.Println("Initiating `submit`")
-
 	return s
 }
 
+// initLogLevel sets the level of the logger from the -quiet, -verbose,
+// -log-level, and -output flags.
+func (s *Submit) initLogLevel() error {
+	if s.quiet && s.verbose {
+		return fmt.Errorf("invalid use of flag -quiet with flag -verbose: use one or the other, but not both")
+	}
+	if s.logLevel != "" && (s.quiet || s.verbose) {
+		return fmt.Errorf("invalid use of flag -log-level with flag -quiet or -verbose: use one or the other, but not both")
+	}
+	// The log would be mixed into the JSON on stdout
+	if s.output == outputJSON && (s.verbose || s.logLevel != "") {
+		return fmt.Errorf("invalid use of flag -output json with flag -verbose or -log-level: the JSON is all that's printed")
+	}
+
+	switch {
+	case s.quiet || s.output == outputJSON:
+		s.logger.SetLevel(logger.LevelError)
+	case s.verbose:
+		s.logger.SetLevel(logger.LevelDebug)
+	case s.logLevel != "":
+		level, err := logger.ParseLevel(s.logLevel)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -log-level: %v", s.logLevel, err)
+		}
+		s.logger.SetLevel(level)
+	}
+
+	return nil
+}
+
+// SetClient sets the HTTP client used to contact BuildPulse and S3, so that
+// connections can be reused across submissions (e.g., by the agent).
+func (s *Submit) SetClient(client *http.Client) {
+	s.client = client
+}
+
+// SetTestCommandResult records the exit status and wall time of the test
+// command that produced the test results (e.g., run by `exec`), so that they're
+// included in the metadata of the submission.
+func (s *Submit) SetTestCommandResult(exitStatus int, duration time.Duration) {
+	s.testExitStatus = &exitStatus
+	s.testDuration = duration
+}
+
+// A testRetry is a retry of the failed tests of the test command.
+type testRetry struct {
+	exitStatus int
+
+	// reports are the paths of copies of the reports written by the retry,
+	// keyed to the paths the retry wrote them to
+	reports map[string]string
+}
+
+// AddTestRetry records a retry of the failed tests of the test command (e.g.,
+// run by `exec -retries`): its exit status and the reports it wrote, given as
+// the paths of copies to submit, keyed to the paths the retry wrote them to.
+// The reports are submitted alongside those in TEST_RESULTS_PATH, under
+// test_results/retry-N/, so that BuildPulse can tell the retries apart from the
+// first run. It must be called (once per retry, in order) before Init.
+func (s *Submit) AddTestRetry(exitStatus int, reports map[string]string) {
+	s.testRetries = append(s.testRetries, testRetry{exitStatus: exitStatus, reports: reports})
+}
+
+// SetDefaults sets the values to use when the -account-id and -repository-id
+// flags and the BUILDPULSE_BUCKET environment variable aren't given. It must be
+// called before Init.
+func (s *Submit) SetDefaults(d Defaults) {
+	s.defaults = d
+	s.accountID = d.AccountID
+	s.repositoryID = d.RepositoryID
+}
+
+// CheckArgs checks args and envs as Init does, without finding the test reports
+// or coverage files, so that a command that runs the tests before submitting
+// their results (e.g., `exec`) can report malformed flags or missing
+// credentials before the tests run rather than after. Init must still be
+// called once the reports are written; until then, s only remembers how the
+// args say to look for the reports (see ReportPaths).
+func (s *Submit) CheckArgs(args []string, envs map[string]string, commitResolverFactory CommitResolverFactory) error {
+	c := NewSubmit(s.version, logger.New())
+	c.client = s.client
+	c.stdin = s.stdin
+	c.SetDefaults(s.defaults)
+	c.checkOnly = true
+
+	if err := c.Init(args, envs, commitResolverFactory); err != nil {
+		return err
+	}
+	s.checkedWalkOptions = c.walkOptions()
+
+	return nil
+}
+
+// ReportPaths returns the XML reports found at the paths in args, searching
+// directories as the args given to CheckArgs say to (i.e., -exclude-hidden and
+// -follow-symlinks), for commands that read the reports before Init (e.g.,
+// `exec -retries`).
+func (s *Submit) ReportPaths(args []string) ([]string, error) {
+	return xmlPathsFromArgs(args, s.checkedWalkOptions)
+}
+
 // Init populates s from args and envs. It returns an error if the required args
 // or environment variables are missing or malformed.
 func (s *Submit) Init(args []string, envs map[string]string, commitResolverFactory CommitResolverFactory) error {
-	s.logger.Printf("Received args: %s", strings.Join(args, " "))
+	pathArgs, flagArgs := pathsAndFlagsFromArgs(args)
+
+	if err := s.fs.Parse(flagArgs); err != nil {
+		return err
+	}
+
+	// Apply the level given on the command line before logging anything, and
+	// again after loading the config file, in case the config file sets it
+	if err := s.initLogLevel(); err != nil {
+		return err
+	}
+
+	s.logger.Printf("Current version: %s", s.version.String())
+	s.logger.Debugf("Initiating `submit`")
+	s.logger.Debugf("Received args: %s", strings.Join(args, " "))
 
 	dir, err := os.Getwd()
 	if err != nil {
 		return err
 	}
-	s.logger.Printf("Using working directory: %v", dir)
+	s.logger.Debugf("Using working directory: %v", dir)
 
-	pathArgs, flagArgs := pathsAndFlagsFromArgs(args)
-	if len(pathArgs) == 0 {
-		return fmt.Errorf("missing TEST_RESULTS_PATH")
+	if err := s.loadConfig(dir, envs); err != nil {
+		return err
+	}
+	if err := s.initLogLevel(); err != nil {
+		return err
 	}
 
-	s.paths, err = xmlPathsFromArgs(pathArgs)
-	if err != nil {
+	flagset := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) { flagset[f.Name] = true })
+
+	if flagset["framework"] && !contains(supportedFrameworks, s.framework) {
+		return fmt.Errorf("invalid value \"%s\" for flag -framework: supported values are: %s", s.framework, strings.Join(supportedFrameworks, ", "))
+	}
+
+	if flagset["format"] && !contains(report.Formats, s.format) {
+		return fmt.Errorf("invalid value \"%s\" for flag -format: supported values are: %s", s.format, strings.Join(report.Formats, ", "))
+	}
+
+	if flagset["timestamp-override"] {
+		s.timestampOverride, err = time.Parse(time.RFC3339, s.timestampOverrideString)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -timestamp-override: should be an RFC 3339 timestamp (e.g., 2006-01-02T15:04:05Z)", s.timestampOverrideString)
+		}
+	}
+
+	if s.truncateFailuresKB < 0 {
+		return fmt.Errorf("invalid value \"%d\" for flag -truncate-failures: should be 0 or more", s.truncateFailuresKB)
+	}
+
+	for name, d := range map[string]time.Duration{"timeout": s.timeout, "bundle-timeout": s.bundleTimeout, "upload-timeout": s.uploadTimeout} {
+		if d < 0 {
+			return fmt.Errorf("invalid value \"%s\" for flag -%s: should be 0 or more", d, name)
+		}
+	}
+
+	if err := s.initBackfill(flagset); err != nil {
 		return err
 	}
-	if len(s.paths) == 0 {
-		// To maintain backwards compatibility with releases prior to v0.19.0, if
-		// exactly one path was given, and it's a directory, and it contains no XML
-		// reports, continue without erroring. The resulting upload will contain
-		// *zero* XML reports. In all other scenarios, treat this as an error.
-		//
-		// TODO: Treat this scenario as an error for the next major version release.
-		info, err := os.Stat(pathArgs[0])
-		isSingleDir := len(pathArgs) == 1 && err == nil && info.IsDir()
-		if !isSingleDir {
-			return fmt.Errorf("no XML reports found at TEST_RESULTS_PATH: %s", strings.Join(pathArgs, " "))
+
+	if err := s.initSigning(); err != nil {
+		return err
+	}
+
+	if !contains(supportedDedupeAttempts, s.dedupeAttempts) {
+		return fmt.Errorf("invalid value \"%s\" for flag -dedupe-attempts: supported values are: %s", s.dedupeAttempts, strings.Join(supportedDedupeAttempts, ", "))
+	}
+
+	if !contains(supportedOutputs, s.output) {
+		return fmt.Errorf("invalid value \"%s\" for flag -output: supported values are: %s", s.output, strings.Join(supportedOutputs, ", "))
+	}
+
+	if !contains(supportedKeySchemes, s.keyScheme) {
+		return fmt.Errorf("invalid value \"%s\" for flag -key-scheme: supported values are: %s", s.keyScheme, strings.Join(supportedKeySchemes, ", "))
+	}
+
+	// The tags given by both flags are applied together, so they must agree
+	if _, err := metadata.Dimensions(s.tags()); err != nil {
+		return fmt.Errorf("invalid value \"%s\" for flags -tags and -tag: %v", strings.Join(s.tags(), " "), err)
+	}
+
+	if flagset["project"] && !projectRegex.MatchString(s.project) {
+		return fmt.Errorf("invalid value \"%s\" for flag -project: should be a non-empty name without whitespace", s.project)
+	}
+
+	if s.enrichersString != "" {
+		s.enrichers = strings.Split(s.enrichersString, ",")
+	}
+	for _, name := range s.enrichers {
+		if !contains(metadata.Enrichers(), name) {
+			return fmt.Errorf("invalid value \"%s\" for flag -enrichers: supported values are: %s", name, strings.Join(metadata.Enrichers(), ", "))
 		}
 	}
 
-	if err := s.fs.Parse(flagArgs); err != nil {
+	if s.shardPattern != "" {
+		s.shardRegex, err = regexp.Compile(s.shardPattern)
+		if err != nil || s.shardRegex.NumSubexp() < 1 {
+			return fmt.Errorf("invalid value \"%s\" for flag -shard-pattern: should be a regular expression with a capture group", s.shardPattern)
+		}
+	}
+
+	if s.shardTimingFile != "" && s.shardRegex == nil {
+		return fmt.Errorf("invalid use of flag -shard-timing-file without flag -shard-pattern")
+	}
+
+	switch {
+	case flagset["shard-index"] && !flagset["shard-total"]:
+		return fmt.Errorf("invalid use of flag -shard-index without flag -shard-total")
+	case flagset["shard-total"] && !flagset["shard-index"]:
+		return fmt.Errorf("invalid use of flag -shard-total without flag -shard-index")
+	case flagset["shard-total"] && s.shardTotal == 0:
+		return fmt.Errorf("invalid value \"0\" for flag -shard-total: should be at least 1")
+	case flagset["shard-index"] && s.shardIndex >= s.shardTotal:
+		return fmt.Errorf("invalid value \"%d\" for flag -shard-index: should be less than -shard-total (%d)", s.shardIndex, s.shardTotal)
+	}
+
+	if flagset["provider"] {
+		if !contains(metadata.Providers, s.provider) {
+			return fmt.Errorf("invalid value \"%s\" for flag -provider: supported values are: %s", s.provider, strings.Join(metadata.Providers, ", "))
+		}
+
+		envs = withEnv(envs, "BUILDPULSE_PROVIDER", s.provider)
+	}
+
+	if flagset["repo-name-with-owner"] {
+		if !nameWithOwnerRegex.MatchString(s.repoNameWithOwner) {
+			return fmt.Errorf("invalid value \"%s\" for flag -repo-name-with-owner: should be of the form OWNER/NAME", s.repoNameWithOwner)
+		}
+		envs = withEnv(envs, "BUILDPULSE_REPO_NAME_WITH_OWNER", s.repoNameWithOwner)
+	}
+
+	if flagset["commit"] {
+		if !commitSHARegex.MatchString(s.commitSHA) {
+			return fmt.Errorf("invalid value \"%s\" for flag -commit: should be a 40-character SHA-1 hash", s.commitSHA)
+		}
+		envs = withEnv(envs, "BUILDPULSE_COMMIT_SHA", strings.ToLower(s.commitSHA))
+	} else if sha := envs["BUILDPULSE_COMMIT_SHA"]; sha != "" {
+		if !commitSHARegex.MatchString(sha) {
+			return fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_COMMIT_SHA: should be a 40-character SHA-1 hash", sha)
+		}
+		envs = withEnv(envs, "BUILDPULSE_COMMIT_SHA", strings.ToLower(sha))
+	}
+
+	if flagset["branch"] {
+		branch := strings.TrimSpace(s.branch)
+		if branch == "" {
+			return fmt.Errorf("invalid value \"%s\" for flag -branch: should not be blank", s.branch)
+		}
+		envs = withEnv(envs, "BUILDPULSE_BRANCH", branch)
+	} else if value := envs["BUILDPULSE_BRANCH"]; value != "" {
+		branch := strings.TrimSpace(value)
+		if branch == "" {
+			return fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_BRANCH: should not be blank", value)
+		}
+		envs = withEnv(envs, "BUILDPULSE_BRANCH", branch)
+	}
+
+	if flagset["check-name"] {
+		check, err := normalizeCheckName(s.checkName)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -check-name: %v", s.checkName, err)
+		}
+		envs = withEnv(envs, "BUILDPULSE_CHECK_NAME", check)
+	}
+
+	for _, f := range []struct{ name, value, key string }{
+		{"include-env", s.includeEnv, "BUILDPULSE_INCLUDE_ENV"},
+		{"exclude-env", s.excludeEnv, "BUILDPULSE_EXCLUDE_ENV"},
+	} {
+		if !flagset[f.name] {
+			continue
+		}
+		if _, err := metadata.ParseEnvPatterns(f.value); err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -%s: %v", f.value, f.name, err)
+		}
+		envs = withEnv(envs, f.key, f.value)
+	}
+
+	pathArgs, err = s.expandPathArgs(pathArgs, envs)
+	if err != nil {
 		return err
 	}
 
-	flagset := make(map[string]bool)
-	s.fs.Visit(func(f *flag.Flag) { flagset[f.Name] = true })
+	if !flagset["fail-on-empty"] {
+		s.failOnEmpty = envs["BUILDPULSE_FAIL_ON_EMPTY"] == "true"
+	}
+
+	if s.versionCheck && s.noVersionCheck {
+		return fmt.Errorf("invalid use of flag -version-check with flag -no-version-check: use one or the other, but not both")
+	}
+	if !flagset["version-check"] {
+		s.versionCheck = envs["BUILDPULSE_VERSION_CHECK"] == "true"
+	}
+	if s.noVersionCheck {
+		s.versionCheck = false
+	}
+
+	// The reports are found once the tests have written them (see CheckArgs)
+	if !s.checkOnly {
+		if err := s.initReports(pathArgs, envs, flagset); err != nil {
+			return err
+		}
+	}
 
 	if s.accountID == 0 {
 		return fmt.Errorf("missing required flag: -account-id")
 	}
+	if !flagset["account-id"] && s.defaults.AccountID != 0 {
+		s.logger.Printf("Using embedded default for -account-id: %d", s.accountID)
+	}
 
 	if s.repositoryID == 0 {
 		return fmt.Errorf("missing required flag: -repository-id")
 	}
+	if !flagset["repository-id"] && s.defaults.RepositoryID != 0 {
+		s.logger.Printf("Using embedded default for -repository-id: %d", s.repositoryID)
+	}
 
-	if len(s.coveragePathsString) > 0 {
-		s.coveragePaths = strings.Split(s.coveragePathsString, " ")
-	} else {
-		s.coveragePaths = []string{}
+	if flagset["coverage-baseline"] != flagset["coverage-baseline-ref"] {
+		return fmt.Errorf("invalid use of flags -coverage-baseline and -coverage-baseline-ref: use both or neither")
+	}
+	if flagset["coverage-baseline"] {
+		if info, err := os.Stat(s.coverageBaseline); err != nil || !info.IsDir() {
+			return fmt.Errorf("invalid value \"%s\" for flag -coverage-baseline: should be a directory", s.coverageBaseline)
+		}
 	}
 
-	id, ok := envs["BUILDPULSE_ACCESS_KEY_ID"]
-	if !ok || id == "" {
-		return fmt.Errorf("missing required environment variable: BUILDPULSE_ACCESS_KEY_ID")
+	s.coveragePaths = []string{}
+	if !s.checkOnly {
+		for _, p := range strings.Fields(s.coveragePathsString) {
+			if !hasMeta(p) {
+				s.coveragePaths = append(s.coveragePaths, p)
+				continue
+			}
+			matches, err := glob(p, s.followSymlinks)
+			if err != nil {
+				return fmt.Errorf("invalid value \"%s\" for flag -coverage-files: %v", s.coveragePathsString, err)
+			}
+			if len(matches) == 0 {
+				s.logger.Printf("⚠️ No coverage files match %s", p)
+			}
+			s.coveragePaths = append(s.coveragePaths, matches...)
+		}
 	}
-	s.credentials.AccessKeyID = id
 
-	key, ok := envs["BUILDPULSE_SECRET_ACCESS_KEY"]
-	if !ok || key == "" {
-		return fmt.Errorf("missing required environment variable: BUILDPULSE_SECRET_ACCESS_KEY")
+	// A dry run doesn't upload anything, so it doesn't need credentials
+	if s.dryRun {
+		s.logger.Printf("Dry run (-dry-run): the bundle will be prepared but not uploaded")
+	} else if err := s.initCredentials(envs); err != nil {
+		return err
 	}
-	s.credentials.SecretAccessKey = key
 
-	s.bucket, ok = envs["BUILDPULSE_BUCKET"]
-	if !ok {
-		s.bucket = "buildpulse-uploads"
+	if !flagset["s3-accelerate"] {
+		s.s3Accelerate = envs["BUILDPULSE_S3_ACCELERATE"] == "true"
+	}
+	if !flagset["s3-dualstack"] {
+		s.s3DualStack = envs["BUILDPULSE_S3_DUALSTACK"] == "true"
+	}
+	if !flagset["strict-commit-resolution"] {
+		s.strictCommitResolution = envs["BUILDPULSE_STRICT_COMMIT_RESOLUTION"] == "true"
+	}
+	if flagset["best-effort-exit"] && flagset["soft-fail"] {
+		return fmt.Errorf("invalid use of flag -soft-fail with flag -best-effort-exit: -soft-fail is an alias for -best-effort-exit, so use one or the other")
+	}
+	if !flagset["best-effort-exit"] && !flagset["soft-fail"] {
+		s.bestEffortExit = envs["BUILDPULSE_SOFT_FAIL"] == "true"
+	}
+
+	if flagset["receipt"] && !s.bestEffortExit {
+		return fmt.Errorf("invalid use of flag -receipt without flag -best-effort-exit: receipts are only written for ignored failures")
+	}
+
+	if err := s.initBackend(envs); err != nil {
+		return err
+	}
+
+	if s.simulate != "" {
+		if !contains(supportedSimulations, s.simulate) {
+			return fmt.Errorf("invalid value \"%s\" for flag -simulate: supported values are: %s", s.simulate, strings.Join(supportedSimulations, ", "))
+		}
+		s.logger.Printf("⚠️ Simulating %s (-simulate); this submission is only a test", s.simulate)
+	}
+
+	s.telemetry, err = telemetry.Enabled(envs)
+	if err != nil {
+		return err
+	}
+	if s.telemetry {
+		s.logger.Printf("Sending anonymous telemetry about this submission (BUILDPULSE_TELEMETRY=on); run `telemetry show` to see what's sent")
+	}
+
+	// Innermost, so that the trace context and the audit see the delay
+	if s.simulate == simulateSlowNetwork {
+		s.client = wrapTransport(s.client, func(next http.RoundTripper) http.RoundTripper {
+			return newSlowTransport(next, simulatedLatency)
+		})
+	}
+
+	// An invalid trace context is reported along with the rest of the metadata
+	if traceparent, tracestate, err := metadata.TraceContext(envs); err == nil && traceparent != "" {
+		s.logger.Printf("Propagating trace context to outbound requests: %s", traceparent)
+		s.client = wrapTransport(s.client, func(next http.RoundTripper) http.RoundTripper {
+			return newTraceTransport(next, traceparent, tracestate)
+		})
+	}
+
+	if s.networkAuditPath != "" {
+		s.logger.Printf("Recording outbound requests to %s", s.networkAuditPath)
+		s.client = wrapTransport(s.client, func(next http.RoundTripper) http.RoundTripper {
+			s.audit = newAuditTransport(next)
+			return s.audit
+		})
 	}
 
 	if flagset["repository-dir"] && flagset["tree"] {
 		return fmt.Errorf("invalid use of flag -repository-dir with flag -tree: use one or the other, but not both")
 	}
 
+	if flagset["hash-tree"] && flagset["tree"] {
+		return fmt.Errorf("invalid use of flag -hash-tree with flag -tree: use one or the other, but not both")
+	}
+
+	if flagset["deepen-shallow-clone"] && flagset["tree"] {
+		return fmt.Errorf("invalid use of flag -deepen-shallow-clone with flag -tree: no repository is used with -tree")
+	}
+
+	if flagset["resolve-base-branch"] && flagset["tree"] {
+		return fmt.Errorf("invalid use of flag -resolve-base-branch with flag -tree: no repository is used with -tree")
+	}
+
 	re := regexp.MustCompile(`^[0-9a-f]{40}$`)
 	if flagset["tree"] && !re.MatchString(s.tree) {
 		return fmt.Errorf("invalid value \"%s\" for flag -tree: should be a 40-character SHA-1 hash", s.tree)
@@ -211,154 +812,1522 @@ func (s *Submit) Init(args []string, envs map[string]string, commitResolverFacto
 		s.logger.Printf("Using default value for -repository-dir flag: %s", s.repositoryPath)
 	}
 
-	s.logger.Printf("Looking for git repository at %s", s.repositoryPath)
-	s.commitResolver, err = commitResolverFactory.NewFromRepository(s.repositoryPath)
+	s.logger.Debugf("Looking for repository at %s", s.repositoryPath)
+	s.commitResolver, err = commitResolverFactory.NewFromRepository(s.repositoryPath, s.deepenShallowClone)
+	if github := s.gitHubCommitResolver(envs); github != nil {
+		if err != nil {
+			s.logger.Printf("Unable to open git repository (%v); using the GitHub API to look up the commit", err)
+			s.commitResolver = github
+			return nil
+		}
+		s.commitResolver = metadata.NewFallbackCommitResolver(s.logger, s.commitResolver, github)
+	}
+	if err != nil && s.hashTree {
+		s.logger.Printf("Unable to open git repository (%v); computing the tree SHA from the files in %s", err, s.repositoryPath)
+		tree, err := metadata.HashTree(s.repositoryPath)
+		if err != nil {
+			return fmt.Errorf("unable to compute tree SHA for %s: %v", s.repositoryPath, err)
+		}
+		s.logger.Printf("Computed tree SHA: %s", tree)
+		s.commitResolver = commitResolverFactory.NewFromStaticValue(&metadata.Commit{TreeSHA: tree})
+		return nil
+	}
+	if err != nil && envs["HEROKU_TEST_RUN_ID"] != "" {
+		// Heroku CI runs the tests in a slug without a git repository, but
+		// provides the commit SHA in HEROKU_TEST_RUN_COMMIT_VERSION
+		s.logger.Printf("Unable to open git repository (%v); using commit SHA from Heroku CI", err)
+		s.commitResolver = commitResolverFactory.NewFromStaticValue(&metadata.Commit{})
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("invalid value for flag -repository-dir: %v", err)
 	}
-	s.logger.Printf("Found git repository at %s", s.repositoryPath)
+	s.logger.Printf("Found repository at %s", s.repositoryPath)
 
 	return nil
 }
 
-// Run packages up the test results and sends them to BuildPulse. It returns the
-// key that uniquely identifies the uploaded object.
-func (s *Submit) Run() (string, error) {
-	tarpath, err := s.bundle()
-	if err != nil {
-		return "", err
-	}
+// projectRegex matches the names accepted by the -project flag (e.g., "api" or
+// "packages/web").
+var projectRegex = regexp.MustCompile(`^\S+$`)
 
-	s.logger.Printf("Gzipping tarball (%s)", tarpath)
-	zippath, err := toGz(tarpath)
-	if err != nil {
-		return "", err
+// metaKeyRegex matches the keys accepted by the -meta flag (e.g., "browser" or
+// "db.version").
+var metaKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// metaFlag holds the key=value pairs given by repeated -meta flags.
+type metaFlag map[string]string
+
+func (m *metaFlag) String() string {
+	if m == nil {
+		return ""
 	}
 
-	s.logger.Printf("Sending %s to BuildPulse", zippath)
-	key, err := s.upload(zippath)
-	if err != nil {
-		return "", err
+	pairs := make([]string, 0, len(*m))
+	for k, v := range *m {
+		pairs = append(pairs, k+"="+v)
 	}
-	s.logger.Printf("Delivered test results to BuildPulse (%s)", key)
+	sort.Strings(pairs)
 
-	return key, nil
+	return strings.Join(pairs, ",")
 }
 
-// bundle gathers the artifacts expected by BuildPulse, creates a tarball
-// containing those artifacts, and returns the path of the resulting file.
-func (s *Submit) bundle() (string, error) {
-	// Prepare the metadata file
-	//////////////////////////////////////////////////////////////////////////////
-
-	s.logger.Printf("Gathering metadata to describe the build")
-	tags := strings.Split(s.tagsString, " ")
-	meta, err := metadata.NewMetadata(s.version, s.envs, tags, s.quotaID, s.commitResolver, time.Now, s.logger)
-	if err != nil {
-		return "", err
+func (m *metaFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return errors.New("should be of the form key=value")
 	}
-	yaml, err := meta.MarshalYAML()
-	if err != nil {
-		return "", err
+	if !metaKeyRegex.MatchString(key) {
+		return errors.New("key should start with a letter or underscore and contain only letters, digits, underscores, dots, and hyphens")
 	}
-
-	yamlfile, err := os.CreateTemp("", "buildpulse-*.yml")
-	if err != nil {
-		return "", err
+	if _, dup := (*m)[key]; dup {
+		return fmt.Errorf("duplicate key %s", key)
 	}
-	defer yamlfile.Close()
 
-	s.logger.Printf("Writing metadata to %s", yamlfile.Name())
-	_, err = yamlfile.Write(yaml)
-	if err != nil {
-		return "", err
+	if *m == nil {
+		*m = make(metaFlag)
 	}
+	(*m)[key] = val
 
-	// Initialize the tarfile for writing
-	//////////////////////////////////////////////////////////////////////////////
+	return nil
+}
 
-	f, err := os.CreateTemp("", "buildpulse-*.tar")
-	if err != nil {
-		return "", err
+// tagFlag holds the tags given by repeated -tag flags, each of which can give
+// several comma-separated tags.
+type tagFlag []string
+
+func (t *tagFlag) String() string {
+	if t == nil {
+		return ""
 	}
-	defer f.Close()
 
-	t := tar.Create(f)
-	defer t.Close()
+	return strings.Join(*t, ",")
+}
 
-	// Write the XML reports to the tarfile
-	//////////////////////////////////////////////////////////////////////////////
+func (t *tagFlag) Set(value string) error {
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
 
-	s.logger.Printf("Preparing tarball of test results:")
+	return nil
+}
+
+// tags returns the tags given by -tags and -tag, without empty tags, so that no
+// tags are recorded when neither flag is given.
+func (s *Submit) tags() []string {
+	var tags []string
+	tags = append(tags, strings.Fields(s.tagsString)...)
+	tags = append(tags, s.tagArgs...)
+
+	return tags
+}
+
+// walkOptions returns the options for searching the directories in
+// TEST_RESULTS_PATH for reports.
+func (s *Submit) walkOptions() walkOptions {
+	return walkOptions{includeHidden: !s.excludeHidden, followSymlinks: s.followSymlinks}
+}
+
+// A formattedPath is a path to test reports in a given format, as given by the
+// -path flag.
+type formattedPath struct {
+	format string
+	path   string
+}
+
+// pathFlag holds the format=path pairs given by repeated -path flags.
+type pathFlag []formattedPath
+
+func (p *pathFlag) String() string {
+	if p == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*p))
+	for _, fp := range *p {
+		pairs = append(pairs, fp.format+"="+fp.path)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func (p *pathFlag) Set(value string) error {
+	format, path, ok := strings.Cut(value, "=")
+	if !ok || path == "" {
+		return errors.New("should be of the form format=path")
+	}
+	if !contains(report.AllFormats, format) {
+		return fmt.Errorf("unsupported format %s (supported: %s)", format, strings.Join(report.AllFormats, ", "))
+	}
+
+	*p = append(*p, formattedPath{format: format, path: path})
+
+	return nil
+}
+
+// resolvePathArgs adds the reports at the paths given by the -path flags to
+// s.paths, along with their formats. A report that was also found via
+// TEST_RESULTS_PATH takes on the format given by -path.
+func (s *Submit) resolvePathArgs(envs map[string]string) error {
+	s.pathFormats = make(map[string]string)
+
+	seen := make(map[string]bool)
 	for _, p := range s.paths {
-		s.logger.Printf("- %s", p)
-		internalPath := fmt.Sprintf("test_results/%s", p)
-		err = t.Write(p, internalPath)
+		seen[p] = true
+	}
+
+	for _, fp := range s.pathArgs {
+		expanded, err := s.expandPathArgs([]string{fp.path}, envs)
+		if err != nil {
+			return err
+		}
+
+		var paths []string
+		switch fp.format {
+		case report.FormatJUnit:
+			paths, err = xmlPathsFromArgs(expanded, s.walkOptions())
+		case report.FormatTRX:
+			paths, err = trxPathsFromArgs(expanded, s.walkOptions())
+		default:
+			paths, err = jsonPathsFromArgs(expanded, s.walkOptions())
+		}
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			s.logger.Printf("No %s reports found at %s", fp.format, fp.path)
+		}
+
+		for _, p := range paths {
+			s.pathFormats[p] = fp.format
+			if !seen[p] {
+				seen[p] = true
+				s.paths = append(s.paths, p)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reportFormat returns the format of the report at the given path: the format
+// given by -path, if any, or else the format implied by its extension (and
+// for JSON reports, by -format or the report's contents).
+func (s *Submit) reportFormat(path string) string {
+	if format, ok := s.pathFormats[path]; ok {
+		return format
+	}
+
+	switch {
+	case isTRX(path):
+		return report.FormatTRX
+	case isJSON(path):
+		return s.jsonFormats[path]
+	default:
+		return report.FormatJUnit
+	}
+}
+
+// reportTarPath returns the path of the report in the tarball. Reports that
+// are converted into JUnit XML get an additional .xml extension.
+func (s *Submit) reportTarPath(path string) string {
+	tarPath, ok := s.retryReports[path]
+	if !ok {
+		tarPath = fmt.Sprintf("test_results/%s", path)
+	}
+	if s.reportFormat(path) != report.FormatJUnit {
+		tarPath += ".xml"
+	}
+
+	return tarPath
+}
+
+// gitHubCommitResolver returns a resolver for looking up commits via the GitHub
+// API if BUILDPULSE_GITHUB_TOKEN is set, or nil otherwise. The repository is
+// given by BUILDPULSE_REPO_NAME_WITH_OWNER (e.g., from -repo-name-with-owner)
+// or GITHUB_REPOSITORY, and the API by GITHUB_API_URL (e.g., for GitHub
+// Enterprise Server).
+func (s *Submit) gitHubCommitResolver(envs map[string]string) metadata.CommitResolver {
+	token := envs["BUILDPULSE_GITHUB_TOKEN"]
+	if token == "" {
+		return nil
+	}
+
+	repo := envs["BUILDPULSE_REPO_NAME_WITH_OWNER"]
+	if repo == "" {
+		repo = envs["GITHUB_REPOSITORY"]
+	}
+	if repo == "" {
+		s.logger.Printf("Ignoring BUILDPULSE_GITHUB_TOKEN: unable to determine the GitHub repository (set GITHUB_REPOSITORY or use -repo-name-with-owner)")
+		return nil
+	}
+
+	apiURL := envs["GITHUB_API_URL"]
+	if apiURL == "" {
+		apiURL = metadata.DefaultGitHubAPIURL
+	}
+
+	s.logger.Printf("Using the GitHub API (%s) to look up commits missing from the git repository", apiURL)
+	return metadata.NewGitHubCommitResolver(s.client, apiURL, repo, token, s.logger)
+}
+
+// nameWithOwnerRegex matches a repository name-with-owner, allowing for nested
+// groups (e.g., "some-group/some-subgroup/some-repo").
+var nameWithOwnerRegex = regexp.MustCompile(`^[^/\s]+(/[^/\s]+)+$`)
+
+// commitSHARegex matches a full SHA-1 commit hash, in either case.
+var commitSHARegex = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// maxCheckNameLength is the maximum length of a check name, in characters.
+const maxCheckNameLength = 255
+
+// normalizeCheckName returns the check name given by -check-name with its
+// whitespace trimmed, and each run of whitespace inside it (e.g., from a matrix
+// variable that's empty) collapsed into a single space. It returns an error if
+// the result is empty, too long, or contains control characters.
+func normalizeCheckName(name string) (string, error) {
+	check := strings.Join(strings.Fields(name), " ")
+
+	switch {
+	case check == "":
+		return "", fmt.Errorf("should not be blank")
+	case utf8.RuneCountInString(check) > maxCheckNameLength:
+		return "", fmt.Errorf("should be at most %d characters", maxCheckNameLength)
+	case strings.IndexFunc(check, unicode.IsControl) >= 0:
+		return "", fmt.Errorf("should not contain control characters")
+	}
+
+	return check, nil
+}
+
+// withEnv returns a copy of envs with key set to value, so that flags can be
+// passed on to the metadata via the environment without modifying the caller's
+// map.
+func withEnv(envs map[string]string, key string, value string) map[string]string {
+	copied := make(map[string]string, len(envs)+1)
+	for k, v := range envs {
+		copied[k] = v
+	}
+	copied[key] = value
+
+	return copied
+}
+
+// initCredentials populates the credentials for uploading to S3 from envs. It
+// returns an error if they're missing.
+func (s *Submit) initCredentials(envs map[string]string) error {
+	id, ok := envs["BUILDPULSE_ACCESS_KEY_ID"]
+	if !ok || id == "" {
+		return fmt.Errorf("missing required environment variable: BUILDPULSE_ACCESS_KEY_ID")
+	}
+	s.credentials.AccessKeyID = id
+
+	key, ok := envs["BUILDPULSE_SECRET_ACCESS_KEY"]
+	if !ok || key == "" {
+		return fmt.Errorf("missing required environment variable: BUILDPULSE_SECRET_ACCESS_KEY")
+	}
+	s.credentials.SecretAccessKey = key
+
+	nextID, nextKey := envs["BUILDPULSE_ACCESS_KEY_ID_NEXT"], envs["BUILDPULSE_SECRET_ACCESS_KEY_NEXT"]
+	switch {
+	case nextID != "" && nextKey != "":
+		s.logger.Printf("Using BUILDPULSE_ACCESS_KEY_ID_NEXT as fallback credentials")
+		s.nextCredentials = &credentials{AccessKeyID: nextID, SecretAccessKey: nextKey}
+	case nextID != "":
+		return fmt.Errorf("missing required environment variable: BUILDPULSE_SECRET_ACCESS_KEY_NEXT")
+	case nextKey != "":
+		return fmt.Errorf("missing required environment variable: BUILDPULSE_ACCESS_KEY_ID_NEXT")
+	}
+
+	return nil
+}
+
+// initBackend determines where to submit the test results. BUILDPULSE_ENV
+// selects the defaults for a BuildPulse environment (production, unless set
+// otherwise), and BUILDPULSE_URL, BUILDPULSE_BUCKET, and BUILDPULSE_S3_ENDPOINT
+// override them individually (e.g., for a self-hosted backend).
+func (s *Submit) initBackend(envs map[string]string) error {
+	name := envs["BUILDPULSE_ENV"]
+	if name == "" {
+		name = "production"
+	}
+	b, ok := backends[name]
+	if !ok {
+		return fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_ENV: supported values are: %s", name, strings.Join(supportedBackends, ", "))
+	}
+
+	s.apiURL = b.apiURL
+	if value := envs["BUILDPULSE_URL"]; value != "" {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_URL: should be an absolute http or https URL", value)
+		}
+		s.apiURL = strings.TrimSuffix(value, "/")
+	}
+
+	s.bucket, ok = envs["BUILDPULSE_BUCKET"]
+	switch {
+	case !ok && s.defaults.Bucket != "":
+		s.logger.Printf("Using embedded default bucket: %s", s.defaults.Bucket)
+		s.bucket = s.defaults.Bucket
+	case !ok:
+		s.bucket = b.bucket
+	}
+
+	if value := envs["BUILDPULSE_S3_ENDPOINT"]; value != "" {
+		s.endpoint = value
+	}
+
+	if name := envs["AWS_CA_BUNDLE"]; name != "" {
+		client, err := withCABundle(s.client, name)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for environment variable AWS_CA_BUNDLE: %v", name, err)
+		}
+		s.client = client
+	}
+
+	s.logger.Printf("Using BuildPulse backend: %s (API: %s, bucket: %s)", name, s.apiURL, s.bucket)
+	switch {
+	case s.endpoint != "" && (s.s3Accelerate || s.s3DualStack):
+		// Acceleration and dual-stack are features of the AWS endpoints; a custom
+		// endpoint is used as-is
+		s.logger.Printf("Using S3 endpoint: %s (ignoring S3 Transfer Acceleration and dual-stack settings)", s.endpoint)
+		s.s3Accelerate, s.s3DualStack = false, false
+	case s.endpoint != "":
+		s.logger.Printf("Using S3 endpoint: %s", s.endpoint)
+	}
+	if s.s3Accelerate {
+		s.logger.Printf("Using S3 Transfer Acceleration")
+	}
+	if s.s3DualStack {
+		s.logger.Printf("Using dual-stack S3 endpoint")
+	}
+
+	return nil
+}
+
+// Run packages up the test results and sends them to BuildPulse. It returns the
+// key that uniquely identifies the uploaded object. With -best-effort-exit, a
+// failure is logged and described in a receipt instead of being returned, and
+// the key is empty.
+func (s *Submit) Run() (key string, err error) {
+	// Deferred first, so that it also covers a failure to write the audit
+	if s.bestEffortExit {
+		defer func() {
+			if err != nil {
+				s.ignoreFailure(err)
+				key, err = "", nil
+			}
+		}()
+	}
+
+	if s.audit != nil {
+		defer func() {
+			if werr := s.audit.writeFile(s.networkAuditPath); werr != nil && err == nil {
+				err = fmt.Errorf("unable to write network audit: %v", werr)
+			}
+		}()
+	}
+
+	// Deferred after the audit, so that the audit records the ping
+	ping := s.newPing()
+	if s.telemetry && !s.dryRun {
+		start := time.Now()
+		defer func() {
+			ping.DurationMillis = time.Since(start).Milliseconds()
+			s.sendPing(ping, err)
+		}()
+	}
+
+	ctx, cancel := withTimeout(context.Background(), s.timeout, fmt.Errorf("submission timed out after %s (-timeout)", s.timeout))
+	defer cancel()
+
+	// The API URL is set by Init; without it, there's nowhere to check
+	ping.FailureClass = telemetry.FailureVersionCheck
+	if s.versionCheck && !s.dryRun && s.apiURL != "" {
+		if err := s.checkVersion(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	ping.FailureClass = telemetry.FailureBundle
+	bundleCtx, cancelBundle := withTimeout(ctx, s.bundleTimeout, fmt.Errorf("bundling timed out after %s (-bundle-timeout)", s.bundleTimeout))
+	defer cancelBundle()
+	start := time.Now()
+	tarpath, err := s.bundle(bundleCtx)
+	ping.BundleMillis = time.Since(start).Milliseconds()
+	if err != nil {
+		return "", timeoutCause(bundleCtx, err)
+	}
+
+	ping.FailureClass = telemetry.FailureCompress
+	s.logger.Debugf("Gzipping tarball (%s)", tarpath)
+	zippath, err := toGz(tarpath)
+	if err == nil {
+		// Compressing the bundle can't be interrupted, but counts toward
+		// -bundle-timeout all the same
+		err = bundleCtx.Err()
+	}
+	if err != nil {
+		return "", timeoutCause(bundleCtx, err)
+	}
+	cancelBundle()
+
+	if s.simulate == simulatePartialBundle {
+		s.logger.Printf("Keeping only the first half of %s (-simulate partial-bundle)", zippath)
+		zippath, err = truncateBundle(zippath)
 		if err != nil {
 			return "", err
 		}
 	}
 
+	info, err := os.Stat(zippath)
+	if err != nil {
+		return "", err
+	}
+	s.result.BundleBytes = info.Size()
+	s.result.Reports = len(s.paths)
+	s.result.CIProvider = s.ciProvider
+
+	if s.dryRun {
+		s.result.DryRun = true
+		return "", s.describeBundle(tarpath, zippath)
+	}
+
+	ping.FailureClass = telemetry.FailureUpload
+	s.logger.Printf("Sending %s to BuildPulse", zippath)
+	uploadCtx, cancelUpload := withTimeout(ctx, s.uploadTimeout, fmt.Errorf("upload timed out after %s (-upload-timeout)", s.uploadTimeout))
+	defer cancelUpload()
+	start = time.Now()
+	key, err = s.upload(uploadCtx, zippath)
+	ping.UploadMillis = time.Since(start).Milliseconds()
+	if err != nil {
+		return "", timeoutCause(uploadCtx, err)
+	}
+	s.logger.Printf("Delivered test results to BuildPulse (%s)", key)
+	s.result.Key = key
+
+	return key, nil
+}
+
+// bundle gathers the artifacts expected by BuildPulse, creates a tarball
+// containing those artifacts, and returns the path of the resulting file. It
+// stops early, returning an error, if ctx is done.
+func (s *Submit) bundle(ctx context.Context) (string, error) {
+	// Prepare the metadata file
+	//////////////////////////////////////////////////////////////////////////////
+
+	s.logger.Printf("Gathering metadata to describe the build")
+	tags := s.tags()
+	now := time.Now
+	if !s.timestampOverride.IsZero() {
+		if s.backfillFrom != "" {
+			s.logger.Printf("Using the timestamp of the original submission (from %s) as the timestamp of the backfill: %s", s.backfillFrom, s.timestampOverride.Format(time.RFC3339))
+		} else {
+			s.logger.Printf("Using value of -timestamp-override flag as the timestamp of the submission: %s", s.timestampOverride.Format(time.RFC3339))
+		}
+		now = func() time.Time { return s.timestampOverride }
+	}
+	meta, err := metadata.NewMetadata(ctx, s.version, s.envs, tags, s.quotaID, s.commitResolver, now, s.logger)
+	if err != nil {
+		return "", err
+	}
+	if err := meta.CommitLookupError(); err != nil && s.strictCommitResolution {
+		return "", fmt.Errorf("commit lookup unsuccessful: %v", err)
+	}
+
+	if err := meta.Enrich(ctx, s.enrichers); err != nil {
+		return "", err
+	}
+	s.ciProvider = meta.CIProvider
+
+	plugin, err := s.detectRetryPlugin()
+	if err != nil {
+		return "", err
+	}
+	if plugin != nil {
+		s.logger.Printf("Detected retry plugin: %s (max retries: %d)", plugin.Name, plugin.MaxRetries)
+		meta.RetryPlugin = plugin.Name
+		meta.RetryPluginMaxRetries = plugin.MaxRetries
+	}
+
+	if len(s.meta) > 0 {
+		meta.CustomMetadata = s.meta
+	}
+
+	// -shard-total is at least 1 whenever the shard flags are given
+	if s.shardTotal > 0 {
+		index, total := s.shardIndex, s.shardTotal
+		meta.ShardIndex, meta.ShardTotal = &index, &total
+	}
+
+	if versions := s.detectHarnessVersions(); len(versions) > 0 {
+		s.logger.Printf("Detected test harness versions: %v", versions)
+		meta.HarnessVersions = versions
+	}
+
+	if s.shardRegex != nil {
+		if err := s.analyzeShardTimes(meta); err != nil {
+			return "", err
+		}
+	}
+
+	if s.testExitStatus != nil {
+		status := *s.testExitStatus
+		meta.TestCommandExitStatus = &status
+		meta.TestCommandSeconds = s.testDuration.Seconds()
+	}
+
+	for i, r := range s.testRetries {
+		meta.TestRetryExitStatuses = append(meta.TestRetryExitStatuses, r.exitStatus)
+		for p := range r.reports {
+			if meta.TestRetryReports == nil {
+				meta.TestRetryReports = make(map[string]int)
+			}
+			meta.TestRetryReports[s.reportTarPath(p)] = i + 1
+		}
+	}
+
+	if s.backfill {
+		meta.Backfill = true
+		s.logger.Printf("Skipping the check for stale reports, since this is a backfill")
+	} else {
+		s.checkReportTimes(meta)
+	}
+
+	if s.resolveBaseBranch {
+		s.initBaseBranch(meta)
+	}
+
+	// The -tree flag stands in for the repository, so there's no checkout to
+	// inspect for submodules
+	if s.tree == "" {
+		s.detectSubmodules(meta)
+	}
+
+	meta.KeyScheme = s.keyScheme
+	meta.Project = s.project
+
+	for _, p := range s.paths {
+		if meta.ReportFormats == nil {
+			meta.ReportFormats = make(map[string]string)
+		}
+		meta.ReportFormats[s.reportTarPath(p)] = s.reportFormat(p)
+	}
+
+	for p, attempt := range s.attempts {
+		if meta.ReportAttempts == nil {
+			meta.ReportAttempts = make(map[string]int)
+		}
+		meta.ReportAttempts[fmt.Sprintf("test_results/%s", p)] = attempt
+	}
+
 	// if coverage file paths are not provided, we infer them
 	var coveragePaths = s.coveragePaths
 	if len(coveragePaths) == 0 && !s.disableCoverageAutoDiscovery {
 		coveragePaths, err = s.coveragePathsInferred()
+		if err != nil {
+			coveragePaths = nil
+		}
 	}
 
-	if err == nil && len(coveragePaths) > 0 {
-		for _, p := range coveragePaths {
-			internalPath := fmt.Sprintf("coverage/%s", p)
-			s.logger.Printf("- %s", p)
-			err = t.Write(p, internalPath)
-			if err != nil {
-				return "", err
+	// LCOV files converted from other coverage formats
+	var lcovPaths []struct{ path, tarPath string }
+	for _, conv := range []struct {
+		enabled bool
+		lcovConversion
+	}{
+		{s.convertSimpleCov, simplecovConversion},
+		{s.convertExcoveralls, excoverallsConversion},
+	} {
+		if !conv.enabled {
+			continue
+		}
+		var lcovPath string
+		coveragePaths, lcovPath, err = s.toLCOV(coveragePaths, conv.lcovConversion)
+		if err != nil {
+			return "", err
+		}
+		if lcovPath != "" {
+			lcovPaths = append(lcovPaths, struct{ path, tarPath string }{lcovPath, conv.tarPath})
+		}
+	}
+
+	coverageDeltas := make(map[string]string)
+	if s.coverageBaseline != "" && len(coveragePaths) > 0 {
+		coverageDeltas, err = s.coverageDeltas(coveragePaths)
+		if err != nil {
+			return "", err
+		}
+		if len(coverageDeltas) > 0 {
+			meta.CoverageBaseline = s.coverageBaselineRef
+			meta.CoverageDeltas = make(map[string]string)
+			for p := range coverageDeltas {
+				meta.CoverageDeltas[coverageDeltaTarPath(p)] = coverageTarPath(p)
 			}
 		}
 	}
 
-	// Write the metadata file to the tarfile
-	//////////////////////////////////////////////////////////////////////////////
+	yaml, err := meta.MarshalYAML()
+	if err != nil {
+		return "", err
+	}
+
+	yamlfile, err := os.CreateTemp("", "buildpulse-*.yml")
+	if err != nil {
+		return "", err
+	}
+	defer yamlfile.Close()
+
+	s.logger.Debugf("Writing metadata to %s", yamlfile.Name())
+	_, err = yamlfile.Write(yaml)
+	if err != nil {
+		return "", err
+	}
+
+	// Initialize the tarfile for writing
+	//////////////////////////////////////////////////////////////////////////////
+
+	f, err := os.CreateTemp("", "buildpulse-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	t := tar.Create(f)
+	defer t.Close()
+
+	// With -sign-key or -sign-keyless, every file written to the tarball is
+	// listed in the manifest that's signed
+	signing := s.signer != nil || s.signKeyless
+	var m manifest
+	write := func(src string, internalPath string) error {
+		if err := t.Write(src, internalPath); err != nil {
+			return err
+		}
+		if signing {
+			return m.add(src, internalPath)
+		}
+		return nil
+	}
+
+	// Write the XML reports to the tarfile
+	//////////////////////////////////////////////////////////////////////////////
+
+	var php *report.PHPNormalizer
+	if s.framework == frameworkPest || s.framework == frameworkPHPUnit {
+		php, err = report.NewPHPNormalizer(s.repositoryPath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// The digest covers what identifies the test results, leaving out the
+	// metadata and log, which differ between otherwise identical attempts
+	digest := sha256.New()
+	addIdentityToDigest(digest, meta)
+
+	s.logger.Printf("Preparing tarball of test results:")
+	for _, p := range s.paths {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		s.logger.Debugf("- %s", p)
+		src := p
+		internalPath := s.reportTarPath(p)
+
+		switch format := s.reportFormat(p); format {
+		case report.FormatJUnit:
+			src, err = s.transcodeReport(p)
+			if err == nil {
+				src, err = s.unwrapNestedReport(p, src)
+			}
+			if err == nil && php != nil {
+				src, err = rewriteReport(src, php.Normalize)
+			}
+		default:
+			convert, _ := report.Converter(format)
+			src, err = convertReport(p, convert)
+		}
+		if err == nil && s.truncateFailuresKB > 0 {
+			src, err = s.truncateFailures(p, src)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		err = write(src, internalPath)
+		if err != nil {
+			return "", err
+		}
+		if err := addToDigest(digest, src, internalPath); err != nil {
+			return "", err
+		}
+	}
+
+	for _, p := range coveragePaths {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		internalPath := coverageTarPath(p)
+		s.logger.Debugf("- %s", p)
+		if deltaPath, ok := coverageDeltas[p]; ok {
+			err = write(deltaPath, coverageDeltaTarPath(p))
+		} else {
+			err = write(p, internalPath)
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := addToDigest(digest, p, internalPath); err != nil {
+			return "", err
+		}
+	}
+	for _, l := range lcovPaths {
+		s.logger.Debugf("- %s", l.tarPath)
+		err = write(l.path, l.tarPath)
+		if err != nil {
+			return "", err
+		}
+		if err := addToDigest(digest, l.path, l.tarPath); err != nil {
+			return "", err
+		}
+	}
+	s.digest = hex.EncodeToString(digest.Sum(nil))
+	s.result.CoverageFiles = len(coveragePaths) + len(lcovPaths)
+
+	// Write the metadata file to the tarfile
+	//////////////////////////////////////////////////////////////////////////////
+
+	s.logger.Debugf("Adding buildpulse.yml to tarball")
+	err = write(yamlfile.Name(), "buildpulse.yml")
+	if err != nil {
+		return "", err
+	}
+
+	// Write the log to the tarfile
+	//////////////////////////////////////////////////////////////////////////////
+
+	logfile, err := os.CreateTemp("", "buildpulse-*.log")
+	if err != nil {
+		return "", err
+	}
+	defer logfile.Close()
+
+	// The log may echo secrets (e.g., in the output of a failed git command)
+	s.logger.Debugf("Flushing log to %s", logfile.Name())
+	_, err = logfile.Write([]byte(logger.Redact(s.logger.Text(), s.envs)))
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Debugf("Adding buildpulse.log to tarball")
+	err = write(logfile.Name(), "buildpulse.log")
+	if err != nil {
+		return "", err
+	}
+
+	// Write the signed manifest to the tarfile
+	//////////////////////////////////////////////////////////////////////////////
+
+	if signing {
+		manifestPath, sigPath, certPath, err := s.signManifest(&m)
+		if err != nil {
+			return "", err
+		}
+		if err := t.Write(manifestPath, manifestTarPath); err != nil {
+			return "", err
+		}
+		if err := t.Write(sigPath, manifestSignatureTarPath); err != nil {
+			return "", err
+		}
+		if certPath != "" {
+			if err := t.Write(certPath, manifestCertificateTarPath); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return f.Name(), nil
+}
+
+// shardImbalanceRatio is the ratio of the slowest shard's duration to the
+// fastest shard's duration above which the shards are considered imbalanced.
+const shardImbalanceRatio = 2.0
+
+// shardTiming is the content of the file written for -shard-timing-file.
+type shardTiming struct {
+	// Shards holds the total duration in seconds of the suites in each shard's
+	// reports, keyed by the shard identified by -shard-pattern.
+	Shards map[string]float64 `json:"shards"`
+
+	// Ratio is the ratio of the slowest shard's duration to the fastest's.
+	Ratio float64 `json:"ratio"`
+
+	// Suggested is an assignment of the suites to the same number of shards
+	// that would balance their durations.
+	Suggested []report.ShardPlan `json:"suggested"`
+}
+
+// analyzeShardTimes totals the durations of the suites in the reports from each
+// shard, records them in meta, and warns if the shards are badly imbalanced.
+// Reports whose paths don't match -shard-pattern are left out.
+func (s *Submit) analyzeShardTimes(meta *metadata.Metadata) error {
+	shards := make(map[string]float64)
+	suites := make(map[string]float64)
+
+	for _, p := range s.paths {
+		if !isXML(p) {
+			continue
+		}
+
+		// Retries rerun only the failed tests, so they'd skew the shard's time
+		if _, ok := s.retryReports[p]; ok {
+			continue
+		}
+
+		m := s.shardRegex.FindStringSubmatch(filepath.ToSlash(p))
+		if m == nil {
+			s.logger.Printf("Skipping %s for shard timing: path doesn't match -shard-pattern", p)
+			continue
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		times, err := report.ReadSuiteTimes(f)
+		f.Close()
+		if err != nil {
+			// Leave malformed reports for BuildPulse to diagnose
+			continue
+		}
+
+		if _, ok := shards[m[1]]; !ok {
+			shards[m[1]] = 0
+		}
+		for name, secs := range times {
+			shards[m[1]] += secs
+			suites[name] += secs
+		}
+	}
+
+	if len(shards) == 0 {
+		s.logger.Printf("No reports match -shard-pattern: %s", s.shardPattern)
+		return nil
+	}
+	meta.ShardTimes = shards
+
+	timing := &shardTiming{Shards: shards}
+	min, max := math.Inf(1), 0.0
+	for _, secs := range shards {
+		min = math.Min(min, secs)
+		max = math.Max(max, secs)
+	}
+	if min > 0 {
+		timing.Ratio = max / min
+	}
+	timing.Suggested = report.Rebalance(suites, len(shards))
+
+	if len(shards) > 1 && (min == 0 || timing.Ratio > shardImbalanceRatio) {
+		var ids []string
+		for id := range shards {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		s.logger.Printf("⚠️ Shards are imbalanced: the slowest shard took %.1fs and the fastest took %.1fs", max, min)
+		for _, id := range ids {
+			s.logger.Printf("  Shard %s: %.1fs", id, shards[id])
+		}
+		slowest := 0.0
+		for _, plan := range timing.Suggested {
+			slowest = math.Max(slowest, plan.Seconds)
+		}
+		s.logger.Printf("A rebalanced split of the test suites would take at most %.1fs per shard", slowest)
+	}
+
+	if s.shardTimingFile != "" {
+		data, err := json.MarshalIndent(timing, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(s.shardTimingFile, data, 0644); err != nil {
+			return err
+		}
+		s.logger.Printf("Wrote shard timing to %s", s.shardTimingFile)
+	}
+
+	return nil
+}
+
+// detectSubmodules records the revision of each of the repository's
+// submodules in meta, so that test failures can be correlated with submodule
+// updates. Failing to read the submodules is logged rather than returned.
+func (s *Submit) detectSubmodules(meta *metadata.Metadata) {
+	rpath := s.repositoryPath
+	if rpath == "" {
+		rpath = "."
+	}
+
+	subs, err := metadata.Submodules(rpath)
+	if err != nil {
+		s.logger.Printf("⚠️ Unable to read submodules of repository at %s: %v", rpath, err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	s.logger.Printf("Detected submodules: %v", subs)
+	meta.Submodules = subs
+}
+
+// initBaseBranch records the base branch of the build in meta, along with the
+// merge base of the commit and the base branch. The base branch comes from the
+// CI provider if it reports one, and otherwise from the repository (see
+// metadata.ResolveBaseBranch). Since the base branch is only informational, a
+// failure to resolve it (e.g., in a shallow clone) is logged rather than
+// returned.
+func (s *Submit) initBaseBranch(meta *metadata.Metadata) {
+	branch := meta.ProviderBaseBranch()
+	if branch != "" {
+		s.logger.Printf("Using base branch reported by CI provider: %s", branch)
+		meta.BaseBranch = branch
+	}
+
+	branch, mergeBase, err := metadata.ResolveBaseBranch(s.repositoryPath, meta.CommitSHA, branch)
+	if err != nil {
+		s.logger.Printf("⚠️ Unable to resolve base branch from repository at %s: %v", s.repositoryPath, err)
+		return
+	}
+
+	s.logger.Printf("Resolved base branch %s with merge base %s", branch, mergeBase)
+	meta.BaseBranch = branch
+	meta.MergeBase = mergeBase
+}
+
+// staleReportTolerance is how far a report's timestamp can predate the commit
+// or the start of the build before the report is considered stale, to allow
+// for clock skew between the machines involved.
+const staleReportTolerance = 5 * time.Minute
+
+// checkReportTimes compares the timestamps of the XML reports with the time of
+// the commit and the start of the build, and records the reports that predate
+// either of them in meta (e.g., reports left behind by an earlier build, or
+// restored from a cache). Reports without timestamps aren't checked.
+func (s *Submit) checkReportTimes(meta *metadata.Metadata) {
+	committedAt := meta.CommittedAt
+	if committedAt.IsZero() {
+		committedAt = meta.AuthoredAt
+	}
+	startedAt := meta.BuildStartedAt()
+
+	for _, p := range s.paths {
+		ts, ok := s.reportTimes[p]
+		if !ok {
+			continue
+		}
+
+		var reason string
+		switch {
+		case !committedAt.IsZero() && ts.Before(committedAt.Add(-staleReportTolerance)):
+			reason = "predates_commit"
+			s.logger.Printf("⚠️ %s predates the commit (report timestamp %s, committed at %s); it may be a stale artifact from an earlier build", p, ts.Format(time.RFC3339), committedAt.Format(time.RFC3339))
+		case !startedAt.IsZero() && ts.Before(startedAt.Add(-staleReportTolerance)):
+			reason = "predates_build_start"
+			s.logger.Printf("⚠️ %s predates the start of the build (report timestamp %s, build started at %s); it may be a stale artifact from an earlier build", p, ts.Format(time.RFC3339), startedAt.Format(time.RFC3339))
+		default:
+			continue
+		}
+
+		if meta.StaleReports == nil {
+			meta.StaleReports = make(map[string]string)
+		}
+		meta.StaleReports[s.reportTarPath(p)] = reason
+	}
+}
+
+// detectAttempts finds the XML reports that were produced by successive
+// attempts of a retried CI step (i.e., reports of the same suites and tests at
+// different times). With -dedupe-attempts=latest, the reports from all but the
+// latest attempt are skipped; otherwise, each report is numbered by its
+// attempt.
+func (s *Submit) detectAttempts() error {
+	s.attempts = make(map[string]int)
+	s.reportTimes = make(map[string]time.Time)
+
+	sigs := make(map[string]*report.Signature)
+	for _, p := range s.paths {
+		if !isXML(p) {
+			continue
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		sig, err := report.ReadSignature(f)
+		f.Close()
+		if err != nil {
+			// Leave malformed reports for BuildPulse to diagnose
+			continue
+		}
+
+		if !sig.Timestamp.IsZero() {
+			s.reportTimes[p] = sig.Timestamp
+		}
+
+		// Fall back to the time the report was written
+		if sig.Timestamp.IsZero() {
+			info, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			sig.Timestamp = info.ModTime()
+		}
+
+		sigs[p] = sig
+	}
+
+	skipped := make(map[string]bool)
+	for _, group := range report.GroupAttempts(sigs) {
+		s.logger.Printf("Detected %d attempts of the same tests: %s", len(group), strings.Join(group, ", "))
+
+		for i, p := range group {
+			if s.dedupeAttempts == dedupeAttemptsLatest && i < len(group)-1 {
+				s.logger.Printf("Skipping %s: superseded by a later attempt", p)
+				skipped[p] = true
+				continue
+			}
+			s.attempts[p] = i + 1
+		}
+	}
+
+	if len(skipped) > 0 {
+		var paths []string
+		for _, p := range s.paths {
+			if !skipped[p] {
+				paths = append(paths, p)
+			}
+		}
+		s.paths = paths
+	}
+
+	return nil
+}
+
+// addRetryReports adds the reports written by retries of the failed tests (see
+// AddTestRetry) to those to submit. They report the same suites as the test
+// command, so they're added after detectAttempts, which would otherwise take
+// them for attempts of a retried CI step.
+func (s *Submit) addRetryReports() {
+	s.retryReports = make(map[string]string)
+
+	for i, r := range s.testRetries {
+		var paths []string
+		for p := range r.reports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		for _, p := range paths {
+			s.retryReports[p] = fmt.Sprintf("test_results/retry-%d/%s", i+1, r.reports[p])
+			s.paths = append(s.paths, p)
+		}
+	}
+
+	if len(s.retryReports) > 0 {
+		s.logger.Printf("Found %d test reports from %d retries of the failed tests", len(s.retryReports), len(s.testRetries))
+	}
+}
+
+// detectRetryPlugin returns the retry plugin in use according to the first XML
+// report that shows evidence of one, or nil if none of the reports do.
+func (s *Submit) detectRetryPlugin() (*report.RetryPlugin, error) {
+	for _, p := range s.paths {
+		if !isXML(p) {
+			continue
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		plugin, err := report.DetectRetryPlugin(f)
+		f.Close()
+		if err != nil {
+			// Leave malformed reports for BuildPulse to diagnose
+			s.logger.Printf("Unable to inspect %s for retry plugins: %v", p, err)
+			continue
+		}
+
+		if plugin != nil {
+			if plugin.Name == report.RetryPluginGradle {
+				plugin.MaxRetries = report.GradleMaxRetries(s.repositoryPath)
+			}
+			return plugin, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// detectHarnessVersions returns the versions of the test harnesses recorded in
+// the XML reports, keyed by harness name. Harnesses that none of the reports
+// mention are looked up in the lockfiles and module files of the repository
+// (e.g., Gemfile.lock), which may be less precise than what actually ran.
+func (s *Submit) detectHarnessVersions() map[string]string {
+	versions := make(map[string]string)
+
+	for _, p := range s.paths {
+		if !isXML(p) {
+			continue
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		found, err := report.ReadHarnessVersions(f)
+		f.Close()
+		if err != nil {
+			// Leave malformed reports for BuildPulse to diagnose
+			s.logger.Printf("Unable to inspect %s for test harness versions: %v", p, err)
+			continue
+		}
+
+		for harness, version := range found {
+			if _, ok := versions[harness]; !ok {
+				versions[harness] = version
+			}
+		}
+	}
+
+	for harness, version := range report.ProbeHarnessVersions(s.repositoryPath) {
+		if _, ok := versions[harness]; !ok {
+			versions[harness] = version
+		}
+	}
+
+	return versions
+}
+
+// expandPathArgs replaces the variables (e.g., ${BUILDKITE_PARALLEL_JOB}) in
+// each path in args with their values in envs. A variable that isn't set is
+// replaced with an empty string, unless -strict-path-vars is set, in which case
+// it's an error.
+func (s *Submit) expandPathArgs(args []string, envs map[string]string) ([]string, error) {
+	var paths []string
+
+	for _, arg := range args {
+		var unresolved []string
+		path := os.Expand(arg, func(name string) string {
+			value, ok := envs[name]
+			if !ok {
+				unresolved = append(unresolved, name)
+			}
+			return value
+		})
+
+		if len(unresolved) > 0 {
+			if s.strictPathVars {
+				return nil, fmt.Errorf("invalid value \"%s\" for path: unresolved variable $%s", arg, unresolved[0])
+			}
+			s.logger.Printf("Variables in %s are not set and will be treated as empty: $%s", arg, strings.Join(unresolved, ", $"))
+		}
+		if path != arg {
+			s.logger.Debugf("Expanded path %s to %s", arg, path)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// jsonFormat returns the format of the JSON test report at the given path: the
+// value of the -format flag, if given and the path was named explicitly, or
+// else the format detected from the shape of the report. It returns an empty
+// string if the file isn't a recognized test report. The JSON files found in a
+// directory or by a glob pattern are always detected, so that -format doesn't
+// force unrelated files (e.g., package.json) through the converter.
+func (s *Submit) jsonFormat(path string, named bool) (string, error) {
+	if s.format != "" && named {
+		return s.format, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	format := report.DetectJSONFormat(f)
+	if format != "" {
+		s.logger.Debugf("Detected %s report: %s", format, path)
+	}
+
+	return format, nil
+}
+
+// objectKey returns the S3 key to upload the bundle to.
+func (s *Submit) objectKey() string {
+	if s.keyScheme == keySchemeDigest {
+		return fmt.Sprintf("%d/%d/buildpulse-sha256-%s.gz", s.accountID, s.repositoryID, s.digest)
+	}
+
+	return fmt.Sprintf("%d/%d/buildpulse-%s.gz", s.accountID, s.repositoryID, s.idgen())
+}
+
+// upload transmits the file at the given path to S3. If the credentials are
+// rejected and fallback credentials are configured (e.g., during an access key
+// rotation), it retries the upload with the fallback credentials.
+func (s *Submit) upload(ctx context.Context, path string) (string, error) {
+	key := s.objectKey()
+
+	objectMetadata := map[string]string{
+		"reporter-version": s.version.Number,
+		"schema-version":   bundleLayoutVersion,
+	}
+	if s.keyScheme != "" {
+		objectMetadata["key-scheme"] = s.keyScheme
+	}
+	if s.ciProvider != "" {
+		objectMetadata["ci-provider"] = s.ciProvider
+	}
+	// So that the shards of a build can be told apart without downloading them
+	if s.shardTotal > 0 {
+		objectMetadata["shard-index"] = fmt.Sprint(s.shardIndex)
+		objectMetadata["shard-total"] = fmt.Sprint(s.shardTotal)
+	}
+	if s.manifestSignature != "" {
+		objectMetadata["manifest-sha256"] = s.manifestDigest
+		objectMetadata["manifest-signature"] = s.manifestSignature
+	}
+
+	if s.simulate == simulateUploadFailure {
+		return "", errSimulatedUploadFailure
+	}
+
+	accelerate := s.s3Accelerate
+	acl := true
+	put := func(creds credentials) error {
+		err := s.putS3Object(ctx, creds, key, path, objectMetadata, accelerate, acl)
+		if err != nil && accelerate && isAccelerateError(err) {
+			s.logger.Printf("S3 Transfer Acceleration is unavailable for bucket %s (%v); retrying without it", s.bucket, err)
+			accelerate = false
+			err = s.putS3Object(ctx, creds, key, path, objectMetadata, false, acl)
+		}
+		if err != nil && acl && isACLNotSupportedError(err) {
+			// Buckets whose Object Ownership is BucketOwnerEnforced reject
+			// requests that set any ACL, but the bucket owner already owns the
+			// object, so the ACL isn't needed
+			s.logger.Printf("Bucket %s doesn't allow ACLs (%v); retrying without the bucket-owner-full-control ACL", s.bucket, err)
+			acl = false
+			err = s.putS3Object(ctx, creds, key, path, objectMetadata, accelerate, false)
+		}
+		return err
+	}
+
+	err := put(s.credentials)
+	if err != nil && s.nextCredentials != nil && isAuthError(err) {
+		s.logger.Printf("Credentials from BUILDPULSE_ACCESS_KEY_ID were rejected (%v); retrying with BUILDPULSE_ACCESS_KEY_ID_NEXT", err)
+		err = put(*s.nextCredentials)
+	}
+	if err != nil {
+		return "", s.explainS3Error(err, acl)
+	}
+
+	return key, nil
+}
+
+// addIdentityToDigest writes what identifies the job that produced the test
+// results to digest: the commit, check, and project, and what tells apart the
+// jobs of a matrix or the shards of a check that share them (the tags, custom
+// metadata, shard, and quota), so that their uploads don't overwrite each
+// other. The latter are only written if given, so that the digest of a job
+// without them is unchanged.
+func addIdentityToDigest(digest hash.Hash, meta *metadata.Metadata) {
+	fmt.Fprintf(digest, "%s\x00%s\x00%s\x00%s\x00", meta.CommitSHA, meta.TreeSHA, meta.Check, meta.Project)
+
+	if len(meta.Tags) > 0 {
+		tags := append([]string(nil), meta.Tags...)
+		sort.Strings(tags)
+		fmt.Fprintf(digest, "tags\x00%d\x00%s\x00", len(tags), strings.Join(tags, "\x00"))
+	}
+	if len(meta.CustomMetadata) > 0 {
+		keys := make([]string, 0, len(meta.CustomMetadata))
+		for k := range meta.CustomMetadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(digest, "meta\x00%d\x00", len(keys))
+		for _, k := range keys {
+			fmt.Fprintf(digest, "%s\x00%s\x00", k, meta.CustomMetadata[k])
+		}
+	}
+	if meta.ShardIndex != nil && meta.ShardTotal != nil {
+		fmt.Fprintf(digest, "shard\x00%d\x00%d\x00", *meta.ShardIndex, *meta.ShardTotal)
+	}
+	if meta.QuotaID != "" {
+		fmt.Fprintf(digest, "quota\x00%s\x00", meta.QuotaID)
+	}
+}
+
+// addToDigest writes the path of a file in the bundle and the content of the
+// file at src to digest.
+func addToDigest(digest hash.Hash, src string, internalPath string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(digest, "%s\x00%d\x00", internalPath, info.Size())
+	_, err = io.Copy(digest, f)
+	return err
+}
+
+// convertReport converts the report at the named path (src) into JUnit XML
+// using the given conversion function, and returns the path of the resulting
+// file.
+func convertReport(src string, convert func(io.Reader) (*report.Testsuites, error)) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	suites, err := convert(in)
+	if err != nil {
+		return "", fmt.Errorf("unable to convert %s: %v", src, err)
+	}
+
+	data, err := report.Marshal(suites)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.CreateTemp("", "buildpulse-*.xml")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	_, err = out.Write(data)
+	return out.Name(), err
+}
+
+// rewriteReport copies the report at the named path (src) using the given
+// rewrite function, and returns the path of the resulting file.
+func rewriteReport(src string, rewrite func(io.Reader, io.Writer) error) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp("", "buildpulse-*.xml")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if err := rewrite(in, out); err != nil {
+		return "", fmt.Errorf("unable to process %s: %v", src, err)
+	}
+
+	return out.Name(), nil
+}
 
-	s.logger.Printf("Adding buildpulse.yml to tarball")
-	err = t.Write(yamlfile.Name(), "buildpulse.yml")
+// truncateFailures returns the path of a copy of the XML report at the named
+// path (src), which is a copy of the report at p, in which the failures and
+// errors longer than twice the -truncate-failures limit are truncated, or src
+// itself if none are. A report that the XML decoder rejects (e.g., one with
+// ANSI escapes in its output, which BuildPulse accepts) is submitted as is,
+// with a warning, rather than failing the submission.
+func (s *Submit) truncateFailures(p string, src string) (string, error) {
+	in, err := os.Open(src)
 	if err != nil {
 		return "", err
 	}
+	defer in.Close()
 
-	// Write the log to the tarfile
-	//////////////////////////////////////////////////////////////////////////////
-
-	logfile, err := os.CreateTemp("", "buildpulse-*.log")
+	out, err := os.CreateTemp("", "buildpulse-*.xml")
 	if err != nil {
 		return "", err
 	}
-	defer logfile.Close()
+	defer out.Close()
 
-	s.logger.Printf("Flushing log to %s", logfile.Name())
-	_, err = logfile.Write([]byte(s.logger.Text()))
+	n, err := report.TruncateFailures(in, out, s.truncateFailuresKB*1024)
 	if err != nil {
-		return "", err
+		s.logger.Printf("⚠️ Unable to truncate the failures in %s, so it's submitted as is: %v", p, err)
+		os.Remove(out.Name())
+		return src, nil
+	}
+	if n == 0 {
+		os.Remove(out.Name())
+		return src, nil
 	}
 
-	s.logger.Printf("Adding buildpulse.log to tarball")
-	err = t.Write(logfile.Name(), "buildpulse.log")
+	s.logger.Printf("Truncated %d failures in %s, keeping the first and last %d KB of each", n, p, s.truncateFailuresKB)
+	return out.Name(), nil
+}
+
+// transcodeReport returns the path of a UTF-8 copy of the XML report at the
+// named path (src), or src itself if the report is already encoded as UTF-8.
+func (s *Submit) transcodeReport(src string) (string, error) {
+	f, err := os.Open(src)
 	if err != nil {
 		return "", err
 	}
+	defer f.Close()
 
-	return f.Name(), nil
-}
+	enc, err := report.XMLEncoding(f)
+	if err != nil {
+		return "", fmt.Errorf("unable to process %s: %v", src, err)
+	}
+	if report.IsUTF8(enc) {
+		return src, nil
+	}
 
-// upload transmits the file at the given path to S3
-func (s *Submit) upload(path string) (string, error) {
-	key := fmt.Sprintf("%d/%d/buildpulse-%s.gz", s.accountID, s.repositoryID, s.idgen())
+	s.logger.Debugf("Transcoding %s from %s to UTF-8", src, enc)
+	return rewriteReport(src, report.ToUTF8)
+}
 
-	err := putS3Object(s.client, s.credentials.AccessKeyID, s.credentials.SecretAccessKey, s.bucket, key, path)
+// unwrapNestedReport returns the path of a JUnit report holding the test suites
+// of the JUnit reports embedded in the text of the JUnit report at the named
+// path (src), which is a copy of the report at p, in place of the test suites
+// of the wrapper. If src doesn't embed any reports, or -unwrap-nested-reports
+// isn't given, it returns src.
+func (s *Submit) unwrapNestedReport(p string, src string) (string, error) {
+	f, err := os.Open(src)
 	if err != nil {
 		return "", err
 	}
+	defer f.Close()
+
+	nested, err := report.NestedJUnit(f)
+	if err != nil || nested == nil {
+		// Malformed reports are submitted as is, for BuildPulse to reject
+		return src, nil
+	}
 
-	return key, nil
+	if !s.unwrapNestedReports {
+		s.logger.Printf("⚠️ %s embeds %d test suites in its text, which BuildPulse will see as the wrapper's test results instead; use -unwrap-nested-reports to submit the embedded test suites", p, len(nested.Suites))
+		return src, nil
+	}
+
+	s.logger.Printf("Unwrapping %d test suites embedded in %s", len(nested.Suites), p)
+	return convertReport(src, func(io.Reader) (*report.Testsuites, error) { return nested, nil })
 }
 
 // toGz gzips the named file (src) and returns the path of the resulting file.
@@ -381,21 +2350,13 @@ func toGz(src string) (dest string, err error) {
 	return zipfile.Name(), err
 }
 
-// putS3Object puts the named file (src) as an object in the named bucket with the named key.
-func putS3Object(client *http.Client, id string, secret string, bucket string, objectKey string, src string) error {
-	provider := &awscreds.StaticProvider{
-		Value: awscreds.Value{
-			AccessKeyID:     id,
-			SecretAccessKey: secret,
-		},
-	}
-
-	sess, err := session.NewSession(
-		aws.NewConfig().
-			WithCredentials(awscreds.NewCredentials(provider)).
-			WithRegion("us-east-1").
-			WithHTTPClient(client),
-	)
+// putS3Object puts the named file (src) as an object in the bucket with the
+// named key, using the given credentials. The object is labeled as a gzip file
+// and annotated with the given user-defined metadata. If acl is true, the
+// object is given the bucket-owner-full-control ACL. The upload is abandoned if
+// ctx is done first.
+func (s *Submit) putS3Object(ctx context.Context, creds credentials, objectKey string, src string, metadata map[string]string, accelerate bool, acl bool) error {
+	sess, err := s.newS3Session(creds, accelerate)
 	if err != nil {
 		return err
 	}
@@ -406,13 +2367,22 @@ func putS3Object(client *http.Client, id string, secret string, bucket string, o
 	}
 	defer file.Close()
 
-	uploader := s3manager.NewUploader(sess)
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(bucket),
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(objectKey),
-		ACL:    aws.String("bucket-owner-full-control"),
 		Body:   file,
-	})
+		// The object is a gzip file, rather than a gzip-encoded tarball, so it's
+		// labeled with a Content-Type instead of a Content-Encoding (which would
+		// cause HTTP clients to decompress it on download)
+		ContentType: aws.String("application/gzip"),
+		Metadata:    aws.StringMap(metadata),
+	}
+	if acl {
+		input.ACL = aws.String("bucket-owner-full-control")
+	}
+
+	uploader := s3manager.NewUploader(sess)
+	_, err = uploader.UploadWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
@@ -420,6 +2390,133 @@ func putS3Object(client *http.Client, id string, secret string, bucket string, o
 	return nil
 }
 
+// newS3Session returns a session for requests to S3 with the given
+// credentials, via the endpoint configured for the submission.
+func (s *Submit) newS3Session(creds credentials, accelerate bool) (*session.Session, error) {
+	provider := &awscreds.StaticProvider{
+		Value: awscreds.Value{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+		},
+	}
+
+	// The SDK loads AWS_CA_BUNDLE into the client it's given, replacing the
+	// client's transport, which it can't do once the transport is wrapped (e.g.,
+	// by -network-audit). So the session is created with a stand-in, and then
+	// given s.client, which already trusts the bundle (see initBackend).
+	config := aws.NewConfig().
+		WithCredentials(awscreds.NewCredentials(provider)).
+		WithRegion("us-east-1").
+		WithHTTPClient(&http.Client{})
+	if s.endpoint != "" {
+		// S3-compatible servers (e.g., s3test.Server) generally don't support
+		// virtual-hosted-style addressing
+		config = config.WithEndpoint(s.endpoint).WithS3ForcePathStyle(true)
+	}
+	if accelerate {
+		config = config.WithS3UseAccelerate(true)
+	}
+	if s.s3DualStack {
+		config = config.WithUseDualStack(true)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+	sess.Config.HTTPClient = s.client
+
+	return sess, nil
+}
+
+// isAuthError returns true if err indicates that S3 rejected the credentials
+// used for the request; false, otherwise.
+func isAuthError(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		return true
+	default:
+		return false
+	}
+}
+
+// isAccelerateError returns true if err indicates that the upload can't use S3
+// Transfer Acceleration (e.g., because it isn't enabled on the bucket, or the
+// bucket name isn't compatible with it); false, otherwise.
+func isAccelerateError(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "InvalidParameterException":
+		// The SDK rejects bucket names that can't be used in the accelerated
+		// endpoint's hostname (e.g., names containing dots) before sending
+		return true
+	case "InvalidRequest":
+		return strings.Contains(aerr.Message(), "Transfer Acceleration")
+	default:
+		return false
+	}
+}
+
+// isACLNotSupportedError returns true if err indicates that the bucket doesn't
+// allow ACLs (i.e., its Object Ownership is set to BucketOwnerEnforced); false,
+// otherwise.
+func isACLNotSupportedError(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	return aerr.Code() == "AccessControlListNotSupported"
+}
+
+// explainS3Error returns err annotated with what to do about it, for the S3
+// errors that are typical of self-hosted buckets with Object Lock or
+// restrictive policies, whose codes alone don't say what's wrong. acl reports
+// whether the failed upload set the bucket-owner-full-control ACL. Other errors
+// are returned as is.
+func (s *Submit) explainS3Error(err error, acl bool) error {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return err
+	}
+
+	switch aerr.Code() {
+	case "AccessDenied":
+		if !acl {
+			return fmt.Errorf("access to bucket %s was denied: check that the credentials are allowed to s3:PutObject in the bucket: %w", s.bucket, err)
+		}
+		return fmt.Errorf("access to bucket %s was denied: check that the credentials are allowed to s3:PutObject and s3:PutObjectAcl in the bucket, and that the bucket policy doesn't deny the bucket-owner-full-control ACL (or set the bucket's Object Ownership to BucketOwnerEnforced, so that no ACL is needed): %w", s.bucket, err)
+	case "ObjectLockConfigurationNotFoundError":
+		return fmt.Errorf("bucket %s requires uploads to use Object Lock, but Object Lock isn't enabled on it: enable Object Lock on the bucket, or remove the requirement from its policy: %w", s.bucket, err)
+	case "InvalidRequest":
+		if strings.Contains(aerr.Message(), "Object Lock") {
+			return fmt.Errorf("bucket %s has Object Lock enabled with a default retention period, which this upload can't satisfy: remove the default retention from the bucket (e.g., in favor of a lifecycle rule), or upload to a bucket without Object Lock: %w", s.bucket, err)
+		}
+	}
+
+	return err
+}
+
+// contains returns true if values includes v; false, otherwise.
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
 // flagRegex matches args that are flags.
 var flagRegex = regexp.MustCompile("^-")
 
@@ -440,21 +2537,191 @@ func pathsAndFlagsFromArgs(args []string) ([]string, []string) {
 	return args, []string{}
 }
 
+// readPathsFrom returns the paths listed one per line in the named file, or in
+// stdin if name is "-", ignoring blank lines. The paths are taken as is, without
+// expanding variables or glob patterns, since the build systems that write these
+// lists (e.g., Bazel) give exact paths. It returns an error if no paths are
+// listed.
+func (s *Submit) readPathsFrom(name string) ([]string, error) {
+	r := s.stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p := strings.TrimSpace(scanner.Text()); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no paths listed")
+	}
+
+	return paths, nil
+}
+
+// initReports finds the test reports to submit at the paths in pathArgs, the
+// paths listed by -paths-from, and the paths given by -path, and detects which
+// of them are retries. It returns an error if no reports are found (except
+// where releases prior to v0.19.0 allowed it).
+func (s *Submit) initReports(pathArgs []string, envs map[string]string, flagset map[string]bool) error {
+	var err error
+
+	var listedPaths []string
+	if flagset["paths-from"] {
+		listedPaths, err = s.readPathsFrom(s.pathsFrom)
+		if err != nil {
+			return fmt.Errorf("invalid value \"%s\" for flag -paths-from: %v", s.pathsFrom, err)
+		}
+		s.logger.Printf("Read %d paths from %s", len(listedPaths), s.pathsFrom)
+	}
+
+	noPathArgs := len(pathArgs) == 0 && len(listedPaths) == 0
+	switch {
+	case noPathArgs && len(s.pathArgs) > 0:
+		// The reports are given by -path alone
+	case noPathArgs && s.framework == frameworkDotnet:
+		s.logger.Printf("Looking for TRX reports in TestResults directories beneath %s", s.repositoryPath)
+		s.paths, err = trxPathsFromDir(s.repositoryPath, true, s.walkOptions())
+		if err != nil {
+			return err
+		}
+		if len(s.paths) == 0 {
+			return fmt.Errorf("no TRX reports found in TestResults directories beneath %s", s.repositoryPath)
+		}
+	case noPathArgs:
+		return fmt.Errorf("missing TEST_RESULTS_PATH")
+	default:
+		s.jsonFormats = make(map[string]string)
+		if err := s.addReportPaths(pathArgs, s.walkOptions()); err != nil {
+			return err
+		}
+
+		// The listed paths are exact, so they aren't expanded as globs
+		opts := s.walkOptions()
+		opts.literal = true
+		if err := s.addReportPaths(listedPaths, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := s.resolvePathArgs(envs); err != nil {
+		return err
+	}
+
+	switch {
+	case len(s.paths) > 0:
+		s.logger.Printf("Found %d test reports to submit", len(s.paths))
+	case len(listedPaths) > 0:
+		// Name the list rather than its paths, which may number in the thousands
+		if len(pathArgs) > 0 {
+			return fmt.Errorf("no XML reports found at TEST_RESULTS_PATH: %s, or at the %d paths listed in %s (-paths-from)", strings.Join(pathArgs, " "), len(listedPaths), s.pathsFrom)
+		}
+		return fmt.Errorf("no XML reports found at the %d paths listed in %s (-paths-from)", len(listedPaths), s.pathsFrom)
+	case len(pathArgs) > 0:
+		// To maintain backwards compatibility with releases prior to v0.19.0, if
+		// exactly one path was given, and it's a directory, and it contains no XML
+		// reports, continue without erroring (unless -fail-on-empty is set). The
+		// resulting upload will contain *zero* XML reports. In all other
+		// scenarios, treat this as an error.
+		//
+		// TODO: Treat this scenario as an error for the next major version release.
+		info, err := os.Stat(pathArgs[0])
+		isSingleDir := len(pathArgs) == 1 && err == nil && info.IsDir()
+		if !isSingleDir || s.failOnEmpty {
+			return fmt.Errorf("no XML reports found at TEST_RESULTS_PATH: %s", strings.Join(pathArgs, " "))
+		}
+		s.logger.Printf("⚠️ Found 0 test reports to submit: no XML reports found in %s, so the upload will contain no test results. This will be an error in a future release; use -fail-on-empty to make it one now", pathArgs[0])
+	case len(s.pathArgs) > 0:
+		return fmt.Errorf("no reports found for flag -path: %s", s.pathArgs.String())
+	}
+
+	if err := s.detectAttempts(); err != nil {
+		return err
+	}
+	s.addRetryReports()
+
+	return nil
+}
+
+// addReportPaths adds the reports found at the paths in args to s.paths: XML
+// reports, TRX reports (for -framework dotnet), and JSON reports in a
+// recognized format, which are recorded in s.jsonFormats.
+func (s *Submit) addReportPaths(args []string, opts walkOptions) error {
+	xmls, err := xmlPathsFromArgs(args, opts)
+	if err != nil {
+		return err
+	}
+	s.paths = append(s.paths, xmls...)
+
+	if s.framework == frameworkDotnet {
+		trxs, err := trxPathsFromArgs(args, opts)
+		if err != nil {
+			return err
+		}
+		s.paths = append(s.paths, trxs...)
+	}
+
+	named := make(map[string]bool)
+	for _, arg := range args {
+		named[arg] = true
+	}
+
+	jsons, err := jsonPathsFromArgs(args, opts)
+	if err != nil {
+		return err
+	}
+	for _, p := range jsons {
+		format, err := s.jsonFormat(p, named[p])
+		if err != nil {
+			return err
+		}
+		if format == "" {
+			s.logger.Debugf("Skipping %s: not a recognized test report", p)
+			continue
+		}
+		s.jsonFormats[p] = format
+		s.paths = append(s.paths, p)
+	}
+
+	return nil
+}
+
+// XMLPaths returns the XML reports that `submit` finds at the paths in args
+// (files, directories, or glob patterns), for other commands that inspect the
+// same reports (e.g., `validate`). Hidden directories beneath a directory in
+// args are skipped unless includeHidden is true, and symbolic links to
+// directories are skipped unless followSymlinks is true.
+func XMLPaths(args []string, includeHidden bool, followSymlinks bool) ([]string, error) {
+	return xmlPathsFromArgs(args, walkOptions{includeHidden: includeHidden, followSymlinks: followSymlinks})
+}
+
 // xmlPathsFromArgs translates each path in args into a list of XML files present
-// at that path. It returns the resulting list of XML file paths.
-func xmlPathsFromArgs(args []string) ([]string, error) {
+// at that path. It returns the resulting list of XML file paths. Directories in
+// args are searched as given by opts.
+func xmlPathsFromArgs(args []string, opts walkOptions) ([]string, error) {
 	var paths []string
 
 	for _, arg := range args {
 		info, err := os.Stat(arg)
 		if err == nil && info.IsDir() {
-			xmls, err := xmlPathsFromDir(arg)
+			xmls, err := xmlPathsFromDir(arg, opts)
 			if err != nil {
 				return nil, err
 			}
 			paths = append(paths, xmls...)
 		} else {
-			xmls, err := xmlPathsFromGlob(arg)
+			xmls, err := xmlPathsFromGlob(arg, opts)
 			if err != nil {
 				return nil, fmt.Errorf("invalid value \"%s\" for path: %v", arg, err)
 			}
@@ -488,6 +2755,7 @@ func (s *Submit) coveragePathsInferred() ([]string, error) {
 		"*.gcov",
 		"*.lst",
 		"test_cov.xml",
+		".resultset.json",
 	}
 
 	fileBlocklistMatchers := []string{
@@ -616,15 +2884,19 @@ func (s *Submit) coveragePathsInferred() ([]string, error) {
 }
 
 // xmlPathsFromDir returns a list of all the XML files in the given directory
-// and its subdirectories.
-func xmlPathsFromDir(dir string) ([]string, error) {
+// and its subdirectories, searched as given by opts.
+func xmlPathsFromDir(dir string, opts walkOptions) ([]string, error) {
 	var paths []string
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	err := walk(dir, opts.followSymlinks, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if !opts.includeHidden && isHiddenDir(dir, path, info) {
+			return filepath.SkipDir
+		}
+
 		if isXML(info.Name()) {
 			paths = append(paths, path)
 		}
@@ -639,9 +2911,9 @@ func xmlPathsFromDir(dir string) ([]string, error) {
 }
 
 // xmlPathsFromGlob returns a list of all the XML files that match the given
-// glob pattern.
-func xmlPathsFromGlob(pattern string) ([]string, error) {
-	candidates, err := filepath.Glob(pattern)
+// glob pattern, as given by opts (see matchArg).
+func xmlPathsFromGlob(pattern string, opts walkOptions) ([]string, error) {
+	candidates, err := matchArg(pattern, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -656,23 +2928,204 @@ func xmlPathsFromGlob(pattern string) ([]string, error) {
 	return paths, nil
 }
 
+// matchArg returns the files that arg matches as a glob pattern. Symbolic links
+// to directories are followed when matching "**" if opts.followSymlinks is true.
+// If opts.literal is true, arg is instead taken as an exact path, and matches
+// itself if it exists.
+func matchArg(arg string, opts walkOptions) ([]string, error) {
+	if !opts.literal {
+		return glob(arg, opts.followSymlinks)
+	}
+
+	if _, err := os.Stat(arg); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []string{arg}, nil
+}
+
+// trxPathsFromArgs translates each path in args into a list of TRX files
+// present at that path. It returns the resulting list of TRX file paths.
+// Directories in args are searched as given by opts.
+func trxPathsFromArgs(args []string, opts walkOptions) ([]string, error) {
+	var paths []string
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err == nil && info.IsDir() {
+			trxs, err := trxPathsFromDir(arg, false, opts)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, trxs...)
+		} else {
+			candidates, err := matchArg(arg, opts)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value \"%s\" for path: %v", arg, err)
+			}
+			for _, p := range candidates {
+				if isTRX(p) {
+					paths = append(paths, p)
+				}
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// jsonPathsFromArgs translates each path in args into a list of JSON files
+// present at that path. It returns the resulting list of JSON file paths.
+// Directories in args are searched as given by opts.
+func jsonPathsFromArgs(args []string, opts walkOptions) ([]string, error) {
+	var paths []string
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err == nil && info.IsDir() {
+			err := walk(arg, opts.followSymlinks, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !opts.includeHidden && isHiddenDir(arg, path, info) {
+					return filepath.SkipDir
+				}
+
+				if !info.IsDir() && isJSON(info.Name()) {
+					paths = append(paths, path)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			candidates, err := matchArg(arg, opts)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value \"%s\" for path: %v", arg, err)
+			}
+			for _, p := range candidates {
+				if isJSON(p) {
+					paths = append(paths, p)
+				}
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// trxPathsFromDir returns a list of all the TRX files in the given directory
+// and its subdirectories. If conventional is true, only the TRX files located
+// within a TestResults directory (where `dotnet test` writes them by default)
+// are returned. The directory is searched as given by opts.
+func trxPathsFromDir(dir string, conventional bool, opts walkOptions) ([]string, error) {
+	var paths []string
+
+	err := walk(dir, opts.followSymlinks, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !opts.includeHidden && isHiddenDir(dir, path, info) {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || !isTRX(info.Name()) {
+			return nil
+		}
+
+		if conventional && !inTestResultsDir(path) {
+			return nil
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// inTestResultsDir returns true if any directory in the given path is named
+// TestResults (case-insensitive); false, otherwise.
+func inTestResultsDir(path string) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if strings.EqualFold(dir, "TestResults") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHiddenDir returns true if the given path is a hidden directory (i.e., one
+// whose name starts with a dot, such as .cache or .venv) beneath root; false,
+// otherwise. The root itself is never considered hidden, since it was named
+// explicitly.
+func isHiddenDir(root string, path string, info os.FileInfo) bool {
+	if !info.IsDir() || filepath.Clean(path) == filepath.Clean(root) {
+		return false
+	}
+
+	name := info.Name()
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// isJSON returns true if the given filename has a JSON extension
+// (case-insensitive); false, otherwise.
+func isJSON(filename string) bool {
+	return hasExt(filename, ".json")
+}
+
+// isTRX returns true if the given filename has a TRX extension
+// (case-insensitive); false, otherwise.
+func isTRX(filename string) bool {
+	return hasExt(filename, ".trx")
+}
+
 // isXML returns true if the given filename has an XML extension
 // (case-insensitive); false, otherwise.
 func isXML(filename string) bool {
-	return bytes.EqualFold([]byte(filepath.Ext(filename)), []byte(".xml"))
+	return hasExt(filename, ".xml")
+}
+
+// hasExt returns true if the given filename has the given extension
+// (case-insensitive); false, otherwise. Tools on Windows often write file names
+// in upper case (e.g., Cobertura.XML).
+func hasExt(filename string, ext string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ext)
 }
 
-// locate files given an include list and ingore list (regex)
+// matchFold reports whether name matches the shell file name pattern
+// (case-insensitive).
+func matchFold(pattern string, name string) bool {
+	ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return ok
+}
+
+// locate files given an include list (shell file name patterns) and ignore
+// list (regex), both matched case-insensitively
 func locateFiles(baseDir string, includeList []string, ignoreList []string) ([]string, error) {
 	matched := []string{}
 
+	var ignores []*regexp.Regexp
+	for _, ignorePattern := range ignoreList {
+		ignores = append(ignores, regexp.MustCompile("(?i)"+ignorePattern))
+	}
+
 	err := filepath.Walk(baseDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		for _, ignorePattern := range ignoreList {
-			regex, _ := regexp.Compile(ignorePattern)
+		for _, regex := range ignores {
 			if regex.MatchString(path) {
 				if info.IsDir() {
 					return filepath.SkipDir
@@ -684,7 +3137,7 @@ func locateFiles(baseDir string, includeList []string, ignoreList []string) ([]s
 
 		if !info.IsDir() {
 			for _, filePattern := range includeList {
-				if fileMatch, _ := filepath.Match(filePattern, info.Name()); fileMatch {
+				if matchFold(filePattern, info.Name()) {
 					matched = append(matched, path)
 				}
 			}
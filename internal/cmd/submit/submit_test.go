@@ -2,16 +2,26 @@ package submit
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/buildpulse/test-reporter/internal/logger"
 	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
 	"github.com/dnaeon/go-vcr/cassette"
 	"github.com/dnaeon/go-vcr/recorder"
 	"github.com/google/uuid"
@@ -49,7 +59,7 @@ func TestSubmit_Init(t *testing.T) {
 
 	t.Run("WithCoveragePathString", func(t *testing.T) {
 		s := NewSubmit(&metadata.Version{}, logger.New())
-		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--coverage-files", "./dir1/**/*.xml ./dir2/**/*.xml"}, exampleEnv, new(stubCommitResolverFactory))
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--coverage-files", "./testdata/example-reports-dir/coverage/**/*.xml testdata/example-coverage/coverage.xml"}, exampleEnv, new(stubCommitResolverFactory))
 		require.NoError(t, err)
 		assert.ElementsMatch(t, []string{"testdata/example-reports-dir/example-1.xml"}, s.paths)
 		assert.EqualValues(t, 42, s.accountID)
@@ -60,7 +70,7 @@ func TestSubmit_Init(t *testing.T) {
 		assert.Equal(t, exampleEnv, s.envs)
 		assert.Equal(t, ".", s.repositoryPath)
 		assert.Equal(t, "Repository", s.commitResolver.Source())
-		assert.Equal(t, s.coveragePaths, []string{"./dir1/**/*.xml", "./dir2/**/*.xml"})
+		assert.Equal(t, s.coveragePaths, []string{"testdata/example-reports-dir/coverage/report-2.xml", "testdata/example-reports-dir/coverage/report.xml", "testdata/example-coverage/coverage.xml"})
 	})
 
 	t.Run("WithDisableCoverageAutoDiscovery", func(t *testing.T) {
@@ -112,6 +122,441 @@ func TestSubmit_Init(t *testing.T) {
 		assert.Equal(t, s.tagsString, "tag1 tag2")
 	})
 
+	t.Run("WithTagArgs", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--tags", "tag1 tag2", "--tag", "os:linux", "--tag", "ruby:3.3, unit,"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, tagFlag{"os:linux", "ruby:3.3", "unit"}, s.tagArgs)
+		assert.Equal(t, []string{"tag1", "tag2", "os:linux", "ruby:3.3", "unit"}, s.tags())
+	})
+
+	t.Run("WithoutTags", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--tag", ","}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Empty(t, s.tags())
+	})
+
+	t.Run("WithPathsFrom", func(t *testing.T) {
+		list := filepath.Join(t.TempDir(), "reports.txt")
+		require.NoError(t, os.WriteFile(list, []byte("testdata/example-reports-dir/example-1.xml\n\n  testdata/example-surefire-reports \r\n"), 0644))
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"--paths-from", list, "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Contains(t, s.paths, "testdata/example-reports-dir/example-1.xml")
+		assert.Greater(t, len(s.paths), 1)
+	})
+
+	t.Run("WithPathsFromExactPaths", func(t *testing.T) {
+		// Bazel, e.g., writes paths like this, which a glob would take as a
+		// character class
+		dir := t.TempDir()
+		report := filepath.Join(dir, "a[1].xml")
+		data, err := os.ReadFile("testdata/example-reports-dir/example-1.xml")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(report, data, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a1.xml"), data, 0644))
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		s.stdin = strings.NewReader(report + "\n" + filepath.Join(dir, "missing.xml") + "\n")
+		err = s.Init([]string{"--paths-from", "-", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, []string{report}, s.paths)
+	})
+
+	t.Run("WithPathsFromWithoutReports", func(t *testing.T) {
+		// The error names the list, not each of the (possibly many) paths in it
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		s.stdin = strings.NewReader("testdata/no-such-report-1.xml\ntestdata/no-such-report-2.xml\n")
+		err := s.Init([]string{"--paths-from", "-", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		assert.EqualError(t, err, "no XML reports found at the 2 paths listed in - (-paths-from)")
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		s.stdin = strings.NewReader("testdata/no-such-report-1.xml\n")
+		err = s.Init([]string{"testdata/example-reports-dir/dir-without-xml-files", "--paths-from", "-", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		assert.EqualError(t, err, "no XML reports found at TEST_RESULTS_PATH: testdata/example-reports-dir/dir-without-xml-files, or at the 1 paths listed in - (-paths-from)")
+	})
+
+	t.Run("WithPathsFromStdin", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		s.stdin = strings.NewReader("testdata/example-reports-dir/example-1.xml\n")
+		err := s.Init([]string{"testdata/example-reports-dir/dir-without-xml-files", "--paths-from", "-", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"testdata/example-reports-dir/example-1.xml"}, s.paths)
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		s.stdin = strings.NewReader("\n\n")
+		err = s.Init([]string{"--paths-from", "-", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		assert.EqualError(t, err, `invalid value "-" for flag -paths-from: no paths listed`)
+	})
+
+	t.Run("WithProvider", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--provider", "jenkins"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "jenkins", s.envs["BUILDPULSE_PROVIDER"])
+		assert.NotContains(t, exampleEnv, "BUILDPULSE_PROVIDER")
+	})
+
+	t.Run("WithRepoNameWithOwner", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--repo-name-with-owner", "some-group/some-subgroup/some-repo"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "some-group/some-subgroup/some-repo", s.envs["BUILDPULSE_REPO_NAME_WITH_OWNER"])
+		assert.NotContains(t, exampleEnv, "BUILDPULSE_REPO_NAME_WITH_OWNER")
+	})
+
+	t.Run("WithCommitAndBranch", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--commit", "CCCCCCCCCCCCCCCCCCCCDDDDDDDDDDDDDDDDDDDD", "--branch", " release/1.2 "}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "ccccccccccccccccccccdddddddddddddddddddd", s.envs["BUILDPULSE_COMMIT_SHA"])
+		assert.Equal(t, "release/1.2", s.envs["BUILDPULSE_BRANCH"])
+		assert.NotContains(t, exampleEnv, "BUILDPULSE_COMMIT_SHA")
+	})
+
+	t.Run("WithCheckName", func(t *testing.T) {
+		envs := map[string]string{"BUILDPULSE_CHECK_NAME": "from-env"}
+		for k, v := range exampleEnv {
+			envs[k] = v
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--check-name", "  test (ubuntu,\t 3.12)\n"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "test (ubuntu, 3.12)", s.envs["BUILDPULSE_CHECK_NAME"])
+		assert.Equal(t, "from-env", envs["BUILDPULSE_CHECK_NAME"])
+	})
+
+	t.Run("WithExcludeEnv", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--exclude-env", "GITHUB_ACTOR,GITHUB_EVENT_*"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "GITHUB_ACTOR,GITHUB_EVENT_*", s.envs["BUILDPULSE_EXCLUDE_ENV"])
+		assert.NotContains(t, exampleEnv, "BUILDPULSE_EXCLUDE_ENV")
+	})
+
+	t.Run("WithEnrichersDisabled", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--enrichers="}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Empty(t, s.enrichers)
+	})
+
+	t.Run("WithStagingBackend", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_ENV":               "staging",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "https://staging.buildpulse.io", s.apiURL)
+		assert.Equal(t, "buildpulse-uploads-staging", s.bucket)
+		assert.Empty(t, s.endpoint)
+	})
+
+	t.Run("WithSelfHostedBackend", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_URL":               "https://buildpulse.example.com/",
+			"BUILDPULSE_BUCKET":            "some-bucket",
+			"BUILDPULSE_S3_ENDPOINT":       "https://s3.example.com",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "https://buildpulse.example.com", s.apiURL)
+		assert.Equal(t, "some-bucket", s.bucket)
+		assert.Equal(t, "https://s3.example.com", s.endpoint)
+	})
+
+	t.Run("WithTimestampOverride", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--timestamp-override", "2020-07-11T01:02:03-05:00"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "2020-07-11T06:02:03Z", s.timestampOverride.UTC().Format(time.RFC3339))
+	})
+
+	t.Run("WithBackfillFromReceipt", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--backfill", "--backfill-from", "testdata/example-backfill/buildpulse-receipt.json"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.backfill)
+		assert.Equal(t, "2020-07-11T06:20:00Z", s.timestampOverride.Format(time.RFC3339))
+	})
+
+	t.Run("WithBackfillFromMetadata", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--backfill", "--backfill-from", "testdata/example-backfill/buildpulse.yml"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.backfill)
+		assert.Equal(t, "2020-07-11T01:02:03-05:00", s.timestampOverride.Format(time.RFC3339))
+	})
+
+	t.Run("WithBackfillAndTimestampOverride", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--backfill", "--timestamp-override", "2020-07-11T01:02:03-05:00"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.backfill)
+		assert.Equal(t, "2020-07-11T06:02:03Z", s.timestampOverride.UTC().Format(time.RFC3339))
+	})
+
+	t.Run("WithLogLevel", func(t *testing.T) {
+		tests := []struct {
+			flag string
+			want logger.Level
+		}{
+			{flag: "--quiet", want: logger.LevelError},
+			{flag: "--verbose", want: logger.LevelDebug},
+			{flag: "--log-level=warn", want: logger.LevelWarn},
+		}
+		for _, tt := range tests {
+			var out bytes.Buffer
+			log := logger.New(&out)
+			s := NewSubmit(&metadata.Version{}, log)
+			err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", tt.flag}, exampleEnv, new(stubCommitResolverFactory))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, log.Level(), tt.flag)
+
+			// The full log is kept for the bundle, whatever the level
+			assert.Contains(t, log.Text(), "Received args:")
+			if tt.want > logger.LevelDebug {
+				assert.NotContains(t, out.String(), "Received args:", tt.flag)
+			} else {
+				assert.Contains(t, out.String(), "Received args:", tt.flag)
+			}
+		}
+	})
+
+	t.Run("WithOutputJSON", func(t *testing.T) {
+		var out bytes.Buffer
+		log := logger.New(&out)
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--output", "json"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+
+		// The log stays off stdout, so that the JSON can be parsed
+		assert.Equal(t, logger.LevelError, log.Level())
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("WithDryRun", func(t *testing.T) {
+		envs := map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"} // no credentials
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--dry-run"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.dryRun)
+	})
+
+	t.Run("WithConfig", func(t *testing.T) {
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--config", "testdata/example-config/.buildpulse.yml", "--repository-id", "1"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.EqualValues(t, 42, s.accountID)
+		assert.EqualValues(t, 1, s.repositoryID) // the flag overrides the config file
+		assert.Equal(t, "unit linux", s.tagsString)
+		assert.Equal(t, "ci-quota", s.quotaID)
+		assert.Equal(t, metaFlag{"browser": "chrome", "db": "postgres15"}, s.meta)
+		assert.Contains(t, s.paths, "testdata/example-go-reports/unit.json")
+		assert.Contains(t, log.Text(), "Using flags from config file: account-id, meta, path, quota-id, tags")
+	})
+
+	t.Run("WithConfigAndEnv", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_SOFT_FAIL":         "false",
+		}
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--config", "testdata/example-config-env/.buildpulse.yml"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.bestEffortExit) // the environment variable overrides the config file
+		assert.True(t, s.failOnEmpty)
+		assert.Contains(t, log.Text(), "Ignoring flag -best-effort-exit in config file testdata/example-config-env/.buildpulse.yml, since BUILDPULSE_SOFT_FAIL is set")
+		assert.Contains(t, log.Text(), "Using flags from config file: account-id, fail-on-empty, repository-id")
+	})
+
+	t.Run("WithMeta", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--meta", "browser=chrome", "--meta", "db=postgres15", "--meta", "note=a=b"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, metaFlag{"browser": "chrome", "db": "postgres15", "note": "a=b"}, s.meta)
+	})
+
+	t.Run("WithProject", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--project", "packages/api"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "packages/api", s.project)
+	})
+
+	t.Run("WithNetworkAudit", func(t *testing.T) {
+		t.Setenv("AWS_CA_BUNDLE", "")
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--network-audit", "audit.json"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		require.NotNil(t, s.audit)
+		assert.Same(t, s.audit, s.client.Transport)
+		assert.Same(t, http.DefaultTransport, s.audit.next)
+		assert.Nil(t, http.DefaultClient.Transport)
+	})
+
+	t.Run("WithBestEffortExit", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--best-effort-exit", "--receipt", "receipt.json"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.bestEffortExit)
+		assert.Equal(t, "receipt.json", s.receiptPath)
+	})
+
+	t.Run("WithSoftFail", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_SOFT_FAIL":         "true",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--soft-fail"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.bestEffortExit)
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--soft-fail=false"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.bestEffortExit)
+	})
+
+	t.Run("WithSoftFailFromEnv", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_SOFT_FAIL":         "true",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.bestEffortExit)
+		assert.Equal(t, "buildpulse-receipt.json", s.receiptPath)
+	})
+
+	t.Run("WithTelemetry", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_TELEMETRY":         "on",
+		}
+
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.telemetry)
+		assert.Contains(t, log.Text(), "Sending anonymous telemetry about this submission")
+
+		envs["BUILDPULSE_TELEMETRY"] = "yes"
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		assert.EqualError(t, err, `invalid value "yes" for environment variable BUILDPULSE_TELEMETRY: supported values are: on, off`)
+	})
+
+	t.Run("WithStrictCommitResolutionFromEnv", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":            "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY":        "some-secret-access-key",
+			"BUILDPULSE_STRICT_COMMIT_RESOLUTION": "true",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.strictCommitResolution)
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--strict-commit-resolution=false"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.strictCommitResolution)
+	})
+
+	t.Run("WithSlowNetworkSimulation", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--simulate", "slow-network"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		require.IsType(t, &slowTransport{}, s.client.Transport)
+		assert.Equal(t, simulatedLatency, s.client.Transport.(*slowTransport).delay)
+		assert.Nil(t, http.DefaultClient.Transport)
+	})
+
+	t.Run("WithTraceContext", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"TRACEPARENT":                  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		require.IsType(t, &traceTransport{}, s.client.Transport)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", s.client.Transport.(*traceTransport).traceparent)
+		assert.Nil(t, http.DefaultClient.Transport)
+	})
+
+	t.Run("WithS3AccelerateAndDualStackFromEnv", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_S3_ACCELERATE":     "true",
+			"BUILDPULSE_S3_DUALSTACK":      "true",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.s3Accelerate)
+		assert.True(t, s.s3DualStack)
+	})
+
+	t.Run("WithS3AccelerateFlagOverridingEnv", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_S3_ACCELERATE":     "true",
+		}
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--s3-accelerate=false", "--s3-dualstack"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.s3Accelerate)
+		assert.True(t, s.s3DualStack)
+	})
+
+	t.Run("WithS3AccelerateAndCustomEndpoint", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_S3_ENDPOINT":       "https://s3.example.com",
+		}
+
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--s3-accelerate", "--s3-dualstack"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.s3Accelerate)
+		assert.False(t, s.s3DualStack)
+		assert.Contains(t, log.Text(), "ignoring S3 Transfer Acceleration and dual-stack settings")
+	})
+
 	t.Run("WithMultiplePathArgs", func(t *testing.T) {
 		s := NewSubmit(&metadata.Version{}, logger.New())
 		err := s.Init(
@@ -151,7 +596,8 @@ func TestSubmit_Init(t *testing.T) {
 	//
 	// TODO: Treat this scenario as an error for the next major version release.
 	t.Run("WithDirectoryWithoutReportsAsPathArg", func(t *testing.T) {
-		s := NewSubmit(&metadata.Version{}, logger.New())
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
 		err := s.Init(
 			[]string{"testdata/example-reports-dir/dir-without-xml-files", "--account-id", "42", "--repository-id", "8675309"},
 			exampleEnv,
@@ -161,6 +607,67 @@ func TestSubmit_Init(t *testing.T) {
 		assert.Empty(t, s.paths)
 		assert.EqualValues(t, 42, s.accountID)
 		assert.EqualValues(t, 8675309, s.repositoryID)
+		assert.Contains(t, log.Text(), "⚠️ Found 0 test reports to submit: no XML reports found in testdata/example-reports-dir/dir-without-xml-files")
+	})
+
+	t.Run("WithDirectoryWithoutReportsAsPathArgAndFailOnEmpty", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-reports-dir/dir-without-xml-files", "--account-id", "42", "--repository-id", "8675309", "--fail-on-empty"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		assert.EqualError(t, err, "no XML reports found at TEST_RESULTS_PATH: testdata/example-reports-dir/dir-without-xml-files")
+
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_FAIL_ON_EMPTY":     "true",
+		}
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/dir-without-xml-files", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		assert.EqualError(t, err, "no XML reports found at TEST_RESULTS_PATH: testdata/example-reports-dir/dir-without-xml-files")
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/dir-without-xml-files", "--account-id", "42", "--repository-id", "8675309", "--fail-on-empty=false"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Empty(t, s.paths)
+	})
+
+	t.Run("WithVersionCheck", func(t *testing.T) {
+		// Off by default, so that a release isn't held up by reaching BuildPulse
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-1.xml", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.versionCheck)
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/example-1.xml", "--account-id", "42", "--repository-id", "8675309", "--version-check"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.versionCheck)
+
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_VERSION_CHECK":     "true",
+		}
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/example-1.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.versionCheck)
+
+		s = NewSubmit(&metadata.Version{}, logger.New())
+		err = s.Init([]string{"testdata/example-reports-dir/example-1.xml", "--account-id", "42", "--repository-id", "8675309", "--no-version-check"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.False(t, s.versionCheck)
+	})
+
+	t.Run("WithReportCount", func(t *testing.T) {
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Contains(t, log.Text(), fmt.Sprintf("Found %d test reports to submit", len(s.paths)))
 	})
 
 	t.Run("WithRepositoryDirArg", func(t *testing.T) {
@@ -177,6 +684,14 @@ func TestSubmit_Init(t *testing.T) {
 		assert.Equal(t, "Repository", s.commitResolver.Source())
 	})
 
+	t.Run("WithDeepenShallowClone", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--deepen-shallow-clone"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.True(t, s.deepenShallowClone)
+		assert.Equal(t, "Repository", s.commitResolver.Source())
+	})
+
 	t.Run("WithTreeArg", func(t *testing.T) {
 		s := NewSubmit(&metadata.Version{}, logger.New())
 		err := s.Init(
@@ -188,9 +703,307 @@ func TestSubmit_Init(t *testing.T) {
 		assert.Equal(t, "Static", s.commitResolver.Source())
 	})
 
-	t.Run("WithBuildPulseBucketEnvVar", func(t *testing.T) {
-		repoDir := t.TempDir()
-
+	t.Run("WithDotnetFrameworkAndNoPathArg", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"--account-id", "42", "--repository-id", "8675309", "--framework", "dotnet", "--repository-dir", "testdata/example-dotnet-solution"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t,
+			[]string{
+				"testdata/example-dotnet-solution/Calculator.MSTests/TestResults/attempt-1/results.trx",
+				"testdata/example-dotnet-solution/Calculator.Tests/TestResults/runner_build-agent_2020-07-11_01_02_03.trx",
+			},
+			s.paths,
+		)
+	})
+
+	t.Run("WithDotnetFrameworkAndPathArg", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-dotnet-solution/artifacts", "--account-id", "42", "--repository-id", "8675309", "--framework", "dotnet"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"testdata/example-dotnet-solution/artifacts/stray.trx"}, s.paths)
+	})
+
+	t.Run("WithExcludeHiddenDisabled", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-reports-dir", "--account-id", "42", "--repository-id", "8675309", "--exclude-hidden=false"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.Contains(t, s.paths, "testdata/example-reports-dir/.cache/junk.xml")
+	})
+
+	t.Run("WithFollowSymlinks", func(t *testing.T) {
+		dir := newSymlinkedReportsDir(t)
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{dir, "--account-id", "42", "--repository-id", "8675309", "--follow-symlinks"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{filepath.Join(dir, "local.xml"), filepath.Join(dir, "shared", "report.xml")}, s.paths)
+	})
+
+	t.Run("WithJSONReports", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-js-reports", "--account-id", "42", "--repository-id", "8675309"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"testdata/example-js-reports/karma.json", "testdata/example-js-reports/vitest.json"}, s.paths)
+		assert.Equal(t, "karma", s.jsonFormats["testdata/example-js-reports/karma.json"])
+		assert.Equal(t, "vitest", s.jsonFormats["testdata/example-js-reports/vitest.json"])
+	})
+
+	t.Run("WithExUnitReports", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-exunit-reports", "--account-id", "42", "--repository-id", "8675309"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"testdata/example-exunit-reports/mix-test.json"}, s.paths)
+		assert.Equal(t, "exunit", s.jsonFormats["testdata/example-exunit-reports/mix-test.json"])
+	})
+
+	t.Run("WithPathFlags", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--path", "gotest=testdata/example-go-reports/unit.json", "--path", "trx=testdata/example-dotnet-solution/artifacts", "--path", "junit=testdata/example-reports-dir/example-1.xml"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"testdata/example-reports-dir/example-1.xml",
+			"testdata/example-go-reports/unit.json",
+			"testdata/example-dotnet-solution/artifacts/stray.trx",
+		}, s.paths)
+		assert.Equal(t, "gotest", s.reportFormat("testdata/example-go-reports/unit.json"))
+		assert.Equal(t, "trx", s.reportFormat("testdata/example-dotnet-solution/artifacts/stray.trx"))
+		assert.Equal(t, "junit", s.reportFormat("testdata/example-reports-dir/example-1.xml"))
+	})
+
+	t.Run("WithOnlyPathFlags", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"--account-id", "42", "--repository-id", "8675309", "--path", "vitest=testdata/example-js-reports/vitest.json"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"testdata/example-js-reports/vitest.json"}, s.paths)
+		assert.Equal(t, "vitest", s.reportFormat("testdata/example-js-reports/vitest.json"))
+	})
+
+	t.Run("WithFormatFlag", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-js-reports/vitest.json", "--account-id", "42", "--repository-id", "8675309", "--format", "karma"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"testdata/example-js-reports/vitest.json"}, s.paths)
+		assert.Equal(t, "karma", s.jsonFormats["testdata/example-js-reports/vitest.json"])
+		assert.Contains(t, s.fs.Lookup("format").Usage, "(supported: exunit, gotest, karma, vitest)")
+	})
+
+	t.Run("WithFormatFlagAndDirectory", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-js-reports", "--account-id", "42", "--repository-id", "8675309", "--format", "vitest"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"testdata/example-js-reports/karma.json", "testdata/example-js-reports/vitest.json"}, s.paths)
+		assert.Equal(t, "karma", s.jsonFormats["testdata/example-js-reports/karma.json"])
+		assert.Equal(t, "vitest", s.jsonFormats["testdata/example-js-reports/vitest.json"])
+	})
+
+	t.Run("WithPathVariables", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"SHARD":                        "browserstack",
+		}
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-reports-dir/dir-with-xml-files/${SHARD}/*.xml", "${UNSET_VAR}testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"},
+			envs,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t,
+			[]string{
+				"testdata/example-reports-dir/dir-with-xml-files/browserstack/example-1.xml",
+				"testdata/example-reports-dir/dir-with-xml-files/browserstack/example-2.xml",
+				"testdata/example-reports-dir/example-1.xml",
+			},
+			s.paths,
+		)
+	})
+
+	t.Run("WithHerokuCIAndNoRepository", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":       "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY":   "some-secret-access-key",
+			"HEROKU_TEST_RUN_COMMIT_VERSION": "1f192ff735f887dd7a25229b2ece0422d17931f5",
+			"HEROKU_TEST_RUN_ID":             "7d2b6c2e-1e9a-4b0b-8d9c-5a6f0e3c2b1a",
+		}
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--repository-dir", t.TempDir()},
+			envs,
+			NewCommitResolverFactory(logger.New()),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "Static", s.commitResolver.Source())
+	})
+
+	t.Run("WithHashTreeAndNoRepository", func(t *testing.T) {
+		repoDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte("hello\n"), 0644))
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--repository-dir", repoDir, "--hash-tree"},
+			exampleEnv,
+			NewCommitResolverFactory(logger.New()),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "Static", s.commitResolver.Source())
+
+		c, err := s.commitResolver.Lookup(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, "2e81171448eb9f2ee3821e3d447aa6b2fe3ddba1", c.TreeSHA)
+	})
+
+	t.Run("WithHashTreeAndRepository", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--hash-tree"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "Repository", s.commitResolver.Source())
+	})
+
+	t.Run("WithGitHubToken", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_GITHUB_TOKEN":      "some-token",
+			"GITHUB_REPOSITORY":            "some-owner/some-repo",
+		}
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+
+		// The repository is used first, with the GitHub API as a fallback
+		assert.Equal(t, "Repository", s.commitResolver.Source())
+		assert.Contains(t, log.Text(), "Using the GitHub API (https://api.github.com) to look up commits missing from the git repository")
+	})
+
+	t.Run("WithGitHubTokenAndNoRepository", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_GITHUB_TOKEN":      "some-token",
+			"GITHUB_API_URL":               "https://github.example.com/api/v3",
+			"GITHUB_REPOSITORY":            "some-owner/some-repo",
+		}
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309", "--repository-dir", t.TempDir()},
+			envs,
+			NewCommitResolverFactory(logger.New()),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "GitHub API", s.commitResolver.Source())
+	})
+
+	t.Run("WithGitHubTokenAndNoGitHubRepository", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_GITHUB_TOKEN":      "some-token",
+		}
+		log := logger.New()
+		s := NewSubmit(&metadata.Version{}, log)
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--account-id", "42", "--repository-id", "8675309"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.Equal(t, "Repository", s.commitResolver.Source())
+		assert.Contains(t, log.Text(), "Ignoring BUILDPULSE_GITHUB_TOKEN: unable to determine the GitHub repository")
+	})
+
+	t.Run("WithRetriedAttempts", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-retried-reports", "--account-id", "42", "--repository-id", "8675309"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t,
+			[]string{
+				"testdata/example-retried-reports/attempt-1/results.xml",
+				"testdata/example-retried-reports/attempt-2/results.xml",
+				"testdata/example-retried-reports/lint.xml",
+			},
+			s.paths,
+		)
+		assert.Equal(t,
+			map[string]int{
+				"testdata/example-retried-reports/attempt-1/results.xml": 1,
+				"testdata/example-retried-reports/attempt-2/results.xml": 2,
+			},
+			s.attempts,
+		)
+	})
+
+	t.Run("WithRetriedAttemptsAndDedupeAttemptsLatest", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init(
+			[]string{"testdata/example-retried-reports", "--account-id", "42", "--repository-id", "8675309", "--dedupe-attempts", "latest"},
+			exampleEnv,
+			new(stubCommitResolverFactory),
+		)
+		require.NoError(t, err)
+		assert.ElementsMatch(t,
+			[]string{
+				"testdata/example-retried-reports/attempt-2/results.xml",
+				"testdata/example-retried-reports/lint.xml",
+			},
+			s.paths,
+		)
+		assert.Equal(t, map[string]int{"testdata/example-retried-reports/attempt-2/results.xml": 2}, s.attempts)
+	})
+
+	t.Run("WithUnrelatedPytestReportsAndDedupeAttemptsLatest", func(t *testing.T) {
+		// Both reports have the single suite "pytest", but different tests, so
+		// neither is an attempt of the other
+		dir := t.TempDir()
+		unit := filepath.Join(dir, "unit.xml")
+		api := filepath.Join(dir, "api.xml")
+		require.NoError(t, os.WriteFile(unit, []byte(`<testsuites><testsuite name="pytest" timestamp="2020-07-11T01:02:03"><testcase classname="tests.test_models" name="test_save"/></testsuite></testsuites>`), 0644))
+		require.NoError(t, os.WriteFile(api, []byte(`<testsuites><testsuite name="pytest" timestamp="2020-07-11T01:05:09"><testcase classname="tests.test_api" name="test_get"/></testsuite></testsuites>`), 0644))
+
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{dir, "--account-id", "42", "--repository-id", "8675309", "--dedupe-attempts", "latest"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{unit, api}, s.paths)
+		assert.Empty(t, s.attempts)
+	})
+
+	t.Run("WithBuildPulseBucketEnvVar", func(t *testing.T) {
+		repoDir := t.TempDir()
+
 		envs := map[string]string{
 			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
 			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
@@ -205,6 +1018,72 @@ func TestSubmit_Init(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "buildpulse-uploads-test", s.bucket)
 	})
+
+	t.Run("WithDefaults", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		s.SetDefaults(Defaults{AccountID: 42, RepositoryID: 8675309, Bucket: "some-org-bucket"})
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml"}, exampleEnv, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.EqualValues(t, 42, s.accountID)
+		assert.EqualValues(t, 8675309, s.repositoryID)
+		assert.Equal(t, "some-org-bucket", s.bucket)
+	})
+
+	t.Run("WithDefaultsOverriddenByArgsAndEnvs", func(t *testing.T) {
+		envs := map[string]string{
+			"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			"BUILDPULSE_BUCKET":            "buildpulse-uploads-test",
+		}
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		s.SetDefaults(Defaults{AccountID: 42, RepositoryID: 8675309, Bucket: "some-org-bucket"})
+		err := s.Init([]string{"testdata/example-reports-dir/example-*.xml", "--repository-id", "123"}, envs, new(stubCommitResolverFactory))
+		require.NoError(t, err)
+		assert.EqualValues(t, 42, s.accountID)
+		assert.EqualValues(t, 123, s.repositoryID)
+		assert.Equal(t, "buildpulse-uploads-test", s.bucket)
+	})
+}
+
+func Test_flagEnvs(t *testing.T) {
+	// Each flag that overrides an environment variable yields to it in a config
+	// file
+	s := NewSubmit(&metadata.Version{}, logger.New())
+	re := regexp.MustCompile(`\(overrides (BUILDPULSE_[A-Z_]+)\)`)
+	s.fs.VisitAll(func(f *flag.Flag) {
+		if m := re.FindStringSubmatch(f.Usage); m != nil {
+			assert.Equal(t, m[1], flagEnvs[f.Name], "flag -%s", f.Name)
+		}
+	})
+}
+
+func Test_defaultConfigPath(t *testing.T) {
+	path, err := defaultConfigPath("testdata/example-config")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("testdata/example-config", ".buildpulse.yml"), path)
+
+	path, err = defaultConfigPath("testdata/example-reports-dir")
+	require.NoError(t, err)
+	assert.Empty(t, path)
+
+	_, err = defaultConfigPath("testdata/example-config-both")
+	assert.EqualError(t, err, "found config files testdata/example-config-both/.buildpulse.yml and testdata/example-config-both/buildpulse.config.yml: use one or the other, but not both")
+}
+
+func TestSubmit_CheckArgs(t *testing.T) {
+	// The reports don't exist yet, and the list of them isn't read
+	s := NewSubmit(&metadata.Version{}, logger.New())
+	s.stdin = iotest.ErrReader(errors.New("unexpected read"))
+	err := s.CheckArgs([]string{"testdata/no-such-dir", "--paths-from", "-", "--coverage-files", "no-such-dir/*.xml", "--account-id", "42", "--repository-id", "8675309"}, exampleEnv, new(stubCommitResolverFactory))
+	require.NoError(t, err)
+	assert.Zero(t, s.accountID)
+	assert.Nil(t, s.paths)
+
+	err = s.CheckArgs([]string{"testdata/no-such-dir", "--account-id", "42"}, exampleEnv, new(stubCommitResolverFactory))
+	assert.EqualError(t, err, "missing required flag: -repository-id")
+
+	err = s.CheckArgs([]string{"testdata/no-such-dir", "--account-id", "42", "--repository-id", "8675309"}, map[string]string{}, new(stubCommitResolverFactory))
+	assert.EqualError(t, err, "missing required environment variable: BUILDPULSE_ACCESS_KEY_ID")
 }
 
 func TestSubmit_Init_invalidArgs(t *testing.T) {
@@ -262,42 +1141,327 @@ func TestSubmit_Init_invalidArgs(t *testing.T) {
 			errMsg: `invalid value "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx" for flag -tree: should be a 40-character SHA-1 hash`,
 		},
 		{
-			name:   "TreeAndRepoPathBothGiven",
-			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --repository-dir . --tree 0000000000000000000000000000000000000000", dir),
-			errMsg: `invalid use of flag -repository-dir with flag -tree: use one or the other, but not both`,
+			name:   "UnsupportedFramework",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --framework bogus", dir),
+			errMsg: `invalid value "bogus" for flag -framework: supported values are: dotnet, pest, phpunit`,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			s := NewSubmit(&metadata.Version{}, logger.New())
-			err := s.Init(strings.Split(tt.args, " "), exampleEnv, &stubCommitResolverFactory{})
-			if assert.Error(t, err) {
-				assert.Regexp(t, tt.errMsg, err.Error())
-			}
-		})
-	}
-}
-
-func TestSubmit_Init_invalidEnv(t *testing.T) {
-	tests := []struct {
-		name    string
-		envVars map[string]string
-		errMsg  string
-	}{
 		{
-			name: "MissingAccessKeyID",
-			envVars: map[string]string{
-				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
-			},
-			errMsg: "missing required environment variable: BUILDPULSE_ACCESS_KEY_ID",
+			name:   "UnsupportedKeyScheme",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --key-scheme bogus", dir),
+			errMsg: `invalid value "bogus" for flag -key-scheme: supported values are: uuid, digest`,
 		},
 		{
-			name: "EmptyAccessKeyID",
-			envVars: map[string]string{
-				"BUILDPULSE_ACCESS_KEY_ID":     "",
-				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
-			},
+			name:   "UnsupportedSimulation",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --simulate bogus", dir),
+			errMsg: `invalid value "bogus" for flag -simulate: supported values are: upload-failure, slow-network, partial-bundle`,
+		},
+		{
+			name:   "NegativeTruncateFailures",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --truncate-failures -1", dir),
+			errMsg: `invalid value "-1" for flag -truncate-failures: should be 0 or more`,
+		},
+		{
+			name:   "NegativeUploadTimeout",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --upload-timeout -1s", dir),
+			errMsg: `invalid value "-1s" for flag -upload-timeout: should be 0 or more`,
+		},
+		{
+			name:   "ConfigWithUnknownFlag",
+			args:   fmt.Sprintf("%s --config testdata/example-config-invalid/unknown.yml", dir),
+			errMsg: `unknown flag "flavor" in config file testdata/example-config-invalid/unknown.yml`,
+		},
+		{
+			name:   "ConfigWithMalformedValue",
+			args:   fmt.Sprintf("%s --config testdata/example-config-invalid/malformed.yml", dir),
+			errMsg: `invalid value "forty-two" for flag -account-id in config file testdata/example-config-invalid/malformed.yml: parse error`,
+		},
+		{
+			name:   "MissingConfig",
+			args:   fmt.Sprintf("%s --config testdata/example-config/missing.yml", dir),
+			errMsg: `unable to read config file: open testdata/example-config/missing.yml: no such file or directory`,
+		},
+		{
+			name:   "UnsupportedFormat",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --format bogus", dir),
+			errMsg: `invalid value "bogus" for flag -format: supported values are: exunit, gotest, karma, vitest`,
+		},
+		{
+			name:   "MalformedStructuredTag",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --tags os:", dir),
+			errMsg: `invalid value "os:" for flags -tags and -tag: invalid tag "os:": missing value for dimension os`,
+		},
+		{
+			name:   "MetaWithoutValue",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --meta browser", dir),
+			errMsg: `invalid value "browser" for flag -meta: should be of the form key=value`,
+		},
+		{
+			name:   "MetaWithInvalidKey",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --meta =chrome", dir),
+			errMsg: `invalid value "=chrome" for flag -meta: key should start with a letter or underscore`,
+		},
+		{
+			name:   "MetaWithDuplicateKey",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --meta db=postgres15 --meta db=mysql8", dir),
+			errMsg: `invalid value "db=mysql8" for flag -meta: duplicate key db`,
+		},
+		{
+			name:   "ShardIndexWithoutShardTotal",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --shard-index 1", dir),
+			errMsg: `invalid use of flag -shard-index without flag -shard-total`,
+		},
+		{
+			name:   "ShardTotalWithoutShardIndex",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --shard-total 4", dir),
+			errMsg: `invalid use of flag -shard-total without flag -shard-index`,
+		},
+		{
+			name:   "ZeroShardTotal",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --shard-index 0 --shard-total 0", dir),
+			errMsg: `invalid value "0" for flag -shard-total: should be at least 1`,
+		},
+		{
+			name:   "ShardIndexOutOfRange",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --shard-index 4 --shard-total 4", dir),
+			errMsg: `invalid value "4" for flag -shard-index: should be less than -shard-total \(4\)`,
+		},
+		{
+			name:   "UnsupportedDedupeAttempts",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --dedupe-attempts bogus", dir),
+			errMsg: `invalid value "bogus" for flag -dedupe-attempts: supported values are: all, latest`,
+		},
+		{
+			name:   "UnsupportedEnricher",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --enrichers runner,bogus", dir),
+			errMsg: `invalid value "bogus" for flag -enrichers: supported values are: runner`,
+		},
+		{
+			name:   "MalformedIncludeEnv",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --include-env GITHUB_[", dir),
+			errMsg: `invalid value "GITHUB_\[" for flag -include-env: malformed pattern "GITHUB_\["`,
+		},
+		{
+			name:   "MalformedRepoNameWithOwner",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --repo-name-with-owner some-repo", dir),
+			errMsg: `invalid value "some-repo" for flag -repo-name-with-owner: should be of the form OWNER/NAME`,
+		},
+		{
+			name:   "MalformedCommit",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --commit abc123", dir),
+			errMsg: `invalid value "abc123" for flag -commit: should be a 40-character SHA-1 hash`,
+		},
+		{
+			name:   "BlankBranch",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --branch \t", dir),
+			errMsg: `invalid value "\t" for flag -branch: should not be blank`,
+		},
+		{
+			name:   "BlankCheckName",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --check-name \t", dir),
+			errMsg: `invalid value "\t" for flag -check-name: should not be blank`,
+		},
+		{
+			name:   "CheckNameWithControlCharacters",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --check-name test\x1b[31m", dir),
+			errMsg: `invalid value "test\x1b\[31m" for flag -check-name: should not contain control characters`,
+		},
+		{
+			name:   "CheckNameTooLong",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --check-name %s", dir, strings.Repeat("a", 256)),
+			errMsg: `invalid value "a+" for flag -check-name: should be at most 255 characters`,
+		},
+		{
+			name:   "ShardPatternWithoutCaptureGroup",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --shard-pattern shard-[0-9]+", dir),
+			errMsg: `invalid value "shard-\[0-9\]\+" for flag -shard-pattern: should be a regular expression with a capture group`,
+		},
+		{
+			name:   "ShardTimingFileWithoutShardPattern",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --shard-timing-file timing.json", dir),
+			errMsg: `invalid use of flag -shard-timing-file without flag -shard-pattern`,
+		},
+		{
+			name:   "UnsupportedProvider",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --provider bogus", dir),
+			errMsg: `invalid value "bogus" for flag -provider: supported values are: appveyor, argo-workflows, .*, xcode-cloud`,
+		},
+		{
+			name:   "TreeAndRepoPathBothGiven",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --repository-dir . --tree 0000000000000000000000000000000000000000", dir),
+			errMsg: `invalid use of flag -repository-dir with flag -tree: use one or the other, but not both`,
+		},
+		{
+			name:   "PathWithoutFormat",
+			args:   "--account-id 1 --repository-id 2 --path reports/unit.json",
+			errMsg: `invalid value "reports/unit.json" for flag -path: should be of the form format=path`,
+		},
+		{
+			name:   "PathWithUnsupportedFormat",
+			args:   "--account-id 1 --repository-id 2 --path bogus=reports/unit.json",
+			errMsg: `invalid value "bogus=reports/unit.json" for flag -path: unsupported format bogus \(supported: junit, trx, exunit, gotest, karma, vitest\)`,
+		},
+		{
+			name:   "PathWithNoReports",
+			args:   "--account-id 1 --repository-id 2 --path gotest=testdata/example-reports-dir",
+			errMsg: `no reports found for flag -path: gotest=testdata/example-reports-dir`,
+		},
+		{
+			name:   "SignKeyWithSignKeyless",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --sign-key testdata/example-reports-dir/example-1.xml --sign-keyless", dir),
+			errMsg: `invalid use of flag -sign-key with flag -sign-keyless: use one or the other, but not both`,
+		},
+		{
+			name:   "SignKeyNotAKey",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --sign-key testdata/example-reports-dir/example-1.xml", dir),
+			errMsg: `invalid value "testdata/example-reports-dir/example-1.xml" for flag -sign-key: no PEM-encoded key found`,
+		},
+		{
+			name:   "UnsupportedOutput",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --output yaml", dir),
+			errMsg: `invalid value "yaml" for flag -output: supported values are: text, json`,
+		},
+		{
+			name:   "OutputJSONWithVerbose",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --output json --verbose", dir),
+			errMsg: `invalid use of flag -output json with flag -verbose or -log-level: the JSON is all that's printed`,
+		},
+		{
+			name:   "VersionCheckWithNoVersionCheck",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --version-check --no-version-check", dir),
+			errMsg: `invalid use of flag -version-check with flag -no-version-check: use one or the other, but not both`,
+		},
+		{
+			name:   "QuietWithVerbose",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --quiet --verbose", dir),
+			errMsg: `invalid use of flag -quiet with flag -verbose: use one or the other, but not both`,
+		},
+		{
+			name:   "LogLevelWithVerbose",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --log-level warn --verbose", dir),
+			errMsg: `invalid use of flag -log-level with flag -quiet or -verbose: use one or the other, but not both`,
+		},
+		{
+			name:   "UnsupportedLogLevel",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --log-level trace", dir),
+			errMsg: `invalid value "trace" for flag -log-level: should be one of: debug, info, warn, error`,
+		},
+		{
+			name:   "BackfillWithoutTimestamp",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --backfill", dir),
+			errMsg: `missing flag -backfill-from or -timestamp-override: a backfill needs the timestamp of the original submission`,
+		},
+		{
+			name:   "BackfillFromWithoutBackfill",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --backfill-from testdata/example-backfill/buildpulse.yml", dir),
+			errMsg: `invalid use of flag -backfill-from without flag -backfill`,
+		},
+		{
+			name:   "BackfillFromWithTimestampOverride",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --backfill --backfill-from testdata/example-backfill/buildpulse.yml --timestamp-override 2020-07-11T01:02:03Z", dir),
+			errMsg: `invalid use of flag -backfill-from with flag -timestamp-override: use one or the other, but not both`,
+		},
+		{
+			name:   "BackfillFromMalformedFile",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --backfill --backfill-from testdata/example-js-reports/package.json", dir),
+			errMsg: `invalid value "testdata/example-js-reports/package.json" for flag -backfill-from: receipt has no time`,
+		},
+		{
+			name:   "MalformedTimestampOverride",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --timestamp-override 2020-07-11", dir),
+			errMsg: `invalid value "2020-07-11" for flag -timestamp-override: should be an RFC 3339 timestamp \(e.g., 2006-01-02T15:04:05Z\)`,
+		},
+		{
+			name:   "TreeAndHashTreeBothGiven",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --hash-tree --tree 0000000000000000000000000000000000000000", dir),
+			errMsg: `invalid use of flag -hash-tree with flag -tree: use one or the other, but not both`,
+		},
+		{
+			name:   "CoverageBaselineWithoutRef",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --coverage-baseline testdata", dir),
+			errMsg: `invalid use of flags -coverage-baseline and -coverage-baseline-ref: use both or neither`,
+		},
+		{
+			name:   "CoverageBaselineNotDirectory",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --coverage-baseline testdata/example-coverage/coverage.xml --coverage-baseline-ref abc", dir),
+			errMsg: `invalid value "testdata/example-coverage/coverage.xml" for flag -coverage-baseline: should be a directory`,
+		},
+		{
+			name:   "EmptyProject",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --project=", dir),
+			errMsg: `invalid value "" for flag -project: should be a non-empty name without whitespace`,
+		},
+		{
+			name:   "ProjectWithWhitespace",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --project=my\tapi", dir),
+			errMsg: `invalid value "my\tapi" for flag -project: should be a non-empty name without whitespace`,
+		},
+		{
+			name:   "PathsFromMissingFile",
+			args:   "--account-id 1 --repository-id 2 --paths-from testdata/no-such-file.txt",
+			errMsg: `invalid value "testdata/no-such-file.txt" for flag -paths-from: open testdata/no-such-file.txt: no such file or directory`,
+		},
+		{
+			name:   "TagConflictingWithTags",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --tags os:linux --tag os:macos", dir),
+			errMsg: `invalid value "os:linux os:macos" for flags -tags and -tag: conflicting tags for dimension os: linux and macos`,
+		},
+		{
+			name:   "TagsConflicting",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --tag os:linux,os:macos", dir),
+			errMsg: `invalid value "os:linux os:macos" for flags -tags and -tag: conflicting tags for dimension os: linux and macos`,
+		},
+		{
+			name:   "SoftFailWithBestEffortExit",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --soft-fail --best-effort-exit", dir),
+			errMsg: `invalid use of flag -soft-fail with flag -best-effort-exit: -soft-fail is an alias for -best-effort-exit, so use one or the other`,
+		},
+		{
+			name:   "ReceiptWithoutBestEffortExit",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --receipt receipt.json", dir),
+			errMsg: `invalid use of flag -receipt without flag -best-effort-exit: receipts are only written for ignored failures`,
+		},
+		{
+			name:   "TreeAndResolveBaseBranchBothGiven",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --resolve-base-branch --tree 0000000000000000000000000000000000000000", dir),
+			errMsg: `invalid use of flag -resolve-base-branch with flag -tree: no repository is used with -tree`,
+		},
+		{
+			name:   "TreeAndDeepenShallowCloneBothGiven",
+			args:   fmt.Sprintf("%s --account-id 1 --repository-id 2 --deepen-shallow-clone --tree 0000000000000000000000000000000000000000", dir),
+			errMsg: `invalid use of flag -deepen-shallow-clone with flag -tree: no repository is used with -tree`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSubmit(&metadata.Version{}, logger.New())
+			err := s.Init(strings.Split(tt.args, " "), exampleEnv, &stubCommitResolverFactory{})
+			if assert.Error(t, err) {
+				assert.Regexp(t, tt.errMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestSubmit_Init_invalidEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		envVars map[string]string
+		errMsg  string
+	}{
+		{
+			name: "MissingAccessKeyID",
+			envVars: map[string]string{
+				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			},
+			errMsg: "missing required environment variable: BUILDPULSE_ACCESS_KEY_ID",
+		},
+		{
+			name: "EmptyAccessKeyID",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":     "",
+				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+			},
 			errMsg: "missing required environment variable: BUILDPULSE_ACCESS_KEY_ID",
 		},
 		{
@@ -315,6 +1479,60 @@ func TestSubmit_Init_invalidEnv(t *testing.T) {
 			},
 			errMsg: "missing required environment variable: BUILDPULSE_SECRET_ACCESS_KEY",
 		},
+		{
+			name: "MissingNextSecretAccessKey",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":      "some-access-id",
+				"BUILDPULSE_SECRET_ACCESS_KEY":  "some-secret-access-key",
+				"BUILDPULSE_ACCESS_KEY_ID_NEXT": "some-next-access-id",
+			},
+			errMsg: "missing required environment variable: BUILDPULSE_SECRET_ACCESS_KEY_NEXT",
+		},
+		{
+			name: "MissingNextAccessKeyID",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":          "some-access-id",
+				"BUILDPULSE_SECRET_ACCESS_KEY":      "some-secret-access-key",
+				"BUILDPULSE_SECRET_ACCESS_KEY_NEXT": "some-next-secret-access-key",
+			},
+			errMsg: "missing required environment variable: BUILDPULSE_ACCESS_KEY_ID_NEXT",
+		},
+		{
+			name: "UnsupportedBackend",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":     "some-access-id",
+				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+				"BUILDPULSE_ENV":               "bogus",
+			},
+			errMsg: `invalid value "bogus" for environment variable BUILDPULSE_ENV: supported values are: production, staging`,
+		},
+		{
+			name: "RelativeURL",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":     "some-access-id",
+				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+				"BUILDPULSE_URL":               "buildpulse.example.com",
+			},
+			errMsg: `invalid value "buildpulse.example.com" for environment variable BUILDPULSE_URL: should be an absolute http or https URL`,
+		},
+		{
+			name: "MalformedCommit",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":     "some-access-id",
+				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+				"BUILDPULSE_COMMIT_SHA":        "abc123",
+			},
+			errMsg: `invalid value "abc123" for environment variable BUILDPULSE_COMMIT_SHA: should be a 40-character SHA-1 hash`,
+		},
+		{
+			name: "BlankBranch",
+			envVars: map[string]string{
+				"BUILDPULSE_ACCESS_KEY_ID":     "some-access-id",
+				"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+				"BUILDPULSE_BRANCH":            "  ",
+			},
+			errMsg: `invalid value "  " for environment variable BUILDPULSE_BRANCH: should not be blank`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -332,6 +1550,38 @@ func TestSubmit_Init_invalidEnv(t *testing.T) {
 }
 
 func TestSubmit_Init_invalidPaths(t *testing.T) {
+	t.Run("DotnetFrameworkWithNoReportFiles", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{
+			"--account-id", "42",
+			"--repository-id", "8675309",
+			"--framework", "dotnet",
+			"--repository-dir", "testdata/example-reports-dir",
+		},
+			exampleEnv,
+			&stubCommitResolverFactory{},
+		)
+		if assert.Error(t, err) {
+			assert.Equal(t, "no TRX reports found in TestResults directories beneath testdata/example-reports-dir", err.Error())
+		}
+	})
+
+	t.Run("UnresolvedPathVariableWithStrictPathVars", func(t *testing.T) {
+		s := NewSubmit(&metadata.Version{}, logger.New())
+		err := s.Init([]string{
+			"testdata/example-reports-dir/${SHARD}/*.xml",
+			"--account-id", "42",
+			"--repository-id", "8675309",
+			"--strict-path-vars",
+		},
+			exampleEnv,
+			&stubCommitResolverFactory{},
+		)
+		if assert.Error(t, err) {
+			assert.Equal(t, `invalid value "testdata/example-reports-dir/${SHARD}/*.xml" for path: unresolved variable $SHARD`, err.Error())
+		}
+	})
+
 	t.Run("NonexistentPath", func(t *testing.T) {
 		s := NewSubmit(&metadata.Version{}, logger.New())
 		err := s.Init([]string{
@@ -477,49 +1727,270 @@ func TestSubmit_Run(t *testing.T) {
 	assert.Equal(t, "42/8675309/buildpulse-00000000-0000-0000-0000-000000000000.gz", key)
 }
 
-func Test_bundle(t *testing.T) {
-	t.Run("bundle with coverage files provided", func(t *testing.T) {
-		envs := map[string]string{
-			"GITHUB_ACTIONS": "true",
-			"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
-		}
+func TestSubmit_Run_dryRun(t *testing.T) {
+	// Neither the API nor S3 is contacted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+	}))
+	defer server.Close()
 
-		log := logger.New()
-		s := &Submit{
-			logger:         log,
-			version:        &metadata.Version{Number: "v1.2.3"},
-			commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
-			envs:           envs,
-			paths:          []string{"testdata/example-reports-dir/example-1.xml"},
-			coveragePaths:  []string{"testdata/example-reports-dir/coverage/report.xml", "testdata/example-reports-dir/coverage/report-2.xml"},
-			bucket:         "buildpulse-uploads",
-			accountID:      42,
-			repositoryID:   8675309,
-		}
+	log := logger.New()
+	s := &Submit{
+		client:         http.DefaultClient,
+		apiURL:         server.URL,
+		endpoint:       server.URL,
+		telemetry:      true,
+		dryRun:         true,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+	}
 
-		path, err := s.bundle()
-		require.NoError(t, err)
+	key, err := s.Run()
+	require.NoError(t, err)
+	assert.Empty(t, key)
+	assert.Regexp(t, `Dry run \(-dry-run\): not uploading \S+\.gz \(\d+ bytes\) to s3://buildpulse-uploads/42/8675309/buildpulse-00000000-0000-0000-0000-000000000000\.gz`, log.Text())
+	assert.Contains(t, log.Text(), "- test_results/testdata/example-reports-dir/example-1.xml (")
+	assert.Contains(t, log.Text(), "buildpulse.yml:\n:branch:")
+	assert.NotContains(t, log.Text(), "Sending")
+}
 
-		unzipDir := t.TempDir()
-		err = archiver.Unarchive(path, unzipDir)
-		require.NoError(t, err)
+func TestSubmit_Run_withFakeS3(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+	server.AllowAccessKeys("some-next-access-key-id")
 
-		// Verify buildpulse.yml is present and contains expected content
-		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
-		require.NoError(t, err)
-		assert.Contains(t, string(yaml), ":ci_provider: github-actions")
-		assert.Contains(t, string(yaml), ":commit: aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb")
-		assert.Contains(t, string(yaml), ":tree: ccccccccccccccccccccdddddddddddddddddddd")
-		assert.Contains(t, string(yaml), ":reporter_version: v1.2.3")
+	// The first request fails transiently and is retried; the retry is rejected
+	// because of the access key, so the upload falls back to the next access key
+	server.FailNext(1, http.StatusInternalServerError, "InternalError")
 
-		// Verify test report XML file is present and contains expected content
-		assertEqualContent(t,
-			"testdata/example-reports-dir/example-1.xml",
-			filepath.Join(unzipDir, "test_results/testdata/example-reports-dir/example-1.xml"),
-		)
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
 
-		// Verify coverage files are present and contains expected content
-		assertEqualContent(t,
+	log := logger.New()
+	s := &Submit{
+		client:         http.DefaultClient,
+		endpoint:       server.URL,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           envs,
+		paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+		nextCredentials: &credentials{
+			AccessKeyID:     "some-next-access-key-id",
+			SecretAccessKey: "some-next-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+	assert.Equal(t, "42/8675309/buildpulse-00000000-0000-0000-0000-000000000000.gz", key)
+	assert.Equal(t, []string{key}, server.Objects("buildpulse-uploads"))
+
+	obj := server.Object("buildpulse-uploads", key)
+	assert.Equal(t, "application/gzip", obj.Header.Get("Content-Type"))
+	assert.Equal(t, "bucket-owner-full-control", obj.Header.Get("X-Amz-Acl"))
+	assert.Equal(t, map[string]string{"ci-provider": "github-actions", "reporter-version": "v1.2.3", "schema-version": bundleLayoutVersion}, obj.Metadata())
+
+	// Verify the object contains the bundle
+	gzpath := filepath.Join(t.TempDir(), "upload.tar.gz")
+	require.NoError(t, os.WriteFile(gzpath, obj.Body, 0600))
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(gzpath, unzipDir))
+	assert.FileExists(t, filepath.Join(unzipDir, "buildpulse.yml"))
+	assert.FileExists(t, filepath.Join(unzipDir, "test_results/testdata/example-reports-dir/example-1.xml"))
+	assert.Equal(t, 3, server.Requests())
+}
+
+func TestSubmit_Run_withShard(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	log := logger.New()
+	s := &Submit{
+		client:         http.DefaultClient,
+		endpoint:       server.URL,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+		shardIndex:     0,
+		shardTotal:     4,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+
+	obj := server.Object("buildpulse-uploads", key)
+	require.NotNil(t, obj)
+	assert.Equal(t, "0", obj.Metadata()["shard-index"])
+	assert.Equal(t, "4", obj.Metadata()["shard-total"])
+}
+
+func TestSubmit_Run_withDigestKeyScheme(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	log := logger.New()
+	newSubmit := func(sha string) *Submit {
+		return &Submit{
+			client:   http.DefaultClient,
+			endpoint: server.URL,
+			idgen:    func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+			logger:   log,
+			version:  &metadata.Version{Number: "v1.2.3"},
+			commitResolver: metadata.NewStaticCommitResolver(
+				&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"},
+				log,
+			),
+			envs: map[string]string{
+				"GITHUB_ACTIONS": "true",
+				"GITHUB_SHA":     sha,
+			},
+			paths:        []string{"testdata/example-reports-dir/example-1.xml"},
+			bucket:       "buildpulse-uploads",
+			accountID:    42,
+			repositoryID: 8675309,
+			keyScheme:    keySchemeDigest,
+			credentials: credentials{
+				AccessKeyID:     "some-access-key-id",
+				SecretAccessKey: "some-secret-access-key",
+			},
+		}
+	}
+
+	key, err := newSubmit("aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb").Run()
+	require.NoError(t, err)
+	assert.Regexp(t, `^42/8675309/buildpulse-sha256-[0-9a-f]{64}\.gz$`, key)
+
+	obj := server.Object("buildpulse-uploads", key)
+	require.NotNil(t, obj)
+	assert.Equal(t, "digest", obj.Metadata()["key-scheme"])
+
+	gzpath := filepath.Join(t.TempDir(), "upload.tar.gz")
+	require.NoError(t, os.WriteFile(gzpath, obj.Body, 0600))
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(gzpath, unzipDir))
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":key_scheme: digest\n")
+
+	// A retry with the same results overwrites the earlier object
+	retryKey, err := newSubmit("aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb").Run()
+	require.NoError(t, err)
+	assert.Equal(t, key, retryKey)
+	assert.Equal(t, []string{key}, server.Objects("buildpulse-uploads"))
+
+	// The same results for another commit get a separate object
+	otherKey, err := newSubmit("eeeeeeeeeeeeeeeeeeeeffffffffffffffffffff").Run()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, otherKey)
+	assert.Len(t, server.Objects("buildpulse-uploads"), 2)
+}
+
+func Test_bundle_digestKeyIdentity(t *testing.T) {
+	// The jobs of a matrix or the shards of a check get separate objects, rather
+	// than overwriting each other's
+	digest := func(configure func(s *Submit)) string {
+		s := newBundleSubmit()
+		s.keyScheme = keySchemeDigest
+		configure(s)
+		_, err := s.bundle(context.Background())
+		require.NoError(t, err)
+		return s.digest
+	}
+
+	base := digest(func(s *Submit) {})
+	linux := digest(func(s *Submit) { s.tagArgs = tagFlag{"os:linux", "ruby"} })
+	digests := map[string]string{
+		"base":  base,
+		"linux": linux,
+		"macos": digest(func(s *Submit) { s.tagArgs = tagFlag{"os:macos", "ruby"} }),
+		"meta":  digest(func(s *Submit) { s.meta = metaFlag{"browser": "chrome"} }),
+		"shard": digest(func(s *Submit) { s.shardIndex, s.shardTotal = 1, 4 }),
+		"quota": digest(func(s *Submit) { s.quotaID = "nightly" }),
+	}
+	seen := make(map[string]string)
+	for name, d := range digests {
+		if other, dup := seen[d]; dup {
+			t.Errorf("%s and %s have the same digest", name, other)
+		}
+		seen[d] = name
+	}
+
+	// The order in which the tags are given doesn't matter
+	assert.Equal(t, linux, digest(func(s *Submit) { s.tagArgs = tagFlag{"ruby", "os:linux"} }))
+	assert.Equal(t, base, digest(func(s *Submit) {}))
+}
+
+func Test_bundle(t *testing.T) {
+	t.Run("bundle with coverage files provided", func(t *testing.T) {
+		envs := map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+		}
+
+		log := logger.New()
+		s := &Submit{
+			logger:         log,
+			version:        &metadata.Version{Number: "v1.2.3"},
+			commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+			envs:           envs,
+			paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+			coveragePaths:  []string{"testdata/example-reports-dir/coverage/report.xml", "testdata/example-reports-dir/coverage/report-2.xml"},
+			bucket:         "buildpulse-uploads",
+			accountID:      42,
+			repositoryID:   8675309,
+		}
+
+		path, err := s.bundle(context.Background())
+		require.NoError(t, err)
+
+		unzipDir := t.TempDir()
+		err = archiver.Unarchive(path, unzipDir)
+		require.NoError(t, err)
+
+		// Verify buildpulse.yml is present and contains expected content
+		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(yaml), ":ci_provider: github-actions")
+		assert.Contains(t, string(yaml), ":commit: aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb")
+		assert.Contains(t, string(yaml), ":tree: ccccccccccccccccccccdddddddddddddddddddd")
+		assert.Contains(t, string(yaml), ":reporter_version: v1.2.3")
+
+		// Verify test report XML file is present and contains expected content
+		assertEqualContent(t,
+			"testdata/example-reports-dir/example-1.xml",
+			filepath.Join(unzipDir, "test_results/testdata/example-reports-dir/example-1.xml"),
+		)
+
+		// Verify coverage files are present and contains expected content
+		assertEqualContent(t,
 			"testdata/example-reports-dir/coverage/report.xml",
 			filepath.Join(unzipDir, "coverage/testdata/example-reports-dir/coverage/report.xml"),
 		)
@@ -553,7 +2024,7 @@ func Test_bundle(t *testing.T) {
 			repositoryID:   8675309,
 		}
 
-		path, err := s.bundle()
+		path, err := s.bundle(context.Background())
 		require.NoError(t, err)
 
 		unzipDir := t.TempDir()
@@ -613,70 +2084,866 @@ func Test_bundle(t *testing.T) {
 			repositoryID:                 8675309,
 		}
 
-		path, err := s.bundle()
+		path, err := s.bundle(context.Background())
+		require.NoError(t, err)
+
+		unzipDir := t.TempDir()
+		err = archiver.Unarchive(path, unzipDir)
+		require.NoError(t, err)
+
+		// Verify buildpulse.yml is present and contains expected content
+		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(yaml), ":ci_provider: github-actions")
+		assert.Contains(t, string(yaml), ":commit: aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb")
+		assert.Contains(t, string(yaml), ":tree: ccccccccccccccccccccdddddddddddddddddddd")
+		assert.Contains(t, string(yaml), ":reporter_version: v1.2.3")
+
+		// Verify test report XML file is present and contains expected content
+		assertEqualContent(t,
+			"testdata/example-reports-dir/example-1.xml",
+			filepath.Join(unzipDir, "test_results/testdata/example-reports-dir/example-1.xml"),
+		)
+
+		ignoredCoverageReportPath := filepath.Join(unzipDir, "coverage/testdata/example-reports-dir/coverage/report.xml")
+		_, err = os.Stat(ignoredCoverageReportPath)
+		assert.True(t, os.IsNotExist(err))
+
+		// Verify buildpulse.log is present and contains expected content
+		logdata, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.log"))
+		require.NoError(t, err)
+		assert.Contains(t, string(logdata), "Gathering metadata to describe the build")
+	})
+
+	t.Run("bundle with tags", func(t *testing.T) {
+		envs := map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+		}
+
+		log := logger.New()
+		s := &Submit{
+			logger:         log,
+			version:        &metadata.Version{Number: "v1.2.3"},
+			commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+			envs:           envs,
+			paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+			bucket:         "buildpulse-uploads",
+			accountID:      42,
+			repositoryID:   8675309,
+			tagsString:     "tag1 tag2",
+		}
+
+		path, err := s.bundle(context.Background())
+		require.NoError(t, err)
+
+		unzipDir := t.TempDir()
+		err = archiver.Unarchive(path, unzipDir)
+		require.NoError(t, err)
+
+		// Verify buildpulse.yml is present and contains expected content
+		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(yaml), "- tag1")
+		assert.Contains(t, string(yaml), "- tag2")
+	})
+
+	t.Run("bundle without tags", func(t *testing.T) {
+		envs := map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+		}
+
+		log := logger.New()
+		s := &Submit{
+			logger:         log,
+			version:        &metadata.Version{Number: "v1.2.3"},
+			commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+			envs:           envs,
+			paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+			bucket:         "buildpulse-uploads",
+			accountID:      42,
+			repositoryID:   8675309,
+		}
+
+		path, err := s.bundle(context.Background())
 		require.NoError(t, err)
 
 		unzipDir := t.TempDir()
 		err = archiver.Unarchive(path, unzipDir)
 		require.NoError(t, err)
 
-		// Verify buildpulse.yml is present and contains expected content
-		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
-		require.NoError(t, err)
-		assert.Contains(t, string(yaml), ":ci_provider: github-actions")
-		assert.Contains(t, string(yaml), ":commit: aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb")
-		assert.Contains(t, string(yaml), ":tree: ccccccccccccccccccccdddddddddddddddddddd")
-		assert.Contains(t, string(yaml), ":reporter_version: v1.2.3")
+		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(yaml), ":tags:")
+	})
+}
+
+func Test_bundle_trx(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+
+	log := logger.New()
+	s := &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         envs,
+		paths:                        []string{"testdata/example-dotnet-solution/artifacts/stray.trx"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	// Verify the TRX report was converted to JUnit XML
+	junit, err := os.ReadFile(filepath.Join(unzipDir, "test_results/testdata/example-dotnet-solution/artifacts/stray.trx.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuites tests="6" failures="1" errors="0" skipped="1"`)
+	assert.Contains(t, string(junit), `<testcase name="Calculator.Tests.AdditionTests.AddsPairs(a: 2, b: 2)" classname="Calculator.Tests.AdditionTests"`)
+}
+
+func Test_bundle_json(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+
+	log := logger.New()
+	s := &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         envs,
+		paths:                        []string{"testdata/example-js-reports/vitest.json"},
+		jsonFormats:                  map[string]string{"testdata/example-js-reports/vitest.json": "vitest"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	// Verify the Vitest report was converted to JUnit XML
+	junit, err := os.ReadFile(filepath.Join(unzipDir, "test_results/testdata/example-js-reports/vitest.json.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuites tests="5" failures="1" errors="1" skipped="1"`)
+	assert.Contains(t, string(junit), `<testcase name="adds floats" classname="math sum"`)
+}
+
+func Test_bundle_mixedFormats(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+
+	log := logger.New()
+	s := &Submit{
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           envs,
+		paths: []string{
+			"testdata/example-reports-dir/example-1.xml",
+			"testdata/example-go-reports/unit.json",
+			"testdata/example-dotnet-solution/artifacts/stray.trx",
+		},
+		pathFormats: map[string]string{
+			"testdata/example-go-reports/unit.json":                "gotest",
+			"testdata/example-dotnet-solution/artifacts/stray.trx": "trx",
+		},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(unzipDir, "test_results/testdata/example-reports-dir/example-1.xml"))
+	assert.FileExists(t, filepath.Join(unzipDir, "test_results/testdata/example-dotnet-solution/artifacts/stray.trx.xml"))
+
+	// Verify the go test report was converted to JUnit XML
+	junit, err := os.ReadFile(filepath.Join(unzipDir, "test_results/testdata/example-go-reports/unit.json.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<testcase name="TestAdd" classname="example.com/app/math"`)
+
+	// Verify the format of each report is recorded in buildpulse.yml
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":report_formats:\n")
+	assert.Contains(t, string(yaml), "test_results/testdata/example-reports-dir/example-1.xml: junit\n")
+	assert.Contains(t, string(yaml), "test_results/testdata/example-go-reports/unit.json.xml: gotest\n")
+	assert.Contains(t, string(yaml), "test_results/testdata/example-dotnet-solution/artifacts/stray.trx.xml: trx\n")
+}
+
+func Test_bundle_nonUTF8(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+
+	log := logger.New()
+	s := &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         envs,
+		paths:                        []string{"testdata/example-encoded-reports/shift_jis.xml"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	// Verify the report was transcoded to UTF-8
+	junit, err := os.ReadFile(filepath.Join(unzipDir, "test_results/testdata/example-encoded-reports/shift_jis.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, string(junit), `<testcase name="足し算ができる" classname="計算機"/>`)
+}
+
+func Test_bundle_nestedReports(t *testing.T) {
+	tests := []struct {
+		name   string
+		unwrap bool
+		want   []string
+		log    string
+	}{
+		{
+			name:   "unwrapped",
+			unwrap: true,
+			want:   []string{`<testsuite name="LoginTests"`, `<testsuite name="CartTests"`},
+			log:    "Unwrapping 2 test suites embedded in testdata/example-nested-reports/wrapper.xml",
+		},
+		{
+			name:   "not unwrapped",
+			unwrap: false,
+			want:   []string{`<testsuite name="run-tests"`, `<![CDATA[<?xml`},
+			log:    "⚠️ testdata/example-nested-reports/wrapper.xml embeds 2 test suites in its text",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New()
+			s := &Submit{
+				logger:                       log,
+				version:                      &metadata.Version{Number: "v1.2.3"},
+				commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+				envs:                         map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+				paths:                        []string{"testdata/example-nested-reports/wrapper.xml"},
+				unwrapNestedReports:          tt.unwrap,
+				bucket:                       "buildpulse-uploads",
+				disableCoverageAutoDiscovery: true,
+				accountID:                    42,
+				repositoryID:                 8675309,
+			}
+
+			path, err := s.bundle(context.Background())
+			require.NoError(t, err)
+
+			unzipDir := t.TempDir()
+			err = archiver.Unarchive(path, unzipDir)
+			require.NoError(t, err)
+
+			junit, err := os.ReadFile(filepath.Join(unzipDir, "test_results/testdata/example-nested-reports/wrapper.xml"))
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, string(junit), want)
+			}
+			assert.Contains(t, log.Text(), tt.log)
+		})
+	}
+}
+
+func Test_bundle_simplecov(t *testing.T) {
+	resultsets := []string{
+		"testdata/example-simplecov/worker-1/coverage/.resultset.json",
+		"testdata/example-simplecov/worker-2/coverage/.resultset.json",
+	}
+
+	tests := []struct {
+		name    string
+		convert bool
+		want    []string
+	}{
+		{
+			name:    "converted",
+			convert: true,
+			want:    []string{"coverage/simplecov.lcov"},
+		},
+		{
+			name:    "not converted",
+			convert: false,
+			want: []string{
+				"coverage/testdata/example-simplecov/worker-1/coverage/.resultset.json",
+				"coverage/testdata/example-simplecov/worker-2/coverage/.resultset.json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New()
+			s := &Submit{
+				logger:           log,
+				version:          &metadata.Version{Number: "v1.2.3"},
+				commitResolver:   metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+				envs:             map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+				paths:            []string{"testdata/example-reports-dir/example-1.xml"},
+				coveragePaths:    resultsets,
+				convertSimpleCov: tt.convert,
+				bucket:           "buildpulse-uploads",
+				accountID:        42,
+				repositoryID:     8675309,
+			}
+
+			path, err := s.bundle(context.Background())
+			require.NoError(t, err)
+
+			unzipDir := t.TempDir()
+			err = archiver.Unarchive(path, unzipDir)
+			require.NoError(t, err)
+
+			var got []string
+			err = filepath.Walk(filepath.Join(unzipDir, "coverage"), func(p string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(unzipDir, p)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+
+	t.Run("merged across workers", func(t *testing.T) {
+		s := &Submit{logger: logger.New()}
+
+		rest, lcov, err := s.simplecovToLCOV(append([]string{"testdata/example-coverage/coverage.xml"}, resultsets...))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"testdata/example-coverage/coverage.xml"}, rest)
+
+		data, err := os.ReadFile(lcov)
+		require.NoError(t, err)
+		assert.Equal(t, "TN:\nSF:/app/app/models/user.rb\nDA:1,2\nDA:2,2\nDA:4,1\nDA:5,2\nLF:4\nLH:4\nend_of_record\n", string(data))
+		assert.Contains(t, s.logger.Text(), "Converted 2 SimpleCov result sets (1 source files) to coverage/simplecov.lcov")
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		malformed := filepath.Join(t.TempDir(), ".resultset.json")
+		err := os.WriteFile(malformed, []byte("not json"), 0644)
+		require.NoError(t, err)
+
+		s := &Submit{logger: logger.New()}
+		rest, lcov, err := s.simplecovToLCOV([]string{malformed})
+		require.NoError(t, err)
+		assert.Equal(t, []string{malformed}, rest)
+		assert.Empty(t, lcov)
+		assert.Contains(t, s.logger.Text(), "⚠️ Unable to convert "+malformed+" to LCOV")
+	})
+}
+
+func Test_bundle_backfill(t *testing.T) {
+	log := logger.New()
+	commit := &metadata.Commit{
+		TreeSHA:     "ccccccccccccccccccccdddddddddddddddddddd",
+		CommittedAt: time.Date(2020, 7, 11, 1, 10, 0, 0, time.UTC),
+	}
+	s := &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(commit, log),
+		envs:                         map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+		paths:                        []string{"testdata/example-retried-reports/lint.xml"}, // 2020-07-11T01:00:00
+		backfill:                     true,
+		backfillFrom:                 "testdata/example-backfill/buildpulse.yml",
+		timestampOverride:            time.Date(2020, 7, 11, 1, 2, 3, 0, time.FixedZone("", -5*60*60)),
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+	require.NoError(t, s.detectAttempts())
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	// Verify buildpulse.yml marks the backfill, keeps the original timestamp,
+	// and doesn't flag the report as stale
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":backfill: true\n")
+	assert.Contains(t, string(yaml), ":timestamp: 2020-07-11T06:02:03Z\n")
+	assert.Contains(t, string(yaml), ":timestamp_zone: \"-05:00\"\n")
+	assert.NotContains(t, string(yaml), ":stale_reports:")
+	assert.NotContains(t, log.Text(), "predates the commit")
+	assert.Contains(t, log.Text(), "Using the timestamp of the original submission (from testdata/example-backfill/buildpulse.yml)")
+}
+
+func Test_bundle_truncateFailures(t *testing.T) {
+	dump := strings.Repeat("at com.example.Frame.call(Frame.java:42)\n", 100)
+	reportPath := filepath.Join(t.TempDir(), "results.xml")
+	err := os.WriteFile(reportPath, []byte(`<testsuite name="suite"><testcase name="fails"><failure message="boom">`+dump+`</failure></testcase></testsuite>`), 0644)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		kb   int
+		want string
+	}{
+		{name: "truncated", kb: 1, want: "\n[... 2052 bytes truncated ...]\n"},
+		{name: "disabled", kb: 0, want: dump},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New()
+			s := &Submit{
+				logger:                       log,
+				version:                      &metadata.Version{Number: "v1.2.3"},
+				commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+				envs:                         map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+				paths:                        []string{reportPath},
+				truncateFailuresKB:           tt.kb,
+				bucket:                       "buildpulse-uploads",
+				disableCoverageAutoDiscovery: true,
+				accountID:                    42,
+				repositoryID:                 8675309,
+			}
+
+			path, err := s.bundle(context.Background())
+			require.NoError(t, err)
+
+			unzipDir := t.TempDir()
+			err = archiver.Unarchive(path, unzipDir)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(filepath.Join(unzipDir, s.reportTarPath(reportPath)))
+			require.NoError(t, err)
+			assert.Contains(t, string(data), tt.want)
+			if tt.kb > 0 {
+				assert.Contains(t, string(data), `original-size="4100"`)
+				assert.Contains(t, log.Text(), "Truncated 1 failures in "+reportPath)
+			}
+		})
+	}
+}
+
+func Test_bundle_truncateFailuresMalformed(t *testing.T) {
+	// The XML decoder rejects the ANSI escape, but BuildPulse accepts the
+	// report, so it's submitted as is rather than failing the submission
+	content := "<testsuite name=\"suite\"><testcase name=\"fails\"><failure>\x1b[31mred\x1b[0m</failure></testcase></testsuite>"
+	reportPath := filepath.Join(t.TempDir(), "results.xml")
+	require.NoError(t, os.WriteFile(reportPath, []byte(content), 0644))
+
+	s := newBundleSubmit()
+	s.paths = []string{reportPath}
+	s.truncateFailuresKB = 64
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(path, unzipDir))
+
+	data, err := os.ReadFile(filepath.Join(unzipDir, s.reportTarPath(reportPath)))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.Contains(t, s.logger.Text(), "⚠️ Unable to truncate the failures in "+reportPath+", so it's submitted as is: ")
+}
+
+func Test_bundle_excoveralls(t *testing.T) {
+	reports := []string{
+		"testdata/example-excoveralls/partition-1/cover/excoveralls.json",
+		"testdata/example-excoveralls/partition-2/cover/excoveralls.json",
+	}
+
+	t.Run("converted", func(t *testing.T) {
+		log := logger.New()
+		s := &Submit{
+			logger:             log,
+			version:            &metadata.Version{Number: "v1.2.3"},
+			commitResolver:     metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+			envs:               map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+			paths:              []string{"testdata/example-reports-dir/example-1.xml"},
+			coveragePaths:      reports,
+			convertExcoveralls: true,
+			bucket:             "buildpulse-uploads",
+			accountID:          42,
+			repositoryID:       8675309,
+		}
+
+		path, err := s.bundle(context.Background())
+		require.NoError(t, err)
+
+		unzipDir := t.TempDir()
+		err = archiver.Unarchive(path, unzipDir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(unzipDir, "coverage/excoveralls.lcov"))
+		require.NoError(t, err)
+		assert.Equal(t, "TN:\nSF:lib/my_app/user.ex\nDA:2,3\nDA:4,1\nLF:2\nLH:2\nend_of_record\n", string(data))
+		assert.NoFileExists(t, filepath.Join(unzipDir, "coverage", reports[0]))
+		assert.Contains(t, log.Text(), "Converted 2 excoveralls reports (1 source files) to coverage/excoveralls.lcov")
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		malformed := filepath.Join(t.TempDir(), "excoveralls.json")
+		err := os.WriteFile(malformed, []byte(`{"coverage": 80.0}`), 0644)
+		require.NoError(t, err)
+
+		s := &Submit{logger: logger.New()}
+		rest, lcov, err := s.excoverallsToLCOV([]string{malformed})
+		require.NoError(t, err)
+		assert.Equal(t, []string{malformed}, rest)
+		assert.Empty(t, lcov)
+		assert.Contains(t, s.logger.Text(), "submitting the excoveralls reports as is")
+	})
+}
+
+func Test_bundle_strictCommitResolution(t *testing.T) {
+	tests := []struct {
+		name   string
+		strict bool
+		errMsg string
+	}{
+		{name: "strict", strict: true, errMsg: "commit lookup unsuccessful: no such commit"},
+		{name: "lenient", strict: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Submit{
+				logger:                       logger.New(),
+				version:                      &metadata.Version{Number: "v1.2.3"},
+				commitResolver:               &failingCommitResolver{err: errors.New("no such commit")},
+				envs:                         map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+				paths:                        []string{"testdata/example-reports-dir/example-1.xml"},
+				strictCommitResolution:       tt.strict,
+				bucket:                       "buildpulse-uploads",
+				disableCoverageAutoDiscovery: true,
+				accountID:                    42,
+				repositoryID:                 8675309,
+			}
+
+			_, err := s.bundle(context.Background())
+			if tt.errMsg != "" {
+				assert.EqualError(t, err, tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_bundle_redactsLog(t *testing.T) {
+	log := logger.New()
+	s := &Submit{
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs: map[string]string{
+			"GITHUB_ACTIONS":               "true",
+			"GITHUB_SHA":                   "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+			"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+		},
+		paths:                        []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+	log.Printf("Some command echoed some-secret-access-key")
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	logdata, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(logdata), "Some command echoed [REDACTED]")
+	assert.NotContains(t, string(logdata), "some-secret-access-key")
+}
+
+func Test_bundle_retryPlugin(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+
+	log := logger.New()
+	s := &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         envs,
+		paths:                        []string{"testdata/example-surefire-reports/TEST-com.example.AppTest.xml"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
+
+	// Verify buildpulse.yml records the retry plugin
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":retry_plugin: maven-surefire\n")
+	assert.Contains(t, string(yaml), ":retry_plugin_max_retries: 2\n")
+}
+
+// newBundleSubmit returns a Submit that bundles example-1.xml from a GitHub
+// Actions build, for tests of what the bundle records.
+func newBundleSubmit() *Submit {
+	log := logger.New()
+	return &Submit{
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs: map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+		},
+		paths:                        []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+}
+
+// bundledYAML bundles s and returns the buildpulse.yml in the bundle.
+func bundledYAML(t *testing.T, s *Submit) string {
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
+
+	unzipDir := t.TempDir()
+	require.NoError(t, archiver.Unarchive(path, unzipDir))
+
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	return string(yaml)
+}
+
+func Test_bundle_shard(t *testing.T) {
+	s := newBundleSubmit()
+	s.shardIndex, s.shardTotal = 0, 4
+
+	// Verify buildpulse.yml records the shard, including the first shard's index
+	yaml := bundledYAML(t, s)
+	assert.Contains(t, yaml, ":shard_index: 0\n")
+	assert.Contains(t, yaml, ":shard_total: 4\n")
+}
+
+func Test_bundle_customMetadata(t *testing.T) {
+	s := newBundleSubmit()
+	s.meta = metaFlag{"browser": "chrome"}
+
+	assert.Contains(t, bundledYAML(t, s), ":custom_metadata:\n    browser: chrome\n")
+}
+
+func Test_bundle_project(t *testing.T) {
+	s := newBundleSubmit()
+	s.project = "packages/api"
+
+	assert.Contains(t, bundledYAML(t, s), ":project: packages/api\n")
+}
+
+func Test_bundle_timestampOverride(t *testing.T) {
+	s := newBundleSubmit()
+	s.timestampOverride = time.Date(2020, 7, 11, 1, 2, 3, 0, time.FixedZone("", -5*60*60))
+
+	// Verify buildpulse.yml records the overridden timestamp in UTC, with its zone
+	assert.Contains(t, bundledYAML(t, s), ":timestamp: 2020-07-11T06:02:03Z\n:timestamp_zone: \"-05:00\"\n")
+}
+
+func Test_bundle_resolveBaseBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Some Author", "GIT_AUTHOR_EMAIL=author@example.com",
+			"GIT_COMMITTER_NAME=Some Committer", "GIT_COMMITTER_EMAIL=committer@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "--initial-branch", "main")
+	git("commit", "--quiet", "--allow-empty", "-m", "Base")
+	mergeBase := git("rev-parse", "HEAD")
+	git("branch", "develop")
+	git("checkout", "--quiet", "-b", "feature")
+	git("commit", "--quiet", "--allow-empty", "-m", "Feature")
+	head := git("rev-parse", "HEAD")
+
+	tests := []struct {
+		name       string
+		baseRef    string
+		wantBranch string
+	}{
+		{name: "FromRepository", baseRef: "", wantBranch: "main"},
+		{name: "FromProvider", baseRef: "develop", wantBranch: "develop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New()
+			s := &Submit{
+				logger:  log,
+				version: &metadata.Version{Number: "v1.2.3"},
+				commitResolver: metadata.NewStaticCommitResolver(
+					&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"},
+					log,
+				),
+				envs: map[string]string{
+					"GITHUB_ACTIONS":  "true",
+					"GITHUB_SHA":      head,
+					"GITHUB_BASE_REF": tt.baseRef,
+				},
+				paths:                        []string{"testdata/example-reports-dir/example-1.xml"},
+				bucket:                       "buildpulse-uploads",
+				disableCoverageAutoDiscovery: true,
+				accountID:                    42,
+				repositoryID:                 8675309,
+				repositoryPath:               dir,
+				resolveBaseBranch:            true,
+			}
+
+			path, err := s.bundle(context.Background())
+			require.NoError(t, err)
+
+			unzipDir := t.TempDir()
+			err = archiver.Unarchive(path, unzipDir)
+			require.NoError(t, err)
+
+			yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+			require.NoError(t, err)
+			assert.Contains(t, string(yaml), ":base_branch: "+tt.wantBranch+"\n")
+			assert.Contains(t, string(yaml), ":merge_base: "+mergeBase+"\n")
+		})
+	}
+}
+
+func Test_bundle_staleReports(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
 
-		// Verify test report XML file is present and contains expected content
-		assertEqualContent(t,
-			"testdata/example-reports-dir/example-1.xml",
-			filepath.Join(unzipDir, "test_results/testdata/example-reports-dir/example-1.xml"),
-		)
+	log := logger.New()
+	commit := &metadata.Commit{
+		TreeSHA:     "ccccccccccccccccccccdddddddddddddddddddd",
+		CommittedAt: time.Date(2020, 7, 11, 1, 10, 0, 0, time.UTC),
+	}
+	s := &Submit{
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(commit, log),
+		envs:           envs,
+		paths: []string{
+			"testdata/example-retried-reports/lint.xml",              // 2020-07-11T01:00:00
+			"testdata/example-retried-reports/attempt-2/results.xml", // 2020-07-11T01:05:09
+		},
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
+	require.NoError(t, s.detectAttempts())
 
-		ignoredCoverageReportPath := filepath.Join(unzipDir, "coverage/testdata/example-reports-dir/coverage/report.xml")
-		_, err = os.Stat(ignoredCoverageReportPath)
-		assert.True(t, os.IsNotExist(err))
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
 
-		// Verify buildpulse.log is present and contains expected content
-		logdata, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.log"))
-		require.NoError(t, err)
-		assert.Contains(t, string(logdata), "Gathering metadata to describe the build")
-	})
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
 
-	t.Run("bundle with tags", func(t *testing.T) {
-		envs := map[string]string{
-			"GITHUB_ACTIONS": "true",
-			"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
-		}
+	// Verify buildpulse.yml records only the report that predates the commit
+	// by more than the tolerance
+	yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":stale_reports:\n    test_results/testdata/example-retried-reports/lint.xml: predates_commit\n")
+	assert.NotContains(t, string(yaml), "attempt-2/results.xml: predates")
+	assert.Contains(t, log.Text(), "⚠️ testdata/example-retried-reports/lint.xml predates the commit")
+}
 
-		log := logger.New()
-		s := &Submit{
-			logger:         log,
-			version:        &metadata.Version{Number: "v1.2.3"},
-			commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
-			envs:           envs,
-			paths:          []string{"testdata/example-reports-dir/example-1.xml"},
-			bucket:         "buildpulse-uploads",
-			accountID:      42,
-			repositoryID:   8675309,
-			tagsString:     "tag1 tag2",
-		}
+func Test_bundle_phpunit(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
 
-		path, err := s.bundle()
-		require.NoError(t, err)
+	log := logger.New()
+	s := &Submit{
+		logger:                       log,
+		version:                      &metadata.Version{Number: "v1.2.3"},
+		commitResolver:               metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:                         envs,
+		paths:                        []string{"testdata/example-php-project/reports/junit.xml"},
+		framework:                    "phpunit",
+		repositoryPath:               "testdata/example-php-project",
+		bucket:                       "buildpulse-uploads",
+		disableCoverageAutoDiscovery: true,
+		accountID:                    42,
+		repositoryID:                 8675309,
+	}
 
-		unzipDir := t.TempDir()
-		err = archiver.Unarchive(path, unzipDir)
-		require.NoError(t, err)
+	path, err := s.bundle(context.Background())
+	require.NoError(t, err)
 
-		// Verify buildpulse.yml is present and contains expected content
-		yaml, err := os.ReadFile(filepath.Join(unzipDir, "buildpulse.yml"))
-		require.NoError(t, err)
+	unzipDir := t.TempDir()
+	err = archiver.Unarchive(path, unzipDir)
+	require.NoError(t, err)
 
-		assert.Contains(t, string(yaml), "- tag1")
-		assert.Contains(t, string(yaml), "- tag2")
-	})
+	// Verify the file paths in the report are relative to the repository root
+	junit, err := os.ReadFile(filepath.Join(unzipDir, "test_results/testdata/example-php-project/reports/junit.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<testcase name="testCreatesUser" class="Tests\Unit\UserTest" classname="Tests.Unit.UserTest" file="tests/Unit/UserTest.php"`)
+	assert.NotContains(t, string(junit), `file="/home/runner`)
 }
 
 func Test_upload(t *testing.T) {
@@ -750,6 +3017,7 @@ func Test_upload(t *testing.T) {
 				client:       &http.Client{Transport: r},
 				idgen:        func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
 				logger:       logger.New(),
+				version:      &metadata.Version{Number: "v1.2.3"},
 				bucket:       tt.bucket,
 				accountID:    tt.accountID,
 				repositoryID: 8675309,
@@ -758,7 +3026,358 @@ func Test_upload(t *testing.T) {
 					SecretAccessKey: tt.secretAccessKey,
 				},
 			}
-			key, err := s.upload("testdata/example-test-results.tar.gz")
+			key, err := s.upload(context.Background(), "testdata/example-test-results.tar.gz")
+			if tt.err == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, "42/8675309/buildpulse-00000000-0000-0000-0000-000000000000.gz", key)
+			} else {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func Test_upload_objectHeaders(t *testing.T) {
+	r, err := recorder.New("testdata/s3-success")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, r.Stop())
+	}()
+
+	r.SetMatcher(interactionMatcher)
+
+	var headers http.Header
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		headers = req.Header.Clone()
+		return r.RoundTrip(req)
+	})
+
+	s := &Submit{
+		client:       &http.Client{Transport: transport},
+		idgen:        func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:       logger.New(),
+		version:      &metadata.Version{Number: "v1.2.3"},
+		bucket:       "buildpulse-uploads",
+		accountID:    42,
+		repositoryID: 8675309,
+		ciProvider:   "github-actions",
+		credentials: credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+		},
+	}
+	_, err = s.upload(context.Background(), "testdata/example-test-results.tar.gz")
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/gzip", headers.Get("Content-Type"))
+	assert.Equal(t, "v1.2.3", headers.Get("X-Amz-Meta-Reporter-Version"))
+	assert.Equal(t, bundleLayoutVersion, headers.Get("X-Amz-Meta-Schema-Version"))
+	assert.Equal(t, "github-actions", headers.Get("X-Amz-Meta-Ci-Provider"))
+}
+
+func Test_upload_s3Endpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		accelerate   bool
+		dualStack    bool
+		noAccelerate bool // whether the bucket rejects accelerated requests
+		hosts        []string
+		log          string
+	}{
+		{
+			name:  "default",
+			hosts: []string{"buildpulse-uploads.s3.amazonaws.com"},
+		},
+		{
+			name:       "accelerate",
+			accelerate: true,
+			hosts:      []string{"buildpulse-uploads.s3-accelerate.amazonaws.com"},
+		},
+		{
+			name:      "dual-stack",
+			dualStack: true,
+			hosts:     []string{"buildpulse-uploads.s3.dualstack.us-east-1.amazonaws.com"},
+		},
+		{
+			name:       "accelerate and dual-stack",
+			accelerate: true,
+			dualStack:  true,
+			hosts:      []string{"buildpulse-uploads.s3-accelerate.dualstack.amazonaws.com"},
+		},
+		{
+			name:         "falls back when the bucket rejects acceleration",
+			accelerate:   true,
+			noAccelerate: true,
+			hosts: []string{
+				"buildpulse-uploads.s3-accelerate.amazonaws.com",
+				"buildpulse-uploads.s3.amazonaws.com",
+			},
+			log: "S3 Transfer Acceleration is unavailable for bucket buildpulse-uploads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := s3test.NewServer("buildpulse-uploads")
+			defer server.Close()
+
+			// Route the virtual-hosted-style requests for the AWS endpoints to the
+			// fake server, using path-style addressing
+			var hosts []string
+			transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				hosts = append(hosts, req.URL.Host)
+				if tt.noAccelerate && strings.Contains(req.URL.Host, ".s3-accelerate.") {
+					body := `<Error><Code>InvalidRequest</Code><Message>S3 Transfer Acceleration is not configured on this bucket</Message></Error>`
+					return &http.Response{
+						StatusCode: http.StatusBadRequest,
+						Header:     http.Header{"Content-Type": []string{"application/xml"}},
+						Body:       io.NopCloser(strings.NewReader(body)),
+						Request:    req,
+					}, nil
+				}
+
+				bucket, _, _ := strings.Cut(req.URL.Host, ".")
+				u, err := url.Parse(server.URL + "/" + bucket + req.URL.Path)
+				require.NoError(t, err)
+				u.RawQuery = req.URL.RawQuery
+
+				req = req.Clone(req.Context())
+				req.URL = u
+				req.Host = u.Host
+				return http.DefaultTransport.RoundTrip(req)
+			})
+
+			log := logger.New()
+			s := &Submit{
+				client:       &http.Client{Transport: transport},
+				idgen:        func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+				logger:       log,
+				version:      &metadata.Version{Number: "v1.2.3"},
+				bucket:       "buildpulse-uploads",
+				accountID:    42,
+				repositoryID: 8675309,
+				s3Accelerate: tt.accelerate,
+				s3DualStack:  tt.dualStack,
+				credentials: credentials{
+					AccessKeyID:     "some-access-key-id",
+					SecretAccessKey: "some-secret-access-key",
+				},
+			}
+			key, err := s.upload(context.Background(), "testdata/example-test-results.tar.gz")
+			require.NoError(t, err)
+			assert.Equal(t, []string{key}, server.Objects("buildpulse-uploads"))
+			assert.Equal(t, tt.hosts, hosts)
+			if tt.log != "" {
+				assert.Contains(t, log.Text(), tt.log)
+			}
+		})
+	}
+}
+
+func Test_upload_s3Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*s3test.Server)
+		err   string
+		log   string
+	}{
+		{
+			name:  "retries without the ACL when the bucket disallows ACLs",
+			setup: func(s *s3test.Server) { s.DisallowACLs() },
+			log:   "Bucket buildpulse-uploads doesn't allow ACLs",
+		},
+		{
+			name:  "access denied",
+			setup: func(s *s3test.Server) { s.FailNext(1, http.StatusForbidden, "AccessDenied") },
+			err:   "access to bucket buildpulse-uploads was denied: check that the credentials are allowed to s3:PutObject and s3:PutObjectAcl",
+		},
+		{
+			name: "access denied without the ACL",
+			setup: func(s *s3test.Server) {
+				s.FailNext(1, http.StatusBadRequest, "AccessControlListNotSupported")
+				s.FailNext(1, http.StatusForbidden, "AccessDenied")
+			},
+			err: "access to bucket buildpulse-uploads was denied: check that the credentials are allowed to s3:PutObject in the bucket: AccessDenied",
+		},
+		{
+			name:  "object lock not enabled",
+			setup: func(s *s3test.Server) { s.FailNext(1, http.StatusNotFound, "ObjectLockConfigurationNotFoundError") },
+			err:   "bucket buildpulse-uploads requires uploads to use Object Lock, but Object Lock isn't enabled on it",
+		},
+		{
+			name:  "other errors",
+			setup: func(s *s3test.Server) { s.FailNext(1, http.StatusBadRequest, "InvalidRequest") },
+			err:   "InvalidRequest: Injected failure",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := s3test.NewServer("buildpulse-uploads")
+			defer server.Close()
+			tt.setup(server)
+
+			log := logger.New()
+			s := &Submit{
+				client:       server.Client(),
+				endpoint:     server.URL,
+				idgen:        func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+				logger:       log,
+				version:      &metadata.Version{Number: "v1.2.3"},
+				bucket:       "buildpulse-uploads",
+				accountID:    42,
+				repositoryID: 8675309,
+				credentials: credentials{
+					AccessKeyID:     "some-access-key-id",
+					SecretAccessKey: "some-secret-access-key",
+				},
+			}
+			key, err := s.upload(context.Background(), "testdata/example-test-results.tar.gz")
+			if tt.err == "" {
+				require.NoError(t, err)
+				obj := server.Object("buildpulse-uploads", key)
+				require.NotNil(t, obj)
+				assert.Empty(t, obj.Header.Get("X-Amz-Acl"))
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+			}
+			if tt.log != "" {
+				assert.Contains(t, log.Text(), tt.log)
+			}
+		})
+	}
+}
+
+func TestSubmit_analyzeShardTimes(t *testing.T) {
+	timingFile := filepath.Join(t.TempDir(), "timing.json")
+
+	log := logger.New()
+	s := &Submit{
+		logger: log,
+		paths: []string{
+			"testdata/example-sharded-reports/shard-1/results.xml",
+			"testdata/example-sharded-reports/shard-2/results.xml",
+			"testdata/example-reports-dir/example-1.xml",
+		},
+		shardPattern:    `shard-(\d+)/`,
+		shardRegex:      regexp.MustCompile(`shard-(\d+)/`),
+		shardTimingFile: timingFile,
+	}
+
+	meta := &metadata.Metadata{}
+	require.NoError(t, s.analyzeShardTimes(meta))
+	assert.Equal(t, map[string]float64{"1": 125.5, "2": 30.5}, meta.ShardTimes)
+
+	assert.Contains(t, log.Text(), "Skipping testdata/example-reports-dir/example-1.xml for shard timing")
+	assert.Contains(t, log.Text(), "Shards are imbalanced: the slowest shard took 125.5s and the fastest took 30.5s")
+	assert.Contains(t, log.Text(), "A rebalanced split of the test suites would take at most 95.5s per shard")
+
+	data, err := os.ReadFile(timingFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"shards": {"1": 125.5, "2": 30.5},
+		"ratio": 4.114754098360656,
+		"suggested": [
+			{"seconds": 95.5, "suites": ["CheckoutTest"]},
+			{"seconds": 60.5, "suites": ["LoginTest", "ProfileTest", "SearchTest"]}
+		]
+	}`, string(data))
+}
+
+func TestSubmit_analyzeShardTimes_balanced(t *testing.T) {
+	log := logger.New()
+	s := &Submit{
+		logger:       log,
+		paths:        []string{"testdata/example-sharded-reports/shard-1/results.xml"},
+		shardPattern: `shard-(\d+)/`,
+		shardRegex:   regexp.MustCompile(`shard-(\d+)/`),
+	}
+
+	meta := &metadata.Metadata{}
+	require.NoError(t, s.analyzeShardTimes(meta))
+	assert.Equal(t, map[string]float64{"1": 125.5}, meta.ShardTimes)
+	assert.NotContains(t, log.Text(), "imbalanced")
+}
+
+func TestSubmit_detectHarnessVersions(t *testing.T) {
+	dir := t.TempDir()
+	gotestsum := `<testsuites><testsuite name="example.com/some/pkg"><properties><property name="go.version" value="go1.21.5 linux/amd64"></property></properties></testsuite></testsuites>`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.xml"), []byte(gotestsum), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "malformed.xml"), []byte("<testsuite"), 0600))
+
+	repo := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "go.mod"), []byte("module example.com/some/module\n\ntoolchain go1.20.0\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "Gemfile.lock"), []byte("GEM\n  specs:\n    rspec-core (3.12.2)\n"), 0600))
+
+	log := logger.New()
+	s := &Submit{
+		logger:         log,
+		paths:          []string{filepath.Join(dir, "go.xml"), filepath.Join(dir, "malformed.xml")},
+		repositoryPath: repo,
+	}
+
+	// The version in the report takes precedence over the one in go.mod
+	assert.Equal(t, map[string]string{"go": "1.21.5", "rspec": "3.12.2"}, s.detectHarnessVersions())
+	assert.Contains(t, log.Text(), "Unable to inspect "+filepath.Join(dir, "malformed.xml")+" for test harness versions")
+}
+
+// roundTripperFunc adapts a function to the http.RoundTripper interface.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_upload_withNextCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		bucket  string
+		err     string
+	}{
+		{
+			name:    "falls back to next credentials when credentials are rejected",
+			fixture: "testdata/s3-fallback-to-next-credentials",
+			bucket:  "buildpulse-uploads",
+			err:     "",
+		},
+		{
+			name:    "does not fall back for other errors",
+			fixture: "testdata/s3-bad-bucket",
+			bucket:  "some-bogus-bucket",
+			err:     "NoSuchBucket",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := recorder.New(tt.fixture)
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, r.Stop())
+			}()
+
+			r.SetMatcher(interactionMatcher)
+
+			s := &Submit{
+				client:       &http.Client{Transport: r},
+				idgen:        func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+				logger:       logger.New(),
+				version:      &metadata.Version{Number: "v1.2.3"},
+				bucket:       tt.bucket,
+				accountID:    42,
+				repositoryID: 8675309,
+				credentials: credentials{
+					AccessKeyID:     "some-bogus-access-key-id",
+					SecretAccessKey: secretAccessKey,
+				},
+				nextCredentials: &credentials{
+					AccessKeyID:     accessKeyID,
+					SecretAccessKey: secretAccessKey,
+				},
+			}
+			key, err := s.upload(context.Background(), "testdata/example-test-results.tar.gz")
 			if tt.err == "" {
 				assert.NoError(t, err)
 				assert.Equal(t, "42/8675309/buildpulse-00000000-0000-0000-0000-000000000000.gz", key)
@@ -811,13 +3430,62 @@ func Test_xmlPathsFromDir(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reportPaths, err := xmlPathsFromDir(tt.path)
+			reportPaths, err := xmlPathsFromDir(tt.path, walkOptions{})
 			require.NoError(t, err)
 			assert.ElementsMatch(t, tt.want, reportPaths)
 		})
 	}
 }
 
+func Test_pathsFromArgs_mixedCaseExtensions(t *testing.T) {
+	dir := "testdata/example-mixed-case-reports"
+
+	xmls, err := xmlPathsFromArgs([]string{dir}, walkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir + "/Cobertura.XML"}, xmls)
+
+	trxs, err := trxPathsFromArgs([]string{dir}, walkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir + "/Results.TRX"}, trxs)
+
+	jsons, err := jsonPathsFromArgs([]string{dir + "/*"}, walkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir + "/Vitest.JSON"}, jsons)
+}
+
+func TestSubmit_coveragePathsInferred_mixedCase(t *testing.T) {
+	s := &Submit{repositoryPath: "testdata/example-mixed-case-reports"}
+
+	paths, err := s.coveragePathsInferred()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"testdata/example-mixed-case-reports/Cobertura.XML",
+		"testdata/example-mixed-case-reports/LCOV.INFO",
+	}, paths)
+}
+
+func TestSubmit_coveragePathsInferred_simplecov(t *testing.T) {
+	s := &Submit{repositoryPath: "testdata/example-simplecov"}
+
+	paths, err := s.coveragePathsInferred()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"testdata/example-simplecov/worker-1/coverage/.resultset.json",
+		"testdata/example-simplecov/worker-2/coverage/.resultset.json",
+	}, paths)
+}
+
+func Test_xmlPathsFromDir_includeHidden(t *testing.T) {
+	reportPaths, err := xmlPathsFromDir("testdata/example-reports-dir", walkOptions{includeHidden: true})
+	require.NoError(t, err)
+	assert.Contains(t, reportPaths, "testdata/example-reports-dir/.cache/junk.xml")
+
+	// A hidden directory that's named explicitly is searched regardless
+	reportPaths, err = xmlPathsFromDir("testdata/example-reports-dir/.cache", walkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdata/example-reports-dir/.cache/junk.xml"}, reportPaths)
+}
+
 func Test_xmlPathsFromGlob(t *testing.T) {
 	tests := []struct {
 		name string
@@ -852,7 +3520,7 @@ func Test_xmlPathsFromGlob(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reportPaths, err := xmlPathsFromGlob(tt.path)
+			reportPaths, err := xmlPathsFromGlob(tt.path, walkOptions{})
 			require.NoError(t, err)
 			assert.ElementsMatch(t, tt.want, reportPaths)
 		})
@@ -890,7 +3558,7 @@ type stubCommitResolver struct {
 	source string
 }
 
-func (s *stubCommitResolver) Lookup(sha string) (*metadata.Commit, error) {
+func (s *stubCommitResolver) Lookup(ctx context.Context, sha string) (*metadata.Commit, error) {
 	return &metadata.Commit{}, nil
 }
 
@@ -898,11 +3566,25 @@ func (s *stubCommitResolver) Source() string {
 	return s.source
 }
 
+var _ metadata.CommitResolver = (*failingCommitResolver)(nil)
+
+type failingCommitResolver struct {
+	err error
+}
+
+func (f *failingCommitResolver) Lookup(ctx context.Context, sha string) (*metadata.Commit, error) {
+	return nil, f.err
+}
+
+func (f *failingCommitResolver) Source() string {
+	return "Repository"
+}
+
 var _ CommitResolverFactory = (*stubCommitResolverFactory)(nil)
 
 type stubCommitResolverFactory struct{}
 
-func (s *stubCommitResolverFactory) NewFromRepository(path string) (metadata.CommitResolver, error) {
+func (s *stubCommitResolverFactory) NewFromRepository(path string, deepen bool) (metadata.CommitResolver, error) {
 	return &stubCommitResolver{source: "Repository"}, nil
 }
 
@@ -0,0 +1,43 @@
+package submit
+
+import (
+	"github.com/buildpulse/test-reporter/internal/telemetry"
+)
+
+// newPing returns the telemetry ping for this submission, as far as it's known
+// before the submission starts.
+func (s *Submit) newPing() *telemetry.Ping {
+	return &telemetry.Ping{
+		ReporterVersion: s.version.Number,
+		GoOS:            s.version.GoOS,
+	}
+}
+
+// sendPing completes ping with the outcome of the submission (err) and sends
+// it. Failures to send are logged but otherwise ignored. Simulated submissions
+// (-simulate) aren't reported, so they don't skew the error rates.
+func (s *Submit) sendPing(ping *telemetry.Ping, err error) {
+	if s.simulate != "" {
+		s.logger.Printf("Skipping telemetry for simulated submission")
+		return
+	}
+
+	ping.CIProvider = s.ciProvider
+	ping.Outcome = telemetry.OutcomeSuccess
+	if err != nil {
+		ping.Outcome = telemetry.OutcomeFailure
+	} else {
+		ping.FailureClass = ""
+	}
+
+	data, merr := ping.MarshalIndent()
+	if merr != nil {
+		s.logger.Printf("Unable to send telemetry: %v", merr)
+		return
+	}
+	s.logger.Debugf("Sending telemetry to %s%s:\n%s", s.apiURL, telemetry.Path, data)
+
+	if serr := telemetry.Send(s.client, s.apiURL, "buildpulse-test-reporter/"+s.version.Number, ping); serr != nil {
+		s.logger.Printf("Unable to send telemetry: %v", serr)
+	}
+}
@@ -0,0 +1,99 @@
+package submit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/buildpulse/test-reporter/internal/telemetry"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmit_Run_withTelemetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		accessKey string
+		simulate  string
+		want      *telemetry.Ping
+	}{
+		{
+			name:      "Success",
+			accessKey: "some-access-key-id",
+			want:      &telemetry.Ping{ReporterVersion: "v1.2.3", GoOS: "linux", CIProvider: "github-actions", Outcome: "success"},
+		},
+		{
+			name:      "Failure",
+			accessKey: "some-other-access-key-id",
+			want:      &telemetry.Ping{ReporterVersion: "v1.2.3", GoOS: "linux", CIProvider: "github-actions", Outcome: "failure", FailureClass: "upload"},
+		},
+		{
+			name:      "Simulation",
+			accessKey: "some-access-key-id",
+			simulate:  simulateUploadFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pings []*telemetry.Ping
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/reporter/telemetry", r.URL.Path)
+				ping := &telemetry.Ping{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(ping))
+				pings = append(pings, ping)
+			}))
+			defer api.Close()
+
+			server := s3test.NewServer("buildpulse-uploads")
+			defer server.Close()
+			server.AllowAccessKeys(tt.accessKey)
+
+			log := logger.New()
+			s := &Submit{
+				client:         http.DefaultClient,
+				apiURL:         api.URL,
+				endpoint:       server.URL,
+				idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+				logger:         log,
+				version:        &metadata.Version{Number: "v1.2.3", GoOS: "linux"},
+				commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+				envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+				paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+				bucket:         "buildpulse-uploads",
+				accountID:      42,
+				repositoryID:   8675309,
+				credentials: credentials{
+					AccessKeyID:     "some-access-key-id",
+					SecretAccessKey: "some-secret-access-key",
+				},
+				simulate:  tt.simulate,
+				telemetry: true,
+			}
+
+			_, err := s.Run()
+			if tt.want != nil && tt.want.Outcome == "success" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			if tt.want == nil {
+				assert.Empty(t, pings)
+				assert.Contains(t, log.Text(), "Skipping telemetry for simulated submission")
+				return
+			}
+			require.Len(t, pings, 1)
+			got := pings[0]
+			assert.Greater(t, got.DurationMillis, int64(-1))
+			got.DurationMillis, got.BundleMillis, got.UploadMillis = 0, 0, 0
+			assert.Equal(t, tt.want, got)
+			assert.Contains(t, log.Text(), "Sending telemetry to "+api.URL+"/reporter/telemetry")
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package submit
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout returns a copy of ctx that's done after d, with the given cause,
+// or that's only done when ctx is if d isn't positive (i.e., no timeout).
+func withTimeout(ctx context.Context, d time.Duration, cause error) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, d, cause)
+}
+
+// timeoutCause returns the cause of ctx being done (e.g., the timeout that
+// expired), if it is, or else err. A phase that's cut short by a timeout
+// typically fails with whatever error the interrupted operation returns, so
+// this reports which timeout to raise instead.
+func timeoutCause(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	return err
+}
@@ -0,0 +1,87 @@
+package submit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmit_Run_timeouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeout       time.Duration
+		bundleTimeout time.Duration
+		uploadTimeout time.Duration
+		err           string
+	}{
+		{
+			name:          "Bundle",
+			bundleTimeout: time.Nanosecond,
+			err:           "bundling timed out after 1ns (-bundle-timeout)",
+		},
+		{
+			name:          "Upload",
+			uploadTimeout: 100 * time.Millisecond,
+			err:           "upload timed out after 100ms (-upload-timeout)",
+		},
+		{
+			name:          "Global",
+			timeout:       100 * time.Millisecond,
+			uploadTimeout: time.Minute,
+			err:           "submission timed out after 100ms (-timeout)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := s3test.NewServer("buildpulse-uploads")
+			defer server.Close()
+			server.Stall(time.Minute)
+
+			s := newSimulationSubmit(server, "")
+			s.timeout = tt.timeout
+			s.bundleTimeout = tt.bundleTimeout
+			s.uploadTimeout = tt.uploadTimeout
+
+			start := time.Now()
+			_, err := s.Run()
+			assert.EqualError(t, err, tt.err)
+			assert.Less(t, time.Since(start), 10*time.Second)
+			assert.Empty(t, server.Objects("buildpulse-uploads"))
+		})
+	}
+}
+
+func TestSubmit_Run_timeoutDuringCommitLookup(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	s := newSimulationSubmit(server, "")
+	s.commitResolver = new(hangingCommitResolver)
+	s.bundleTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := s.Run()
+	assert.EqualError(t, err, "bundling timed out after 100ms (-bundle-timeout)")
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Empty(t, server.Objects("buildpulse-uploads"))
+}
+
+var _ metadata.CommitResolver = (*hangingCommitResolver)(nil)
+
+// hangingCommitResolver never finds a commit, like a fetch that never
+// completes, so its lookups end only when their context is done.
+type hangingCommitResolver struct{}
+
+func (h *hangingCommitResolver) Lookup(ctx context.Context, sha string) (*metadata.Commit, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (h *hangingCommitResolver) Source() string {
+	return "Repository"
+}
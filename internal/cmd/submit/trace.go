@@ -0,0 +1,32 @@
+package submit
+
+import (
+	"net/http"
+)
+
+// traceTransport adds the CI job's W3C trace context (see
+// metadata.TraceContext) to each request made through it, so that the requests
+// show up in the pipeline's trace.
+type traceTransport struct {
+	next        http.RoundTripper
+	traceparent string
+	tracestate  string
+}
+
+func newTraceTransport(next http.RoundTripper, traceparent string, tracestate string) *traceTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &traceTransport{next: next, traceparent: traceparent, tracestate: tracestate}
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("traceparent", t.traceparent)
+	if t.tracestate != "" {
+		req.Header.Set("tracestate", t.tracestate)
+	}
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,76 @@
+package submit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmit_Run_withTraceContext(t *testing.T) {
+	server := s3test.NewServer("buildpulse-uploads")
+	defer server.Close()
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	log := logger.New()
+	s := &Submit{
+		client:         &http.Client{Transport: newTraceTransport(nil, traceparent, "rojo=00f067aa0ba902b7")},
+		endpoint:       server.URL,
+		idgen:          func() uuid.UUID { return uuid.MustParse("00000000-0000-0000-0000-000000000000") },
+		logger:         log,
+		version:        &metadata.Version{Number: "v1.2.3"},
+		commitResolver: metadata.NewStaticCommitResolver(&metadata.Commit{TreeSHA: "ccccccccccccccccccccdddddddddddddddddddd"}, log),
+		envs:           map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb", "TRACEPARENT": traceparent},
+		paths:          []string{"testdata/example-reports-dir/example-1.xml"},
+		bucket:         "buildpulse-uploads",
+		accountID:      42,
+		repositoryID:   8675309,
+		credentials: credentials{
+			AccessKeyID:     "some-access-key-id",
+			SecretAccessKey: "some-secret-access-key",
+		},
+	}
+
+	key, err := s.Run()
+	require.NoError(t, err)
+
+	obj := server.Object("buildpulse-uploads", key)
+	require.NotNil(t, obj)
+	assert.Equal(t, traceparent, obj.Header.Get("traceparent"))
+	assert.Equal(t, "rojo=00f067aa0ba902b7", obj.Header.Get("tracestate"))
+}
+
+func TestSubmit_Run_withTraceContextAndCABundle(t *testing.T) {
+	server := s3test.NewTLSServer("buildpulse-uploads")
+	defer server.Close()
+
+	// The trace context is propagated whenever TRACEPARENT is set, without any
+	// flag, so it mustn't get in the way of the CA bundle
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	s := newCABundleSubmit(t, server, map[string]string{"TRACEPARENT": traceparent})
+
+	key, err := s.Run()
+	require.NoError(t, err)
+	assert.Equal(t, traceparent, server.Object("buildpulse-uploads", key).Header.Get("traceparent"))
+}
+
+func Test_traceTransport_withoutTracestate(t *testing.T) {
+	var got http.Header
+	client := &http.Client{Transport: newTraceTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")}
+
+	resp, err := client.Get("https://buildpulse.example.com/reporter/versions.json")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", got.Get("traceparent"))
+	assert.NotContains(t, got, "Tracestate")
+}
@@ -0,0 +1,53 @@
+package submit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// withCABundle returns a copy of client whose transport trusts only the
+// certificates in the named PEM file, as aws-sdk-go does for AWS_CA_BUNDLE.
+// aws-sdk-go can only load the bundle into an *http.Transport, so it's loaded
+// here, before the transport is wrapped (e.g., by wrapTransport), and the SDK
+// is kept from loading it again (see newS3Session).
+func withCABundle(client *http.Client, name string) (*http.Client, error) {
+	var t *http.Transport
+	switch next := client.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = next.Clone()
+	default:
+		return nil, fmt.Errorf("unsupported transport %T", next)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.RootCAs = pool
+
+	c := *client
+	c.Transport = t
+	return &c, nil
+}
+
+// wrapTransport returns a copy of client whose transport is wrap applied to
+// client's, leaving client (which may be shared, e.g., http.DefaultClient)
+// as is.
+func wrapTransport(client *http.Client, wrap func(next http.RoundTripper) http.RoundTripper) *http.Client {
+	c := *client
+	c.Transport = wrap(client.Transport)
+	return &c
+}
@@ -0,0 +1,90 @@
+package submit
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/s3test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCABundleSubmit returns a Submit, initialized with the given flags and
+// additional environment variables, that reaches the TLS server by trusting its
+// certificate through AWS_CA_BUNDLE alone, as in CI behind a TLS-intercepting
+// proxy.
+func newCABundleSubmit(t *testing.T, server *s3test.Server, extraEnvs map[string]string, args ...string) *Submit {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, server.CertificatePEM(), 0644))
+
+	// The SDK reads the bundle from the process's environment
+	t.Setenv("AWS_CA_BUNDLE", bundle)
+
+	envs := map[string]string{
+		"AWS_CA_BUNDLE":                bundle,
+		"BUILDPULSE_ACCESS_KEY_ID":     "some-access-key-id",
+		"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+		"BUILDPULSE_BUCKET":            "buildpulse-uploads",
+		"BUILDPULSE_S3_ENDPOINT":       server.URL,
+		"GITHUB_ACTIONS":               "true",
+		"GITHUB_SHA":                   "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+	for k, v := range extraEnvs {
+		envs[k] = v
+	}
+
+	s := NewSubmit(&metadata.Version{Number: "v1.2.3"}, logger.New())
+	args = append([]string{"testdata/example-reports-dir/example-1.xml", "--account-id", "42", "--repository-id", "8675309", "--tree", "ccccccccccccccccccccdddddddddddddddddddd"}, args...)
+	require.NoError(t, s.Init(args, envs, new(stubCommitResolverFactory)))
+
+	return s
+}
+
+func TestSubmit_Run_withCABundle(t *testing.T) {
+	server := s3test.NewTLSServer("buildpulse-uploads")
+	defer server.Close()
+
+	s := newCABundleSubmit(t, server, nil)
+	_, err := s.Run()
+	require.NoError(t, err)
+	assert.Len(t, server.Objects("buildpulse-uploads"), 1)
+
+	// The shared client is left as is
+	assert.Nil(t, http.DefaultClient.Transport)
+}
+
+func Test_withCABundle(t *testing.T) {
+	server := s3test.NewTLSServer()
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, server.CertificatePEM(), 0644))
+
+	t.Run("DefaultTransport", func(t *testing.T) {
+		client, err := withCABundle(http.DefaultClient, bundle)
+		require.NoError(t, err)
+		assert.NotSame(t, http.DefaultClient, client)
+		assert.Nil(t, http.DefaultClient.Transport)
+
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+
+	t.Run("UnsupportedTransport", func(t *testing.T) {
+		client := &http.Client{Transport: newTraceTransport(nil, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "")}
+		_, err := withCABundle(client, bundle)
+		assert.EqualError(t, err, "unsupported transport *submit.traceTransport")
+	})
+
+	t.Run("NotPEM", func(t *testing.T) {
+		notPEM := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0644))
+		_, err := withCABundle(http.DefaultClient, notPEM)
+		assert.EqualError(t, err, "no PEM certificates found")
+	})
+}
@@ -0,0 +1,129 @@
+package submit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// versionSkewMinorReleases is the number of minor releases by which the running
+// version can trail the latest release before the reporter warns that it's
+// outdated.
+const versionSkewMinorReleases = 3
+
+// versionCheckTimeout bounds the time spent checking for newer versions, so that
+// an unreachable API doesn't delay the submission.
+const versionCheckTimeout = 5 * time.Second
+
+// A versionManifest describes the releases of the reporter, as advertised by
+// the BuildPulse API.
+type versionManifest struct {
+	Latest           string `json:"latest"`
+	MinimumSupported string `json:"minimum_supported"`
+}
+
+// checkVersion compares the running version with the version manifest. It
+// returns an error if the running version is below the minimum supported
+// version, and logs a warning if it's significantly outdated. Failure to fetch
+// the manifest is logged but otherwise ignored.
+func (s *Submit) checkVersion(ctx context.Context) error {
+	current, ok := parseSemver(s.version.Number)
+	if !ok {
+		s.logger.Printf("Skipping version check for unreleased version: %s", s.version.Number)
+		return nil
+	}
+
+	m, err := s.fetchVersionManifest(ctx)
+	if err != nil {
+		s.logger.Printf("Unable to check for newer versions of the reporter: %v", err)
+		return nil
+	}
+
+	if min, ok := parseSemver(m.MinimumSupported); ok && current.less(min) {
+		return fmt.Errorf("test-reporter %s is no longer supported: upgrade to %s or later (see https://github.com/buildpulse/test-reporter/releases), or drop --version-check (or BUILDPULSE_VERSION_CHECK) to skip this check", s.version.Number, m.MinimumSupported)
+	}
+
+	if latest, ok := parseSemver(m.Latest); ok && current.trails(latest, versionSkewMinorReleases) {
+		s.logger.Printf("⚠️ test-reporter %s is outdated: the latest version is %s (see https://github.com/buildpulse/test-reporter/releases)", s.version.Number, m.Latest)
+	}
+
+	return nil
+}
+
+func (s *Submit) fetchVersionManifest(ctx context.Context) (*versionManifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, versionCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.apiURL+"/reporter/versions.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "buildpulse-test-reporter/"+s.version.Number)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	m := &versionManifest{}
+	if err := json.NewDecoder(resp.Body).Decode(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// A semver is the major, minor, and patch numbers of a release version.
+type semver [3]int
+
+var semverRegex = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)$`)
+
+// parseSemver parses a release version such as "v0.30.1" or "0.30.1". It
+// returns false for anything else, including development and pre-release
+// versions.
+func parseSemver(s string) (semver, bool) {
+	m := semverRegex.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, false
+	}
+
+	var v semver
+	for i := range v {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return semver{}, false
+		}
+		v[i] = n
+	}
+
+	return v, true
+}
+
+func (v semver) less(other semver) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+
+	return false
+}
+
+// trails reports whether v is at least n minor releases behind other, or
+// behind it by a major release.
+func (v semver) trails(other semver, n int) bool {
+	if v[0] != other[0] {
+		return v[0] < other[0]
+	}
+
+	return other[1]-v[1] >= n
+}
@@ -0,0 +1,128 @@
+package submit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmit_checkVersion(t *testing.T) {
+	manifest := `{"latest": "v0.30.2", "minimum_supported": "v0.20.0"}`
+
+	tests := []struct {
+		name     string
+		version  string
+		response string
+		status   int
+		errMsg   string
+		logMsg   string
+	}{
+		{
+			name:     "Latest",
+			version:  "v0.30.2",
+			response: manifest,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "SlightlyOutdated",
+			version:  "0.28.0",
+			response: manifest,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "SignificantlyOutdated",
+			version:  "0.27.5",
+			response: manifest,
+			status:   http.StatusOK,
+			logMsg:   "test-reporter 0.27.5 is outdated: the latest version is v0.30.2",
+		},
+		{
+			name:     "Unsupported",
+			version:  "v0.19.9",
+			response: manifest,
+			status:   http.StatusOK,
+			errMsg:   "test-reporter v0.19.9 is no longer supported: upgrade to v0.20.0 or later",
+		},
+		{
+			name:     "DevelopmentVersion",
+			version:  "development",
+			response: manifest,
+			status:   http.StatusOK,
+			logMsg:   "Skipping version check for unreleased version: development",
+		},
+		{
+			name:     "ManifestUnavailable",
+			version:  "v0.19.9",
+			response: "",
+			status:   http.StatusServiceUnavailable,
+			logMsg:   "Unable to check for newer versions of the reporter: unexpected response: 503 Service Unavailable",
+		},
+		{
+			name:     "MalformedManifest",
+			version:  "v0.19.9",
+			response: "<html>",
+			status:   http.StatusOK,
+			logMsg:   "Unable to check for newer versions of the reporter: invalid character",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/reporter/versions.json", r.URL.Path)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			log := logger.New()
+			s := &Submit{
+				client:  http.DefaultClient,
+				apiURL:  server.URL,
+				logger:  log,
+				version: &metadata.Version{Number: tt.version},
+			}
+
+			err := s.checkVersion(context.Background())
+			if tt.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.errMsg)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tt.logMsg != "" {
+				assert.Contains(t, log.Text(), tt.logMsg)
+			} else {
+				assert.NotContains(t, log.Text(), "outdated")
+			}
+		})
+	}
+}
+
+func Test_parseSemver(t *testing.T) {
+	tests := []struct {
+		version string
+		want    semver
+		ok      bool
+	}{
+		{version: "v0.30.2", want: semver{0, 30, 2}, ok: true},
+		{version: "1.2.3", want: semver{1, 2, 3}, ok: true},
+		{version: "v1.2.3-rc.1", ok: false},
+		{version: "development", ok: false},
+		{version: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, ok := parseSemver(tt.version)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package submit
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// walkOptions controls how directories are searched for reports.
+type walkOptions struct {
+	// includeHidden descends into hidden directories (e.g., .cache)
+	includeHidden bool
+
+	// followSymlinks descends into symbolic links to directories (e.g., reports
+	// symlinked from a shared volume)
+	followSymlinks bool
+
+	// literal takes paths that aren't directories as exact paths rather than
+	// glob patterns (e.g., the paths listed by -paths-from, which may contain
+	// characters like [ that glob would interpret)
+	literal bool
+}
+
+// walk walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, as filepath.Walk does. If
+// followSymlinks is true, symbolic links to directories (including root) are
+// walked too, with their contents reported beneath the link's path. Each
+// directory is walked only once, however many links lead to it, so that a link
+// to one of its own ancestors can't make the walk loop forever.
+func walk(root string, followSymlinks bool, fn filepath.WalkFunc) error {
+	if !followSymlinks {
+		return filepath.Walk(root, fn)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFollowing(root, info, fn, make(map[string]bool))
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+// walkFollowing walks path, following symbolic links, and skipping the
+// directories in visited (keyed by their paths with symbolic links resolved).
+func walkFollowing(path string, info os.FileInfo, fn filepath.WalkFunc, visited map[string]bool) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
+
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err := fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+
+		// Stat follows the link; a broken link is reported as the link itself
+		fi, err := os.Stat(name)
+		if err != nil {
+			if fi, err = os.Lstat(name); err != nil {
+				if err := fn(name, nil, err); err != nil && err != filepath.SkipDir {
+					return err
+				}
+				continue
+			}
+		}
+
+		err = walkFollowing(name, fi, fn, visited)
+		if err != nil {
+			if err != filepath.SkipDir {
+				return err
+			}
+			if !fi.IsDir() {
+				// As with filepath.Walk, SkipDir from a file skips the rest of
+				// the directory
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
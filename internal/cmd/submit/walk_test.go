@@ -0,0 +1,78 @@
+package submit
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSymlinkedReportsDir returns a directory whose reports live outside of it,
+// in a directory reached through a symbolic link, along with a link back to
+// the directory itself.
+func newSymlinkedReportsDir(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires extra privileges on Windows")
+	}
+
+	shared := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(shared, "report.xml"), []byte("<testsuite/>"), 0644))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "local.xml"), []byte("<testsuite/>"), 0644))
+	require.NoError(t, os.Symlink(shared, filepath.Join(dir, "shared")))
+	require.NoError(t, os.Symlink(dir, filepath.Join(dir, "loop")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken")))
+
+	return dir
+}
+
+func Test_xmlPathsFromDir_symlinks(t *testing.T) {
+	dir := newSymlinkedReportsDir(t)
+
+	paths, err := xmlPathsFromDir(dir, walkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "local.xml")}, paths)
+
+	paths, err = xmlPathsFromDir(dir, walkOptions{followSymlinks: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "local.xml"),
+		filepath.Join(dir, "shared", "report.xml"),
+	}, paths)
+}
+
+func Test_glob_symlinks(t *testing.T) {
+	dir := newSymlinkedReportsDir(t)
+	pattern := filepath.Join(dir, "**", "*.xml")
+
+	paths, err := glob(pattern, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "local.xml")}, paths)
+
+	paths, err = glob(pattern, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "local.xml"),
+		filepath.Join(dir, "shared", "report.xml"),
+	}, paths)
+}
+
+func Test_walk_symlinkedRoot(t *testing.T) {
+	dir := newSymlinkedReportsDir(t)
+
+	var paths []string
+	err := walk(filepath.Join(dir, "shared"), true, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		paths = append(paths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "shared"),
+		filepath.Join(dir, "shared", "report.xml"),
+	}, paths)
+}
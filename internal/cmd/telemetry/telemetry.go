@@ -0,0 +1,95 @@
+package telemetry
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/buildpulse/test-reporter/internal/telemetry"
+)
+
+// Telemetry represents the task of showing the telemetry ping that `submit`
+// would send from the current build environment, so that users can review it
+// before opting in.
+type Telemetry struct {
+	fs      *flag.FlagSet
+	logger  logger.Logger
+	version *metadata.Version
+
+	envs    map[string]string
+	enabled bool
+}
+
+// NewTelemetry creates a new Telemetry instance.
+func NewTelemetry(version *metadata.Version, log logger.Logger) *Telemetry {
+	t := &Telemetry{
+		fs:      flag.NewFlagSet("telemetry", flag.ContinueOnError),
+		logger:  log,
+		version: version,
+	}
+	t.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return t
+}
+
+// Init populates t from args, which are the action (show), and envs. It
+// returns an error if the args are malformed.
+func (t *Telemetry) Init(args []string, envs map[string]string) error {
+	if err := t.fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch t.fs.NArg() {
+	case 0:
+		return fmt.Errorf("missing action: supported values are: show")
+	case 1:
+	default:
+		return fmt.Errorf("unexpected argument: %s", t.fs.Arg(1))
+	}
+
+	if action := t.fs.Arg(0); action != "show" {
+		return fmt.Errorf("invalid value \"%s\" for action: supported values are: show", action)
+	}
+
+	enabled, err := telemetry.Enabled(envs)
+	if err != nil {
+		return err
+	}
+	t.enabled = enabled
+	t.envs = envs
+
+	return nil
+}
+
+// Run writes the ping that a successful submission would send to w, as JSON,
+// and whether telemetry is on to status. A real ping has the durations of the
+// submission (which are zero here) and, for a failed submission, the stage
+// that failed.
+func (t *Telemetry) Run(w io.Writer, status io.Writer) error {
+	provider, err := metadata.DetectProvider(t.envs, t.logger)
+	if err != nil {
+		t.logger.Printf("Unable to detect the CI provider: %v", err)
+	}
+
+	ping := &telemetry.Ping{
+		ReporterVersion: t.version.Number,
+		GoOS:            t.version.GoOS,
+		CIProvider:      provider,
+		Outcome:         telemetry.OutcomeSuccess,
+	}
+	data, err := ping.MarshalIndent()
+	if err != nil {
+		return err
+	}
+
+	if t.enabled {
+		fmt.Fprintf(status, "Telemetry is on (BUILDPULSE_TELEMETRY=on). After each submission, the following is sent to the BuildPulse API (%s):\n", telemetry.Path)
+	} else {
+		fmt.Fprintf(status, "Telemetry is off. With BUILDPULSE_TELEMETRY=on, the following would be sent to the BuildPulse API (%s) after each submission:\n", telemetry.Path)
+	}
+
+	_, err = w.Write(data)
+	return err
+}
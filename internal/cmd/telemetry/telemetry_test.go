@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetry_Run(t *testing.T) {
+	tests := []struct {
+		name   string
+		envs   map[string]string
+		status string
+	}{
+		{
+			name:   "off",
+			envs:   map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"},
+			status: "Telemetry is off.",
+		},
+		{
+			name:   "on",
+			envs:   map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb", "BUILDPULSE_TELEMETRY": "on"},
+			status: "Telemetry is on (BUILDPULSE_TELEMETRY=on).",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := NewTelemetry(&metadata.Version{Number: "v1.2.3", GoOS: "linux"}, logger.New())
+			require.NoError(t, tel.Init([]string{"show"}, tt.envs))
+
+			var out, status bytes.Buffer
+			require.NoError(t, tel.Run(&out, &status))
+			assert.Contains(t, status.String(), tt.status)
+
+			var ping map[string]interface{}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &ping))
+			assert.Equal(t, "v1.2.3", ping["reporter_version"])
+			assert.Equal(t, "linux", ping["go_os"])
+			assert.Equal(t, "github-actions", ping["ci_provider"])
+			assert.Equal(t, "success", ping["outcome"])
+			assert.NotContains(t, out.String(), "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb")
+		})
+	}
+}
+
+func TestTelemetry_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		envs   map[string]string
+		errMsg string
+	}{
+		{args: nil, errMsg: "missing action: supported values are: show"},
+		{args: []string{"send"}, errMsg: `invalid value "send" for action: supported values are: show`},
+		{args: []string{"show", "extra"}, errMsg: "unexpected argument: extra"},
+		{args: []string{"show"}, envs: map[string]string{"BUILDPULSE_TELEMETRY": "yes"}, errMsg: `invalid value "yes" for environment variable BUILDPULSE_TELEMETRY: supported values are: on, off`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.errMsg, func(t *testing.T) {
+			tel := NewTelemetry(&metadata.Version{}, logger.New())
+			assert.EqualError(t, tel.Init(tt.args, tt.envs), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,388 @@
+// Package update implements a command that replaces the running binary with a
+// release of the reporter from GitHub, so that CI images that download the
+// binary once can keep it current without being rebuilt.
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+)
+
+// releasesRepository is the GitHub repository whose releases are installed.
+const releasesRepository = "buildpulse/test-reporter"
+
+// The names of the release assets that describe the binaries: the checksums of
+// the binaries, and the cosign signature and certificate of the checksums.
+const (
+	checksumsAsset   = "checksums.txt"
+	signatureAsset   = "checksums.txt.sig"
+	certificateAsset = "checksums.txt.pem"
+)
+
+// The identity that signs the releases: the repository's GitHub Actions
+// workflows, signing keylessly with cosign.
+const (
+	certificateIdentityRegexp = `^https://github\.com/buildpulse/test-reporter/`
+	certificateOIDCIssuer     = "https://token.actions.githubusercontent.com"
+)
+
+// requestTimeout bounds the time spent on each request to GitHub, including
+// downloading the binary.
+const requestTimeout = 5 * time.Minute
+
+// A release is a release of the reporter, as represented by the GitHub API.
+type release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []asset `json:"assets"`
+}
+
+// An asset is a file attached to a release.
+type asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Update represents the task of replacing the running binary with a release of
+// the reporter.
+type Update struct {
+	client  *http.Client
+	fs      *flag.FlagSet
+	logger  logger.Logger
+	version *metadata.Version
+
+	apiURL     string // base URL of the GitHub API
+	token      string
+	executable string // path of the binary to replace
+	cosign     string // name or path of the cosign binary
+
+	check            bool
+	requireSignature bool
+	target           string
+}
+
+// NewUpdate creates a new Update instance.
+func NewUpdate(version *metadata.Version, log logger.Logger) *Update {
+	u := &Update{
+		client:  http.DefaultClient,
+		fs:      flag.NewFlagSet("update", flag.ContinueOnError),
+		logger:  log,
+		version: version,
+		apiURL:  metadata.DefaultGitHubAPIURL,
+		cosign:  "cosign",
+	}
+
+	u.fs.BoolVar(&u.check, "check", false, "Report whether an update is available, without installing it")
+	u.fs.BoolVar(&u.requireSignature, "require-signature", false, "Fail unless the release's signature can be verified with cosign")
+	u.fs.StringVar(&u.target, "version", "", "Release to install (e.g., v0.30.0; default: the latest release)")
+	u.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return u
+}
+
+// Init populates u from args and envs. It returns an error if the args are
+// malformed.
+func (u *Update) Init(args []string, envs map[string]string) error {
+	if err := u.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if u.fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", u.fs.Arg(0))
+	}
+
+	if u.target != "" && !strings.HasPrefix(u.target, "v") {
+		u.target = "v" + u.target
+	}
+
+	// Authenticated requests aren't subject to the GitHub API's low rate limit
+	// for anonymous requests, which runners behind a shared IP address can hit
+	u.token = envs["BUILDPULSE_GITHUB_TOKEN"]
+
+	if u.executable == "" {
+		path, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("unable to find the running binary: %v", err)
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return fmt.Errorf("unable to find the running binary: %v", err)
+		}
+		u.executable = path
+	}
+
+	return nil
+}
+
+// Run fetches the release, verifies its binary for this platform, and replaces
+// the running binary with it, writing its progress to w. With -check, it only
+// reports whether the release differs from the running version.
+func (u *Update) Run(w io.Writer) error {
+	r, err := u.fetchRelease()
+	if err != nil {
+		return err
+	}
+
+	current := "v" + strings.TrimPrefix(u.version.Number, "v")
+	if r.TagName == current {
+		fmt.Fprintf(w, "test-reporter %s is already installed\n", r.TagName)
+		return nil
+	}
+	if u.check {
+		fmt.Fprintf(w, "test-reporter %s is available (installed: %s); run `test-reporter update` to install it\n", r.TagName, u.version.Number)
+		return nil
+	}
+
+	name := assetName(u.version.GoOS, u.version.GoArch)
+	binaryAsset, ok := r.asset(name)
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s (expected asset %s)", r.TagName, u.version.GoOS, u.version.GoArch, name)
+	}
+	checksumsFile, ok := r.asset(checksumsAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s to verify the binary with", r.TagName, checksumsAsset)
+	}
+
+	checksums, err := u.download(checksumsFile)
+	if err != nil {
+		return err
+	}
+	if err := u.verifySignature(r, checksums, w); err != nil {
+		return err
+	}
+	want, err := findChecksum(checksums, name)
+	if err != nil {
+		return err
+	}
+
+	u.logger.Printf("Downloading %s", binaryAsset.URL)
+	binary, err := u.download(binaryAsset)
+	if err != nil {
+		return err
+	}
+	got := sha256.Sum256(binary)
+	if hex.EncodeToString(got[:]) != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, hex.EncodeToString(got[:]))
+	}
+
+	if err := replaceExecutable(u.executable, binary, u.version.GoOS); err != nil {
+		return fmt.Errorf("unable to replace %s: %v", u.executable, err)
+	}
+
+	fmt.Fprintf(w, "Updated %s from %s to %s\n", u.executable, u.version.Number, r.TagName)
+	return nil
+}
+
+// fetchRelease returns the release given by -version, or the latest release.
+func (u *Update) fetchRelease() (*release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", u.apiURL, releasesRepository)
+	if u.target != "" {
+		url = fmt.Sprintf("%s/repos/%s/releases/tags/%s", u.apiURL, releasesRepository, u.target)
+	}
+
+	body, err := u.get(url, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch release: %v", err)
+	}
+
+	r := &release{}
+	if err := json.Unmarshal(body, r); err != nil {
+		return nil, fmt.Errorf("unable to parse release: %v", err)
+	}
+
+	return r, nil
+}
+
+// verifySignature verifies the cosign signature of the release's checksums,
+// if the release is signed and cosign is installed. Otherwise, it writes a
+// warning to w, or returns an error with -require-signature.
+func (u *Update) verifySignature(r *release, checksums []byte, w io.Writer) error {
+	sigAsset, hasSig := r.asset(signatureAsset)
+	certAsset, hasCert := r.asset(certificateAsset)
+	_, lookErr := exec.LookPath(u.cosign)
+
+	var reason string
+	switch {
+	case !hasSig || !hasCert:
+		reason = fmt.Sprintf("release %s isn't signed", r.TagName)
+	case lookErr != nil:
+		reason = fmt.Sprintf("unable to find cosign to verify the signature of release %s", r.TagName)
+	}
+	if reason != "" {
+		if u.requireSignature {
+			return fmt.Errorf("%s, but -require-signature was given", reason)
+		}
+		fmt.Fprintf(w, "⚠️ %s; verifying the binary's checksum only\n", reason)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "buildpulse-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	paths := make(map[string]string)
+	for _, a := range []asset{sigAsset, certAsset} {
+		data, err := u.download(a)
+		if err != nil {
+			return err
+		}
+		paths[a.Name] = filepath.Join(dir, a.Name)
+		if err := os.WriteFile(paths[a.Name], data, 0644); err != nil {
+			return err
+		}
+	}
+	paths[checksumsAsset] = filepath.Join(dir, checksumsAsset)
+	if err := os.WriteFile(paths[checksumsAsset], checksums, 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(u.cosign, "verify-blob",
+		"--signature", paths[signatureAsset],
+		"--certificate", paths[certificateAsset],
+		"--certificate-identity-regexp", certificateIdentityRegexp,
+		"--certificate-oidc-issuer", certificateOIDCIssuer,
+		paths[checksumsAsset],
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to verify the signature of release %s: %v\n%s", r.TagName, err, out)
+	}
+
+	u.logger.Printf("Verified the signature of release %s", r.TagName)
+	return nil
+}
+
+func (u *Update) download(a asset) ([]byte, error) {
+	body, err := u.get(a.URL, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %v", a.Name, err)
+	}
+
+	return body, nil
+}
+
+func (u *Update) get(url string, accept string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "buildpulse-test-reporter/"+u.version.Number)
+	if u.token != "" && strings.HasPrefix(url, u.apiURL) {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (r *release) asset(name string) (asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+
+	return asset{}, false
+}
+
+// assetName returns the name of the release asset that holds the binary for
+// the given platform (see .goreleaser.yml).
+func assetName(goos string, goarch string) string {
+	name := fmt.Sprintf("test-reporter-%s-%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+
+	return name
+}
+
+// findChecksum returns the SHA-256 checksum of the named asset from the
+// contents of checksums.txt, as written by `sha256sum`.
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("no checksum found for %s in %s", name, checksumsAsset)
+}
+
+// replaceExecutable replaces the binary at path with data, keeping its mode.
+// The new binary is written alongside the old one and renamed into place, so
+// that a failed update leaves the old binary intact. Windows doesn't allow a
+// running binary to be replaced, but does allow it to be renamed, so the old
+// binary is moved aside to path.old there.
+func replaceExecutable(path string, data []byte, goos string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".test-reporter-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if goos == "windows" {
+		old := path + ".old"
+		if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+		if err := os.Rename(f.Name(), path); err != nil {
+			os.Rename(old, path)
+			return err
+		}
+		return nil
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,232 @@
+package update
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var newBinary = []byte("#!/bin/sh\necho v0.31.0\n")
+
+// newReleaseServer returns a server that stands in for the GitHub API and the
+// release downloads, serving release v0.31.0 with the given extra assets.
+// checksums is the content of checksums.txt.
+func newReleaseServer(t *testing.T, checksums string, extra ...string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	assets := map[string][]byte{
+		"test-reporter-linux-amd64": newBinary,
+		"checksums.txt":             []byte(checksums),
+	}
+	for _, name := range extra {
+		assets[name] = []byte("some " + name)
+	}
+
+	r := release{TagName: "v0.31.0"}
+	for name, data := range assets {
+		data := data
+		r.Assets = append(r.Assets, asset{Name: name, URL: server.URL + "/download/" + name})
+		mux.HandleFunc("/download/"+name, func(w http.ResponseWriter, req *http.Request) {
+			w.Write(data)
+		})
+	}
+
+	serveRelease := func(w http.ResponseWriter, req *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(r))
+	}
+	mux.HandleFunc("/repos/buildpulse/test-reporter/releases/latest", serveRelease)
+	mux.HandleFunc("/repos/buildpulse/test-reporter/releases/tags/v0.31.0", serveRelease)
+
+	return server
+}
+
+func validChecksums() string {
+	sum := sha256.Sum256(newBinary)
+	return fmt.Sprintf("0000000000000000000000000000000000000000000000000000000000000000  test-reporter-darwin-arm64\n%s  test-reporter-linux-amd64\n", hex.EncodeToString(sum[:]))
+}
+
+// newUpdate returns an Update that replaces a temporary stand-in for the
+// running binary, using the server as the GitHub API.
+func newUpdate(t *testing.T, server *httptest.Server, args ...string) *Update {
+	executable := filepath.Join(t.TempDir(), "test-reporter")
+	require.NoError(t, os.WriteFile(executable, []byte("old binary"), 0755))
+
+	u := NewUpdate(&metadata.Version{Number: "v0.30.0", GoOS: "linux", GoArch: "amd64"}, logger.New())
+	u.apiURL = server.URL
+	u.executable = executable
+	u.cosign = filepath.Join(t.TempDir(), "cosign") // not installed, unless a test writes it
+	require.NoError(t, u.Init(args, map[string]string{}))
+
+	return u
+}
+
+func TestUpdate_Run(t *testing.T) {
+	server := newReleaseServer(t, validChecksums())
+	u := newUpdate(t, server)
+
+	var out bytes.Buffer
+	require.NoError(t, u.Run(&out))
+	assert.Contains(t, out.String(), "⚠️ release v0.31.0 isn't signed; verifying the binary's checksum only")
+	assert.Contains(t, out.String(), fmt.Sprintf("Updated %s from v0.30.0 to v0.31.0", u.executable))
+
+	data, err := os.ReadFile(u.executable)
+	require.NoError(t, err)
+	assert.Equal(t, newBinary, data)
+	info, err := os.Stat(u.executable)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+func TestUpdate_Run_alreadyInstalled(t *testing.T) {
+	server := newReleaseServer(t, validChecksums())
+	u := newUpdate(t, server, "--version", "0.31.0")
+	u.version.Number = "0.31.0"
+
+	var out bytes.Buffer
+	require.NoError(t, u.Run(&out))
+	assert.Equal(t, "test-reporter v0.31.0 is already installed\n", out.String())
+
+	data, err := os.ReadFile(u.executable)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestUpdate_Run_check(t *testing.T) {
+	server := newReleaseServer(t, validChecksums())
+	u := newUpdate(t, server, "--check")
+
+	var out bytes.Buffer
+	require.NoError(t, u.Run(&out))
+	assert.Contains(t, out.String(), "test-reporter v0.31.0 is available (installed: v0.30.0)")
+
+	data, err := os.ReadFile(u.executable)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestUpdate_Run_signed(t *testing.T) {
+	server := newReleaseServer(t, validChecksums(), "checksums.txt.sig", "checksums.txt.pem")
+	u := newUpdate(t, server, "--require-signature")
+
+	// Stand in for cosign, recording its arguments
+	argsPath := filepath.Join(t.TempDir(), "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n", argsPath)
+	require.NoError(t, os.WriteFile(u.cosign, []byte(script), 0755))
+
+	var out bytes.Buffer
+	require.NoError(t, u.Run(&out))
+	assert.NotContains(t, out.String(), "⚠️")
+
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "verify-blob --signature")
+	assert.Contains(t, string(args), "--certificate-identity-regexp ^https://github\\.com/buildpulse/test-reporter/ --certificate-oidc-issuer https://token.actions.githubusercontent.com")
+
+	data, err := os.ReadFile(u.executable)
+	require.NoError(t, err)
+	assert.Equal(t, newBinary, data)
+}
+
+func TestUpdate_Run_errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		checksums string
+		extra     []string
+		args      []string
+		cosign    string
+		goos      string
+		wantErr   string
+	}{
+		{
+			name:      "ChecksumMismatch",
+			checksums: "0000000000000000000000000000000000000000000000000000000000000000  test-reporter-linux-amd64\n",
+			wantErr:   "checksum mismatch for test-reporter-linux-amd64: expected 0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			name:      "NoChecksum",
+			checksums: "0000000000000000000000000000000000000000000000000000000000000000  test-reporter-darwin-arm64\n",
+			wantErr:   "no checksum found for test-reporter-linux-amd64 in checksums.txt",
+		},
+		{
+			name:      "NoBinaryForPlatform",
+			checksums: validChecksums(),
+			goos:      "windows",
+			wantErr:   "release v0.31.0 has no binary for windows/amd64 (expected asset test-reporter-windows-amd64.exe)",
+		},
+		{
+			name:      "UnsignedWithRequireSignature",
+			checksums: validChecksums(),
+			args:      []string{"--require-signature"},
+			wantErr:   "release v0.31.0 isn't signed, but -require-signature was given",
+		},
+		{
+			name:      "NoCosignWithRequireSignature",
+			checksums: validChecksums(),
+			extra:     []string{"checksums.txt.sig", "checksums.txt.pem"},
+			args:      []string{"--require-signature"},
+			wantErr:   "unable to find cosign to verify the signature of release v0.31.0, but -require-signature was given",
+		},
+		{
+			name:      "InvalidSignature",
+			checksums: validChecksums(),
+			extra:     []string{"checksums.txt.sig", "checksums.txt.pem"},
+			cosign:    "#!/bin/sh\necho 'Error: none of the expected identities matched' >&2\nexit 1\n",
+			wantErr:   "unable to verify the signature of release v0.31.0: exit status 1\nError: none of the expected identities matched",
+		},
+		{
+			name:      "UnknownVersion",
+			checksums: validChecksums(),
+			args:      []string{"--version", "v9.9.9"},
+			wantErr:   "unable to fetch release: unexpected response: 404 Not Found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newReleaseServer(t, tt.checksums, tt.extra...)
+			u := newUpdate(t, server, tt.args...)
+			if tt.goos != "" {
+				u.version.GoOS = tt.goos
+			}
+			if tt.cosign != "" {
+				require.NoError(t, os.WriteFile(u.cosign, []byte(tt.cosign), 0755))
+			}
+
+			err := u.Run(&bytes.Buffer{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			data, err := os.ReadFile(u.executable)
+			require.NoError(t, err)
+			assert.Equal(t, "old binary", string(data))
+		})
+	}
+}
+
+func Test_replaceExecutable_windows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test-reporter.exe")
+	require.NoError(t, os.WriteFile(path, []byte("old binary"), 0755))
+
+	require.NoError(t, replaceExecutable(path, newBinary, "windows"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, newBinary, data)
+	old, err := os.ReadFile(path + ".old")
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(old))
+}
@@ -0,0 +1,114 @@
+package validate
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/buildpulse/test-reporter/internal/cmd/submit"
+	"github.com/buildpulse/test-reporter/internal/report"
+)
+
+// Validate represents the task of checking that the reports that `submit`
+// would find are valid JUnit XML, so that the reporter's configuration can be
+// verified locally before it's wired up in CI.
+type Validate struct {
+	fs *flag.FlagSet
+
+	excludeHidden  bool
+	followSymlinks bool
+	paths          []string
+}
+
+// NewValidate creates a new Validate instance.
+func NewValidate() *Validate {
+	v := &Validate{
+		fs: flag.NewFlagSet("validate", flag.ContinueOnError),
+	}
+	v.fs.BoolVar(&v.excludeHidden, "exclude-hidden", true, "Skip hidden directories (e.g., .cache, .venv) when searching TEST_RESULTS_PATH for reports")
+	v.fs.BoolVar(&v.followSymlinks, "follow-symlinks", false, "Follow symbolic links to directories when searching TEST_RESULTS_PATH for reports")
+	v.fs.SetOutput(io.Discard) // Disable automatic writing to STDERR
+
+	return v
+}
+
+// Init populates v from args: the paths of the reports (files, directories,
+// or glob patterns, as for `submit`), followed by any flags. It returns an
+// error if the args are malformed or no reports are found.
+func (v *Validate) Init(args []string) error {
+	var pathArgs []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		pathArgs = append(pathArgs, args[0])
+		args = args[1:]
+	}
+	if err := v.fs.Parse(args); err != nil {
+		return err
+	}
+	pathArgs = append(pathArgs, v.fs.Args()...)
+
+	if len(pathArgs) == 0 {
+		return fmt.Errorf("missing TEST_RESULTS_PATH")
+	}
+
+	paths, err := submit.XMLPaths(pathArgs, !v.excludeHidden, v.followSymlinks)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no XML reports found at %s", strings.Join(pathArgs, " "))
+	}
+	v.paths = paths
+
+	return nil
+}
+
+// Run parses each report as JUnit XML and writes a line describing it to w
+// (the numbers of test suites and test cases, or why it's malformed), followed
+// by a summary. It returns an error if any report is malformed.
+func (v *Validate) Run(w io.Writer) error {
+	var suites, cases, invalid int
+	for _, p := range v.paths {
+		summary, err := validateFile(p)
+		if err != nil {
+			fmt.Fprintf(w, "❌ %s: %v\n", p, err)
+			invalid++
+			continue
+		}
+		fmt.Fprintf(w, "✅ %s: %s, %s\n", p, plural(summary.Suites, "test suite"), plural(summary.Cases, "test case"))
+		suites += summary.Suites
+		cases += summary.Cases
+	}
+
+	fmt.Fprintf(w, "Found %s with %s and %s\n", plural(len(v.paths), "report"), plural(suites, "test suite"), plural(cases, "test case"))
+	if invalid > 0 {
+		return fmt.Errorf("%d of %s malformed", invalid, plural(len(v.paths), "report is", "reports are"))
+	}
+
+	return nil
+}
+
+// validateFile parses the named JUnit XML report.
+func validateFile(name string) (*report.JUnitSummary, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return report.ValidateJUnit(f)
+}
+
+// plural returns n followed by the singular noun if n is 1, or else by the
+// plural (the singular with an "s", unless given).
+func plural(n int, singular string, plural ...string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	if len(plural) > 0 {
+		return fmt.Sprintf("%d %s", n, plural[0])
+	}
+
+	return fmt.Sprintf("%d %ss", n, singular)
+}
@@ -0,0 +1,57 @@
+package validate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_Run(t *testing.T) {
+	v := NewValidate()
+	require.NoError(t, v.Init([]string{"testdata/reports/unit.xml"}))
+
+	var out bytes.Buffer
+	require.NoError(t, v.Run(&out))
+	assert.Equal(t, "✅ testdata/reports/unit.xml: 1 test suite, 2 test cases\nFound 1 report with 1 test suite and 2 test cases\n", out.String())
+}
+
+func TestValidate_Run_malformed(t *testing.T) {
+	v := NewValidate()
+	require.NoError(t, v.Init([]string{"testdata/reports"}))
+
+	var out bytes.Buffer
+	err := v.Run(&out)
+	assert.EqualError(t, err, "1 of 2 reports are malformed")
+	assert.Equal(t,
+		"❌ testdata/reports/truncated.xml: XML syntax error on line 4: unexpected EOF\n"+
+			"✅ testdata/reports/unit.xml: 1 test suite, 2 test cases\n"+
+			"Found 2 reports with 1 test suite and 2 test cases\n",
+		out.String())
+}
+
+func TestValidate_Init(t *testing.T) {
+	v := NewValidate()
+	require.NoError(t, v.Init([]string{"testdata/reports", "--exclude-hidden=false"}))
+	assert.Equal(t, []string{"testdata/reports/.cache/stale.xml", "testdata/reports/truncated.xml", "testdata/reports/unit.xml"}, v.paths)
+
+	v = NewValidate()
+	require.NoError(t, v.Init([]string{"--exclude-hidden=false", "testdata/reports/*.xml"}))
+	assert.Equal(t, []string{"testdata/reports/truncated.xml", "testdata/reports/unit.xml"}, v.paths)
+}
+
+func TestValidate_Init_invalidArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		errMsg string
+	}{
+		{args: []string{}, errMsg: "missing TEST_RESULTS_PATH"},
+		{args: []string{"testdata/reports/*.json"}, errMsg: "no XML reports found at testdata/reports/*.json"},
+		{args: []string{"testdata/reports", "--bogus"}, errMsg: "flag provided but not defined: -bogus"},
+	}
+	for _, tt := range tests {
+		err := NewValidate().Init(tt.args)
+		assert.EqualError(t, err, tt.errMsg)
+	}
+}
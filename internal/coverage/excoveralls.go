@@ -0,0 +1,53 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ExcoverallsReport is the name of the file in which excoveralls (Elixir)
+// stores its results with `mix coveralls.json` (e.g., cover/excoveralls.json).
+const ExcoverallsReport = "excoveralls.json"
+
+// IsExcoverallsReport reports whether the file at path is an excoveralls JSON
+// report, going by its name.
+func IsExcoverallsReport(path string) bool {
+	return strings.EqualFold(filepath.Base(path), ExcoverallsReport)
+}
+
+// MergeExcoveralls merges the excoveralls JSON report read from r into c. The
+// report uses the Coveralls format: the hit count of each line of each source
+// file, or null for lines that aren't relevant. Hit counts are summed across
+// reports (e.g., one per partition of `mix test --partitions`).
+func (c *Coverage) MergeExcoveralls(r io.Reader) error {
+	var report struct {
+		SourceFiles *[]struct {
+			Name     string `json:"name"`
+			Coverage []*int `json:"coverage"`
+		} `json:"source_files"`
+	}
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return fmt.Errorf("unable to parse excoveralls report: %v", err)
+	}
+	if report.SourceFiles == nil {
+		return fmt.Errorf("unable to parse excoveralls report: missing source_files")
+	}
+
+	for _, sf := range *report.SourceFiles {
+		if sf.Name == "" {
+			return fmt.Errorf("unable to parse excoveralls report: source file without a name")
+		}
+
+		f, ok := c.Files[sf.Name]
+		if !ok {
+			f = &File{Branches: make(map[Branch]int)}
+			c.Files[sf.Name] = f
+		}
+		f.mergeLines(sf.Coverage)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package coverage
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsExcoverallsReport(t *testing.T) {
+	assert.True(t, IsExcoverallsReport("cover/excoveralls.json"))
+	assert.True(t, IsExcoverallsReport("cover/ExCoveralls.JSON"))
+	assert.False(t, IsExcoverallsReport("cover/excoveralls.html"))
+}
+
+func TestCoverage_MergeExcoveralls(t *testing.T) {
+	c := New()
+	for i := 0; i < 2; i++ {
+		f, err := os.Open("testdata/excoveralls.json")
+		require.NoError(t, err)
+		err = c.MergeExcoveralls(f)
+		f.Close()
+		require.NoError(t, err)
+	}
+
+	var buf strings.Builder
+	err := c.WriteLCOV(&buf)
+	require.NoError(t, err)
+
+	// The hit counts of the two reports are summed
+	assert.Equal(t, `TN:
+SF:lib/my_app.ex
+DA:2,0
+LF:1
+LH:0
+end_of_record
+TN:
+SF:lib/my_app/user.ex
+DA:2,6
+DA:4,2
+DA:5,0
+LF:3
+LH:2
+end_of_record
+`, buf.String())
+}
+
+func TestCoverage_MergeExcoveralls_malformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "NotJSON", input: `{"source_files": [`, want: "unable to parse excoveralls report"},
+		{name: "MissingSourceFiles", input: `{"coverage": 80.0}`, want: "missing source_files"},
+		{name: "MissingName", input: `{"source_files": [{"coverage": [1]}]}`, want: "source file without a name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().MergeExcoveralls(strings.NewReader(tt.input))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.want)
+			}
+		})
+	}
+}
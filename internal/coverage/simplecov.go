@@ -0,0 +1,209 @@
+// Package coverage converts coverage formats that BuildPulse doesn't accept
+// natively into LCOV.
+package coverage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SimpleCovResultSet is the name of the file in which SimpleCov (Ruby) stores
+// its results (e.g., coverage/.resultset.json).
+const SimpleCovResultSet = ".resultset.json"
+
+// IsSimpleCovResultSet reports whether the file at path is a SimpleCov result
+// set, going by its name.
+func IsSimpleCovResultSet(path string) bool {
+	return strings.EqualFold(filepath.Base(path), SimpleCovResultSet)
+}
+
+// Coverage is the line and branch coverage of a set of source files, keyed by
+// path.
+type Coverage struct {
+	Files map[string]*File
+}
+
+// File is the coverage of a source file. Lines holds the hit count of each
+// line (counting from 1 at index 0), or nil for lines that aren't relevant
+// (e.g., comments). Branches holds the hit count of each branch.
+type File struct {
+	Lines    []*int
+	Branches map[Branch]int
+}
+
+// Branch identifies a branch of a condition: the line the condition starts
+// on, the condition's ID (the LCOV block), and the branch's ID.
+type Branch struct {
+	Line  int
+	Block int
+	ID    int
+}
+
+// New returns an empty Coverage.
+func New() *Coverage {
+	return &Coverage{Files: make(map[string]*File)}
+}
+
+// simplecovFile is the coverage of a source file in a result set, which is
+// either an object (since SimpleCov 0.18) or the array of line hit counts
+// (before that).
+type simplecovFile struct {
+	Lines    []*int                    `json:"lines"`
+	Branches map[string]map[string]int `json:"branches"`
+}
+
+func (f *simplecovFile) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &f.Lines)
+	}
+
+	type plain simplecovFile
+	return json.Unmarshal(data, (*plain)(f))
+}
+
+// simplecovBranchRegex matches the key of a condition or branch in a result
+// set (e.g., "[:then, 3, 12, 6, 12, 30]"), capturing its ID and start line.
+var simplecovBranchRegex = regexp.MustCompile(`^\[:\w+, (\d+), (\d+),`)
+
+// MergeSimpleCov merges the SimpleCov result set read from r into c. A result
+// set holds a run for each command (e.g., one per parallel worker), which are
+// merged the way SimpleCov merges them: hit counts are summed, and a line is
+// relevant if it's relevant in any run.
+func (c *Coverage) MergeSimpleCov(r io.Reader) error {
+	var rs map[string]struct {
+		Coverage map[string]simplecovFile `json:"coverage"`
+	}
+	if err := json.NewDecoder(r).Decode(&rs); err != nil {
+		return fmt.Errorf("unable to parse SimpleCov result set: %v", err)
+	}
+
+	for _, run := range rs {
+		for path, sf := range run.Coverage {
+			f, ok := c.Files[path]
+			if !ok {
+				f = &File{Branches: make(map[Branch]int)}
+				c.Files[path] = f
+			}
+
+			f.mergeLines(sf.Lines)
+			if err := f.mergeBranches(sf.Branches); err != nil {
+				return fmt.Errorf("unable to parse SimpleCov result set: %s: %v", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *File) mergeLines(lines []*int) {
+	for len(f.Lines) < len(lines) {
+		f.Lines = append(f.Lines, nil)
+	}
+
+	for i, hits := range lines {
+		switch {
+		case hits == nil:
+		case f.Lines[i] == nil:
+			n := *hits
+			f.Lines[i] = &n
+		default:
+			*f.Lines[i] += *hits
+		}
+	}
+}
+
+func (f *File) mergeBranches(conditions map[string]map[string]int) error {
+	for condition, branches := range conditions {
+		block, line, err := parseSimpleCovBranch(condition)
+		if err != nil {
+			return err
+		}
+
+		for branch, hits := range branches {
+			id, _, err := parseSimpleCovBranch(branch)
+			if err != nil {
+				return err
+			}
+
+			f.Branches[Branch{Line: line, Block: block, ID: id}] += hits
+		}
+	}
+
+	return nil
+}
+
+func parseSimpleCovBranch(key string) (id int, line int, err error) {
+	m := simplecovBranchRegex.FindStringSubmatch(key)
+	if m == nil {
+		return 0, 0, fmt.Errorf("malformed branch %q", key)
+	}
+
+	id, _ = strconv.Atoi(m[1])
+	line, _ = strconv.Atoi(m[2])
+	return id, line, nil
+}
+
+// WriteLCOV writes c to w in the LCOV tracefile format, with the files sorted
+// by path.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	paths := make([]string, 0, len(c.Files))
+	for p := range c.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	bw := bufio.NewWriter(w)
+	for _, p := range paths {
+		f := c.Files[p]
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", p)
+
+		branches := make([]Branch, 0, len(f.Branches))
+		for b := range f.Branches {
+			branches = append(branches, b)
+		}
+		sort.Slice(branches, func(i, j int) bool {
+			bi, bj := branches[i], branches[j]
+			if bi.Line != bj.Line {
+				return bi.Line < bj.Line
+			}
+			if bi.Block != bj.Block {
+				return bi.Block < bj.Block
+			}
+			return bi.ID < bj.ID
+		})
+
+		var brh int
+		for _, b := range branches {
+			hits := f.Branches[b]
+			if hits > 0 {
+				brh++
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%d\n", b.Line, b.Block, b.ID, hits)
+		}
+		if len(branches) > 0 {
+			fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", len(branches), brh)
+		}
+
+		var lf, lh int
+		for i, hits := range f.Lines {
+			if hits == nil {
+				continue
+			}
+			lf++
+			if *hits > 0 {
+				lh++
+			}
+			fmt.Fprintf(bw, "DA:%d,%d\n", i+1, *hits)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", lf, lh)
+	}
+
+	return bw.Flush()
+}
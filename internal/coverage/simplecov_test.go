@@ -0,0 +1,79 @@
+package coverage
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSimpleCovResultSet(t *testing.T) {
+	assert.True(t, IsSimpleCovResultSet("coverage/.resultset.json"))
+	assert.True(t, IsSimpleCovResultSet("coverage/.ResultSet.JSON"))
+	assert.False(t, IsSimpleCovResultSet("coverage/resultset.json"))
+	assert.False(t, IsSimpleCovResultSet("coverage/.last_run.json"))
+}
+
+func TestCoverage_MergeSimpleCov(t *testing.T) {
+	f, err := os.Open("testdata/.resultset.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	c := New()
+	err = c.MergeSimpleCov(f)
+	require.NoError(t, err)
+
+	var buf strings.Builder
+	err = c.WriteLCOV(&buf)
+	require.NoError(t, err)
+
+	// The runs of the two parallel workers are merged
+	assert.Equal(t, `TN:
+SF:/app/app/models/post.rb
+DA:1,1
+DA:3,3
+LF:2
+LH:2
+end_of_record
+TN:
+SF:/app/app/models/user.rb
+BRDA:4,0,1,0
+BRDA:4,0,2,3
+BRF:2
+BRH:1
+DA:1,2
+DA:2,2
+DA:4,0
+DA:5,3
+LF:4
+LH:3
+end_of_record
+`, buf.String())
+}
+
+func TestCoverage_MergeSimpleCov_acrossResultSets(t *testing.T) {
+	c := New()
+	for _, p := range []string{"testdata/.resultset.json", "testdata/legacy.resultset.json"} {
+		f, err := os.Open(p)
+		require.NoError(t, err)
+		err = c.MergeSimpleCov(f)
+		f.Close()
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, c.Files, 3)
+	// Result sets from before SimpleCov 0.18 hold only the line hit counts
+	require.Contains(t, c.Files, "/app/lib/tasks.rb")
+	one, zero := 1, 0
+	assert.Equal(t, []*int{&one, nil, &zero}, c.Files["/app/lib/tasks.rb"].Lines)
+}
+
+func TestCoverage_MergeSimpleCov_malformed(t *testing.T) {
+	err := New().MergeSimpleCov(strings.NewReader(`["not", "a", "result", "set"]`))
+	assert.ErrorContains(t, err, "unable to parse SimpleCov result set")
+
+	err = New().MergeSimpleCov(strings.NewReader(`{"RSpec": {"coverage": {"/app/a.rb": {"lines": [1], "branches": {"bogus": {}}}}}}`))
+	assert.ErrorContains(t, err, `malformed branch "bogus"`)
+}
@@ -0,0 +1,133 @@
+// Package delta computes line-based deltas between text files, so that a file
+// that's mostly unchanged from a baseline (such as a coverage report) can be
+// sent as the differences from the baseline.
+package delta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// maxCandidates limits how many earlier occurrences of a line in the baseline
+// are considered as the start of a copy, to bound the time spent on files with
+// many repeated lines (e.g., "}").
+const maxCandidates = 16
+
+// A Delta describes how to produce a target file from a baseline file, as a
+// sequence of operations that either copy lines from the baseline or insert
+// new lines. Each line includes its trailing newline, if any, so the target is
+// reproduced byte for byte.
+type Delta struct {
+	BaselineSHA256 string `json:"baseline_sha256"`
+	TargetSHA256   string `json:"target_sha256"`
+	Ops            []Op   `json:"ops"`
+}
+
+// An Op is an operation of a Delta. A copy operation copies Count lines of the
+// baseline starting at line Start (counting from 0). An insert operation
+// inserts Lines.
+type Op struct {
+	Op    string   `json:"op"` // "copy" or "insert"
+	Start int      `json:"start,omitempty"`
+	Count int      `json:"count,omitempty"`
+	Lines []string `json:"lines,omitempty"`
+}
+
+// Compute returns the Delta that produces target from baseline.
+func Compute(baseline []byte, target []byte) *Delta {
+	d := &Delta{BaselineSHA256: digest(baseline), TargetSHA256: digest(target)}
+
+	base := splitLines(baseline)
+	index := make(map[string][]int)
+	for i, line := range base {
+		if len(index[line]) < maxCandidates {
+			index[line] = append(index[line], i)
+		}
+	}
+
+	lines := splitLines(target)
+	for i := 0; i < len(lines); {
+		// Copy the longest run of lines that matches the baseline
+		start, count := -1, 0
+		for _, j := range index[lines[i]] {
+			n := 0
+			for i+n < len(lines) && j+n < len(base) && lines[i+n] == base[j+n] {
+				n++
+			}
+			if n > count {
+				start, count = j, n
+			}
+		}
+		if count > 0 {
+			d.Ops = append(d.Ops, Op{Op: "copy", Start: start, Count: count})
+			i += count
+			continue
+		}
+
+		// Otherwise insert the line, merging it with the preceding insert
+		if n := len(d.Ops); n > 0 && d.Ops[n-1].Op == "insert" {
+			d.Ops[n-1].Lines = append(d.Ops[n-1].Lines, lines[i])
+		} else {
+			d.Ops = append(d.Ops, Op{Op: "insert", Lines: []string{lines[i]}})
+		}
+		i++
+	}
+
+	return d
+}
+
+// Apply returns the target file produced by applying d to baseline.
+func (d *Delta) Apply(baseline []byte) ([]byte, error) {
+	if sum := digest(baseline); sum != d.BaselineSHA256 {
+		return nil, fmt.Errorf("baseline doesn't match delta: got SHA-256 %s, want %s", sum, d.BaselineSHA256)
+	}
+
+	base := splitLines(baseline)
+	var buf bytes.Buffer
+	for _, op := range d.Ops {
+		switch op.Op {
+		case "copy":
+			if op.Start < 0 || op.Count < 0 || op.Start+op.Count > len(base) {
+				return nil, fmt.Errorf("copy of lines %d to %d is outside the baseline's %d lines", op.Start, op.Start+op.Count, len(base))
+			}
+			for _, line := range base[op.Start : op.Start+op.Count] {
+				buf.WriteString(line)
+			}
+		case "insert":
+			for _, line := range op.Lines {
+				buf.WriteString(line)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported delta operation: %s", op.Op)
+		}
+	}
+
+	if sum := digest(buf.Bytes()); sum != d.TargetSHA256 {
+		return nil, fmt.Errorf("result doesn't match delta: got SHA-256 %s, want %s", sum, d.TargetSHA256)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// splitLines splits data into lines, keeping the newline at the end of each.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+
+	return lines
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package delta
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	baseline := []byte("a\nb\nc\nd\ne\n")
+	target := []byte("a\nb\nX\nd\ne\nf")
+
+	d := Compute(baseline, target)
+	assert.Equal(t, []Op{
+		{Op: "copy", Start: 0, Count: 2},
+		{Op: "insert", Lines: []string{"X\n"}},
+		{Op: "copy", Start: 3, Count: 2},
+		{Op: "insert", Lines: []string{"f"}},
+	}, d.Ops)
+
+	got, err := d.Apply(baseline)
+	require.NoError(t, err)
+	assert.Equal(t, string(target), string(got))
+}
+
+func TestCompute_prefersLongestRun(t *testing.T) {
+	baseline := []byte("}\nfoo\n}\nbar\nbaz\n")
+	target := []byte("}\nbar\nbaz\n")
+
+	d := Compute(baseline, target)
+	assert.Equal(t, []Op{{Op: "copy", Start: 2, Count: 3}}, d.Ops)
+}
+
+func TestCompute_roundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline string
+		target   string
+	}{
+		{name: "identical", baseline: "a\nb\n", target: "a\nb\n"},
+		{name: "empty baseline", baseline: "", target: "a\nb\n"},
+		{name: "empty target", baseline: "a\nb\n", target: ""},
+		{name: "reordered", baseline: "a\nb\nc\n", target: "c\nb\na\n"},
+		{name: "missing final newline", baseline: "a\nb", target: "a\nb\nc"},
+		{name: "large", baseline: strings.Repeat("line\n", 1000) + "end\n", target: strings.Repeat("line\n", 999) + "changed\nend\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Compute([]byte(tt.baseline), []byte(tt.target))
+			got, err := d.Apply([]byte(tt.baseline))
+			require.NoError(t, err)
+			assert.Equal(t, tt.target, string(got))
+		})
+	}
+}
+
+func TestDelta_Apply_wrongBaseline(t *testing.T) {
+	d := Compute([]byte("a\n"), []byte("a\nb\n"))
+	_, err := d.Apply([]byte("z\n"))
+	assert.ErrorContains(t, err, "baseline doesn't match delta")
+}
+
+func TestDelta_Apply_invalidOp(t *testing.T) {
+	d := Compute([]byte("a\n"), []byte("a\n"))
+	d.Ops = []Op{{Op: "copy", Start: 0, Count: 5}}
+	_, err := d.Apply([]byte("a\n"))
+	assert.EqualError(t, err, "copy of lines 0 to 5 is outside the baseline's 1 lines")
+}
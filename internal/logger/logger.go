@@ -2,47 +2,130 @@ package logger
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"log"
+	"strings"
 )
 
 // A Logger represents a mechanism for logging. 🙃
 type Logger interface {
 	Printf(format string, v ...interface{})
 	Println(v ...interface{})
+	Debugf(format string, v ...interface{})
+	SetLevel(level Level)
+	Level() Level
 	Text() string
 }
 
+// A Level is the severity of a log entry. Entries below the logger's level are
+// left out of its writers, but are kept in its Text.
+type Level int
+
+const (
+	// LevelDebug is for detailed output, such as a line per file.
+	LevelDebug Level = iota
+	// LevelInfo is for the progress of the run. It's the default level.
+	LevelInfo
+	// LevelWarn is for warnings (i.e., entries beginning with "⚠️").
+	LevelWarn
+	// LevelError leaves every entry out of the writers, since errors are
+	// reported by the caller rather than logged.
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name (debug, info, warn, or
+// error).
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("should be one of: %s", strings.Join(levelNames, ", "))
+}
+
 type logger struct {
 	buffer *bytes.Buffer
 	log    *log.Logger
+	out    *log.Logger
+	level  Level
 }
 
+// Printf logs an entry at LevelInfo, or at LevelWarn if it's a warning (i.e.,
+// it begins with "⚠️").
 func (l *logger) Printf(format string, v ...interface{}) {
-	l.log.Printf(format, v...)
+	l.write(entryLevel(format), fmt.Sprintf(format, v...))
 }
 
+// Println logs an entry at LevelInfo.
 func (l *logger) Println(v ...interface{}) {
-	l.log.Println(v...)
+	l.write(LevelInfo, fmt.Sprintln(v...))
+}
+
+// Debugf logs an entry at LevelDebug.
+func (l *logger) Debugf(format string, v ...interface{}) {
+	l.write(LevelDebug, fmt.Sprintf(format, v...))
 }
 
-// Text returns a string concatenation of all of the log's entries.
+// SetLevel sets the minimum level of the entries written to the writers.
+func (l *logger) SetLevel(level Level) {
+	l.level = level
+}
+
+// Level returns the minimum level of the entries written to the writers.
+func (l *logger) Level() Level {
+	return l.level
+}
+
+// Text returns a string concatenation of all of the log's entries, regardless
+// of level.
 func (l *logger) Text() string {
 	return l.buffer.String()
 }
 
+func (l *logger) write(level Level, s string) {
+	l.log.Output(3, s)
+	if l.out != nil && level >= l.level {
+		l.out.Output(3, s)
+	}
+}
+
+// entryLevel returns the level of an entry with the given format.
+func entryLevel(format string) Level {
+	if strings.HasPrefix(format, "⚠️") {
+		return LevelWarn
+	}
+
+	return LevelInfo
+}
+
 // New returns a Logger that writes to writers and an in-memory store for
-// on-demand access to log entries via the Text() method.
+// on-demand access to log entries via the Text() method. Only the entries at
+// or above the logger's level (LevelInfo, unless changed with SetLevel) are
+// written to writers; the in-memory store has them all.
 func New(writers ...io.Writer) Logger {
 	var buffer bytes.Buffer
 
-	var logWriters []io.Writer
-	logWriters = append(logWriters, &buffer)
-	logWriters = append(logWriters, writers...)
-	w := io.MultiWriter(logWriters...)
-
-	return &logger{
+	l := &logger{
 		buffer: &buffer,
-		log:    log.New(w, "<buildpulse> ", 0),
+		log:    log.New(&buffer, "<buildpulse> ", 0),
+		level:  LevelInfo,
+	}
+	if len(writers) > 0 {
+		l.out = log.New(io.MultiWriter(writers...), "<buildpulse> ", 0)
 	}
+
+	return l
 }
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_levels(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  string
+	}{
+		{level: LevelDebug, want: "<buildpulse> Adding report.xml\n<buildpulse> Uploading\n<buildpulse> ⚠️ Stale report\n"},
+		{level: LevelInfo, want: "<buildpulse> Uploading\n<buildpulse> ⚠️ Stale report\n"},
+		{level: LevelWarn, want: "<buildpulse> ⚠️ Stale report\n"},
+		{level: LevelError, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			var out bytes.Buffer
+			l := New(&out)
+			l.SetLevel(tt.level)
+
+			l.Debugf("Adding %s", "report.xml")
+			l.Printf("Uploading")
+			l.Printf("⚠️ Stale report")
+
+			assert.Equal(t, tt.want, out.String())
+			assert.Equal(t, "<buildpulse> Adding report.xml\n<buildpulse> Uploading\n<buildpulse> ⚠️ Stale report\n", l.Text())
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	require.NoError(t, err)
+	assert.Equal(t, LevelWarn, level)
+
+	_, err = ParseLevel("trace")
+	assert.EqualError(t, err, "should be one of: debug, info, warn, error")
+}
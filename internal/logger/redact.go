@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redacted replaces each secret in redacted log text.
+const redacted = "[REDACTED]"
+
+// sensitiveEnvRegex matches the names of environment variables whose values
+// are secrets (e.g., BUILDPULSE_SECRET_ACCESS_KEY or GITHUB_TOKEN).
+var sensitiveEnvRegex = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|PRIVATE_KEY|CREDENTIAL)`)
+
+// minSecretLength is the length below which the value of a sensitive
+// environment variable isn't redacted, since masking every occurrence of a
+// short value (e.g., "true") would garble the log without protecting anything.
+const minSecretLength = 8
+
+var (
+	patternsMu sync.RWMutex
+	patterns   []*regexp.Regexp
+)
+
+// RegisterRedactionPattern adds re to the patterns whose matches Redact masks
+// (e.g., for the credentials of an in-house integration). It's intended to be
+// called from an init function.
+func RegisterRedactionPattern(re *regexp.Regexp) {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+
+	patterns = append(patterns, re)
+}
+
+// Redact returns text with the values of the sensitive environment variables
+// in envs, and the matches of the registered patterns, replaced with
+// [REDACTED]. It's for text that leaves the runner, such as the log included
+// in the uploaded bundle.
+func Redact(text string, envs map[string]string) string {
+	var secrets []string
+	for name, value := range envs {
+		if sensitiveEnvRegex.MatchString(name) && len(value) >= minSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+
+	// Longest first, so that a secret containing another is masked whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, s := range secrets {
+		text = strings.ReplaceAll(text, s, redacted)
+	}
+
+	patternsMu.RLock()
+	defer patternsMu.RUnlock()
+
+	for _, re := range patterns {
+		text = re.ReplaceAllLiteralString(text, redacted)
+	}
+
+	return text
+}
+
+// redactingWriter redacts the text written to it before passing it on.
+type redactingWriter struct {
+	w    io.Writer
+	envs map[string]string
+}
+
+// NewRedactingWriter returns a writer that applies Redact to each write before
+// passing it on to w, for destinations outside the runner (e.g., a Sink).
+// Each write is redacted on its own, which suits a Logger, since it writes
+// each entry whole.
+func NewRedactingWriter(w io.Writer, envs map[string]string) io.Writer {
+	return &redactingWriter{w: w, envs: envs}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p), r.envs)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
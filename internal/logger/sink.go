@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// A Sink forwards log records to a centralized logging service. Records are
+// buffered as they're written and delivered in a single batch by Flush, so
+// that the service is contacted once per run instead of once per record.
+type Sink interface {
+	io.Writer
+	Flush() error
+}
+
+// NewSinksFromEnv returns the sinks configured by envs:
+//
+//   - BUILDPULSE_LOG_SYSLOG_ADDR forwards records to a syslog server (e.g.,
+//     "udp://logs.example.com:514" or "tcp://logs.example.com:601").
+//   - BUILDPULSE_LOG_CLOUDWATCH_GROUP forwards records to an AWS CloudWatch
+//     Logs group, in the stream named by BUILDPULSE_LOG_CLOUDWATCH_STREAM. The
+//     region and credentials are resolved in the standard AWS manner (e.g.,
+//     AWS_REGION and AWS_ACCESS_KEY_ID).
+//
+// It returns an empty slice if no sinks are configured.
+func NewSinksFromEnv(envs map[string]string) ([]Sink, error) {
+	var sinks []Sink
+
+	if addr := envs["BUILDPULSE_LOG_SYSLOG_ADDR"]; addr != "" {
+		s, err := newSyslogSink(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_LOG_SYSLOG_ADDR: %v", addr, err)
+		}
+		sinks = append(sinks, s)
+	}
+
+	if group := envs["BUILDPULSE_LOG_CLOUDWATCH_GROUP"]; group != "" {
+		stream := envs["BUILDPULSE_LOG_CLOUDWATCH_STREAM"]
+		if stream == "" {
+			stream = "buildpulse-test-reporter"
+		}
+		s, err := newCloudWatchSink(group, stream)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+// A record is a single log entry awaiting delivery by a sink.
+type record struct {
+	time    time.Time
+	message string
+}
+
+// batch accumulates the records written to a sink.
+type batch struct {
+	mu      sync.Mutex
+	records []record
+}
+
+func (b *batch) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.records = append(b.records, record{
+		time:    time.Now(),
+		message: strings.TrimSuffix(string(p), "\n"),
+	})
+
+	return len(p), nil
+}
+
+// take returns the accumulated records and empties the batch.
+func (b *batch) take() []record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	records := b.records
+	b.records = nil
+
+	return records
+}
+
+// syslogSink delivers records to a syslog server in RFC 5424 format.
+type syslogSink struct {
+	batch
+
+	network  string
+	addr     string
+	hostname string
+	pid      int
+}
+
+func newSyslogSink(rawURL string) (*syslogSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported scheme \"%s\": use udp or tcp", u.Scheme)
+	}
+	if u.Port() == "" {
+		return nil, errors.New("missing port")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	return &syslogSink{
+		network:  u.Scheme,
+		addr:     u.Host,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}, nil
+}
+
+// Flush sends the buffered records to the syslog server over a single
+// connection. Over UDP, each record is sent as its own datagram; over TCP,
+// records are delimited by newlines.
+func (s *syslogSink) Flush() error {
+	records := s.take()
+	if len(records) == 0 {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(s.network, s.addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		msg := s.format(r)
+		if s.network == "tcp" {
+			msg += "\n"
+		}
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// format renders r as an RFC 5424 message with the user.info priority.
+func (s *syslogSink) format(r record) string {
+	return fmt.Sprintf("<14>1 %s %s buildpulse-test-reporter %d - - %s",
+		r.time.UTC().Format(time.RFC3339Nano), s.hostname, s.pid, r.message)
+}
+
+// cloudWatchSink delivers records to a log stream in AWS CloudWatch Logs.
+type cloudWatchSink struct {
+	batch
+
+	client *cloudwatchlogs.CloudWatchLogs
+	group  string
+	stream string
+}
+
+func newCloudWatchSink(group string, stream string) (*cloudWatchSink, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		return nil, errors.New("missing AWS region for BUILDPULSE_LOG_CLOUDWATCH_GROUP: set AWS_REGION")
+	}
+
+	return &cloudWatchSink{
+		client: cloudwatchlogs.New(sess),
+		group:  group,
+		stream: stream,
+	}, nil
+}
+
+// Flush sends the buffered records to CloudWatch Logs, creating the log
+// stream if it doesn't exist yet.
+func (c *cloudWatchSink) Flush() error {
+	records := c.take()
+	if len(records) == 0 {
+		return nil
+	}
+
+	_, err := c.client.CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(c.group),
+		LogStreamName: aws.String(c.stream),
+	})
+	var aerr awserr.Error
+	if err != nil && !(errors.As(err, &aerr) && aerr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException) {
+		return err
+	}
+
+	events := make([]*cloudwatchlogs.InputLogEvent, len(records))
+	for i, r := range records {
+		events[i] = &cloudwatchlogs.InputLogEvent{
+			Message:   aws.String(r.message),
+			Timestamp: aws.Int64(r.time.UnixMilli()),
+		}
+	}
+
+	_, err = c.client.PutLogEvents(&cloudwatchlogs.PutLogEventsInput{
+		LogEvents:     events,
+		LogGroupName:  aws.String(c.group),
+		LogStreamName: aws.String(c.stream),
+	})
+	return err
+}
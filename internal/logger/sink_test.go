@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSinksFromEnv(t *testing.T) {
+	t.Run("NoSinksConfigured", func(t *testing.T) {
+		sinks, err := NewSinksFromEnv(map[string]string{})
+		require.NoError(t, err)
+		assert.Empty(t, sinks)
+	})
+
+	t.Run("InvalidSyslogAddr", func(t *testing.T) {
+		_, err := NewSinksFromEnv(map[string]string{"BUILDPULSE_LOG_SYSLOG_ADDR": "http://logs.example.com:514"})
+		if assert.Error(t, err) {
+			assert.Equal(t, `invalid value "http://logs.example.com:514" for environment variable BUILDPULSE_LOG_SYSLOG_ADDR: unsupported scheme "http": use udp or tcp`, err.Error())
+		}
+	})
+}
+
+func Test_syslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sinks, err := NewSinksFromEnv(map[string]string{
+		"BUILDPULSE_LOG_SYSLOG_ADDR": fmt.Sprintf("udp://%s", conn.LocalAddr()),
+	})
+	require.NoError(t, err)
+	require.Len(t, sinks, 1)
+
+	log := New(sinks[0])
+	log.Printf("Gathering metadata to describe the build")
+	log.Println("Delivered test results to BuildPulse")
+	require.NoError(t, sinks[0].Flush())
+
+	var messages []string
+	buf := make([]byte, 1024)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		messages = append(messages, string(buf[:n]))
+	}
+
+	assert.Regexp(t, `^<14>1 \S+ \S+ buildpulse-test-reporter \d+ - - <buildpulse> Gathering metadata to describe the build$`, messages[0])
+	assert.Regexp(t, `^<14>1 \S+ \S+ buildpulse-test-reporter \d+ - - <buildpulse> Delivered test results to BuildPulse$`, messages[1])
+
+	// Flushing again sends nothing, since the batch was already delivered
+	require.NoError(t, sinks[0].Flush())
+}
+
+func Test_syslogSink_redacted(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	envs := map[string]string{
+		"BUILDPULSE_LOG_SYSLOG_ADDR":   fmt.Sprintf("udp://%s", conn.LocalAddr()),
+		"BUILDPULSE_SECRET_ACCESS_KEY": "some-secret-access-key",
+	}
+	sinks, err := NewSinksFromEnv(envs)
+	require.NoError(t, err)
+	require.Len(t, sinks, 1)
+
+	log := New(NewRedactingWriter(sinks[0], envs))
+	log.Printf("Using secret some-secret-access-key")
+	require.NoError(t, sinks[0].Flush())
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `<buildpulse> Using secret \[REDACTED\]$`, string(buf[:n]))
+}
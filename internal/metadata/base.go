@@ -0,0 +1,102 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// defaultBaseBranches lists the branches to try, in order, as the base branch
+// when the repository doesn't record the default branch of its origin remote.
+var defaultBaseBranches = []string{"main", "master"}
+
+// ResolveBaseBranch uses the repository located at path to find the base
+// branch of the commit with the given SHA (or the commit at the repository's
+// HEAD if sha is empty), and returns the name of the branch along with the SHA
+// of the merge base of the commit and the branch.
+//
+// If branch is empty, the base branch is the default branch of the origin
+// remote (as recorded by refs/remotes/origin/HEAD), or else the first of the
+// defaultBaseBranches that exists. The branch is looked up as a remote-tracking
+// branch of origin before a local branch, since CI checkouts rarely have local
+// branches other than the one being built.
+func ResolveBaseBranch(path string, sha string, branch string) (string, string, error) {
+	repo, err := openRepository(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	var commit *object.Commit
+	if sha == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return "", "", err
+		}
+		commit, err = repo.CommitObject(head.Hash())
+		if err != nil {
+			return "", "", err
+		}
+	} else {
+		commit, err = repo.CommitObject(plumbing.NewHash(sha))
+		if err != nil {
+			return "", "", fmt.Errorf("unable to find commit %s: %v", sha, err)
+		}
+	}
+
+	candidates := []string{branch}
+	if branch == "" {
+		candidates = defaultBaseBranches
+		if name, ok := originDefaultBranch(repo); ok {
+			candidates = []string{name}
+		}
+	}
+
+	for _, name := range candidates {
+		ref, err := branchRef(repo, name)
+		if err != nil {
+			continue
+		}
+
+		base, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return "", "", err
+		}
+
+		bases, err := commit.MergeBase(base)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to find merge base of %s and %s: %v", commit.Hash, name, err)
+		}
+		if len(bases) == 0 {
+			return "", "", fmt.Errorf("commit %s has no history in common with branch %s", commit.Hash, name)
+		}
+
+		return name, bases[0].Hash.String(), nil
+	}
+
+	return "", "", fmt.Errorf("no branch found named %s", strings.Join(candidates, " or "))
+}
+
+// originDefaultBranch returns the name of the origin remote's default branch,
+// if the repository records it.
+func originDefaultBranch(repo *git.Repository) (string, bool) {
+	ref, err := repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return "", false
+	}
+
+	return strings.TrimPrefix(ref.Target().String(), "refs/remotes/origin/"), true
+}
+
+// branchRef returns the reference for the origin remote's branch with the given
+// name, or for the local branch if there's no such remote branch.
+func branchRef(repo *git.Repository, name string) (*plumbing.Reference, error) {
+	ref, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", name), true)
+	if err == nil {
+		return ref, nil
+	}
+
+	return repo.Reference(plumbing.NewBranchReferenceName(name), true)
+}
@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBaseBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	origin := t.TempDir()
+	runGit(t, origin, "init", "--quiet", "--initial-branch", "trunk")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Base")
+	mergeBase := runGit(t, origin, "rev-parse", "HEAD")
+	runGit(t, origin, "branch", "release")
+	runGit(t, origin, "checkout", "--quiet", "-b", "feature")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+	head := runGit(t, origin, "rev-parse", "HEAD")
+	runGit(t, origin, "checkout", "--quiet", "trunk")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Trunk")
+
+	dir := t.TempDir()
+	runGit(t, dir, "clone", "--quiet", "--branch", "feature", "file://"+origin, ".")
+
+	t.Run("FromOriginHEAD", func(t *testing.T) {
+		branch, base, err := ResolveBaseBranch(dir, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, "trunk", branch)
+		assert.Equal(t, mergeBase, base)
+	})
+
+	t.Run("GivenBranch", func(t *testing.T) {
+		branch, base, err := ResolveBaseBranch(dir, head, "release")
+		require.NoError(t, err)
+		assert.Equal(t, "release", branch)
+		assert.Equal(t, mergeBase, base)
+	})
+
+	t.Run("MissingBranch", func(t *testing.T) {
+		_, _, err := ResolveBaseBranch(dir, head, "bogus")
+		assert.EqualError(t, err, "no branch found named bogus")
+	})
+}
+
+func TestResolveBaseBranch_withoutRemote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch", "master")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Base")
+	mergeBase := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "checkout", "--quiet", "-b", "feature")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+
+	branch, base, err := ResolveBaseBranch(dir, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "master", branch)
+	assert.Equal(t, mergeBase, base)
+}
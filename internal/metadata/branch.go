@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// branchSourceInferred is the branch source recorded for a branch inferred
+// from the repository, instead of reported by the CI provider.
+const branchSourceInferred = "inferred"
+
+// A branchInferrer is a CommitResolver that can infer the branch of a commit
+// when the CI provider doesn't report one (e.g., for a detached HEAD).
+type branchInferrer interface {
+	InferBranch(sha string) (string, error)
+}
+
+// InferBranch returns the name of the branch whose tip is the commit with the
+// given SHA: the branch checked out at the repository's HEAD, or else the one
+// local or remote-tracking branch that points at the commit (without the name
+// of the remote). It returns an error if no branch, or more than one, points at
+// the commit.
+func (r *repositoryCommitResolver) InferBranch(sha string) (string, error) {
+	hash := plumbing.NewHash(sha)
+
+	head, err := r.repo.Head()
+	if err == nil && head.Name().IsBranch() && head.Hash() == hash {
+		return head.Name().Short(), nil
+	}
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[string]bool)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || ref.Hash() != hash {
+			return nil
+		}
+
+		switch name := ref.Name(); {
+		case name.IsBranch():
+			names[name.Short()] = true
+		case name.IsRemote():
+			// refs/remotes/<remote>/<branch>
+			_, branch, ok := strings.Cut(strings.TrimPrefix(name.String(), "refs/remotes/"), "/")
+			if ok && branch != "HEAD" {
+				names[branch] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no branch points at commit %s", sha)
+	case 1:
+		for name := range names {
+			return name, nil
+		}
+	}
+
+	var list []string
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return "", fmt.Errorf("several branches point at commit %s: %s", sha, strings.Join(list, ", "))
+}
+
+// InferBranch infers the branch with the first of the resolvers that can.
+func (f *fallbackCommitResolver) InferBranch(sha string) (string, error) {
+	for _, r := range f.resolvers {
+		if bi, ok := r.(branchInferrer); ok {
+			return bi.InferBranch(sha)
+		}
+	}
+
+	return "", fmt.Errorf("unable to infer branches via %s", f.source)
+}
+
+func (m *Metadata) initInferredBranch(cr CommitResolver) {
+	bi, ok := cr.(branchInferrer)
+	if !ok {
+		return
+	}
+
+	branch, err := bi.InferBranch(m.CommitSHA)
+	if err != nil {
+		m.logger.Printf("Unable to infer branch from repository: %v", err)
+		return
+	}
+
+	m.logger.Printf("CI provider didn't report a branch; inferred branch from repository: %s", branch)
+	m.Branch = branch
+	m.BranchSource = branchSourceInferred
+}
@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryCommitResolver_InferBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	origin := t.TempDir()
+	runGit(t, origin, "init", "--quiet", "--initial-branch", "main")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Base")
+	base := runGit(t, origin, "rev-parse", "HEAD")
+	runGit(t, origin, "branch", "release")
+	runGit(t, origin, "checkout", "--quiet", "-b", "feature")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+	feature := runGit(t, origin, "rev-parse", "HEAD")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Unpushed")
+	unpushed := runGit(t, origin, "rev-parse", "HEAD")
+	runGit(t, origin, "reset", "--quiet", "--hard", "HEAD~1")
+
+	dir := t.TempDir()
+	runGit(t, dir, "clone", "--quiet", "--branch", "main", "file://"+origin, ".")
+
+	resolver, err := NewRepositoryCommitResolver(dir, false, logger.New())
+	require.NoError(t, err)
+	bi := resolver.(branchInferrer)
+
+	t.Run("AttachedHEAD", func(t *testing.T) {
+		branch, err := bi.InferBranch(base)
+		require.NoError(t, err)
+		assert.Equal(t, "main", branch)
+	})
+
+	t.Run("DetachedHEAD", func(t *testing.T) {
+		runGit(t, dir, "checkout", "--quiet", "--detach", feature)
+		defer runGit(t, dir, "checkout", "--quiet", "main")
+
+		branch, err := bi.InferBranch(feature)
+		require.NoError(t, err)
+		assert.Equal(t, "feature", branch)
+	})
+
+	t.Run("SeveralBranches", func(t *testing.T) {
+		runGit(t, dir, "checkout", "--quiet", "--detach", base)
+		defer runGit(t, dir, "checkout", "--quiet", "main")
+
+		_, err := bi.InferBranch(base)
+		assert.EqualError(t, err, "several branches point at commit "+base+": main, release")
+	})
+
+	t.Run("NoBranch", func(t *testing.T) {
+		_, err := bi.InferBranch(unpushed)
+		assert.EqualError(t, err, "no branch points at commit "+unpushed)
+	})
+}
+
+func TestNewMetadata_inferredBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch", "main")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Base")
+	runGit(t, dir, "checkout", "--quiet", "-b", "feature")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+	sha := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "checkout", "--quiet", "--detach")
+
+	repoResolver, err := NewRepositoryCommitResolver(dir, false, logger.New())
+	require.NoError(t, err)
+	resolver := NewFallbackCommitResolver(logger.New(), repoResolver, NewStaticCommitResolver(&Commit{}, logger.New()))
+
+	t.Run("NotReported", func(t *testing.T) {
+		envs := map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_SHA":     sha,
+		}
+
+		meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", resolver, time.Now, logger.New())
+		require.NoError(t, err)
+		assert.Equal(t, "feature", meta.Branch)
+		assert.Equal(t, "inferred", meta.BranchSource)
+
+		yaml, err := meta.MarshalYAML()
+		require.NoError(t, err)
+		assert.Contains(t, string(yaml), ":branch: feature\n:branch_source: inferred\n")
+	})
+
+	t.Run("Reported", func(t *testing.T) {
+		envs := map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_REF":     "refs/heads/some-branch",
+			"GITHUB_SHA":     sha,
+		}
+
+		meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", resolver, time.Now, logger.New())
+		require.NoError(t, err)
+		assert.Equal(t, "some-branch", meta.Branch)
+		assert.Empty(t, meta.BranchSource)
+	})
+}
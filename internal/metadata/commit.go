@@ -1,7 +1,10 @@
 package metadata
 
 import (
+	"context"
 	"fmt"
+	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/buildpulse/test-reporter/internal/logger"
@@ -18,25 +21,43 @@ type Commit struct {
 	CommitterEmail string
 	CommitterName  string
 	Message        string
+	ParentSHAs     []string // two or more for a merge commit
 	SHA            string
 	TreeSHA        string
 }
 
-// A CommitResolver provides the ability to look up a commit.
+// A CommitResolver provides the ability to look up a commit. Lookup stops
+// early, returning an error, if ctx is done.
 type CommitResolver interface {
-	Lookup(sha string) (*Commit, error)
+	Lookup(ctx context.Context, sha string) (*Commit, error)
 	Source() string
 }
 
 type repositoryCommitResolver struct {
 	logger logger.Logger
 	repo   *git.Repository
+	path   string
+	deepen bool
 }
 
 // NewRepositoryCommitResolver returns a CommitResolver for looking up commits
-// in the repository located at path.
-func NewRepositoryCommitResolver(path string, logger logger.Logger) (CommitResolver, error) {
-	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+// in the repository located at path. If deepen is true and a commit is missing
+// from a shallow clone, the resolver fetches the rest of the repository's
+// history (using the git CLI) and looks up the commit again.
+func NewRepositoryCommitResolver(path string, deepen bool, logger logger.Logger) (CommitResolver, error) {
+	repo, err := openRepository(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repositoryCommitResolver{repo: repo, logger: logger, path: path, deepen: deepen}, nil
+}
+
+// openRepository opens the repository containing path. The path can be a
+// secondary worktree (created with `git worktree add`), whose .git file points
+// to a directory that shares the objects and refs of the main repository.
+func openRepository(path string) (*git.Repository, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
 	if err != nil {
 		if err == git.ErrRepositoryNotExists {
 			return nil, fmt.Errorf("no repository found at %s", path)
@@ -45,12 +66,34 @@ func NewRepositoryCommitResolver(path string, logger logger.Logger) (CommitResol
 		return nil, err
 	}
 
-	return &repositoryCommitResolver{repo: repo, logger: logger}, nil
+	return repo, nil
 }
 
-func (r *repositoryCommitResolver) Lookup(sha string) (*Commit, error) {
+// Lookup returns the commit with the given SHA, or the commit at the
+// repository's HEAD if sha is empty.
+func (r *repositoryCommitResolver) Lookup(ctx context.Context, sha string) (*Commit, error) {
+	if sha == "" {
+		head, err := r.repo.Head()
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve HEAD: %v", err)
+		}
+		sha = head.Hash().String()
+		r.logger.Printf("No commit SHA given; using repository's HEAD commit: %s", sha)
+	}
+
 	r.logger.Printf("Looking up info for commit `%s` in git repository", sha)
 	c, err := r.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil && r.isShallow() {
+		if !r.deepen {
+			return nil, fmt.Errorf("unable to find commit with SHA `%s`: the repository is a shallow clone that doesn't include the commit; fetch more history (e.g., set `fetch-depth: 0` for actions/checkout, or run `git fetch --unshallow`) or use the -deepen-shallow-clone flag", sha)
+		}
+
+		r.logger.Printf("Commit `%s` not found in shallow clone; fetching the rest of the repository's history", sha)
+		if err := r.unshallow(ctx); err != nil {
+			return nil, fmt.Errorf("unable to find commit with SHA `%s`: unable to deepen shallow clone: %v", sha, err)
+		}
+		c, err = r.repo.CommitObject(plumbing.NewHash(sha))
+	}
 	if err != nil {
 		// To help with diagnosing this error, try to log the HEAD reference, but if we encounter an error, just move on.
 		head, headErr := r.repo.Head()
@@ -62,6 +105,11 @@ func (r *repositoryCommitResolver) Lookup(sha string) (*Commit, error) {
 	}
 	r.logger.Println("Found commit info")
 
+	var parents []string
+	for _, h := range c.ParentHashes {
+		parents = append(parents, h.String())
+	}
+
 	return &Commit{
 		AuthoredAt:     c.Author.When,
 		AuthorEmail:    c.Author.Email,
@@ -70,6 +118,7 @@ func (r *repositoryCommitResolver) Lookup(sha string) (*Commit, error) {
 		CommitterEmail: c.Committer.Email,
 		CommitterName:  c.Committer.Name,
 		Message:        c.Message,
+		ParentSHAs:     parents,
 		SHA:            c.Hash.String(),
 		TreeSHA:        c.TreeHash.String(),
 	}, nil
@@ -79,6 +128,30 @@ func (r *repositoryCommitResolver) Source() string {
 	return "Repository"
 }
 
+// isShallow reports whether the repository is a shallow clone.
+func (r *repositoryCommitResolver) isShallow() bool {
+	shallow, err := r.repo.Storer.Shallow()
+	return err == nil && len(shallow) > 0
+}
+
+// unshallow fetches the rest of the history of the shallow clone, and reopens
+// the repository to pick up the fetched objects. The fetch is killed if ctx is
+// done.
+func (r *repositoryCommitResolver) unshallow(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", r.path, "fetch", "--unshallow", "--no-tags")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	repo, err := openRepository(r.path)
+	if err != nil {
+		return err
+	}
+	r.repo = repo
+
+	return nil
+}
+
 type staticCommitResolver struct {
 	commit *Commit
 }
@@ -89,7 +162,7 @@ func NewStaticCommitResolver(c *Commit, logger logger.Logger) CommitResolver {
 	return &staticCommitResolver{commit: c}
 }
 
-func (s *staticCommitResolver) Lookup(sha string) (*Commit, error) {
+func (s *staticCommitResolver) Lookup(ctx context.Context, sha string) (*Commit, error) {
 	return &Commit{
 		SHA:            sha,
 		AuthoredAt:     s.commit.AuthoredAt,
@@ -99,6 +172,7 @@ func (s *staticCommitResolver) Lookup(sha string) (*Commit, error) {
 		CommitterEmail: s.commit.CommitterEmail,
 		CommitterName:  s.commit.CommitterName,
 		Message:        s.commit.Message,
+		ParentSHAs:     s.commit.ParentSHAs,
 		TreeSHA:        s.commit.TreeSHA,
 	}, nil
 }
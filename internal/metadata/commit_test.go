@@ -1,7 +1,12 @@
 package metadata
 
 import (
+	"context"
+	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,7 +17,7 @@ import (
 )
 
 func TestNewRepositoryCommitResolver_invalidRepo(t *testing.T) {
-	_, err := NewRepositoryCommitResolver(t.TempDir(), logger.New())
+	_, err := NewRepositoryCommitResolver(t.TempDir(), false, logger.New())
 	if assert.Error(t, err) {
 		assert.Contains(t, err.Error(), "no repository found")
 	}
@@ -23,10 +28,10 @@ func Test_repositoryCommitResolver_Lookup(t *testing.T) {
 	err := copy.Copy("./testdata/example-repository.git", path.Join(dir, ".git"))
 	require.NoError(t, err)
 
-	r, err := NewRepositoryCommitResolver(dir, logger.New())
+	r, err := NewRepositoryCommitResolver(dir, false, logger.New())
 	require.NoError(t, err)
 
-	c, err := r.Lookup("5974e4edce87279f60adaf55c2adcee8847b2612")
+	c, err := r.Lookup(context.Background(), "5974e4edce87279f60adaf55c2adcee8847b2612")
 	require.NoError(t, err)
 
 	assert.Equal(t, "Thu Dec 31 01:02:03 +1300 2020", c.AuthoredAt.Format(time.UnixDate))
@@ -36,6 +41,22 @@ func Test_repositoryCommitResolver_Lookup(t *testing.T) {
 	assert.Equal(t, "jim@dundermifflin.com", c.CommitterEmail)
 	assert.Equal(t, "Jim Halpert", c.CommitterName)
 	assert.Equal(t, "Add some flair\n", c.Message)
+	assert.Equal(t, []string{"72bb4b60997efd3c179ccfc0c963ab84b35f992e"}, c.ParentSHAs)
+	assert.Equal(t, "5974e4edce87279f60adaf55c2adcee8847b2612", c.SHA)
+	assert.Equal(t, "eb8b39c87131c1f3543bc6e5a426f7d4d631bc15", c.TreeSHA)
+}
+
+func Test_repositoryCommitResolver_Lookup_head(t *testing.T) {
+	dir := t.TempDir()
+	err := copy.Copy("./testdata/example-repository.git", path.Join(dir, ".git"))
+	require.NoError(t, err)
+
+	r, err := NewRepositoryCommitResolver(dir, false, logger.New())
+	require.NoError(t, err)
+
+	c, err := r.Lookup(context.Background(), "")
+	require.NoError(t, err)
+
 	assert.Equal(t, "5974e4edce87279f60adaf55c2adcee8847b2612", c.SHA)
 	assert.Equal(t, "eb8b39c87131c1f3543bc6e5a426f7d4d631bc15", c.TreeSHA)
 }
@@ -45,11 +66,117 @@ func Test_repositoryCommitResolver_Lookup_notFound(t *testing.T) {
 	err := copy.Copy("./testdata/example-repository.git", path.Join(dir, ".git"))
 	require.NoError(t, err)
 
-	r, err := NewRepositoryCommitResolver(dir, logger.New())
+	r, err := NewRepositoryCommitResolver(dir, false, logger.New())
 	require.NoError(t, err)
 
-	_, err = r.Lookup("0000000000000000000000000000000000000000")
+	_, err = r.Lookup(context.Background(), "0000000000000000000000000000000000000000")
 	if assert.Error(t, err) {
 		assert.Contains(t, err.Error(), "unable to find commit with SHA `0000000000000000000000000000000000000000`")
 	}
 }
+
+func Test_repositoryCommitResolver_Lookup_shallowClone(t *testing.T) {
+	dir := t.TempDir()
+	err := copy.Copy("./testdata/example-repository.git", path.Join(dir, ".git"))
+	require.NoError(t, err)
+
+	// Mark the repository as a shallow clone
+	err = os.WriteFile(path.Join(dir, ".git", "shallow"), []byte("5974e4edce87279f60adaf55c2adcee8847b2612\n"), 0644)
+	require.NoError(t, err)
+
+	r, err := NewRepositoryCommitResolver(dir, false, logger.New())
+	require.NoError(t, err)
+
+	_, err = r.Lookup(context.Background(), "0000000000000000000000000000000000000000")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the repository is a shallow clone that doesn't include the commit")
+		assert.Contains(t, err.Error(), "fetch-depth: 0")
+	}
+}
+
+func Test_repositoryCommitResolver_Lookup_deepenShallowClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	origin := t.TempDir()
+	runGit(t, origin, "init", "--quiet")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "First")
+	first := runGit(t, origin, "rev-parse", "HEAD")
+	runGit(t, origin, "commit", "--quiet", "--allow-empty", "-m", "Second")
+
+	dir := t.TempDir()
+	runGit(t, dir, "clone", "--quiet", "--depth", "1", "file://"+origin, ".")
+
+	r, err := NewRepositoryCommitResolver(dir, true, logger.New())
+	require.NoError(t, err)
+
+	c, err := r.Lookup(context.Background(), first)
+	require.NoError(t, err)
+	assert.Equal(t, first, c.SHA)
+	assert.Equal(t, "First\n", c.Message)
+}
+
+func Test_repositoryCommitResolver_Lookup_mergeCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch", "main")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Base")
+	runGit(t, dir, "checkout", "--quiet", "-b", "feature")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+	head := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "checkout", "--quiet", "main")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Main")
+	base := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "merge", "--quiet", "--no-ff", "-m", "Merge feature", "feature")
+
+	r, err := NewRepositoryCommitResolver(dir, false, logger.New())
+	require.NoError(t, err)
+
+	c, err := r.Lookup(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{base, head}, c.ParentSHAs)
+}
+
+func Test_repositoryCommitResolver_Lookup_worktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch", "main")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Base")
+	runGit(t, dir, "branch", "feature")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "Main")
+
+	worktree := filepath.Join(t.TempDir(), "feature")
+	runGit(t, dir, "worktree", "add", "--quiet", worktree, "feature")
+	runGit(t, worktree, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+	head := runGit(t, worktree, "rev-parse", "HEAD")
+
+	r, err := NewRepositoryCommitResolver(worktree, false, logger.New())
+	require.NoError(t, err)
+
+	c, err := r.Lookup(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, head, c.SHA)
+	assert.Equal(t, "Feature\n", c.Message)
+}
+
+// runGit runs the git CLI in dir and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Some Author", "GIT_AUTHOR_EMAIL=author@example.com",
+		"GIT_COMMITTER_NAME=Some Committer", "GIT_COMMITTER_EMAIL=committer@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	return strings.TrimSpace(string(out))
+}
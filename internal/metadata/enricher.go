@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// An Enricher adds optional information to the metadata, such as facts about
+// the machine on which the tests ran. Each enricher is an independent
+// integration that can be enabled or disabled without affecting the rest of
+// the metadata.
+type Enricher interface {
+	// Name returns the name by which the enricher is enabled (e.g., "runner").
+	Name() string
+
+	// Enrich adds the enricher's information to m.
+	Enrich(ctx context.Context, m *Metadata) error
+}
+
+// DefaultEnrichers lists the names of the enrichers that are enabled unless
+// specified otherwise.
+var DefaultEnrichers = []string{"runner"}
+
+var (
+	enrichersMu sync.RWMutex
+	enrichers   = make(map[string]Enricher)
+)
+
+// RegisterEnricher makes e available by its name. It's intended to be called
+// from the init function of the file that implements e. It panics if an
+// enricher with the same name is already registered.
+func RegisterEnricher(e Enricher) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+
+	if _, dup := enrichers[e.Name()]; dup {
+		panic("metadata: RegisterEnricher called twice for enricher " + e.Name())
+	}
+	enrichers[e.Name()] = e
+}
+
+// Enrichers returns the names of the registered enrichers, in sorted order.
+func Enrichers() []string {
+	enrichersMu.RLock()
+	defer enrichersMu.RUnlock()
+
+	var names []string
+	for name := range enrichers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Enrich applies the enrichers with the given names to m, in the given order.
+// It returns an error if any of the names isn't registered, or if any of the
+// enrichers fails.
+func (m *Metadata) Enrich(ctx context.Context, names []string) error {
+	enrichersMu.RLock()
+	defer enrichersMu.RUnlock()
+
+	for _, name := range names {
+		e, ok := enrichers[name]
+		if !ok {
+			return fmt.Errorf("unknown enricher: %s", name)
+		}
+
+		m.logger.Printf("Enriching metadata with %s", name)
+		if err := e.Enrich(ctx, m); err != nil {
+			return fmt.Errorf("enricher %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Getenv returns the value of the environment variable named by key, from the
+// environment with which m was created.
+func (m *Metadata) Getenv(key string) string {
+	return m.envs[key]
+}
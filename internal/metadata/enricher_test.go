@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tagEnricher struct {
+	name string
+	err  error
+}
+
+func (t *tagEnricher) Name() string {
+	return t.name
+}
+
+func (t *tagEnricher) Enrich(ctx context.Context, m *Metadata) error {
+	m.Tags = append(m.Tags, t.name+":"+m.Getenv("SOME_VAR"))
+	return t.err
+}
+
+func init() {
+	RegisterEnricher(&tagEnricher{name: "test-first"})
+	RegisterEnricher(&tagEnricher{name: "test-second"})
+	RegisterEnricher(&tagEnricher{name: "test-failing", err: errors.New("some error")})
+}
+
+func newEnricherTestMetadata(t *testing.T) *Metadata {
+	envs := map[string]string{
+		"BUILD_URL":         "https://example.com/builds/42",
+		"GIT_BRANCH":        "some-branch",
+		"GIT_COMMIT":        "1f192ff735f887dd7a25229b2ece0422d17931f5",
+		"ORGANIZATION_NAME": "some-owner",
+		"REPOSITORY_NAME":   "some-repo",
+		"SOME_VAR":          "some-value",
+	}
+	m, err := NewMetadata(context.Background(), &Version{}, envs, nil, "", newCommitResolverStub(), time.Now, logger.New())
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestEnrichers(t *testing.T) {
+	assert.Subset(t, Enrichers(), []string{"runner", "test-failing", "test-first", "test-second"})
+	assert.IsIncreasing(t, Enrichers())
+}
+
+func TestMetadata_Enrich(t *testing.T) {
+	m := newEnricherTestMetadata(t)
+
+	err := m.Enrich(context.Background(), []string{"test-second", "test-first"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test-second:some-value", "test-first:some-value"}, m.Tags)
+}
+
+func TestMetadata_Enrich_errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		errMsg string
+	}{
+		{
+			name:   "UnknownEnricher",
+			names:  []string{"test-first", "bogus"},
+			errMsg: "unknown enricher: bogus",
+		},
+		{
+			name:   "FailingEnricher",
+			names:  []string{"test-failing"},
+			errMsg: "enricher test-failing: some error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newEnricherTestMetadata(t).Enrich(context.Background(), tt.names)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.errMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestRegisterEnricher_duplicate(t *testing.T) {
+	assert.Panics(t, func() { RegisterEnricher(&tagEnricher{name: "test-first"}) })
+}
+
+func TestMetadata_Enrich_runner(t *testing.T) {
+	m := newEnricherTestMetadata(t)
+
+	err := m.Enrich(context.Background(), []string{"runner"})
+	require.NoError(t, err)
+	assert.Equal(t, runtime.NumCPU(), m.Runner.CPUCount)
+}
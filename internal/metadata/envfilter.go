@@ -0,0 +1,156 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// An envFilter decides which environment variables may be captured in the
+// provider-specific metadata fields, for compliance teams that consider some
+// CI variables sensitive. It's configured with the BUILDPULSE_INCLUDE_ENV and
+// BUILDPULSE_EXCLUDE_ENV environment variables, which hold comma-separated
+// names or patterns (e.g., GITHUB_EVENT_*). A variable is allowed if it matches
+// an include pattern (or there are none) and doesn't match an exclude pattern.
+type envFilter struct {
+	include []string
+	exclude []string
+}
+
+// ParseEnvPatterns parses a comma-separated list of environment variable names
+// or patterns (in the syntax of path.Match), as given to BUILDPULSE_INCLUDE_ENV
+// and BUILDPULSE_EXCLUDE_ENV.
+func ParseEnvPatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("malformed pattern \"%s\"", p)
+		}
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
+func newEnvFilter(envs map[string]string) (*envFilter, error) {
+	include, err := ParseEnvPatterns(envs["BUILDPULSE_INCLUDE_ENV"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_INCLUDE_ENV: %v", envs["BUILDPULSE_INCLUDE_ENV"], err)
+	}
+
+	exclude, err := ParseEnvPatterns(envs["BUILDPULSE_EXCLUDE_ENV"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_EXCLUDE_ENV: %v", envs["BUILDPULSE_EXCLUDE_ENV"], err)
+	}
+
+	return &envFilter{include: include, exclude: exclude}, nil
+}
+
+// allows reports whether the named environment variable may be captured.
+func (f *envFilter) allows(name string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+
+	return !matchAny(f.exclude, name)
+}
+
+func (f *envFilter) empty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A fieldSourceProvider is a providerMetadata that knows which environment
+// variable each of its provider-specific fields comes from, for providers
+// whose fields aren't described by struct tags.
+type fieldSourceProvider interface {
+	FieldSources() map[string]string
+}
+
+// fieldSources returns the environment variable that each provider-specific
+// field of pm comes from, keyed by the field's name in the metadata (e.g.,
+// ":github_run_id"). Fields that aren't read from a single variable are left
+// out.
+func fieldSources(pm providerMetadata) map[string]string {
+	if p, ok := pm.(fieldSourceProvider); ok {
+		return p.FieldSources()
+	}
+
+	sources := make(map[string]string)
+	v := reflect.Indirect(reflect.ValueOf(pm))
+	if v.Kind() == reflect.Struct {
+		structFieldSources(v.Type(), sources)
+	}
+
+	return sources
+}
+
+func structFieldSources(t reflect.Type, sources map[string]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && strings.Contains(opts, "inline") {
+			structFieldSources(f.Type, sources)
+			continue
+		}
+
+		key, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+		if key == "" || name == "" || name == "-" {
+			continue
+		}
+		sources[name] = key
+	}
+}
+
+// filter returns a copy of the YAML mapping in data (the provider metadata)
+// without the fields whose source (per sources) isn't allowed by f. It also
+// returns the names of the environment variables it left out.
+func (f *envFilter) filter(data []byte, sources map[string]string) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil, nil
+	}
+
+	var excluded []string
+	fields := doc.Content[0]
+	kept := fields.Content[:0]
+	for i := 0; i+1 < len(fields.Content); i += 2 {
+		key, value := fields.Content[i], fields.Content[i+1]
+		if source, ok := sources[key.Value]; ok && !f.allows(source) {
+			excluded = append(excluded, source)
+			continue
+		}
+		kept = append(kept, key, value)
+	}
+	if len(excluded) == 0 {
+		return data, nil, nil
+	}
+	fields.Content = kept
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, excluded, nil
+}
@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvPatterns(t *testing.T) {
+	patterns, err := ParseEnvPatterns(" GITHUB_ACTOR, GITHUB_EVENT_* ,,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GITHUB_ACTOR", "GITHUB_EVENT_*"}, patterns)
+
+	_, err = ParseEnvPatterns("GITHUB_[")
+	assert.EqualError(t, err, `malformed pattern "GITHUB_["`)
+}
+
+func Test_envFilter_allows(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    map[string]bool
+	}{
+		{
+			name: "no patterns",
+			want: map[string]bool{"GITHUB_ACTOR": true},
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"GITHUB_ACTOR", "GITHUB_HEAD_*"},
+			want:    map[string]bool{"GITHUB_ACTOR": false, "GITHUB_HEAD_REF": false, "GITHUB_REF": true},
+		},
+		{
+			name:    "include",
+			include: []string{"GITHUB_RUN_*"},
+			want:    map[string]bool{"GITHUB_ACTOR": false, "GITHUB_RUN_ID": true},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"GITHUB_RUN_*"},
+			exclude: []string{"GITHUB_RUN_ATTEMPT"},
+			want:    map[string]bool{"GITHUB_RUN_ID": true, "GITHUB_RUN_ATTEMPT": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &envFilter{include: tt.include, exclude: tt.exclude}
+			for name, want := range tt.want {
+				assert.Equal(t, want, f.allows(name), name)
+			}
+		})
+	}
+}
+
+func Test_fieldSources(t *testing.T) {
+	sources := fieldSources(&giteaMetadata{})
+	// Fields of the inlined struct are included, and fields that aren't
+	// serialized are left out
+	assert.Equal(t, "GITHUB_RUN_ID", sources[":github_run_id"])
+	assert.NotContains(t, sources, "-")
+
+	c := &configMetadata{config: &ProviderConfig{Fields: map[string]string{"drone_stage_name": "DRONE_STAGE_NAME"}}}
+	assert.Equal(t, map[string]string{":drone_stage_name": "DRONE_STAGE_NAME"}, fieldSources(c))
+}
+
+func TestMetadata_MarshalYAML_excludesEnv(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS":         "true",
+		"GITHUB_ACTOR":           "some-user",
+		"GITHUB_EVENT_NAME":      "push",
+		"GITHUB_REF":             "refs/heads/main",
+		"GITHUB_REPOSITORY":      "some-owner/some-repo",
+		"GITHUB_RUN_ID":          "42",
+		"GITHUB_SHA":             "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+		"BUILDPULSE_EXCLUDE_ENV": "GITHUB_ACTOR,GITHUB_EVENT_*,GITHUB_SHA",
+	}
+
+	log := logger.New()
+	meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", NewStaticCommitResolver(&Commit{}, log), time.Now, log)
+	require.NoError(t, err)
+
+	yaml, err := meta.MarshalYAML()
+	require.NoError(t, err)
+	assert.NotContains(t, string(yaml), ":github_actor")
+	assert.NotContains(t, string(yaml), ":github_event_name")
+	assert.Contains(t, string(yaml), ":github_ref: refs/heads/main\n")
+	// The commit is needed to process the results, so it's kept
+	assert.Contains(t, string(yaml), ":commit: aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb\n")
+	assert.Contains(t, log.Text(), "Excluded environment variables from provider metadata: GITHUB_ACTOR, GITHUB_EVENT_NAME")
+}
+
+func TestNewMetadata_invalidEnvPatterns(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS":         "true",
+		"GITHUB_SHA":             "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+		"BUILDPULSE_INCLUDE_ENV": "GITHUB_[",
+	}
+
+	log := logger.New()
+	_, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", NewStaticCommitResolver(&Commit{}, log), time.Now, log)
+	assert.EqualError(t, err, `invalid value "GITHUB_[" for environment variable BUILDPULSE_INCLUDE_ENV: malformed pattern "GITHUB_["`)
+}
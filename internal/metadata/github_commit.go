@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+)
+
+// DefaultGitHubAPIURL is the base URL of the GitHub API on github.com.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// gitHubLookupTimeout bounds the time spent fetching a commit from the GitHub
+// API.
+const gitHubLookupTimeout = 10 * time.Second
+
+type gitHubCommitResolver struct {
+	client *http.Client
+	apiURL string
+	repo   string // name-with-owner (e.g., "some-owner/some-repo")
+	token  string
+	logger logger.Logger
+}
+
+// NewGitHubCommitResolver returns a CommitResolver for looking up commits in
+// the given GitHub repository (e.g., "some-owner/some-repo") via the GitHub API
+// at apiURL (e.g., DefaultGitHubAPIURL, or the API of a GitHub Enterprise
+// Server), authenticating with token.
+func NewGitHubCommitResolver(client *http.Client, apiURL string, repo string, token string, logger logger.Logger) CommitResolver {
+	return &gitHubCommitResolver{
+		client: client,
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		repo:   repo,
+		token:  token,
+		logger: logger,
+	}
+}
+
+// gitHubPerson is the author or committer of a commit, as represented by the
+// GitHub API.
+type gitHubPerson struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// gitHubCommit is a commit, as represented by the GitHub API's git database
+// endpoint (GET /repos/{owner}/{repo}/git/commits/{sha}).
+type gitHubCommit struct {
+	SHA       string       `json:"sha"`
+	Author    gitHubPerson `json:"author"`
+	Committer gitHubPerson `json:"committer"`
+	Message   string       `json:"message"`
+	Parents   []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+	Tree struct {
+		SHA string `json:"sha"`
+	} `json:"tree"`
+}
+
+// Lookup returns the commit with the given SHA. Unlike the repository
+// resolver, it can't resolve HEAD, so sha is required.
+func (g *gitHubCommitResolver) Lookup(ctx context.Context, sha string) (*Commit, error) {
+	if sha == "" {
+		return nil, errors.New("unable to look up commit via the GitHub API: no commit SHA given")
+	}
+
+	g.logger.Printf("Looking up info for commit `%s` via the GitHub API (%s)", sha, g.repo)
+
+	ctx, cancel := context.WithTimeout(ctx, gitHubLookupTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/repos/%s/git/commits/%s", g.apiURL, g.repo, sha)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up commit via the GitHub API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unable to find commit with SHA `%s` via the GitHub API: %s: %s", sha, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var c gitHubCommit
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("unable to parse commit from the GitHub API: %v", err)
+	}
+	g.logger.Println("Found commit info")
+
+	var parents []string
+	for _, p := range c.Parents {
+		parents = append(parents, p.SHA)
+	}
+
+	return &Commit{
+		AuthoredAt:     c.Author.Date,
+		AuthorEmail:    c.Author.Email,
+		AuthorName:     c.Author.Name,
+		CommittedAt:    c.Committer.Date,
+		CommitterEmail: c.Committer.Email,
+		CommitterName:  c.Committer.Name,
+		Message:        c.Message,
+		ParentSHAs:     parents,
+		SHA:            c.SHA,
+		TreeSHA:        c.Tree.SHA,
+	}, nil
+}
+
+func (g *gitHubCommitResolver) Source() string {
+	return "GitHub API"
+}
+
+type fallbackCommitResolver struct {
+	resolvers []CommitResolver
+	logger    logger.Logger
+	source    string
+}
+
+// NewFallbackCommitResolver returns a CommitResolver that looks up each commit
+// with the first of the given resolvers, and then with each of the others in
+// turn until the lookup succeeds (e.g., to fall back to the GitHub API for a
+// commit that's missing from a shallow clone). Its Source is that of the
+// resolver that produced the most recent commit, or the first resolver if none
+// has.
+func NewFallbackCommitResolver(logger logger.Logger, resolvers ...CommitResolver) CommitResolver {
+	return &fallbackCommitResolver{resolvers: resolvers, logger: logger, source: resolvers[0].Source()}
+}
+
+func (f *fallbackCommitResolver) Lookup(ctx context.Context, sha string) (*Commit, error) {
+	var errs []error
+	for i, r := range f.resolvers {
+		c, err := r.Lookup(ctx, sha)
+		if err == nil {
+			f.source = r.Source()
+			return c, nil
+		}
+		errs = append(errs, err)
+
+		if i < len(f.resolvers)-1 {
+			f.logger.Printf("Commit lookup via %s unsuccessful (%v); trying %s", r.Source(), err, f.resolvers[i+1].Source())
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func (f *fallbackCommitResolver) Source() string {
+	return f.source
+}
@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/otiai10/copy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGitHubServer returns a server that mimics the GitHub API's git commits
+// endpoint for a single commit.
+func newGitHubServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer some-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+		if r.URL.Path != "/repos/some-owner/some-repo/git/commits/1f192ff735f887dd7a25229b2ece0422d17931f5" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"sha": "1f192ff735f887dd7a25229b2ece0422d17931f5",
+			"author": {"name": "Some Author", "email": "some-author@example.com", "date": "2020-07-09T09:05:06Z"},
+			"committer": {"name": "Some Committer", "email": "some-committer@example.com", "date": "2020-07-09T18:08:09Z"},
+			"message": "Some message\n",
+			"parents": [{"sha": "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"}],
+			"tree": {"sha": "0da9df599c02da5e7f5058b7108dcd5e1929a0fe"}
+		}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func Test_gitHubCommitResolver_Lookup(t *testing.T) {
+	server := newGitHubServer(t)
+
+	r := NewGitHubCommitResolver(server.Client(), server.URL+"/", "some-owner/some-repo", "some-token", logger.New())
+	c, err := r.Lookup(context.Background(), "1f192ff735f887dd7a25229b2ece0422d17931f5")
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2020, 7, 9, 9, 5, 6, 0, time.UTC), c.AuthoredAt)
+	assert.Equal(t, "some-author@example.com", c.AuthorEmail)
+	assert.Equal(t, "Some Author", c.AuthorName)
+	assert.Equal(t, time.Date(2020, 7, 9, 18, 8, 9, 0, time.UTC), c.CommittedAt)
+	assert.Equal(t, "some-committer@example.com", c.CommitterEmail)
+	assert.Equal(t, "Some Committer", c.CommitterName)
+	assert.Equal(t, "Some message\n", c.Message)
+	assert.Equal(t, []string{"aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb"}, c.ParentSHAs)
+	assert.Equal(t, "1f192ff735f887dd7a25229b2ece0422d17931f5", c.SHA)
+	assert.Equal(t, "0da9df599c02da5e7f5058b7108dcd5e1929a0fe", c.TreeSHA)
+	assert.Equal(t, "GitHub API", r.Source())
+}
+
+func Test_gitHubCommitResolver_Lookup_errors(t *testing.T) {
+	server := newGitHubServer(t)
+
+	tests := []struct {
+		name   string
+		token  string
+		sha    string
+		errMsg string
+	}{
+		{
+			name:   "no SHA",
+			token:  "some-token",
+			sha:    "",
+			errMsg: "unable to look up commit via the GitHub API: no commit SHA given",
+		},
+		{
+			name:   "bad token",
+			token:  "some-bogus-token",
+			sha:    "1f192ff735f887dd7a25229b2ece0422d17931f5",
+			errMsg: "unable to find commit with SHA `1f192ff735f887dd7a25229b2ece0422d17931f5` via the GitHub API: 401 Unauthorized: {\"message\": \"Bad credentials\"}",
+		},
+		{
+			name:   "unknown commit",
+			token:  "some-token",
+			sha:    "0000000000000000000000000000000000000000",
+			errMsg: "unable to find commit with SHA `0000000000000000000000000000000000000000` via the GitHub API: 404 Not Found: {\"message\": \"Not Found\"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewGitHubCommitResolver(server.Client(), server.URL, "some-owner/some-repo", tt.token, logger.New())
+			_, err := r.Lookup(context.Background(), tt.sha)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func Test_fallbackCommitResolver_Lookup(t *testing.T) {
+	server := newGitHubServer(t)
+
+	dir := t.TempDir()
+	err := copy.Copy("./testdata/example-repository.git", path.Join(dir, ".git"))
+	require.NoError(t, err)
+
+	repo, err := NewRepositoryCommitResolver(dir, false, logger.New())
+	require.NoError(t, err)
+	github := NewGitHubCommitResolver(server.Client(), server.URL, "some-owner/some-repo", "some-token", logger.New())
+
+	log := logger.New()
+	r := NewFallbackCommitResolver(log, repo, github)
+	assert.Equal(t, "Repository", r.Source())
+
+	// The commit is in the repository
+	c, err := r.Lookup(context.Background(), "5974e4edce87279f60adaf55c2adcee8847b2612")
+	require.NoError(t, err)
+	assert.Equal(t, "eb8b39c87131c1f3543bc6e5a426f7d4d631bc15", c.TreeSHA)
+	assert.Equal(t, "Repository", r.Source())
+
+	// The commit is missing from the repository, but not from GitHub
+	c, err = r.Lookup(context.Background(), "1f192ff735f887dd7a25229b2ece0422d17931f5")
+	require.NoError(t, err)
+	assert.Equal(t, "0da9df599c02da5e7f5058b7108dcd5e1929a0fe", c.TreeSHA)
+	assert.Equal(t, "GitHub API", r.Source())
+	assert.Contains(t, log.Text(), "Commit lookup via Repository unsuccessful (unable to find commit with SHA `1f192ff735f887dd7a25229b2ece0422d17931f5`")
+
+	// The commit is missing from both
+	_, err = r.Lookup(context.Background(), "0000000000000000000000000000000000000000")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to find commit with SHA `0000000000000000000000000000000000000000`: ")
+		assert.Contains(t, err.Error(), "via the GitHub API: 404 Not Found")
+	}
+}
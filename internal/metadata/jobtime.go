@@ -0,0 +1,78 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// A jobTimesProvider is a providerMetadata that knows when the CI job running
+// the reporter was enqueued and when it started. Each method returns the zero
+// time if the provider doesn't report it.
+type jobTimesProvider interface {
+	JobQueuedAt() time.Time
+	JobStartedAt() time.Time
+}
+
+// initJobTimes records when the CI job was enqueued and started, so that the
+// pipeline's latency can be measured alongside the test results. The times
+// reported by the provider can be given (or overridden) with the
+// BUILDPULSE_JOB_QUEUED_AT and BUILDPULSE_JOB_STARTED_AT environment variables
+// (e.g., for providers that only expose them via their API). Malformed values
+// are ignored with a warning.
+func (m *Metadata) initJobTimes(envs map[string]string) {
+	if p, ok := m.providerData.(jobTimesProvider); ok {
+		m.JobQueuedAt = p.JobQueuedAt()
+		m.JobStartedAt = p.JobStartedAt()
+	}
+
+	for _, v := range []struct {
+		key string
+		dst *time.Time
+	}{
+		{"BUILDPULSE_JOB_QUEUED_AT", &m.JobQueuedAt},
+		{"BUILDPULSE_JOB_STARTED_AT", &m.JobStartedAt},
+	} {
+		value := envs[v.key]
+		if value == "" {
+			continue
+		}
+
+		t, err := parseJobTime(value)
+		if err != nil {
+			m.logger.Printf("⚠️ Ignoring $%s: %v", v.key, err)
+			continue
+		}
+		*v.dst = t
+	}
+
+	if !m.JobQueuedAt.IsZero() && !m.JobStartedAt.IsZero() {
+		m.logger.Printf("CI job waited %s in the queue", m.JobStartedAt.Sub(m.JobQueuedAt))
+	}
+}
+
+// parseJobTime parses a time given as an RFC 3339 timestamp (e.g.,
+// 2024-01-02T03:04:05Z) or as seconds since the epoch (e.g., 1704164645).
+func parseJobTime(value string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid value \"%s\": should be an RFC 3339 timestamp or seconds since the epoch", value)
+	}
+
+	return t.UTC(), nil
+}
+
+// unixTime returns the time given by s in seconds since the epoch, or the zero
+// time if s is empty or malformed.
+func unixTime(s string) time.Time {
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(secs, 0).UTC()
+}
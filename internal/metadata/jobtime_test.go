@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetadata_jobTimes(t *testing.T) {
+	woodpecker := map[string]string{
+		"CI":                  "woodpecker",
+		"CI_COMMIT_SHA":       "1f192ff735f887dd7a25229b2ece0422d17931f5",
+		"CI_PIPELINE_URL":     "https://ci.example.com/repos/7/pipeline/42",
+		"CI_REPO":             "some-owner/some-repo",
+		"CI_PIPELINE_CREATED": "1704164600",
+		"CI_PIPELINE_STARTED": "1704164630",
+		"CI_STEP_STARTED":     "1704164645",
+	}
+
+	tests := []struct {
+		name        string
+		envs        map[string]string
+		overrides   map[string]string
+		wantQueued  time.Time
+		wantStarted time.Time
+		logMsg      string
+	}{
+		{
+			name:        "provider",
+			envs:        woodpecker,
+			wantQueued:  time.Date(2024, 1, 2, 3, 3, 20, 0, time.UTC),
+			wantStarted: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			logMsg:      "CI job waited 45s in the queue",
+		},
+		{
+			name: "overrides",
+			envs: woodpecker,
+			overrides: map[string]string{
+				"BUILDPULSE_JOB_QUEUED_AT":  "2024-01-02T04:00:00+01:00",
+				"BUILDPULSE_JOB_STARTED_AT": "1704164700",
+			},
+			wantQueued:  time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC),
+			wantStarted: time.Date(2024, 1, 2, 3, 5, 0, 0, time.UTC),
+		},
+		{
+			name: "malformed override",
+			envs: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "1f192ff735f887dd7a25229b2ece0422d17931f5"},
+			overrides: map[string]string{
+				"BUILDPULSE_JOB_STARTED_AT": "yesterday",
+			},
+			logMsg: `⚠️ Ignoring $BUILDPULSE_JOB_STARTED_AT: invalid value "yesterday": should be an RFC 3339 timestamp or seconds since the epoch`,
+		},
+		{
+			name: "not reported",
+			envs: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "1f192ff735f887dd7a25229b2ece0422d17931f5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envs := make(map[string]string)
+			for k, v := range tt.envs {
+				envs[k] = v
+			}
+			for k, v := range tt.overrides {
+				envs[k] = v
+			}
+
+			log := logger.New()
+			meta, err := NewMetadata(context.Background(), &Version{}, envs, nil, "", newCommitResolverStub(), time.Now, log)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantQueued, meta.JobQueuedAt)
+			assert.Equal(t, tt.wantStarted, meta.JobStartedAt)
+			if tt.logMsg != "" {
+				assert.Contains(t, log.Text(), tt.logMsg)
+			}
+
+			yaml, err := meta.MarshalYAML()
+			require.NoError(t, err)
+			if tt.wantStarted.IsZero() {
+				assert.NotContains(t, string(yaml), ":job_started_at")
+			} else {
+				assert.Contains(t, string(yaml), ":job_started_at: "+tt.wantStarted.Format(time.RFC3339)+"\n")
+			}
+		})
+	}
+}
+
+func Test_awsCodeBuildMetadata_jobTimes(t *testing.T) {
+	meta := awsCodeBuildMetadata{}
+	err := meta.Init(map[string]string{"CODEBUILD_START_TIME": "1594429323000"}, logger.New())
+	require.NoError(t, err)
+	assert.True(t, meta.JobQueuedAt().IsZero())
+	assert.Equal(t, time.Date(2020, 7, 11, 1, 2, 3, 0, time.UTC), meta.JobStartedAt())
+}
@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+)
+
+// mercurialMarker precedes the fields in the output of mercurialTemplate.
+const mercurialMarker = "buildpulse-changeset"
+
+// mercurialNullID is the ID Mercurial reports for a missing parent.
+const mercurialNullID = "0000000000000000000000000000000000000000"
+
+// mercurialTemplate is the `hg log` template for the fields of a Commit, one
+// per line, with the description last since it may span several lines. The
+// fields follow a marker line, since --debug may add messages to the output.
+// Mercurial doesn't distinguish the author of a changeset from its committer.
+const mercurialTemplate = mercurialMarker + "\n{node}\n{p1node}\n{p2node}\n{manifest}\n{date|rfc3339date}\n{author|person}\n{author|email}\n{desc}"
+
+type mercurialCommitResolver struct {
+	logger logger.Logger
+	root   string
+
+	// run runs the hg CLI with the given args and returns its output
+	run func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// NewMercurialCommitResolver returns a CommitResolver for looking up
+// changesets in the Mercurial repository containing path, using the hg CLI.
+// The changeset's manifest ID stands in for the tree SHA.
+func NewMercurialCommitResolver(path string, logger logger.Logger) (CommitResolver, error) {
+	root, err := findMercurialRoot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := exec.LookPath("hg"); err != nil {
+		return nil, fmt.Errorf("found Mercurial repository at %s, but the hg CLI isn't installed: %v", root, err)
+	}
+
+	run := func(ctx context.Context, args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, "hg", append([]string{"--repository", root}, args...)...)
+		cmd.Env = append(os.Environ(), "HGPLAIN=1") // ignore the user's hgrc
+		out, err := cmd.Output()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return out, err
+	}
+
+	return &mercurialCommitResolver{logger: logger, root: root, run: run}, nil
+}
+
+// HasMercurialRepository reports whether path is in a Mercurial repository.
+func HasMercurialRepository(path string) bool {
+	_, err := findMercurialRoot(path)
+	return err == nil
+}
+
+// findMercurialRoot returns the root of the Mercurial repository containing
+// path (i.e., the nearest directory with a .hg directory).
+func findMercurialRoot(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".hg")); err == nil && info.IsDir() {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no Mercurial repository found at %s", path)
+		}
+		dir = parent
+	}
+}
+
+// Lookup returns the changeset with the given ID, or the working directory's
+// parent changeset if id is empty.
+func (m *mercurialCommitResolver) Lookup(ctx context.Context, id string) (*Commit, error) {
+	rev := id
+	if rev == "" {
+		rev = "."
+	}
+
+	// --debug makes the manifest ID full-length
+	out, err := m.run(ctx, "log", "--debug", "--rev", rev, "--limit", "1", "--template", mercurialTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up changeset %s: %v", rev, err)
+	}
+
+	return parseMercurialLog(string(out))
+}
+
+func (m *mercurialCommitResolver) Source() string {
+	return "Mercurial"
+}
+
+// parseMercurialLog parses the output of `hg log` with mercurialTemplate.
+func parseMercurialLog(out string) (*Commit, error) {
+	_, out, ok := strings.Cut(out, mercurialMarker+"\n")
+	fields := strings.SplitN(out, "\n", 8)
+	if !ok || len(fields) < 8 {
+		return nil, fmt.Errorf("unexpected output from hg log: %q", out)
+	}
+
+	date, err := time.Parse(time.RFC3339, fields[4])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse changeset date: %v", err)
+	}
+
+	var parents []string
+	for _, p := range fields[1:3] {
+		if p != "" && p != mercurialNullID {
+			parents = append(parents, p)
+		}
+	}
+
+	// The manifest is given as rev:id
+	manifest := fields[3]
+	if i := strings.LastIndex(manifest, ":"); i >= 0 {
+		manifest = manifest[i+1:]
+	}
+
+	return &Commit{
+		AuthoredAt:     date,
+		AuthorEmail:    fields[6],
+		AuthorName:     fields[5],
+		CommittedAt:    date,
+		CommitterEmail: fields[6],
+		CommitterName:  fields[5],
+		Message:        fields[7],
+		ParentSHAs:     parents,
+		SHA:            fields[0],
+		TreeSHA:        manifest,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mercurialCommitResolver_Lookup(t *testing.T) {
+	var gotArgs []string
+	r := &mercurialCommitResolver{
+		logger: logger.New(),
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			gotArgs = args
+			return []byte("updating the branch cache\n" +
+				"buildpulse-changeset\n" +
+				"1f192ff735f887dd7a25229b2ece0422d17931f5\n" +
+				"72bb4b60997efd3c179ccfc0c963ab84b35f992e\n" +
+				"0000000000000000000000000000000000000000\n" +
+				"3:0da9df599c02da5e7f5058b7108dcd5e1929a0fe\n" +
+				"2020-07-09T09:05:06-05:00\n" +
+				"Some Author\n" +
+				"author@example.com\n" +
+				"Fix the thing\n\nWith more detail"), nil
+		},
+	}
+
+	c, err := r.Lookup(context.Background(), "1f192ff735f887dd7a25229b2ece0422d17931f5")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"log", "--debug", "--rev", "1f192ff735f887dd7a25229b2ece0422d17931f5", "--limit", "1", "--template", mercurialTemplate}, gotArgs)
+
+	date := time.Date(2020, 7, 9, 9, 5, 6, 0, time.FixedZone("", -5*60*60))
+	assert.Equal(t, &Commit{
+		AuthoredAt:     date,
+		AuthorEmail:    "author@example.com",
+		AuthorName:     "Some Author",
+		CommittedAt:    date,
+		CommitterEmail: "author@example.com",
+		CommitterName:  "Some Author",
+		Message:        "Fix the thing\n\nWith more detail",
+		ParentSHAs:     []string{"72bb4b60997efd3c179ccfc0c963ab84b35f992e"},
+		SHA:            "1f192ff735f887dd7a25229b2ece0422d17931f5",
+		TreeSHA:        "0da9df599c02da5e7f5058b7108dcd5e1929a0fe",
+	}, c)
+	assert.Equal(t, "Mercurial", r.Source())
+}
+
+func Test_mercurialCommitResolver_Lookup_workingDirectoryParent(t *testing.T) {
+	var gotRev string
+	r := &mercurialCommitResolver{
+		logger: logger.New(),
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			gotRev = args[3]
+			return []byte("buildpulse-changeset\n1f192ff735f887dd7a25229b2ece0422d17931f5\n\n\n0:0da9df599c02da5e7f5058b7108dcd5e1929a0fe\n2020-07-09T14:05:06Z\nSome Author\nauthor@example.com\nInitial"), nil
+		},
+	}
+
+	c, err := r.Lookup(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, ".", gotRev)
+	assert.Empty(t, c.ParentSHAs)
+}
+
+func Test_mercurialCommitResolver_Lookup_unexpectedOutput(t *testing.T) {
+	r := &mercurialCommitResolver{
+		logger: logger.New(),
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			return []byte("1f192ff735f887dd7a25229b2ece0422d17931f5\n"), nil
+		},
+	}
+
+	_, err := r.Lookup(context.Background(), "")
+	assert.ErrorContains(t, err, "unexpected output from hg log")
+}
+
+func TestHasMercurialRepository(t *testing.T) {
+	dir := t.TempDir()
+	assert.False(t, HasMercurialRepository(dir))
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".hg"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "some", "subdir"), 0755))
+	assert.True(t, HasMercurialRepository(dir))
+	assert.True(t, HasMercurialRepository(filepath.Join(dir, "some", "subdir")))
+}
+
+func TestNewMercurialCommitResolver(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg not found in PATH")
+	}
+
+	dir := t.TempDir()
+	hg := func(args ...string) {
+		cmd := exec.Command("hg", append([]string{"--repository", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "HGPLAIN=1", "HGUSER=Some Author <author@example.com>")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	hg("init")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("hello\n"), 0644))
+	hg("commit", "--addremove", "--message", "Initial", "--date", "2020-07-09 09:05:06 -0500")
+
+	r, err := NewMercurialCommitResolver(dir, logger.New())
+	require.NoError(t, err)
+
+	c, err := r.Lookup(context.Background(), "")
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{40}$`, c.SHA)
+	assert.Regexp(t, `^[0-9a-f]{40}$`, c.TreeSHA)
+	assert.Equal(t, "Some Author", c.AuthorName)
+	assert.Equal(t, "author@example.com", c.AuthorEmail)
+	assert.Equal(t, "Initial", c.Message)
+	assert.True(t, c.AuthoredAt.Equal(time.Date(2020, 7, 9, 14, 5, 6, 0, time.UTC)))
+	assert.Empty(t, c.ParentSHAs)
+}
+
+func TestNewMercurialCommitResolver_noRepository(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewMercurialCommitResolver(dir, logger.New())
+	assert.EqualError(t, err, "no Mercurial repository found at "+dir)
+}
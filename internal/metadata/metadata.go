@@ -1,6 +1,9 @@
 package metadata
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,49 +15,124 @@ import (
 // identifies the CI provider, the commit SHA, the time at which the tests were
 // executed, etc.
 type Metadata struct {
-	AuthoredAt           time.Time `yaml:":authored_at,omitempty"`
-	AuthorEmail          string    `yaml:":author_email,omitempty"`
-	AuthorName           string    `yaml:":author_name,omitempty"`
-	Branch               string    `yaml:":branch"`
-	BuildURL             string    `yaml:":build_url"`
-	Check                string    `yaml:":check"`
-	CIProvider           string    `yaml:":ci_provider"`
-	CommitMessage        string    `yaml:":commit_message,omitempty"`
-	CommitMetadataSource string    `yaml:":commit_metadata_source"`
-	CommitSHA            string    `yaml:":commit"`
-	CommittedAt          time.Time `yaml:":committed_at,omitempty"`
-	CommitterEmail       string    `yaml:":committer_email,omitempty"`
-	CommitterName        string    `yaml:":committer_name,omitempty"`
-	QuotaID              string    `yaml:":quota_id,omitempty"`
-	RepoNameWithOwner    string    `yaml:":repo_name_with_owner"`
-	ReporterOS           string    `yaml:":reporter_os"`
-	ReporterVersion      string    `yaml:":reporter_version"`
-	Tags                 []string  `yaml:":tags,omitempty"`
-	Timestamp            time.Time `yaml:":timestamp"`
-	TreeSHA              string    `yaml:":tree,omitempty"`
+	AuthoredAt            time.Time          `yaml:":authored_at,omitempty"`
+	AuthoredAtZone        string             `yaml:":authored_at_zone,omitempty"`
+	AuthorEmail           string             `yaml:":author_email,omitempty"`
+	AuthorName            string             `yaml:":author_name,omitempty"`
+	Backfill              bool               `yaml:":backfill,omitempty"`
+	BaseBranch            string             `yaml:":base_branch,omitempty"`
+	Branch                string             `yaml:":branch"`
+	BranchSource          string             `yaml:":branch_source,omitempty"` // "inferred" if not reported by the CI provider
+	BuildURL              string             `yaml:":build_url"`
+	Check                 string             `yaml:":check"`
+	CIProvider            string             `yaml:":ci_provider"`
+	CommitMessage         string             `yaml:":commit_message,omitempty"`
+	CommitParentSHAs      []string           `yaml:":commit_parents,omitempty"`
+	CommitMetadataSource  string             `yaml:":commit_metadata_source"`
+	CommitSHA             string             `yaml:":commit"`
+	CommittedAt           time.Time          `yaml:":committed_at,omitempty"`
+	CommittedAtZone       string             `yaml:":committed_at_zone,omitempty"`
+	CommitterEmail        string             `yaml:":committer_email,omitempty"`
+	CommitterName         string             `yaml:":committer_name,omitempty"`
+	CoverageBaseline      string             `yaml:":coverage_baseline,omitempty"`
+	CoverageDeltas        map[string]string  `yaml:":coverage_deltas,omitempty"` // coverage file, keyed by path of its delta in the tarball
+	CustomMetadata        map[string]string  `yaml:":custom_metadata,omitempty"`
+	Dimensions            map[string]string  `yaml:":dimensions,omitempty"`
+	HarnessVersions       map[string]string  `yaml:":harness_versions,omitempty"`
+	JobQueuedAt           time.Time          `yaml:":job_queued_at,omitempty"`
+	JobStartedAt          time.Time          `yaml:":job_started_at,omitempty"`
+	KeyScheme             string             `yaml:":key_scheme,omitempty"`
+	MergeBase             string             `yaml:":merge_base,omitempty"`
+	MergeCommit           bool               `yaml:":merge_commit,omitempty"`
+	Project               string             `yaml:":project,omitempty"`
+	QuotaID               string             `yaml:":quota_id,omitempty"`
+	RepoNameWithOwner     string             `yaml:":repo_name_with_owner"`
+	ReportAttempts        map[string]int     `yaml:":report_attempts,omitempty"`
+	ReportFormats         map[string]string  `yaml:":report_formats,omitempty"`
+	ReporterArch          string             `yaml:":reporter_arch,omitempty"`
+	ReporterOS            string             `yaml:":reporter_os"`
+	ReporterVersion       string             `yaml:":reporter_version"`
+	RetryPlugin           string             `yaml:":retry_plugin,omitempty"`
+	RetryPluginMaxRetries int                `yaml:":retry_plugin_max_retries,omitempty"`
+	Runner                Runner             `yaml:",inline"`
+	SchemaVersion         int                `yaml:":schema_version"`
+	ShardIndex            *uint              `yaml:":shard_index,omitempty"` // nil unless given; 0 is the first shard
+	ShardTimes            map[string]float64 `yaml:":shard_times,omitempty"`
+	ShardTotal            *uint              `yaml:":shard_total,omitempty"`
+	StaleReports          map[string]string  `yaml:":stale_reports,omitempty"` // reason, keyed by path in the tarball
+	Submodules            map[string]string  `yaml:":submodules,omitempty"`    // checked-out SHA, keyed by submodule path
+	Tags                  []string           `yaml:":tags,omitempty"`
+	TestCommandExitStatus *int               `yaml:":test_command_exit_status,omitempty"` // nil unless run by `exec`; 0 is success
+	TestCommandSeconds    float64            `yaml:":test_command_seconds,omitempty"`
+	TestRetryExitStatuses []int              `yaml:":test_retry_exit_statuses,omitempty"` // of each retry of the failed tests by `exec`, in order
+	TestRetryReports      map[string]int     `yaml:":test_retry_reports,omitempty"`       // retry number (from 1), keyed by path in the tarball
+	Timestamp             time.Time          `yaml:":timestamp"`
+	TimestampZone         string             `yaml:":timestamp_zone"`
+	TraceParent           string             `yaml:":traceparent,omitempty"`
+	TraceState            string             `yaml:":tracestate,omitempty"`
+	TreeSHA               string             `yaml:":tree,omitempty"`
 
+	commitErr    error // from looking up the commit, if it failed
+	envFilter    *envFilter
+	envs         map[string]string
 	logger       logger.Logger
 	providerData providerMetadata
 }
 
-// NewMetadata creates a new Metadata instance from the given args.
-func NewMetadata(version *Version, envs map[string]string, tags []string, quotaID string, resolver CommitResolver, now func() time.Time, logger logger.Logger) (*Metadata, error) {
-	m := &Metadata{logger: logger}
+// The ways of naming the check when BUILDPULSE_CHECK_NAME isn't set, as given by
+// the BUILDPULSE_CHECK_NAME_SCHEME environment variable.
+const (
+	// checkNameSchemeProvider names the check after the CI provider (e.g.,
+	// "github-actions").
+	checkNameSchemeProvider = "provider"
+
+	// checkNameSchemeJob names the check after the CI provider and the job
+	// (e.g., "github-actions/ci/test"), so that the same tests run by
+	// different jobs (such as the callers of a reusable workflow) are kept
+	// apart.
+	checkNameSchemeJob = "job"
+)
+
+var checkNameSchemes = []string{checkNameSchemeProvider, checkNameSchemeJob}
+
+// NewMetadata creates a new Metadata instance from the given args. It stops
+// looking up the commit, returning an error, if ctx is done.
+func NewMetadata(ctx context.Context, version *Version, envs map[string]string, tags []string, quotaID string, resolver CommitResolver, now func() time.Time, logger logger.Logger) (*Metadata, error) {
+	m := &Metadata{SchemaVersion: SchemaVersion, envs: envs, logger: logger}
+
+	ef, err := newEnvFilter(envs)
+	if err != nil {
+		return nil, err
+	}
+	m.envFilter = ef
 
 	if err := m.initProviderData(envs); err != nil {
 		return nil, err
 	}
 
-	if err := m.initCommitData(resolver, m.providerData.CommitSHA()); err != nil {
+	sha := m.providerData.CommitSHA()
+	if override := envs["BUILDPULSE_COMMIT_SHA"]; override != "" {
+		m.logger.Printf("Using $BUILDPULSE_COMMIT_SHA environment variable as commit SHA: %s", override)
+		sha = override
+	}
+	if err := m.initCommitData(ctx, resolver, sha); err != nil {
 		return nil, err
 	}
 
 	m.initTimestamp(now)
+	m.initJobTimes(envs)
+	m.initTraceContext(envs)
 	m.initVersionData(version)
 
 	m.QuotaID = quotaID
 	m.Tags = tags
 
+	dims, err := Dimensions(tags)
+	if err != nil {
+		return nil, err
+	}
+	m.Dimensions = dims
+
 	return m, nil
 }
 
@@ -66,24 +144,47 @@ func (m *Metadata) initProviderData(envs map[string]string) error {
 	m.providerData = pm
 
 	m.Branch = pm.Branch()
+	if branch := envs["BUILDPULSE_BRANCH"]; branch != "" {
+		m.logger.Printf("Using $BUILDPULSE_BRANCH environment variable as branch: %s", branch)
+		m.Branch = branch
+	}
 	m.BuildURL = pm.BuildURL()
 	m.CIProvider = pm.Name()
 	m.RepoNameWithOwner = pm.RepoNameWithOwner()
+	if nwo := envs["BUILDPULSE_REPO_NAME_WITH_OWNER"]; nwo != "" {
+		m.logger.Printf("Using $BUILDPULSE_REPO_NAME_WITH_OWNER environment variable as repository name-with-owner: %s", nwo)
+		m.RepoNameWithOwner = nwo
+	}
 
 	check, ok := envs["BUILDPULSE_CHECK_NAME"]
 	if ok && check != "" {
 		m.Check = check
-	} else {
+		return nil
+	}
+
+	switch scheme := envs["BUILDPULSE_CHECK_NAME_SCHEME"]; scheme {
+	case "", checkNameSchemeProvider:
 		m.Check = pm.Name()
+	case checkNameSchemeJob:
+		m.Check = pm.Name()
+		if p, ok := pm.(jobCheckProvider); ok && p.JobCheck() != "" {
+			m.Check = pm.Name() + "/" + p.JobCheck()
+		} else {
+			m.logger.Printf("⚠️ Unable to name the check after the CI job on %s; using the provider name as the check name", pm.Name())
+		}
+	default:
+		return fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_CHECK_NAME_SCHEME: supported values are: %s", scheme, strings.Join(checkNameSchemes, ", "))
 	}
 
 	return nil
 }
 
-func (m *Metadata) initCommitData(cr CommitResolver, sha string) error {
-	m.CommitMetadataSource = cr.Source()
+func (m *Metadata) initCommitData(ctx context.Context, cr CommitResolver, sha string) error {
+	c, err := cr.Lookup(ctx, sha)
 
-	c, err := cr.Lookup(sha)
+	// Record the source after the lookup, since a resolver that falls back to
+	// another one reports the source that produced the commit
+	m.CommitMetadataSource = cr.Source()
 	if err != nil {
 		m.logger.Printf("❌")
 		m.logger.Printf("❌ Commit lookup unsuccessful: %v", err)
@@ -94,32 +195,77 @@ func (m *Metadata) initCommitData(cr CommitResolver, sha string) error {
 		m.logger.Printf("❌")
 
 		m.CommitSHA = sha
+		m.commitErr = err
 
 		return nil
 	}
 
-	m.AuthoredAt = c.AuthoredAt
+	m.AuthoredAt, m.AuthoredAtZone = utcAndZone(c.AuthoredAt)
 	m.AuthorEmail = c.AuthorEmail
 	m.AuthorName = c.AuthorName
 	m.CommitMessage = strings.TrimSpace(c.Message)
+	m.CommitParentSHAs = c.ParentSHAs
 	m.CommitSHA = c.SHA
-	m.CommittedAt = c.CommittedAt
+	m.CommittedAt, m.CommittedAtZone = utcAndZone(c.CommittedAt)
 	m.CommitterEmail = c.CommitterEmail
 	m.CommitterName = c.CommitterName
 	m.TreeSHA = c.TreeSHA
 
+	// Some CI providers check out a detached HEAD without reporting the branch
+	if m.Branch == "" {
+		m.initInferredBranch(cr)
+	}
+
+	// CI providers often build pull requests from a synthetic merge of the
+	// pull request's head commit into its base branch, in which case the
+	// results belong to the head commit (usually the second parent)
+	if len(c.ParentSHAs) > 1 {
+		m.MergeCommit = true
+		m.logger.Printf("Commit %s is a merge commit of %s", c.SHA, strings.Join(c.ParentSHAs, ", "))
+	}
+
 	return nil
 }
 
 func (m *Metadata) initTimestamp(now func() time.Time) {
-	m.Timestamp = now()
+	m.Timestamp, m.TimestampZone = utcAndZone(now())
+}
+
+// utcAndZone returns t in UTC, so that the times recorded on different runners
+// (and by different CI providers) compare cleanly, along with the UTC offset of
+// t's original zone (e.g., "-05:00"). The offset is empty if t is the zero
+// time.
+func utcAndZone(t time.Time) (time.Time, string) {
+	if t.IsZero() {
+		return t, ""
+	}
+
+	return t.UTC(), t.Format("-07:00")
 }
 
 func (m *Metadata) initVersionData(version *Version) {
+	m.ReporterArch = version.GoArch
 	m.ReporterOS = version.GoOS
 	m.ReporterVersion = version.Number
 }
 
+// BuildStartedAt returns the time the build started, as reported by the CI
+// provider, or the zero time if the provider doesn't report it.
+func (m *Metadata) BuildStartedAt() time.Time {
+	if p, ok := m.providerData.(buildStartProvider); ok {
+		return p.BuildStartedAt()
+	}
+
+	return time.Time{}
+}
+
+// CommitLookupError returns the error from looking up the build's commit, or
+// nil if the lookup succeeded. A failed lookup isn't fatal to NewMetadata,
+// which records the commit SHA without the rest of the commit's metadata.
+func (m *Metadata) CommitLookupError() error {
+	return m.commitErr
+}
+
 // MarshalYAML serializes the metadata into a YAML document.
 func (m *Metadata) MarshalYAML() (out []byte, err error) {
 	universalFields, err := yaml.Marshal(m)
@@ -132,5 +278,40 @@ func (m *Metadata) MarshalYAML() (out []byte, err error) {
 		return nil, err
 	}
 
+	if m.envFilter != nil && !m.envFilter.empty() {
+		var excluded []string
+		providerSpecificFields, excluded, err = m.envFilter.filter(providerSpecificFields, fieldSources(m.providerData))
+		if err != nil {
+			return nil, err
+		}
+		if len(excluded) > 0 {
+			m.logger.Printf("Excluded environment variables from provider metadata: %s", strings.Join(excluded, ", "))
+		}
+	}
+
+	// Some providers expose credentials to the job (e.g., Bitbucket's OIDC
+	// token), which mustn't leave the runner
+	providerSpecificFields, redactedFields, err := redact(providerSpecificFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(redactedFields) > 0 {
+		m.logger.Printf("Redacted secrets from provider metadata fields: %s", strings.Join(redactedFields, ", "))
+	}
+	if bytes.Equal(providerSpecificFields, []byte("{}\n")) {
+		return universalFields, nil
+	}
+
 	return append(universalFields, providerSpecificFields...), nil
 }
+
+// ProviderBaseBranch returns the branch that the build's pull request targets,
+// as reported by the CI provider, or an empty string if the provider doesn't
+// report it (or the build isn't for a pull request).
+func (m *Metadata) ProviderBaseBranch() string {
+	if p, ok := m.providerData.(baseBranchProvider); ok {
+		return p.BaseBranch()
+	}
+
+	return ""
+}
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -193,7 +194,7 @@ func TestNewMetadata(t *testing.T) {
 			)
 
 			version := &Version{Number: "v1.2.3", GoOS: "linux"}
-			meta, err := NewMetadata(version, tt.envs, tt.tags, "", commitResolver, now, logger.New())
+			meta, err := NewMetadata(context.Background(), version, tt.envs, tt.tags, "", commitResolver, now, logger.New())
 			assert.NoError(t, err)
 
 			yaml, err := meta.MarshalYAML()
@@ -204,7 +205,7 @@ func TestNewMetadata(t *testing.T) {
 }
 
 func TestNewMetadata_unsupportedProvider(t *testing.T) {
-	_, err := NewMetadata(&Version{}, map[string]string{}, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+	_, err := NewMetadata(context.Background(), &Version{}, map[string]string{}, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
 	if assert.Error(t, err) {
 		assert.Contains(t, err.Error(), "env: environment variable \"GIT_BRANCH\" should not be empty; environment variable \"GIT_COMMIT\" should not be empty; environment variable \"BUILD_URL\" should not be empty; environment variable \"ORGANIZATION_NAME\" should not be empty; environment variable \"REPOSITORY_NAME\" should not be empty")
 	}
@@ -239,10 +240,39 @@ func TestNewMetadata_customCheckName(t *testing.T) {
 			},
 			expectedCheck: "github-actions",
 		},
+		{
+			name: "with job check name scheme",
+			envs: map[string]string{
+				"BUILDPULSE_CHECK_NAME_SCHEME": "job",
+				"GITHUB_ACTIONS":               "true",
+				"GITHUB_JOB":                   "unit-tests",
+				"GITHUB_WORKFLOW_REF":          "some-owner/some-repo/.github/workflows/ci.yml@refs/heads/main",
+			},
+			expectedCheck: "github-actions/ci/unit-tests",
+		},
+		{
+			name: "with job check name scheme but no job",
+			envs: map[string]string{
+				"BUILDPULSE_CHECK_NAME_SCHEME": "job",
+				"GITHUB_ACTIONS":               "true",
+			},
+			expectedCheck: "github-actions",
+		},
+		{
+			name: "with custom check name and job check name scheme",
+			envs: map[string]string{
+				"BUILDPULSE_CHECK_NAME":        "some-custom-check-name",
+				"BUILDPULSE_CHECK_NAME_SCHEME": "job",
+				"GITHUB_ACTIONS":               "true",
+				"GITHUB_JOB":                   "unit-tests",
+				"GITHUB_WORKFLOW_REF":          "some-owner/some-repo/.github/workflows/ci.yml@refs/heads/main",
+			},
+			expectedCheck: "some-custom-check-name",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			meta, err := NewMetadata(&Version{}, tt.envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+			meta, err := NewMetadata(context.Background(), &Version{}, tt.envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
 			assert.NoError(t, err)
 
 			yaml, err := meta.MarshalYAML()
@@ -252,6 +282,63 @@ func TestNewMetadata_customCheckName(t *testing.T) {
 	}
 }
 
+func TestNewMetadata_unsupportedCheckNameScheme(t *testing.T) {
+	envs := map[string]string{
+		"BUILDPULSE_CHECK_NAME_SCHEME": "bogus",
+		"GITHUB_ACTIONS":               "true",
+	}
+	_, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+	assert.EqualError(t, err, `invalid value "bogus" for environment variable BUILDPULSE_CHECK_NAME_SCHEME: supported values are: provider, job`)
+}
+
+func TestNewMetadata_repoNameWithOwnerOverride(t *testing.T) {
+	tests := []struct {
+		name string
+		envs map[string]string
+	}{
+		{
+			name: "derived from environment",
+			envs: map[string]string{
+				"BUILDPULSE_REPO_NAME_WITH_OWNER": "some-owner/some-mirrored-repo",
+				"GITHUB_ACTIONS":                  "true",
+				"GITHUB_REPOSITORY":               "some-owner/some-repo",
+			},
+		},
+		{
+			name: "not derivable from environment",
+			envs: map[string]string{
+				"BUILD_URL":                       "https://jenkins.example.com/job/some-job/42/",
+				"BUILDPULSE_REPO_NAME_WITH_OWNER": "some-owner/some-mirrored-repo",
+				"GIT_URL":                         "/srv/git/some-repo.git",
+				"JENKINS_HOME":                    "/var/lib/jenkins",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := NewMetadata(context.Background(), &Version{}, tt.envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+			if assert.NoError(t, err) {
+				assert.Equal(t, "some-owner/some-mirrored-repo", meta.RepoNameWithOwner)
+			}
+		})
+	}
+}
+
+func TestNewMetadata_commitAndBranchOverride(t *testing.T) {
+	envs := map[string]string{
+		"BUILDPULSE_BRANCH":     "release/1.2",
+		"BUILDPULSE_COMMIT_SHA": "cccccccccccccccccccccccccccccccccccccccc",
+		"GITHUB_ACTIONS":        "true",
+		"GITHUB_REF":            "refs/heads/main",
+		"GITHUB_SHA":            "aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb",
+	}
+	meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "cccccccccccccccccccccccccccccccccccccccc", meta.CommitSHA)
+		assert.Equal(t, "release/1.2", meta.Branch)
+	}
+}
+
 func newCommitResolverStub() CommitResolver {
 	return NewStaticCommitResolver(&Commit{}, logger.New())
 }
@@ -283,6 +370,26 @@ func TestNewMetadata_appliesTags(t *testing.T) {
 			fixture: "./testdata/github_tags.yml",
 			tags:    []string{"tag1", "tag2"},
 		},
+		{
+			name: "GitHubActionsWithDimensions",
+			envs: map[string]string{
+				"GITHUB_ACTIONS":     "true",
+				"GITHUB_ACTOR":       "some-user",
+				"GITHUB_BASE_REF":    "refs/heads/main",
+				"GITHUB_EVENT_NAME":  "push",
+				"GITHUB_HEAD_REF":    "refs/heads/some-feature",
+				"GITHUB_REF":         "refs/heads/some-feature",
+				"GITHUB_REPOSITORY":  "some-owner/some-repo",
+				"GITHUB_RUN_ATTEMPT": "1",
+				"GITHUB_RUN_ID":      "8675309",
+				"GITHUB_RUN_NUMBER":  "42",
+				"GITHUB_SERVER_URL":  "https://github.com",
+				"GITHUB_SHA":         "1f192ff735f887dd7a25229b2ece0422d17931f5",
+				"GITHUB_WORKFLOW":    "build",
+			},
+			fixture: "./testdata/github_dimensions.yml",
+			tags:    []string{"tag1", "OS:linux", "ruby:3.3"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -314,7 +421,7 @@ func TestNewMetadata_appliesTags(t *testing.T) {
 			)
 
 			version := &Version{Number: "v1.2.3", GoOS: "linux"}
-			meta, err := NewMetadata(version, tt.envs, tt.tags, "", commitResolver, now, logger.New())
+			meta, err := NewMetadata(context.Background(), version, tt.envs, tt.tags, "", commitResolver, now, logger.New())
 			assert.NoError(t, err)
 
 			yaml, err := meta.MarshalYAML()
@@ -382,7 +489,7 @@ func TestNewMetadata_appliesQuotaID(t *testing.T) {
 			)
 
 			version := &Version{Number: "v1.2.3", GoOS: "linux"}
-			meta, err := NewMetadata(version, tt.envs, []string{}, tt.quotaID, commitResolver, now, logger.New())
+			meta, err := NewMetadata(context.Background(), version, tt.envs, []string{}, tt.quotaID, commitResolver, now, logger.New())
 			assert.NoError(t, err)
 
 			yaml, err := meta.MarshalYAML()
@@ -391,3 +498,45 @@ func TestNewMetadata_appliesQuotaID(t *testing.T) {
 		})
 	}
 }
+
+func TestNewMetadata_mergeCommit(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "1f192ff735f887dd7a25229b2ece0422d17931f5",
+	}
+	commitResolver := NewStaticCommitResolver(
+		&Commit{
+			ParentSHAs: []string{"aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb", "ccccccccccccccccccccdddddddddddddddddddd"},
+			TreeSHA:    "0da9df599c02da5e7f5058b7108dcd5e1929a0fe",
+		},
+		logger.New(),
+	)
+
+	meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", commitResolver, time.Now, logger.New())
+	require.NoError(t, err)
+	assert.True(t, meta.MergeCommit)
+
+	yaml, err := meta.MarshalYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":commit_parents:\n    - aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbb\n    - ccccccccccccccccccccdddddddddddddddddddd\n")
+	assert.Contains(t, string(yaml), ":merge_commit: true\n")
+}
+
+func TestNewMetadata_reporterArch(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_SHA":     "1f192ff735f887dd7a25229b2ece0422d17931f5",
+	}
+
+	meta, err := NewMetadata(context.Background(), &Version{Number: "v1.2.3", GoOS: "linux", GoArch: "arm64"}, envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+	require.NoError(t, err)
+
+	yaml, err := meta.MarshalYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":reporter_arch: arm64\n:reporter_os: linux\n")
+}
+
+func TestVersion_String(t *testing.T) {
+	v := &Version{Number: "v1.2.3", GoOS: "linux", GoArch: "arm64", Commit: "abc1234", GoVersion: "go1.21.5"}
+	assert.Equal(t, "BuildPulse Test Reporter v1.2.3 (linux/arm64 abc1234 go1.21.5)\n", v.String())
+}
@@ -0,0 +1,160 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// A ProviderConfig defines a CI provider by mapping the environment variables
+// it sets to the metadata fields, for CI systems that aren't supported in
+// providers.go. It's read from the YAML (or JSON) file named by the
+// BUILDPULSE_PROVIDER_CONFIG environment variable. For example:
+//
+//	name: drone
+//	detect: DRONE
+//	commit: DRONE_COMMIT_SHA
+//	branch: DRONE_SOURCE_BRANCH
+//	build_url: DRONE_BUILD_LINK
+//	repo_name_with_owner: DRONE_REPO
+//	fields:
+//	  drone_build_number: DRONE_BUILD_NUMBER
+//	  drone_stage_name: DRONE_STAGE_NAME
+type ProviderConfig struct {
+	// Name identifies the provider (e.g., in the metadata's ci_provider field).
+	Name string `yaml:"name"`
+
+	// Detect names the environment variable that identifies the provider. If
+	// it's empty, the provider is used whenever the config is given; otherwise,
+	// it's used only if the variable is set.
+	Detect string `yaml:"detect"`
+
+	// Commit, Branch, BuildURL, and RepoNameWithOwner name the environment
+	// variables holding the corresponding metadata. Commit is required.
+	Commit            string `yaml:"commit"`
+	Branch            string `yaml:"branch"`
+	BuildURL          string `yaml:"build_url"`
+	RepoNameWithOwner string `yaml:"repo_name_with_owner"`
+
+	// Fields maps the names of additional provider-specific metadata fields to
+	// the environment variables holding their values.
+	Fields map[string]string `yaml:"fields"`
+}
+
+// ReadProviderConfig reads the provider config from the file at path.
+func ReadProviderConfig(path string) (*ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+
+	c := &ProviderConfig{}
+	if err := d.Decode(c); err != nil {
+		return nil, fmt.Errorf("invalid provider config %s: %v", path, err)
+	}
+
+	switch {
+	case c.Name == "":
+		return nil, fmt.Errorf("invalid provider config %s: missing name", path)
+	case forcedProviders[c.Name].new != nil:
+		return nil, fmt.Errorf("invalid provider config %s: name \"%s\" is already used by a built-in provider", path, c.Name)
+	case c.Commit == "":
+		return nil, fmt.Errorf("invalid provider config %s: missing commit", path)
+	}
+
+	return c, nil
+}
+
+var _ providerMetadata = (*configMetadata)(nil)
+
+// configMetadata supplies the metadata for a provider defined by a
+// ProviderConfig.
+type configMetadata struct {
+	config *ProviderConfig
+
+	branch            string
+	buildURL          string
+	commitSHA         string
+	repoNameWithOwner string
+	fields            map[string]string
+}
+
+func (c *configMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if envs[c.config.Commit] == "" {
+		return fmt.Errorf("missing required environment variable for provider %s: %s", c.config.Name, c.config.Commit)
+	}
+
+	c.branch = envs[c.config.Branch]
+	c.buildURL = envs[c.config.BuildURL]
+	c.commitSHA = envs[c.config.Commit]
+	c.repoNameWithOwner = envs[c.config.RepoNameWithOwner]
+
+	c.fields = make(map[string]string)
+	for field, key := range c.config.Fields {
+		if value := envs[key]; value != "" {
+			c.fields[field] = value
+		}
+	}
+
+	log.Printf("Using $%s environment variable as commit SHA: %s", c.config.Commit, c.commitSHA)
+
+	return nil
+}
+
+func (c *configMetadata) Branch() string {
+	return c.branch
+}
+
+func (c *configMetadata) BuildURL() string {
+	return c.buildURL
+}
+
+func (c *configMetadata) CommitSHA() string {
+	return c.commitSHA
+}
+
+func (c *configMetadata) Name() string {
+	return c.config.Name
+}
+
+func (c *configMetadata) RepoNameWithOwner() string {
+	return c.repoNameWithOwner
+}
+
+// FieldSources returns the environment variable that each provider-specific
+// field comes from, keyed by the field's name in the metadata.
+func (c *configMetadata) FieldSources() map[string]string {
+	sources := make(map[string]string)
+	for field, key := range c.config.Fields {
+		sources[":"+field] = key
+	}
+
+	return sources
+}
+
+// MarshalYAML serializes the provider-specific fields in the same manner as the
+// built-in providers (i.e., with names prefixed by a colon), in sorted order.
+func (c *configMetadata) MarshalYAML() (interface{}, error) {
+	var names []string
+	for name := range c.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range names {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: ":" + name},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.fields[name]},
+		)
+	}
+
+	return node, nil
+}
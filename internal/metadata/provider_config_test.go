@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exampleProviderConfig = `
+name: drone
+detect: DRONE
+commit: DRONE_COMMIT_SHA
+branch: DRONE_SOURCE_BRANCH
+build_url: DRONE_BUILD_LINK
+repo_name_with_owner: DRONE_REPO
+fields:
+  drone_build_number: DRONE_BUILD_NUMBER
+  drone_stage_name: DRONE_STAGE_NAME
+  drone_step_name: DRONE_STEP_NAME
+`
+
+func writeProviderConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "provider.yml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	return path
+}
+
+func Test_newProviderMetadata_providerConfig(t *testing.T) {
+	envs := map[string]string{
+		"BUILDPULSE_PROVIDER_CONFIG": writeProviderConfig(t, exampleProviderConfig),
+		"DRONE":                      "true",
+		"DRONE_BUILD_LINK":           "https://drone.example.com/some-owner/some-repo/42",
+		"DRONE_BUILD_NUMBER":         "42",
+		"DRONE_COMMIT_SHA":           "1f192ff735f887dd7a25229b2ece0422d17931f5",
+		"DRONE_REPO":                 "some-owner/some-repo",
+		"DRONE_SOURCE_BRANCH":        "some-branch",
+		"DRONE_STAGE_NAME":           "default",
+	}
+
+	m, err := NewMetadata(context.Background(), &Version{}, envs, nil, "", newCommitResolverStub(), time.Now, logger.New())
+	require.NoError(t, err)
+
+	assert.Equal(t, "some-branch", m.Branch)
+	assert.Equal(t, "https://drone.example.com/some-owner/some-repo/42", m.BuildURL)
+	assert.Equal(t, "drone", m.CIProvider)
+	assert.Equal(t, "some-owner/some-repo", m.RepoNameWithOwner)
+
+	yaml, err := m.MarshalYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), "\n:drone_build_number: \"42\"\n:drone_stage_name: default\n")
+	assert.NotContains(t, string(yaml), "drone_step_name")
+}
+
+func Test_newProviderMetadata_providerConfigNotDetected(t *testing.T) {
+	envs := map[string]string{
+		"BUILDPULSE_PROVIDER_CONFIG": writeProviderConfig(t, exampleProviderConfig),
+		"CIRCLECI":                   "true",
+		"CIRCLE_SHA1":                "1f192ff735f887dd7a25229b2ece0422d17931f5",
+	}
+
+	meta, err := newProviderMetadata(envs, logger.New())
+	require.NoError(t, err)
+	assert.Equal(t, "circleci", meta.Name())
+}
+
+func Test_newProviderMetadata_providerConfigWithoutFields(t *testing.T) {
+	envs := map[string]string{
+		"BUILDPULSE_PROVIDER_CONFIG": writeProviderConfig(t, "name: some-ci\ncommit: SOME_CI_COMMIT\n"),
+		"SOME_CI_COMMIT":             "1f192ff735f887dd7a25229b2ece0422d17931f5",
+	}
+
+	m, err := NewMetadata(context.Background(), &Version{}, envs, nil, "", newCommitResolverStub(), time.Now, logger.New())
+	require.NoError(t, err)
+	assert.Equal(t, "some-ci", m.CIProvider)
+
+	yaml, err := m.MarshalYAML()
+	require.NoError(t, err)
+	assert.NotContains(t, string(yaml), "{}")
+}
+
+func TestReadProviderConfig_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{
+			name:    "MissingName",
+			content: "commit: SOME_CI_COMMIT\n",
+			errMsg:  "missing name",
+		},
+		{
+			name:    "MissingCommit",
+			content: "name: some-ci\n",
+			errMsg:  "missing commit",
+		},
+		{
+			name:    "BuiltInName",
+			content: "name: jenkins\ncommit: GIT_COMMIT\n",
+			errMsg:  `name "jenkins" is already used by a built-in provider`,
+		},
+		{
+			name:    "UnknownField",
+			content: "name: some-ci\ncommit: SOME_CI_COMMIT\nbuild_link: SOME_CI_URL\n",
+			errMsg:  "field build_link not found",
+		},
+		{
+			name:    "InvalidJSON",
+			content: `{"name": "some-ci", "commit": ["SOME_CI_COMMIT"]}`,
+			errMsg:  "cannot unmarshal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadProviderConfig(writeProviderConfig(t, tt.content))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func Test_configMetadata_Init_missingCommit(t *testing.T) {
+	meta := configMetadata{config: &ProviderConfig{Name: "some-ci", Commit: "SOME_CI_COMMIT"}}
+	err := meta.Init(map[string]string{}, logger.New())
+	if assert.Error(t, err) {
+		assert.Equal(t, "missing required environment variable for provider some-ci: SOME_CI_COMMIT", err.Error())
+	}
+}
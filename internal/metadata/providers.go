@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/buildpulse/test-reporter/internal/logger"
 	"github.com/caarlos0/env/v6"
@@ -23,46 +26,234 @@ type providerMetadata interface {
 	RepoNameWithOwner() string
 }
 
+// A baseBranchProvider is a providerMetadata that knows which branch a pull
+// request build targets. BaseBranch returns an empty string for builds that
+// aren't for a pull request.
+type baseBranchProvider interface {
+	BaseBranch() string
+}
+
+// A jobCheckProvider is a providerMetadata that can name the check after the
+// CI job that's running (see checkNameSchemeJob).
+type jobCheckProvider interface {
+	JobCheck() string
+}
+
+// A buildStartProvider is a providerMetadata that knows when the build started.
+type buildStartProvider interface {
+	BuildStartedAt() time.Time
+}
+
+// A forcedProvider describes a provider that can be selected explicitly with
+// the BUILDPULSE_PROVIDER environment variable, instead of being detected.
+type forcedProvider struct {
+	new func() providerMetadata
+
+	// required lists the environment variables that must be set to use the
+	// provider (i.e., the ones that identify the build and its commit).
+	required []string
+}
+
+var forcedProviders = map[string]forcedProvider{
+	"appveyor":        {func() providerMetadata { return &appveyorMetadata{} }, []string{"APPVEYOR_REPO_COMMIT", "APPVEYOR_BUILD_ID"}},
+	"argo-workflows":  {func() providerMetadata { return &argoMetadata{} }, []string{"ARGO_WORKFLOW_NAME", "GIT_COMMIT"}},
+	"aws-codebuild":   {func() providerMetadata { return &awsCodeBuildMetadata{} }, []string{"CODEBUILD_BUILD_ID", "CODEBUILD_RESOLVED_SOURCE_VERSION"}},
+	"azure-pipelines": {func() providerMetadata { return &azurePipelinesMetadata{} }, []string{"BUILD_BUILDID", "BUILD_SOURCEVERSION"}},
+	"bitbucket.org":   {func() providerMetadata { return &bitbucketMetadata{} }, []string{"BITBUCKET_BUILD_NUMBER", "BITBUCKET_COMMIT"}},
+	"buildkite":       {func() providerMetadata { return &buildkiteMetadata{} }, []string{"BUILDKITE_BUILD_ID", "BUILDKITE_COMMIT"}},
+	"circleci":        {func() providerMetadata { return &circleMetadata{} }, []string{"CIRCLE_BUILD_URL", "CIRCLE_SHA1"}},
+	"cirrus-ci":       {func() providerMetadata { return &cirrusMetadata{} }, []string{"CIRRUS_BUILD_ID", "CIRRUS_CHANGE_IN_REPO"}},
+	"concourse":       {func() providerMetadata { return &concourseMetadata{} }, []string{"ATC_EXTERNAL_URL", "BUILD_PIPELINE_NAME"}},
+	"custom":          {func() providerMetadata { return &customMetadata{} }, nil},
+	"forgejo-actions": {func() providerMetadata { return &giteaMetadata{forgejo: true} }, []string{"GITHUB_RUN_NUMBER", "GITHUB_SHA"}},
+	"gitea-actions":   {func() providerMetadata { return &giteaMetadata{} }, []string{"GITHUB_RUN_NUMBER", "GITHUB_SHA"}},
+	"github-actions":  {func() providerMetadata { return &githubMetadata{} }, []string{"GITHUB_RUN_ID", "GITHUB_SHA"}},
+	"heroku-ci":       {func() providerMetadata { return &herokuMetadata{} }, []string{"HEROKU_TEST_RUN_ID", "HEROKU_TEST_RUN_COMMIT_VERSION"}},
+	"jenkins":         {func() providerMetadata { return &jenkinsMetadata{} }, []string{"BUILD_URL", "GIT_COMMIT"}},
+	"screwdriver":     {func() providerMetadata { return &screwdriverMetadata{} }, []string{"SD_BUILD_ID", "SD_BUILD_SHA"}},
+	"semaphore":       {func() providerMetadata { return &semaphoreMetadata{} }, []string{"SEMAPHORE_WORKFLOW_ID", "SEMAPHORE_GIT_SHA"}},
+	"travis-ci":       {func() providerMetadata { return &travisMetadata{} }, []string{"TRAVIS_BUILD_WEB_URL", "TRAVIS_COMMIT"}},
+	"webapp.io":       {func() providerMetadata { return &webappioMetadata{} }, []string{"JOB_ID", "GIT_COMMIT"}},
+	"woodpecker":      {func() providerMetadata { return &woodpeckerMetadata{} }, []string{"CI_PIPELINE_URL", "CI_COMMIT_SHA"}},
+	"xcode-cloud":     {func() providerMetadata { return &xcodeCloudMetadata{} }, []string{"CI_BUILD_URL", "CI_COMMIT"}},
+}
+
+// Providers lists the names of the providers that can be selected with the
+// BUILDPULSE_PROVIDER environment variable.
+var Providers = func() []string {
+	var names []string
+	for name := range forcedProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}()
+
 func newProviderMetadata(envs map[string]string, log logger.Logger) (providerMetadata, error) {
 	var pm providerMetadata
 
+	if name := envs["BUILDPULSE_PROVIDER"]; name != "" {
+		p, ok := forcedProviders[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_PROVIDER: supported values are: %s", name, strings.Join(Providers, ", "))
+		}
+		for _, key := range p.required {
+			if envs[key] == "" {
+				return nil, fmt.Errorf("missing required environment variable for provider %s: %s", name, key)
+			}
+		}
+
+		pm = p.new()
+		log.Printf("Using build environment from $BUILDPULSE_PROVIDER: %s", pm.Name())
+	} else if path := envs["BUILDPULSE_PROVIDER_CONFIG"]; path != "" {
+		config, err := ReadProviderConfig(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.Detect == "" || envs[config.Detect] != "" {
+			pm = &configMetadata{config: config}
+			log.Printf("Using build environment from provider config %s: %s", path, pm.Name())
+		} else {
+			pm = detectProviderMetadata(envs)
+			log.Printf("Detected build environment: %s ($%s not set for provider config %s)", pm.Name(), config.Detect, path)
+		}
+	} else {
+		pm = detectProviderMetadata(envs)
+		log.Printf("Detected build environment: %s", pm.Name())
+	}
+
+	if err := pm.Init(envs, log); err != nil {
+		return nil, err
+	}
+
+	return pm, nil
+}
+
+// DetectProvider returns the name of the CI provider of the build environment
+// described by envs, as recorded in the ci_provider field of the metadata.
+func DetectProvider(envs map[string]string, log logger.Logger) (string, error) {
+	pm, err := newProviderMetadata(envs, log)
+	if err != nil {
+		return "", err
+	}
+
+	return pm.Name(), nil
+}
+
+// detectProviderMetadata returns the providerMetadata for the CI provider
+// identified by envs, or customMetadata if no known provider is identified.
+func detectProviderMetadata(envs map[string]string) providerMetadata {
 	switch {
+	case strings.EqualFold(envs["APPVEYOR"], "true"):
+		return &appveyorMetadata{}
 	case envs["BUILDKITE"] == "true":
-		pm = &buildkiteMetadata{}
+		return &buildkiteMetadata{}
 	case envs["CIRCLECI"] == "true":
-		pm = &circleMetadata{}
+		return &circleMetadata{}
+	case envs["CIRRUS_CI"] == "true":
+		return &cirrusMetadata{}
+	case envs["GITEA_ACTIONS"] == "true" || envs["FORGEJO_ACTIONS"] == "true":
+		return &giteaMetadata{}
 	case envs["GITHUB_ACTIONS"] == "true":
-		pm = &githubMetadata{}
+		return &githubMetadata{}
 	case envs["JENKINS_HOME"] != "":
-		pm = &jenkinsMetadata{}
+		return &jenkinsMetadata{}
+	case envs["SCREWDRIVER"] == "true":
+		return &screwdriverMetadata{}
 	case envs["SEMAPHORE"] == "true":
-		pm = &semaphoreMetadata{}
+		return &semaphoreMetadata{}
 	case envs["TRAVIS"] == "true":
-		pm = &travisMetadata{}
+		return &travisMetadata{}
 	case envs["WEBAPPIO"] == "true":
-		pm = &webappioMetadata{}
+		return &webappioMetadata{}
+	case envs["CI"] == "woodpecker":
+		return &woodpeckerMetadata{}
+	case len(envs["ARGO_WORKFLOW_NAME"]) > 0:
+		return &argoMetadata{}
+	case len(envs["ATC_EXTERNAL_URL"]) > 0 && len(envs["BUILD_PIPELINE_NAME"]) > 0:
+		return &concourseMetadata{}
+	case len(envs["CI_XCODE_PROJECT"]) > 0:
+		return &xcodeCloudMetadata{}
+	case len(envs["HEROKU_TEST_RUN_ID"]) > 0:
+		return &herokuMetadata{}
 	case len(envs["CODEBUILD_BUILD_ID"]) > 0:
-		pm = &awsCodeBuildMetadata{}
+		return &awsCodeBuildMetadata{}
 	case len(envs["BITBUCKET_BUILD_NUMBER"]) > 0:
-		pm = &bitbucketMetadata{}
+		return &bitbucketMetadata{}
 	case len(envs["BUILD_BUILDID"]) > 0:
-		pm = &azurePipelinesMetadata{}
+		return &azurePipelinesMetadata{}
 	default:
-		pm = &customMetadata{}
+		return &customMetadata{}
 	}
-	log.Printf("Detected build environment: %s", pm.Name())
+}
 
-	if err := pm.Init(envs, log); err != nil {
-		return nil, err
+var _ providerMetadata = (*appveyorMetadata)(nil)
+
+type appveyorMetadata struct {
+	// Fields derived from AppVeyor-specific environment variables
+	AppveyorAccountName               string `env:"APPVEYOR_ACCOUNT_NAME" yaml:":appveyor_account_name"`
+	AppveyorBuildID                   uint64 `env:"APPVEYOR_BUILD_ID" yaml:":appveyor_build_id"`
+	AppveyorBuildNumber               uint64 `env:"APPVEYOR_BUILD_NUMBER" yaml:":appveyor_build_number"`
+	AppveyorBuildVersion              string `env:"APPVEYOR_BUILD_VERSION" yaml:":appveyor_build_version"`
+	AppveyorJobID                     string `env:"APPVEYOR_JOB_ID" yaml:":appveyor_job_id"`
+	AppveyorJobName                   string `env:"APPVEYOR_JOB_NAME" yaml:":appveyor_job_name,omitempty"`
+	AppveyorProjectSlug               string `env:"APPVEYOR_PROJECT_SLUG" yaml:":appveyor_project_slug"`
+	AppveyorPullRequestHeadCommit     string `env:"APPVEYOR_PULL_REQUEST_HEAD_COMMIT" yaml:":appveyor_pull_request_head_commit,omitempty"`
+	AppveyorPullRequestHeadRepoBranch string `env:"APPVEYOR_PULL_REQUEST_HEAD_REPO_BRANCH" yaml:":appveyor_pull_request_head_repo_branch,omitempty"`
+	AppveyorPullRequestHeadRepoName   string `env:"APPVEYOR_PULL_REQUEST_HEAD_REPO_NAME" yaml:":appveyor_pull_request_head_repo_name,omitempty"`
+	AppveyorPullRequestNumber         uint   `env:"APPVEYOR_PULL_REQUEST_NUMBER" yaml:":appveyor_pull_request_number,omitempty"`
+	AppveyorRepoBranch                string `env:"APPVEYOR_REPO_BRANCH" yaml:":appveyor_repo_branch"`
+	AppveyorRepoCommit                string `env:"APPVEYOR_REPO_COMMIT" yaml:"-"`
+	AppveyorRepoName                  string `env:"APPVEYOR_REPO_NAME" yaml:"-"`
+	AppveyorRepoProvider              string `env:"APPVEYOR_REPO_PROVIDER" yaml:":appveyor_repo_provider"`
+	AppveyorRepoTagName               string `env:"APPVEYOR_REPO_TAG_NAME" yaml:":appveyor_repo_tag_name,omitempty"`
+	AppveyorURL                       string `env:"APPVEYOR_URL" envDefault:"https://ci.appveyor.com" yaml:"-"`
+}
+
+func (a *appveyorMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(a, env.Options{Environment: envs}); err != nil {
+		return err
 	}
 
-	return pm, nil
+	log.Printf("Using $APPVEYOR_REPO_COMMIT environment variable as commit SHA: %s", a.AppveyorRepoCommit)
+
+	return nil
+}
+
+func (a *appveyorMetadata) Branch() string {
+	// For pull request builds, APPVEYOR_REPO_BRANCH holds the base branch, so
+	// report the head branch instead.
+	if a.AppveyorPullRequestNumber > 0 && a.AppveyorPullRequestHeadRepoBranch != "" {
+		return a.AppveyorPullRequestHeadRepoBranch
+	}
+
+	return a.AppveyorRepoBranch
+}
+
+func (a *appveyorMetadata) BuildURL() string {
+	return fmt.Sprintf("%s/project/%s/%s/builds/%d", a.AppveyorURL, a.AppveyorAccountName, a.AppveyorProjectSlug, a.AppveyorBuildID)
+}
+
+func (a *appveyorMetadata) CommitSHA() string {
+	return a.AppveyorRepoCommit
+}
+
+func (a *appveyorMetadata) Name() string {
+	return "appveyor"
+}
+
+func (a *appveyorMetadata) RepoNameWithOwner() string {
+	return a.AppveyorRepoName
 }
 
 var _ providerMetadata = (*buildkiteMetadata)(nil)
 
 type buildkiteMetadata struct {
 	// Fields derived from Buildkite-specific environment variables
+	BuildkiteAgentID                string `env:"BUILDKITE_AGENT_ID" yaml:":buildkite_agent_id,omitempty"`
+	BuildkiteAgentName              string `env:"BUILDKITE_AGENT_NAME" yaml:":buildkite_agent_name,omitempty"`
 	BuildkiteBranch                 string `env:"BUILDKITE_BRANCH" yaml:"-"`
 	BuildkiteBuildID                string `env:"BUILDKITE_BUILD_ID" yaml:":buildkite_build_id"`
 	BuildkiteBuildNumber            uint64 `env:"BUILDKITE_BUILD_NUMBER" yaml:":buildkite_build_number"`
@@ -71,6 +262,8 @@ type buildkiteMetadata struct {
 	BuildkiteJobID                  string `env:"BUILDKITE_JOB_ID" yaml:":buildkite_job_id"`
 	BuildkiteLabel                  string `env:"BUILDKITE_LABEL" yaml:":buildkite_label"`
 	BuildkiteOrganizationSlug       string `env:"BUILDKITE_ORGANIZATION_SLUG" yaml:":buildkite_organization_slug"`
+	BuildkiteParallelJob            *uint  `env:"BUILDKITE_PARALLEL_JOB" yaml:":buildkite_parallel_job,omitempty"` // nil unless the step is parallelized; 0 is the first job
+	BuildkiteParallelJobCount       *uint  `env:"BUILDKITE_PARALLEL_JOB_COUNT" yaml:":buildkite_parallel_job_count,omitempty"`
 	BuildkitePipelineID             string `env:"BUILDKITE_PIPELINE_ID" yaml:":buildkite_pipeline_id"`
 	BuildkitePipelineSlug           string `env:"BUILDKITE_PIPELINE_SLUG" yaml:":buildkite_pipeline_slug"`
 	BuildkiteProjectSlug            string `env:"BUILDKITE_PROJECT_SLUG" yaml:":buildkite_project_slug"`
@@ -94,7 +287,7 @@ func (b *buildkiteMetadata) Init(envs map[string]string, log logger.Logger) erro
 
 	log.Printf("Using $BUILDKITE_COMMIT environment variable as commit SHA: %s", b.BuildkiteCommit)
 
-	nwo, err := nameWithOwnerFromGitURL(b.BuildkiteRepoURL)
+	nwo, err := repoNameWithOwner(b.BuildkiteRepoURL, envs, log)
 	if err != nil {
 		return err
 	}
@@ -128,12 +321,19 @@ func (b *buildkiteMetadata) RepoNameWithOwner() string {
 	return b.nwo
 }
 
+// BaseBranch returns the branch a pull request targets, from BUILDKITE_PULL_REQUEST_BASE_BRANCH.
+func (b *buildkiteMetadata) BaseBranch() string {
+	return b.BuildkitePullRequestBaseBranch
+}
+
 type circleMetadata struct {
 	// Fields derived from Circle-specific environment variables
 	CircleBranch              string `env:"CIRCLE_BRANCH" yaml:"-"`
 	CircleBuildNumber         uint64 `env:"CIRCLE_BUILD_NUM" yaml:":circle_build_num"`
 	CircleBuildURL            string `env:"CIRCLE_BUILD_URL" yaml:"-"`
 	CircleJob                 string `env:"CIRCLE_JOB" yaml:":circle_job"`
+	CircleNodeIndex           *uint  `env:"CIRCLE_NODE_INDEX" yaml:":circle_node_index,omitempty"` // nil unless parallelism is enabled; 0 is the first node
+	CircleNodeTotal           *uint  `env:"CIRCLE_NODE_TOTAL" yaml:":circle_node_total,omitempty"`
 	CircleProjectReponame     string `env:"CIRCLE_PROJECT_REPONAME" yaml:"-"`
 	CircleProjectUsername     string `env:"CIRCLE_PROJECT_USERNAME" yaml:"-"`
 	CirclePullRequestNumber   uint   `env:"CIRCLE_PR_NUMBER" yaml:":circle_pr_number,omitempty"`
@@ -177,23 +377,78 @@ func (c *circleMetadata) RepoNameWithOwner() string {
 	return fmt.Sprintf("%s/%s", c.CircleProjectUsername, c.CircleProjectReponame)
 }
 
+var _ providerMetadata = (*cirrusMetadata)(nil)
+
+type cirrusMetadata struct {
+	// Fields derived from Cirrus-specific environment variables
+	CirrusBaseBranch   string `env:"CIRRUS_BASE_BRANCH" yaml:":cirrus_base_branch,omitempty"`
+	CirrusBranch       string `env:"CIRRUS_BRANCH" yaml:"-"`
+	CirrusBuildID      string `env:"CIRRUS_BUILD_ID" yaml:":cirrus_build_id"`
+	CirrusChangeInRepo string `env:"CIRRUS_CHANGE_IN_REPO" yaml:"-"`
+	CirrusPullRequest  uint   `env:"CIRRUS_PR" yaml:":cirrus_pr,omitempty"`
+	CirrusRepoCloneURL string `env:"CIRRUS_REPO_CLONE_URL" yaml:":cirrus_repo_clone_url"`
+	CirrusRepoFullName string `env:"CIRRUS_REPO_FULL_NAME" yaml:"-"`
+	CirrusTag          string `env:"CIRRUS_TAG" yaml:":cirrus_tag,omitempty"`
+	CirrusTaskID       string `env:"CIRRUS_TASK_ID" yaml:":cirrus_task_id"`
+	CirrusTaskName     string `env:"CIRRUS_TASK_NAME" yaml:":cirrus_task_name"`
+}
+
+func (c *cirrusMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(c, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	log.Printf("Using $CIRRUS_CHANGE_IN_REPO environment variable as commit SHA: %s", c.CirrusChangeInRepo)
+
+	return nil
+}
+
+func (c *cirrusMetadata) Branch() string {
+	return c.CirrusBranch
+}
+
+func (c *cirrusMetadata) BuildURL() string {
+	return fmt.Sprintf("https://cirrus-ci.com/build/%s", c.CirrusBuildID)
+}
+
+func (c *cirrusMetadata) CommitSHA() string {
+	return c.CirrusChangeInRepo
+}
+
+func (c *cirrusMetadata) Name() string {
+	return "cirrus-ci"
+}
+
+func (c *cirrusMetadata) RepoNameWithOwner() string {
+	return c.CirrusRepoFullName
+}
+
+// BaseBranch returns the branch a pull request targets, from CIRRUS_BASE_BRANCH.
+func (c *cirrusMetadata) BaseBranch() string {
+	return c.CirrusBaseBranch
+}
+
 var _ providerMetadata = (*githubMetadata)(nil)
 
 type githubMetadata struct {
 	// Fields derived from GitHub-specific environment variables
-	GithubActor      string `env:"GITHUB_ACTOR" yaml:":github_actor"`
-	GithubBaseRef    string `env:"GITHUB_BASE_REF" yaml:":github_base_ref"`
-	GithubEventName  string `env:"GITHUB_EVENT_NAME" yaml:":github_event_name"`
-	GithubHeadRef    string `env:"GITHUB_HEAD_REF" yaml:":github_head_ref"`
-	GithubRef        string `env:"GITHUB_REF" yaml:":github_ref"`
-	GithubRepoNWO    string `env:"GITHUB_REPOSITORY" yaml:"-"`
-	GithubRepoURL    string `yaml:":github_repo_url"`
-	GithubRunAttempt uint   `env:"GITHUB_RUN_ATTEMPT" yaml:":github_run_attempt"`
-	GithubRunID      uint64 `env:"GITHUB_RUN_ID" yaml:":github_run_id"`
-	GithubRunNumber  uint   `env:"GITHUB_RUN_NUMBER" yaml:":github_run_number"`
-	GithubServerURL  string `env:"GITHUB_SERVER_URL" yaml:"-"`
-	GithubSHA        string `env:"GITHUB_SHA" yaml:"-"`
-	GithubWorkflow   string `env:"GITHUB_WORKFLOW" yaml:":github_workflow"`
+	GithubActionPath       string `env:"GITHUB_ACTION_PATH" yaml:"-"`
+	GithubActionRepository string `env:"GITHUB_ACTION_REPOSITORY" yaml:":github_action_repository,omitempty"`
+	GithubActor            string `env:"GITHUB_ACTOR" yaml:":github_actor"`
+	GithubBaseRef          string `env:"GITHUB_BASE_REF" yaml:":github_base_ref"`
+	GithubEventName        string `env:"GITHUB_EVENT_NAME" yaml:":github_event_name"`
+	GithubHeadRef          string `env:"GITHUB_HEAD_REF" yaml:":github_head_ref"`
+	GithubJob              string `env:"GITHUB_JOB" yaml:":github_job,omitempty"`
+	GithubRef              string `env:"GITHUB_REF" yaml:":github_ref"`
+	GithubRepoNWO          string `env:"GITHUB_REPOSITORY" yaml:"-"`
+	GithubRepoURL          string `yaml:":github_repo_url"`
+	GithubRunAttempt       uint   `env:"GITHUB_RUN_ATTEMPT" yaml:":github_run_attempt"`
+	GithubRunID            uint64 `env:"GITHUB_RUN_ID" yaml:":github_run_id"`
+	GithubRunNumber        uint   `env:"GITHUB_RUN_NUMBER" yaml:":github_run_number"`
+	GithubServerURL        string `env:"GITHUB_SERVER_URL" yaml:"-"`
+	GithubSHA              string `env:"GITHUB_SHA" yaml:"-"`
+	GithubWorkflow         string `env:"GITHUB_WORKFLOW" yaml:":github_workflow"`
+	GithubWorkflowRef      string `env:"GITHUB_WORKFLOW_REF" yaml:":github_workflow_ref,omitempty"`
 
 	branch   string
 	buildURL string
@@ -226,6 +481,13 @@ func (g *githubMetadata) Init(envs map[string]string, log logger.Logger) error {
 		g.branch = strings.TrimPrefix(g.GithubRef, "refs/heads/")
 	}
 
+	// GITHUB_ACTION_REPOSITORY is only set for steps that run an action, and
+	// GITHUB_ACTION_PATH only for composite actions, so the repository is only
+	// meaningful when the reporter runs as part of a composite action
+	if g.GithubActionPath == "" {
+		g.GithubActionRepository = ""
+	}
+
 	return nil
 }
 
@@ -249,6 +511,64 @@ func (g *githubMetadata) RepoNameWithOwner() string {
 	return g.GithubRepoNWO
 }
 
+// BaseBranch returns the branch a pull request targets, from GITHUB_BASE_REF.
+func (g *githubMetadata) BaseBranch() string {
+	return g.GithubBaseRef
+}
+
+// JobCheck returns the name of the workflow file (without its extension) and
+// the ID of the job, separated by a slash (e.g., "ci/test").
+//
+// In a reusable workflow, GITHUB_WORKFLOW and GITHUB_WORKFLOW_REF describe the
+// calling workflow, while GITHUB_JOB is the ID of the called workflow's job, so
+// the check identifies both the caller and the callee.
+func (g *githubMetadata) JobCheck() string {
+	if g.GithubWorkflowRef == "" || g.GithubJob == "" {
+		return ""
+	}
+
+	// e.g., "some-owner/some-repo/.github/workflows/ci.yml@refs/heads/main"
+	file, _, _ := strings.Cut(g.GithubWorkflowRef, "@")
+	file = path.Base(file)
+	file = strings.TrimSuffix(file, path.Ext(file))
+
+	return file + "/" + g.GithubJob
+}
+
+var _ providerMetadata = (*giteaMetadata)(nil)
+
+// giteaMetadata describes a build running in Gitea Actions or Forgejo Actions,
+// which provide the same environment variables as GitHub Actions (including
+// GITHUB_ACTIONS=true), but with GITHUB_SERVER_URL pointing at the Gitea or
+// Forgejo instance.
+type giteaMetadata struct {
+	githubMetadata `yaml:",inline"`
+
+	forgejo bool
+}
+
+func (g *giteaMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := g.githubMetadata.Init(envs, log); err != nil {
+		return err
+	}
+
+	g.forgejo = g.forgejo || envs["FORGEJO_ACTIONS"] == "true"
+
+	// Gitea identifies runs by their number (i.e., their index within the
+	// repository), and doesn't include the attempt in the URL
+	g.buildURL = fmt.Sprintf("%s/actions/runs/%d", g.GithubRepoURL, g.GithubRunNumber)
+
+	return nil
+}
+
+func (g *giteaMetadata) Name() string {
+	if g.forgejo {
+		return "forgejo-actions"
+	}
+
+	return "gitea-actions"
+}
+
 var _ providerMetadata = (*jenkinsMetadata)(nil)
 
 type jenkinsMetadata struct {
@@ -262,10 +582,15 @@ type jenkinsMetadata struct {
 	JenkinsNodeName       string `env:"NODE_NAME" yaml:":jenkins_node_name"`
 	JenkinsWorkspace      string `env:"WORKSPACE" yaml:":jenkins_workspace"`
 
+	branch   string
 	buildURL string
 	nwo      string
 }
 
+// defaultJenkinsGitRemote is the name of the remote whose prefix is removed from
+// GIT_BRANCH, unless BUILDPULSE_JENKINS_GIT_REMOTE is set.
+const defaultJenkinsGitRemote = "origin"
+
 func (j *jenkinsMetadata) Init(envs map[string]string, log logger.Logger) error {
 	if err := env.Parse(j, env.Options{Environment: envs}); err != nil {
 		return err
@@ -279,7 +604,13 @@ func (j *jenkinsMetadata) Init(envs map[string]string, log logger.Logger) error
 	}
 	j.buildURL = url
 
-	nwo, err := nameWithOwnerFromGitURL(j.GitURL)
+	remote, ok := envs["BUILDPULSE_JENKINS_GIT_REMOTE"]
+	if !ok {
+		remote = defaultJenkinsGitRemote
+	}
+	j.branch = trimRemote(j.GitBranch, remote)
+
+	nwo, err := repoNameWithOwner(j.GitURL, envs, log)
 	if err != nil {
 		return err
 	}
@@ -289,7 +620,24 @@ func (j *jenkinsMetadata) Init(envs map[string]string, log logger.Logger) error
 }
 
 func (j *jenkinsMetadata) Branch() string {
-	return j.GitBranch
+	return j.branch
+}
+
+// trimRemote removes the prefix that identifies the given remote from branch,
+// as in the values of GIT_BRANCH set by the Jenkins Git plugin (e.g.,
+// "origin/some-branch" or "refs/remotes/origin/some-branch"), along with any
+// "refs/heads/" prefix. If remote is empty, only "refs/heads/" is removed.
+func trimRemote(branch string, remote string) string {
+	if remote != "" {
+		for _, prefix := range []string{"refs/remotes/" + remote + "/", "remotes/" + remote + "/", remote + "/"} {
+			if strings.HasPrefix(branch, prefix) {
+				branch = strings.TrimPrefix(branch, prefix)
+				break
+			}
+		}
+	}
+
+	return strings.TrimPrefix(branch, "refs/heads/")
 }
 
 func (j *jenkinsMetadata) BuildURL() string {
@@ -308,6 +656,65 @@ func (j *jenkinsMetadata) RepoNameWithOwner() string {
 	return j.nwo
 }
 
+var _ providerMetadata = (*screwdriverMetadata)(nil)
+
+type screwdriverMetadata struct {
+	// Fields derived from Screwdriver-specific environment variables
+	GitBranch     string `env:"GIT_BRANCH" yaml:"-"`
+	GitURL        string `env:"GIT_URL" yaml:"-"`
+	PRBranchName  string `env:"PR_BRANCH_NAME" yaml:"-"`
+	SDBuildID     uint64 `env:"SD_BUILD_ID" yaml:":screwdriver_build_id"`
+	SDBuildSHA    string `env:"SD_BUILD_SHA" yaml:"-"`
+	SDEventID     uint64 `env:"SD_EVENT_ID" yaml:":screwdriver_event_id,omitempty"`
+	SDJobName     string `env:"SD_JOB_NAME" yaml:":screwdriver_job_name"`
+	SDPipelineID  uint64 `env:"SD_PIPELINE_ID" yaml:":screwdriver_pipeline_id"`
+	SDPullRequest uint   `env:"SD_PULL_REQUEST" yaml:":screwdriver_pull_request,omitempty"`
+	SDUIURL       string `env:"SD_UI_URL" envDefault:"https://cd.screwdriver.cd/" yaml:"-"`
+
+	nwo string
+}
+
+func (s *screwdriverMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(s, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	log.Printf("Using $SD_BUILD_SHA environment variable as commit SHA: %s", s.SDBuildSHA)
+
+	nwo, err := repoNameWithOwner(s.GitURL, envs, log)
+	if err != nil {
+		return err
+	}
+	s.nwo = nwo
+
+	return nil
+}
+
+func (s *screwdriverMetadata) Branch() string {
+	// For pull requests, GIT_BRANCH holds the pull request ref
+	if s.PRBranchName != "" {
+		return s.PRBranchName
+	}
+
+	return strings.TrimPrefix(s.GitBranch, "origin/")
+}
+
+func (s *screwdriverMetadata) BuildURL() string {
+	return fmt.Sprintf("%s/pipelines/%d/builds/%d", strings.TrimSuffix(s.SDUIURL, "/"), s.SDPipelineID, s.SDBuildID)
+}
+
+func (s *screwdriverMetadata) CommitSHA() string {
+	return s.SDBuildSHA
+}
+
+func (s *screwdriverMetadata) Name() string {
+	return "screwdriver"
+}
+
+func (s *screwdriverMetadata) RepoNameWithOwner() string {
+	return s.nwo
+}
+
 var _ providerMetadata = (*semaphoreMetadata)(nil)
 
 type semaphoreMetadata struct {
@@ -428,15 +835,82 @@ func (t *travisMetadata) RepoNameWithOwner() string {
 	return t.TravisRepoSlug
 }
 
-func nameWithOwnerFromGitURL(url string) (string, error) {
-	re := regexp.MustCompile(`github.com[:/](.*)`)
+// scpLikeGitURLRegex matches the scp-like syntax for SSH git URLs (e.g.,
+// "git@gitlab.com:some-group/some-repo.git"), capturing the host and the path.
+var scpLikeGitURLRegex = regexp.MustCompile(`^(?:[^@/]+@)?([^@/:]+):(.+)$`)
+
+// nameWithOwnerFromGitURL extracts the repository's path on its git host (e.g.,
+// "some-owner/some-repo") from a clone URL. It supports https, ssh, and git
+// URLs for any host, including SSH URLs with custom ports and the scp-like
+// syntax, and normalizes the paths used by Azure Repos and Bitbucket Server.
+// Paths on hosts with nested groups (e.g., GitLab subgroups) are kept whole.
+func nameWithOwnerFromGitURL(rawURL string) (string, error) {
+	var host, path string
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if m := scpLikeGitURLRegex.FindStringSubmatch(rawURL); m != nil {
+		host, path = m[1], m[2]
+	} else {
+		return "", fmt.Errorf("unable to extract repository name-with-owner from URL: %s", rawURL)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	segments := strings.Split(path, "/")
+
+	switch {
+	case strings.HasSuffix(host, "dev.azure.com"):
+		// https://dev.azure.com/some-org/some-project/_git/some-repo and
+		// git@ssh.dev.azure.com:v3/some-org/some-project/some-repo
+		segments = removeSegment(removeSegment(segments, "_git"), "v3")
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		// https://some-org.visualstudio.com/some-project/_git/some-repo
+		segments = append([]string{strings.TrimSuffix(host, ".visualstudio.com")}, removeSegment(segments, "_git")...)
+	case len(segments) > 2 && segments[0] == "scm":
+		// Bitbucket Server: https://bitbucket.example.com/scm/some-project/some-repo.git
+		segments = segments[1:]
+	}
 
-	matches := re.FindStringSubmatch(url)
-	if len(matches) != 2 {
-		return "", fmt.Errorf("unable to extract repository name-with-owner from URL: %s", url)
+	if len(segments) < 2 || strings.Contains(path, "//") {
+		return "", fmt.Errorf("unable to extract repository name-with-owner from URL: %s", rawURL)
 	}
 
-	return strings.TrimSuffix(matches[1], ".git"), nil
+	return strings.Join(segments, "/"), nil
+}
+
+// repoNameWithOwner extracts the repository's name-with-owner from a clone URL.
+// If it can't be extracted (e.g., for a clone from a local path), it falls back
+// to the ORGANIZATION_NAME and REPOSITORY_NAME environment variables, as used by
+// customMetadata. BUILDPULSE_REPO_NAME_WITH_OWNER takes precedence over both.
+func repoNameWithOwner(rawURL string, envs map[string]string, log logger.Logger) (string, error) {
+	// The name-with-owner is overridden by Metadata, so don't fail for lack of it
+	if nwo := envs["BUILDPULSE_REPO_NAME_WITH_OWNER"]; nwo != "" {
+		return nwo, nil
+	}
+
+	nwo, err := nameWithOwnerFromGitURL(rawURL)
+	if err == nil {
+		return nwo, nil
+	}
+
+	org, repo := envs["ORGANIZATION_NAME"], envs["REPOSITORY_NAME"]
+	if org == "" || repo == "" {
+		return "", fmt.Errorf("%v (set ORGANIZATION_NAME and REPOSITORY_NAME to specify it)", err)
+	}
+
+	log.Printf("Using $ORGANIZATION_NAME and $REPOSITORY_NAME environment variables as repository name-with-owner: %s/%s (%v)", org, repo, err)
+	return fmt.Sprintf("%s/%s", org, repo), nil
+}
+
+// removeSegment returns the path segments without the first occurrence of
+// segment, if any.
+func removeSegment(segments []string, segment string) []string {
+	for i, s := range segments {
+		if s == segment {
+			return append(segments[:i:i], segments[i+1:]...)
+		}
+	}
+
+	return segments
 }
 
 var _ providerMetadata = (*webappioMetadata)(nil)
@@ -491,6 +965,350 @@ func (w *webappioMetadata) RepoNameWithOwner() string {
 	return fmt.Sprintf("%s/%s", w.RepositoryOwner, w.RepositoryName)
 }
 
+var _ providerMetadata = (*woodpeckerMetadata)(nil)
+
+type woodpeckerMetadata struct {
+	// Fields derived from Woodpecker-specific environment variables
+	CICommitBranch       string `env:"CI_COMMIT_BRANCH" yaml:"-"`
+	CICommitPullRequest  uint   `env:"CI_COMMIT_PULL_REQUEST" yaml:":woodpecker_commit_pull_request,omitempty"`
+	CICommitSHA          string `env:"CI_COMMIT_SHA" yaml:"-"`
+	CICommitSourceBranch string `env:"CI_COMMIT_SOURCE_BRANCH" yaml:"-"`
+	CICommitTag          string `env:"CI_COMMIT_TAG" yaml:":woodpecker_commit_tag,omitempty"`
+	CICommitTargetBranch string `env:"CI_COMMIT_TARGET_BRANCH" yaml:":woodpecker_commit_target_branch,omitempty"`
+	CIPipelineEvent      string `env:"CI_PIPELINE_EVENT" yaml:":woodpecker_pipeline_event"`
+	CIPipelineNumber     uint   `env:"CI_PIPELINE_NUMBER" yaml:":woodpecker_pipeline_number"`
+	CIPipelineURL        string `env:"CI_PIPELINE_URL" yaml:"-"`
+	CIRepo               string `env:"CI_REPO" yaml:"-"`
+	CIRepoURL            string `env:"CI_REPO_URL" yaml:":woodpecker_repo_url"`
+	CIStepName           string `env:"CI_STEP_NAME" yaml:":woodpecker_step_name,omitempty"`
+	CIPipelineCreated    string `env:"CI_PIPELINE_CREATED" yaml:"-"`
+	CIPipelineStarted    string `env:"CI_PIPELINE_STARTED" yaml:"-"`
+	CIStepStarted        string `env:"CI_STEP_STARTED" yaml:"-"`
+}
+
+func (w *woodpeckerMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(w, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	log.Printf("Using $CI_COMMIT_SHA environment variable as commit SHA: %s", w.CICommitSHA)
+
+	return nil
+}
+
+func (w *woodpeckerMetadata) Branch() string {
+	// For pull requests, CI_COMMIT_BRANCH holds the target branch
+	if w.CICommitSourceBranch != "" {
+		return w.CICommitSourceBranch
+	}
+
+	return w.CICommitBranch
+}
+
+func (w *woodpeckerMetadata) BuildURL() string {
+	return w.CIPipelineURL
+}
+
+func (w *woodpeckerMetadata) CommitSHA() string {
+	return w.CICommitSHA
+}
+
+func (w *woodpeckerMetadata) Name() string {
+	return "woodpecker"
+}
+
+func (w *woodpeckerMetadata) RepoNameWithOwner() string {
+	return w.CIRepo
+}
+
+// BaseBranch returns the branch a pull request targets, from CI_COMMIT_TARGET_BRANCH.
+func (w *woodpeckerMetadata) BaseBranch() string {
+	return w.CICommitTargetBranch
+}
+
+// BuildStartedAt returns the time the pipeline started, from
+// CI_PIPELINE_STARTED (in seconds since the epoch), or the zero time if it
+// isn't set.
+func (w *woodpeckerMetadata) BuildStartedAt() time.Time {
+	return unixTime(w.CIPipelineStarted)
+}
+
+// JobQueuedAt returns the time the pipeline was created, from
+// CI_PIPELINE_CREATED, since Woodpecker doesn't report when the step was
+// enqueued.
+func (w *woodpeckerMetadata) JobQueuedAt() time.Time {
+	return unixTime(w.CIPipelineCreated)
+}
+
+// JobStartedAt returns the time the step started, from CI_STEP_STARTED.
+func (w *woodpeckerMetadata) JobStartedAt() time.Time {
+	return unixTime(w.CIStepStarted)
+}
+
+var _ providerMetadata = (*argoMetadata)(nil)
+
+// argoMetadata describes a build running in Argo Workflows. Argo only exposes
+// the node and pod of the running step, so the workflow template is expected
+// to pass the workflow details and git info to the step as environment
+// variables (e.g., ARGO_WORKFLOW_NAME: "{{workflow.name}}").
+type argoMetadata struct {
+	// Fields derived from Argo-specific environment variables
+	ArgoNodeID            string `env:"ARGO_NODE_ID" yaml:":argo_node_id"`
+	ArgoPodName           string `env:"ARGO_POD_NAME" yaml:":argo_pod_name,omitempty"`
+	ArgoServerURL         string `env:"ARGO_SERVER_URL" yaml:"-"`
+	ArgoWorkflowName      string `env:"ARGO_WORKFLOW_NAME" yaml:":argo_workflow_name"`
+	ArgoWorkflowNamespace string `env:"ARGO_WORKFLOW_NAMESPACE" yaml:":argo_workflow_namespace"`
+	ArgoWorkflowUID       string `env:"ARGO_WORKFLOW_UID" yaml:":argo_workflow_uid,omitempty"`
+
+	// Fields derived from workflow parameters
+	BuildURI         string `env:"BUILD_URL" yaml:"-"`
+	GitBranch        string `env:"GIT_BRANCH" yaml:"-"`
+	GitCommit        string `env:"GIT_COMMIT" yaml:"-"`
+	OrganizationName string `env:"ORGANIZATION_NAME" yaml:"-"`
+	RepositoryName   string `env:"REPOSITORY_NAME" yaml:"-"`
+}
+
+func (a *argoMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(a, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	log.Printf("Using $GIT_COMMIT environment variable as commit SHA: %s", a.GitCommit)
+
+	return nil
+}
+
+func (a *argoMetadata) Branch() string {
+	return a.GitBranch
+}
+
+func (a *argoMetadata) BuildURL() string {
+	if a.ArgoServerURL == "" {
+		return a.BuildURI
+	}
+
+	u := fmt.Sprintf(
+		"%s/workflows/%s/%s",
+		strings.TrimSuffix(a.ArgoServerURL, "/"),
+		a.ArgoWorkflowNamespace,
+		a.ArgoWorkflowName,
+	)
+	if a.ArgoNodeID != "" {
+		u += "?nodeId=" + a.ArgoNodeID
+	}
+
+	return u
+}
+
+func (a *argoMetadata) CommitSHA() string {
+	return a.GitCommit
+}
+
+func (a *argoMetadata) Name() string {
+	return "argo-workflows"
+}
+
+func (a *argoMetadata) RepoNameWithOwner() string {
+	return fmt.Sprintf("%s/%s", a.OrganizationName, a.RepositoryName)
+}
+
+var _ providerMetadata = (*concourseMetadata)(nil)
+
+// concourseMetadata describes a build running in Concourse. Concourse doesn't
+// expose git info to tasks, so unless GIT_COMMIT is given, the commit is
+// resolved from the HEAD of the git resource checked out at -repository-dir.
+type concourseMetadata struct {
+	// Fields derived from Concourse-specific environment variables
+	ATCExternalURL            string `env:"ATC_EXTERNAL_URL" yaml:"-"`
+	BuildID                   string `env:"BUILD_ID" yaml:":concourse_build_id"`
+	BuildJobName              string `env:"BUILD_JOB_NAME" yaml:":concourse_build_job_name"`
+	BuildName                 string `env:"BUILD_NAME" yaml:":concourse_build_name"`
+	BuildPipelineInstanceVars string `env:"BUILD_PIPELINE_INSTANCE_VARS" yaml:":concourse_build_pipeline_instance_vars,omitempty"`
+	BuildPipelineName         string `env:"BUILD_PIPELINE_NAME" yaml:":concourse_build_pipeline_name"`
+	BuildTeamName             string `env:"BUILD_TEAM_NAME" yaml:":concourse_build_team_name"`
+
+	// Fields derived from task params
+	GitBranch        string `env:"GIT_BRANCH" yaml:"-"`
+	GitCommit        string `env:"GIT_COMMIT" yaml:"-"`
+	OrganizationName string `env:"ORGANIZATION_NAME" yaml:"-"`
+	RepositoryName   string `env:"REPOSITORY_NAME" yaml:"-"`
+}
+
+func (c *concourseMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(c, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	if c.GitCommit != "" {
+		log.Printf("Using $GIT_COMMIT environment variable as commit SHA: %s", c.GitCommit)
+	} else {
+		log.Printf("No $GIT_COMMIT environment variable; using HEAD of the checked-out repository as commit SHA")
+	}
+
+	return nil
+}
+
+func (c *concourseMetadata) Branch() string {
+	return c.GitBranch
+}
+
+func (c *concourseMetadata) BuildURL() string {
+	u := fmt.Sprintf(
+		"%s/teams/%s/pipelines/%s/jobs/%s/builds/%s",
+		strings.TrimSuffix(c.ATCExternalURL, "/"),
+		url.PathEscape(c.BuildTeamName),
+		url.PathEscape(c.BuildPipelineName),
+		url.PathEscape(c.BuildJobName),
+		url.PathEscape(c.BuildName),
+	)
+	if c.BuildPipelineInstanceVars != "" {
+		u += "?vars=" + url.QueryEscape(c.BuildPipelineInstanceVars)
+	}
+
+	return u
+}
+
+func (c *concourseMetadata) CommitSHA() string {
+	return c.GitCommit
+}
+
+func (c *concourseMetadata) Name() string {
+	return "concourse"
+}
+
+func (c *concourseMetadata) RepoNameWithOwner() string {
+	if c.OrganizationName == "" || c.RepositoryName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", c.OrganizationName, c.RepositoryName)
+}
+
+var _ providerMetadata = (*xcodeCloudMetadata)(nil)
+
+// xcodeCloudMetadata describes a build running in Xcode Cloud. Xcode Cloud
+// only exposes the repository for pull request builds, so other builds expect
+// the repository to be given as environment variables in the workflow.
+type xcodeCloudMetadata struct {
+	// Fields derived from Xcode Cloud-specific environment variables
+	CIBranch                  string `env:"CI_BRANCH" yaml:"-"`
+	CIBuildID                 string `env:"CI_BUILD_ID" yaml:":xcode_cloud_build_id"`
+	CIBuildNumber             uint64 `env:"CI_BUILD_NUMBER" yaml:":xcode_cloud_build_number"`
+	CIBuildURL                string `env:"CI_BUILD_URL" yaml:"-"`
+	CICommit                  string `env:"CI_COMMIT" yaml:"-"`
+	CIProduct                 string `env:"CI_PRODUCT" yaml:":xcode_cloud_product,omitempty"`
+	CIPullRequestNumber       uint   `env:"CI_PULL_REQUEST_NUMBER" yaml:":xcode_cloud_pull_request_number,omitempty"`
+	CIPullRequestSourceBranch string `env:"CI_PULL_REQUEST_SOURCE_BRANCH" yaml:"-"`
+	CIPullRequestTargetBranch string `env:"CI_PULL_REQUEST_TARGET_BRANCH" yaml:":xcode_cloud_pull_request_target_branch,omitempty"`
+	CIPullRequestTargetRepo   string `env:"CI_PULL_REQUEST_TARGET_REPO" yaml:"-"`
+	CITag                     string `env:"CI_TAG" yaml:":xcode_cloud_tag,omitempty"`
+	CIWorkflow                string `env:"CI_WORKFLOW" yaml:":xcode_cloud_workflow"`
+	CIXcodebuildAction        string `env:"CI_XCODEBUILD_ACTION" yaml:":xcode_cloud_xcodebuild_action,omitempty"`
+
+	// Fields derived from workflow environment variables
+	OrganizationName string `env:"ORGANIZATION_NAME" yaml:"-"`
+	RepositoryName   string `env:"REPOSITORY_NAME" yaml:"-"`
+}
+
+func (x *xcodeCloudMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(x, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	log.Printf("Using $CI_COMMIT environment variable as commit SHA: %s", x.CICommit)
+
+	return nil
+}
+
+func (x *xcodeCloudMetadata) Branch() string {
+	if x.CIPullRequestSourceBranch != "" {
+		return x.CIPullRequestSourceBranch
+	}
+
+	return x.CIBranch
+}
+
+func (x *xcodeCloudMetadata) BuildURL() string {
+	return x.CIBuildURL
+}
+
+func (x *xcodeCloudMetadata) CommitSHA() string {
+	return x.CICommit
+}
+
+func (x *xcodeCloudMetadata) Name() string {
+	return "xcode-cloud"
+}
+
+func (x *xcodeCloudMetadata) RepoNameWithOwner() string {
+	if x.CIPullRequestTargetRepo != "" {
+		return x.CIPullRequestTargetRepo
+	}
+	if x.OrganizationName == "" || x.RepositoryName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", x.OrganizationName, x.RepositoryName)
+}
+
+// BaseBranch returns the branch a pull request targets, from CI_PULL_REQUEST_TARGET_BRANCH.
+func (x *xcodeCloudMetadata) BaseBranch() string {
+	return x.CIPullRequestTargetBranch
+}
+
+var _ providerMetadata = (*herokuMetadata)(nil)
+
+// herokuMetadata describes a test run in Heroku CI. Heroku CI runs the tests
+// in a slug without a git repository, and doesn't expose the repository or a
+// link to the test run, so those are expected as config vars in app.json.
+type herokuMetadata struct {
+	// Fields derived from Heroku-specific environment variables
+	CINodeIndex                uint   `env:"CI_NODE_INDEX" yaml:":heroku_ci_node_index,omitempty"`
+	CINodeTotal                uint   `env:"CI_NODE_TOTAL" yaml:":heroku_ci_node_total,omitempty"`
+	HerokuTestRunBranch        string `env:"HEROKU_TEST_RUN_BRANCH" yaml:"-"`
+	HerokuTestRunCommitVersion string `env:"HEROKU_TEST_RUN_COMMIT_VERSION" yaml:"-"`
+	HerokuTestRunID            string `env:"HEROKU_TEST_RUN_ID" yaml:":heroku_test_run_id"`
+
+	// Fields derived from app.json config vars
+	BuildURI         string `env:"BUILD_URL" yaml:"-"`
+	OrganizationName string `env:"ORGANIZATION_NAME" yaml:"-"`
+	RepositoryName   string `env:"REPOSITORY_NAME" yaml:"-"`
+}
+
+func (h *herokuMetadata) Init(envs map[string]string, log logger.Logger) error {
+	if err := env.Parse(h, env.Options{Environment: envs}); err != nil {
+		return err
+	}
+
+	log.Printf("Using $HEROKU_TEST_RUN_COMMIT_VERSION environment variable as commit SHA: %s", h.HerokuTestRunCommitVersion)
+
+	return nil
+}
+
+func (h *herokuMetadata) Branch() string {
+	return h.HerokuTestRunBranch
+}
+
+func (h *herokuMetadata) BuildURL() string {
+	return h.BuildURI
+}
+
+func (h *herokuMetadata) CommitSHA() string {
+	return h.HerokuTestRunCommitVersion
+}
+
+func (h *herokuMetadata) Name() string {
+	return "heroku-ci"
+}
+
+func (h *herokuMetadata) RepoNameWithOwner() string {
+	if h.OrganizationName == "" || h.RepositoryName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", h.OrganizationName, h.RepositoryName)
+}
+
 var _ providerMetadata = (*awsCodeBuildMetadata)(nil)
 
 type awsCodeBuildMetadata struct {
@@ -561,6 +1379,34 @@ func (l *awsCodeBuildMetadata) RepoNameWithOwner() string {
 	return fmt.Sprintf("%s/%s", owner, repoName)
 }
 
+// BaseBranch returns the branch a pull request targets, from CODEBUILD_WEBHOOK_BASE_REF (without its refs/heads/ prefix).
+func (l *awsCodeBuildMetadata) BaseBranch() string {
+	return strings.TrimPrefix(l.CodebuildWebhookBaseRef, "refs/heads/")
+}
+
+// BuildStartedAt returns the time the build started, from CODEBUILD_START_TIME
+// (in milliseconds since the epoch), or the zero time if it isn't set.
+func (l *awsCodeBuildMetadata) BuildStartedAt() time.Time {
+	ms, err := strconv.ParseInt(l.CodebuildStartTime, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms).UTC()
+}
+
+// JobQueuedAt returns the zero time, since CodeBuild doesn't report when the
+// build was enqueued.
+func (l *awsCodeBuildMetadata) JobQueuedAt() time.Time {
+	return time.Time{}
+}
+
+// JobStartedAt returns the time the build started, since a CodeBuild build
+// runs as a single job.
+func (l *awsCodeBuildMetadata) JobStartedAt() time.Time {
+	return l.BuildStartedAt()
+}
+
 var _ providerMetadata = (*bitbucketMetadata)(nil)
 
 type bitbucketMetadata struct {
@@ -622,6 +1468,11 @@ func (w *bitbucketMetadata) RepoNameWithOwner() string {
 	return fmt.Sprintf("%s/%s", w.BitbucketWorkspace, w.BitbucketRepoSlug)
 }
 
+// BaseBranch returns the branch a pull request targets, from BITBUCKET_PR_DESTINATION_BRANCH.
+func (w *bitbucketMetadata) BaseBranch() string {
+	return w.BitbucketPrDestinationBranch
+}
+
 var _ providerMetadata = (*azurePipelinesMetadata)(nil)
 
 type azurePipelinesMetadata struct {
@@ -653,6 +1504,11 @@ type azurePipelinesMetadata struct {
 	RepositoryGitSubmoduleCheckout string `env:"BUILD_REPOSITORY_GIT_SUBMODULECHECKOUT" yaml:":build_repository_git_submodulecheckout,omitempty"`
 	SourceTFVCShelveSet            string `env:"BUILD_SOURCETFVCSHELVESET" yaml:":build_sourcetfvcshelveset,omitempty"`
 	TeamFoundationCollectionURI    string `env:"SYSTEM_TEAMFOUNDATIONCOLLECTIONURI" yaml:":system_teamfoundationcollectionuri"`
+	TeamProject                    string `env:"SYSTEM_TEAMPROJECT" yaml:":system_teamproject,omitempty"`
+	TeamProjectID                  string `env:"SYSTEM_TEAMPROJECTID" yaml:":system_teamprojectid,omitempty"`
+	DefinitionID                   string `env:"SYSTEM_DEFINITIONID" yaml:":system_definitionid,omitempty"`
+	JobID                          string `env:"SYSTEM_JOBID" yaml:":system_jobid,omitempty"`
+	JobAttempt                     string `env:"SYSTEM_JOBATTEMPT" yaml:":system_jobattempt,omitempty"`
 	TriggeredByBuildID             string `env:"BUILD_TRIGGEREDBY_BUILDID" yaml:":build_triggeredby_buildid,omitempty"`
 	TriggeredByDefinitionID        string `env:"BUILD_TRIGGEREDBY_DEFINITIONID" yaml:":build_triggeredby_definitionid,omitempty"`
 	TriggeredByDefinitionName      string `env:"BUILD_TRIGGEREDBY_DEFINITIONNAME" yaml:":build_triggeredby_definitionname,omitempty"`
@@ -675,7 +1531,14 @@ func (w *azurePipelinesMetadata) Branch() string {
 }
 
 func (w *azurePipelinesMetadata) BuildURL() string {
-	return w.BuildURI
+	// BUILD_BUILDURI is a vstfs:// URI, which isn't viewable in a browser, so
+	// prefer the URL of the build's results page when it can be determined
+	if w.TeamFoundationCollectionURI == "" || w.TeamProject == "" || w.BuildID == "" {
+		return w.BuildURI
+	}
+
+	return fmt.Sprintf("%s/%s/_build/results?buildId=%s",
+		strings.TrimSuffix(w.TeamFoundationCollectionURI, "/"), url.PathEscape(w.TeamProject), url.QueryEscape(w.BuildID))
 }
 
 func (w *azurePipelinesMetadata) CommitSHA() string {
@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"context"
+	"runtime"
+)
+
+// A Runner describes the machine (or container) on which the tests ran. Each
+// field is empty if it can't be determined on the current platform.
+type Runner struct {
+	OSDistribution   string  `yaml:":runner_os_distribution,omitempty"`
+	OSVersion        string  `yaml:":runner_os_version,omitempty"`
+	KernelVersion    string  `yaml:":runner_kernel_version,omitempty"`
+	ContainerRuntime string  `yaml:":runner_container_runtime,omitempty"`
+	KubernetesPod    string  `yaml:":runner_kubernetes_pod,omitempty"`
+	CPUQuota         float64 `yaml:":runner_cgroup_cpu_quota,omitempty"`    // in CPUs
+	MemoryLimit      int64   `yaml:":runner_cgroup_memory_limit,omitempty"` // in bytes
+	PIDsLimit        int64   `yaml:":runner_cgroup_pids_limit,omitempty"`
+	PIDMax           int64   `yaml:":runner_kernel_pid_max,omitempty"`
+	NofileLimit      int64   `yaml:":runner_ulimit_nofile,omitempty"` // soft limit; -1 if unlimited
+	NprocLimit       int64   `yaml:":runner_ulimit_nproc,omitempty"`  // soft limit; -1 if unlimited
+	EntropyAvail     int64   `yaml:":runner_entropy_avail,omitempty"` // in bits
+	CPUCount         int     `yaml:":runner_cpu_count,omitempty"`
+	MemoryTotal      int64   `yaml:":runner_memory_total,omitempty"` // in bytes
+}
+
+func init() {
+	RegisterEnricher(runnerEnricher{})
+}
+
+// runnerEnricher describes the machine on which the reporter is running, which
+// is presumed to be where the tests ran.
+type runnerEnricher struct{}
+
+func (runnerEnricher) Name() string {
+	return "runner"
+}
+
+func (runnerEnricher) Enrich(ctx context.Context, m *Metadata) error {
+	m.Runner = detectRunner(m.envs)
+	m.Runner.CPUCount = runtime.NumCPU()
+	return nil
+}
@@ -0,0 +1,263 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// unlimitedMemory is the threshold above which a cgroup v1 memory limit means
+// there's no limit (the kernel reports the maximum page-aligned int64).
+const unlimitedMemory = 1 << 62
+
+func detectRunner(envs map[string]string) Runner {
+	return readRunner(os.DirFS("/"), envs)
+}
+
+// readRunner describes the Linux machine whose root filesystem is fsys.
+func readRunner(fsys fs.FS, envs map[string]string) Runner {
+	r := Runner{}
+
+	osRelease := readOSRelease(fsys)
+	r.OSDistribution = osRelease["ID"]
+	r.OSVersion = osRelease["VERSION_ID"]
+	r.KernelVersion = readTrimmed(fsys, "proc/sys/kernel/osrelease")
+	r.ContainerRuntime = containerRuntime(fsys, envs)
+
+	if envs["KUBERNETES_SERVICE_HOST"] != "" {
+		r.KubernetesPod = readTrimmed(fsys, "proc/sys/kernel/hostname")
+	}
+
+	r.CPUQuota = cgroupCPUQuota(fsys)
+	r.MemoryLimit = cgroupMemoryLimit(fsys)
+	r.PIDsLimit = cgroupPIDsLimit(fsys)
+	r.PIDMax = readInt(fsys, "proc/sys/kernel/pid_max")
+
+	limits := readLimits(fsys)
+	r.NofileLimit = limits["Max open files"]
+	r.NprocLimit = limits["Max processes"]
+
+	r.EntropyAvail = readInt(fsys, "proc/sys/kernel/random/entropy_avail")
+	r.MemoryTotal = memoryTotal(fsys)
+
+	return r
+}
+
+// readOSRelease returns the fields of the os-release file, which identifies the
+// Linux distribution.
+func readOSRelease(fsys fs.FS) map[string]string {
+	fields := make(map[string]string)
+
+	data, err := fs.ReadFile(fsys, "etc/os-release")
+	if err != nil {
+		data, err = fs.ReadFile(fsys, "usr/lib/os-release")
+		if err != nil {
+			return fields
+		}
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(s.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'`)
+		}
+		fields[key] = value
+	}
+
+	return fields
+}
+
+// containerRuntime returns the name of the container runtime running the
+// reporter, or an empty string if it isn't running in a container.
+func containerRuntime(fsys fs.FS, envs map[string]string) string {
+	// The cgroup and mount paths name the runtime that created the container
+	var hints []byte
+	for _, name := range []string{"proc/1/cgroup", "proc/self/cgroup", "proc/self/mountinfo"} {
+		data, _ := fs.ReadFile(fsys, name)
+		hints = append(hints, data...)
+	}
+
+	switch {
+	case bytes.Contains(hints, []byte("cri-containerd")) || bytes.Contains(hints, []byte("/containerd/")):
+		return "containerd"
+	case bytes.Contains(hints, []byte("crio-")):
+		return "cri-o"
+	case bytes.Contains(hints, []byte("libpod")):
+		return "podman"
+	case bytes.Contains(hints, []byte("/docker/")) || bytes.Contains(hints, []byte("/var/lib/docker/")):
+		return "docker"
+	case bytes.Contains(hints, []byte("/lxc/")):
+		return "lxc"
+	}
+
+	// Fall back to the marker files and environment variable that runtimes
+	// conventionally provide
+	switch {
+	case fileExists(fsys, ".dockerenv"):
+		return "docker"
+	case fileExists(fsys, "run/.containerenv"):
+		return "podman"
+	case envs["container"] != "":
+		return envs["container"]
+	default:
+		return ""
+	}
+}
+
+// cgroupCPUQuota returns the number of CPUs available to the cgroup, or zero if
+// the cgroup's CPU usage isn't limited.
+func cgroupCPUQuota(fsys fs.FS) float64 {
+	// cgroup v2: "<quota> <period>", where the quota is "max" if unlimited
+	if fields := strings.Fields(readTrimmed(fsys, "sys/fs/cgroup/cpu.max")); len(fields) == 2 {
+		return cpuQuota(fields[0], fields[1])
+	}
+
+	// cgroup v1: the quota is -1 if unlimited
+	return cpuQuota(
+		readTrimmed(fsys, "sys/fs/cgroup/cpu/cpu.cfs_quota_us"),
+		readTrimmed(fsys, "sys/fs/cgroup/cpu/cpu.cfs_period_us"),
+	)
+}
+
+func cpuQuota(quota string, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+
+	return q / p
+}
+
+// cgroupMemoryLimit returns the memory limit of the cgroup in bytes, or zero if
+// the cgroup's memory usage isn't limited.
+func cgroupMemoryLimit(fsys fs.FS) int64 {
+	for _, name := range []string{"sys/fs/cgroup/memory.max", "sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		value := readTrimmed(fsys, name)
+		if value == "" {
+			continue
+		}
+
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit >= unlimitedMemory {
+			return 0 // e.g., "max"
+		}
+
+		return limit
+	}
+
+	return 0
+}
+
+// cgroupPIDsLimit returns the maximum number of processes in the cgroup, or
+// zero if the number isn't limited.
+func cgroupPIDsLimit(fsys fs.FS) int64 {
+	for _, name := range []string{"sys/fs/cgroup/pids.max", "sys/fs/cgroup/pids/pids.max"} {
+		if limit := readInt(fsys, name); limit > 0 {
+			return limit // "max" if unlimited
+		}
+	}
+
+	return 0
+}
+
+// memoryTotal returns the total memory of the machine in bytes (regardless of
+// any cgroup limit), or zero if it can't be determined.
+func memoryTotal(fsys fs.FS) int64 {
+	data, err := fs.ReadFile(fsys, "proc/meminfo")
+	if err != nil {
+		return 0
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		// e.g., "MemTotal:       16318480 kB"
+		fields := strings.Fields(s.Text())
+		if len(fields) != 3 || fields[0] != "MemTotal:" || fields[2] != "kB" {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+
+	return 0
+}
+
+// readLimits returns the soft resource limits of the reporter's process, keyed
+// by the names in /proc/self/limits (e.g., "Max open files"). Unlimited
+// resources have a limit of -1. The process inherits the limits of the shell
+// that runs it, and hence those of the tests.
+func readLimits(fsys fs.FS) map[string]int64 {
+	limits := make(map[string]int64)
+
+	data, err := fs.ReadFile(fsys, "proc/self/limits")
+	if err != nil {
+		return limits
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		// The columns are aligned: "Max open files            1024                 1048576              files"
+		line := s.Text()
+		if len(line) < 26 {
+			continue
+		}
+		name := strings.TrimSpace(line[:26])
+		fields := strings.Fields(line[26:])
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "unlimited" {
+			limits[name] = -1
+		} else if limit, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			limits[name] = limit
+		}
+	}
+
+	return limits
+}
+
+// readInt returns the integer in the named file, or zero if the file can't be
+// read or doesn't hold an integer.
+func readInt(fsys fs.FS, name string) int64 {
+	n, err := strconv.ParseInt(readTrimmed(fsys, name), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// readTrimmed returns the contents of the named file without surrounding
+// whitespace, or an empty string if the file can't be read.
+func readTrimmed(fsys fs.FS, name string) string {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
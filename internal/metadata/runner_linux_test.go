@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_readRunner(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		envs map[string]string
+		want Runner
+	}{
+		{
+			name: "docker with cgroup v2 limits",
+			fsys: fstest.MapFS{
+				".dockerenv":                {Data: []byte{}},
+				"etc/os-release":            {Data: []byte("PRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\nNAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nID=ubuntu\n")},
+				"proc/1/cgroup":             {Data: []byte("0::/\n")},
+				"proc/sys/kernel/osrelease": {Data: []byte("6.5.0-1015-azure\n")},
+				"sys/fs/cgroup/cpu.max":     {Data: []byte("200000 100000\n")},
+				"sys/fs/cgroup/memory.max":  {Data: []byte("4294967296\n")},
+				"sys/fs/cgroup/pids.max":    {Data: []byte("4096\n")},
+				"proc/sys/kernel/pid_max":   {Data: []byte("4194304\n")},
+				"proc/self/limits": {Data: []byte("" +
+					"Limit                     Soft Limit           Hard Limit           Units     \n" +
+					"Max cpu time              unlimited            unlimited            seconds   \n" +
+					"Max processes             unlimited            unlimited            processes \n" +
+					"Max open files            1024                 1048576              files     \n")},
+				"proc/sys/kernel/random/entropy_avail": {Data: []byte("256\n")},
+				"proc/meminfo":                         {Data: []byte("MemTotal:       16318480 kB\nMemFree:         1234567 kB\n")},
+			},
+			envs: map[string]string{},
+			want: Runner{
+				OSDistribution:   "ubuntu",
+				OSVersion:        "22.04",
+				KernelVersion:    "6.5.0-1015-azure",
+				ContainerRuntime: "docker",
+				CPUQuota:         2,
+				MemoryLimit:      4294967296,
+				PIDsLimit:        4096,
+				PIDMax:           4194304,
+				NofileLimit:      1024,
+				NprocLimit:       -1,
+				EntropyAvail:     256,
+				MemoryTotal:      16710123520,
+			},
+		},
+		{
+			name: "kubernetes pod with cgroup v1 limits",
+			fsys: fstest.MapFS{
+				"etc/os-release":                             {Data: []byte("ID=alpine\nVERSION_ID=3.19.0\n")},
+				"proc/1/cgroup":                              {Data: []byte("12:memory:/kubepods/burstable/pod1234/cri-containerd-abcd\n")},
+				"proc/sys/kernel/hostname":                   {Data: []byte("ci-runner-7d9f8-xk2lp\n")},
+				"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         {Data: []byte("50000\n")},
+				"sys/fs/cgroup/cpu/cpu.cfs_period_us":        {Data: []byte("100000\n")},
+				"sys/fs/cgroup/memory/memory.limit_in_bytes": {Data: []byte("9223372036854771712\n")},
+				"sys/fs/cgroup/pids/pids.max":                {Data: []byte("max\n")},
+				"proc/self/limits": {Data: []byte("" +
+					"Limit                     Soft Limit           Hard Limit           Units     \n" +
+					"Max processes             63704                63704                processes \n")},
+			},
+			envs: map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"},
+			want: Runner{
+				OSDistribution:   "alpine",
+				OSVersion:        "3.19.0",
+				ContainerRuntime: "containerd",
+				KubernetesPod:    "ci-runner-7d9f8-xk2lp",
+				CPUQuota:         0.5,
+				NprocLimit:       63704,
+			},
+		},
+		{
+			name: "bare metal without limits",
+			fsys: fstest.MapFS{
+				"usr/lib/os-release":       {Data: []byte("ID=fedora\nVERSION_ID=39\n")},
+				"sys/fs/cgroup/cpu.max":    {Data: []byte("max 100000\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("max\n")},
+				"proc/self/mountinfo":      {Data: []byte("22 1 259:2 / / rw,relatime - ext4 /dev/nvme0n1p2 rw\n")},
+			},
+			envs: map[string]string{},
+			want: Runner{
+				OSDistribution: "fedora",
+				OSVersion:      "39",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, readRunner(tt.fsys, tt.envs))
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package metadata
+
+func detectRunner(envs map[string]string) Runner {
+	return Runner{}
+}
@@ -0,0 +1,121 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the version of the schema of buildpulse.yml, recorded in
+// each bundle as :schema_version. Metadata written before the field existed is
+// version 0.
+//
+// Adding an optional field doesn't change the version. Removing or renaming a
+// field, or changing its type or meaning, does: bump SchemaVersion, describe
+// the change in schemaChanges, and update metadata.schema.json, so that
+// UpgradeYAML can read the metadata of every earlier version.
+const SchemaVersion = 1
+
+// A schemaChange describes how the fields of buildpulse.yml changed in a
+// version of its schema, relative to the version before it.
+type schemaChange struct {
+	version int
+	renamed map[string]string // new name, keyed by old name
+	removed []string
+}
+
+// schemaChanges lists the changes to the schema, in order of version. The last
+// one is for SchemaVersion.
+var schemaChanges = []schemaChange{
+	// Version 1 added :schema_version itself
+	{version: 1},
+}
+
+// UpgradeYAML returns the metadata in data (a buildpulse.yml of any version of
+// the schema) as metadata of the current version, applying the changes of each
+// later version in turn. It returns an error if data isn't a YAML mapping, or
+// if it's of a later version than this reporter knows.
+func UpgradeYAML(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("metadata isn't a YAML mapping")
+	}
+	m := doc.Content[0]
+	latest := schemaChanges[len(schemaChanges)-1].version
+
+	version := 0
+	if v := mappingValue(m, ":schema_version"); v != nil {
+		n, err := strconv.Atoi(v.Value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value \"%s\" for :schema_version", v.Value)
+		}
+		version = n
+	}
+	if version > latest {
+		return nil, fmt.Errorf("unsupported :schema_version %d: the latest version supported by this reporter is %d", version, latest)
+	}
+
+	for _, c := range schemaChanges {
+		if c.version <= version {
+			continue
+		}
+		for from, to := range c.renamed {
+			if k := mappingKey(m, from); k != nil {
+				k.Value = to
+			}
+		}
+		for _, name := range c.removed {
+			deleteMappingKey(m, name)
+		}
+	}
+
+	if v := mappingValue(m, ":schema_version"); v != nil {
+		v.Value = strconv.Itoa(latest)
+	} else {
+		m.Content = append(m.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ":schema_version"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(latest)},
+		)
+	}
+
+	return yaml.Marshal(&doc)
+}
+
+// mappingKey returns the node of the given key in the mapping node m, or nil
+// if m doesn't have the key.
+func mappingKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i]
+		}
+	}
+
+	return nil
+}
+
+// mappingValue returns the node of the value of the given key in the mapping
+// node m, or nil if m doesn't have the key.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// deleteMappingKey removes the given key (and its value) from the mapping node
+// m, if present.
+func deleteMappingKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaChanges(t *testing.T) {
+	for i, c := range schemaChanges {
+		assert.Equal(t, i+1, c.version, "version of change %d", i)
+	}
+	assert.Equal(t, SchemaVersion, schemaChanges[len(schemaChanges)-1].version)
+}
+
+func TestUpgradeYAML(t *testing.T) {
+	t.Run("Version0", func(t *testing.T) {
+		data, err := UpgradeYAML([]byte(":branch: main\n:timestamp: 2020-07-11T01:02:03Z\n"))
+		require.NoError(t, err)
+		assert.Equal(t, ":branch: main\n:timestamp: 2020-07-11T01:02:03Z\n:schema_version: 1\n", string(data))
+	})
+
+	t.Run("CurrentVersion", func(t *testing.T) {
+		data, err := UpgradeYAML([]byte(":branch: main\n:schema_version: 1\n"))
+		require.NoError(t, err)
+		assert.Equal(t, ":branch: main\n:schema_version: 1\n", string(data))
+	})
+
+	t.Run("LaterVersion", func(t *testing.T) {
+		_, err := UpgradeYAML([]byte(":branch: main\n:schema_version: 99\n"))
+		assert.EqualError(t, err, "unsupported :schema_version 99: the latest version supported by this reporter is 1")
+	})
+
+	t.Run("MalformedVersion", func(t *testing.T) {
+		_, err := UpgradeYAML([]byte(":schema_version: one\n"))
+		assert.EqualError(t, err, `invalid value "one" for :schema_version`)
+	})
+
+	t.Run("NotMapping", func(t *testing.T) {
+		_, err := UpgradeYAML([]byte("- main\n"))
+		assert.EqualError(t, err, "metadata isn't a YAML mapping")
+	})
+}
+
+func TestUpgradeYAML_changes(t *testing.T) {
+	defer func(changes []schemaChange) { schemaChanges = changes }(schemaChanges)
+	schemaChanges = append(schemaChanges[:len(schemaChanges):len(schemaChanges)],
+		schemaChange{version: 2, renamed: map[string]string{":tree": ":tree_sha"}, removed: []string{":quota_id"}},
+	)
+
+	// The changes of every version after the metadata's are applied in turn
+	data, err := UpgradeYAML([]byte(":quota_id: q\n:tree: abc\n"))
+	require.NoError(t, err)
+	assert.Equal(t, ":tree_sha: abc\n:schema_version: 2\n", string(data))
+
+	// Only the changes of later versions are applied
+	data, err = UpgradeYAML([]byte(":quota_id: q\n:tree_sha: abc\n:schema_version: 2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, ":quota_id: q\n:tree_sha: abc\n:schema_version: 2\n", string(data))
+}
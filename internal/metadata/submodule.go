@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// Submodules returns the SHA of the commit checked out for each submodule of
+// the repository located at path, keyed by the submodule's path. For a
+// submodule that isn't checked out (i.e., it hasn't been initialized), the SHA
+// is the one recorded in the repository's index. Nested submodules aren't
+// included. If there's no repository at path, Submodules returns nil.
+func Submodules(path string) (map[string]string, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := wt.Submodules()
+	if err != nil {
+		return nil, err
+	}
+
+	var revs map[string]string
+	for _, sub := range subs {
+		status, err := sub.Status()
+		if err != nil {
+			return nil, err
+		}
+
+		sha := status.Current
+		if sha.IsZero() {
+			sha = status.Expected
+		}
+		if sha.IsZero() {
+			continue
+		}
+
+		if revs == nil {
+			revs = make(map[string]string)
+		}
+		revs[status.Path] = sha.String()
+	}
+
+	return revs, nil
+}
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmodules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	sub := t.TempDir()
+	runGit(t, sub, "init", "--quiet")
+	runGit(t, sub, "commit", "--quiet", "--allow-empty", "-m", "First")
+	first := runGit(t, sub, "rev-parse", "HEAD")
+	runGit(t, sub, "commit", "--quiet", "--allow-empty", "-m", "Second")
+	second := runGit(t, sub, "rev-parse", "HEAD")
+
+	origin := t.TempDir()
+	runGit(t, origin, "init", "--quiet")
+	runGit(t, origin, "-c", "protocol.file.allow=always", "submodule", "--quiet", "add", "file://"+sub, "libs/sub")
+	runGit(t, origin, "commit", "--quiet", "-m", "Add submodule")
+
+	t.Run("CheckedOut", func(t *testing.T) {
+		runGit(t, origin+"/libs/sub", "checkout", "--quiet", first)
+
+		subs, err := Submodules(origin)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"libs/sub": first}, subs)
+	})
+
+	t.Run("NotInitialized", func(t *testing.T) {
+		dir := t.TempDir()
+		runGit(t, dir, "clone", "--quiet", "file://"+origin, ".")
+
+		subs, err := Submodules(dir)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"libs/sub": second}, subs)
+	})
+
+	t.Run("NoRepository", func(t *testing.T) {
+		subs, err := Submodules(t.TempDir())
+		require.NoError(t, err)
+		assert.Nil(t, subs)
+	})
+}
@@ -0,0 +1,63 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dimensionRegex matches the (normalized) dimension of a structured tag: a
+// lowercase identifier, such as "os" or "ruby_version".
+var dimensionRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ParseTag splits a structured tag of the form "dim:value" (e.g., "os:linux"
+// or "ruby:3.3") into its dimension and value. The dimension is normalized to
+// lowercase, with hyphens replaced by underscores (e.g., "Node-Version:20"
+// has the dimension "node_version"). The value is everything after the first
+// colon, as given.
+//
+// It returns ok=false for a free-form tag (i.e., one without a colon), and an
+// error if the tag has a colon but isn't a valid structured tag.
+func ParseTag(tag string) (dim string, value string, ok bool, err error) {
+	dim, value, ok = strings.Cut(tag, ":")
+	if !ok {
+		return "", "", false, nil
+	}
+
+	dim = strings.ReplaceAll(strings.ToLower(dim), "-", "_")
+	if !dimensionRegex.MatchString(dim) {
+		return "", "", false, fmt.Errorf("invalid tag \"%s\": dimension should start with a letter and contain only letters, digits, hyphens, and underscores", tag)
+	}
+	if value == "" {
+		return "", "", false, fmt.Errorf("invalid tag \"%s\": missing value for dimension %s", tag, dim)
+	}
+
+	return dim, value, true, nil
+}
+
+// Dimensions returns the dimensions of the structured tags among tags, keyed by
+// their normalized names, or nil if there are none. Free-form tags are
+// ignored. It returns an error if a structured tag is malformed, or if tags
+// give different values for the same dimension.
+func Dimensions(tags []string) (map[string]string, error) {
+	var dims map[string]string
+	for _, tag := range tags {
+		dim, value, ok, err := ParseTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if dims == nil {
+			dims = make(map[string]string)
+		}
+		if prev, seen := dims[dim]; seen && prev != value {
+			return nil, fmt.Errorf("conflicting tags for dimension %s: %s and %s", dim, prev, value)
+		}
+		dims[dim] = value
+	}
+
+	return dims, nil
+}
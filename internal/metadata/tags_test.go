@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag   string
+		dim   string
+		value string
+		ok    bool
+		err   string
+	}{
+		{tag: "nightly"},
+		{tag: "", ok: false},
+		{tag: "os:linux", dim: "os", value: "linux", ok: true},
+		{tag: "ruby:3.3", dim: "ruby", value: "3.3", ok: true},
+		{tag: "Node-Version:20", dim: "node_version", value: "20", ok: true},
+		{tag: "image:ruby:3.3-slim", dim: "image", value: "ruby:3.3-slim", ok: true},
+		{tag: "os:", err: `invalid tag "os:": missing value for dimension os`},
+		{tag: ":linux", err: `invalid tag ":linux": dimension should start with a letter and contain only letters, digits, hyphens, and underscores`},
+		{tag: "3d:yes", err: `invalid tag "3d:yes": dimension should start with a letter and contain only letters, digits, hyphens, and underscores`},
+		{tag: "o.s:linux", err: `invalid tag "o.s:linux": dimension should start with a letter and contain only letters, digits, hyphens, and underscores`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			dim, value, ok, err := ParseTag(tt.tag)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.dim, dim)
+			assert.Equal(t, tt.value, value)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	dims, err := Dimensions([]string{"nightly", "os:linux", "OS:linux", "ruby:3.3"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"os": "linux", "ruby": "3.3"}, dims)
+
+	dims, err = Dimensions([]string{"nightly", ""})
+	assert.NoError(t, err)
+	assert.Nil(t, dims)
+
+	_, err = Dimensions([]string{"os:linux", "os:macos"})
+	assert.EqualError(t, err, "conflicting tags for dimension os: linux and macos")
+}
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// traceparentRegex matches a W3C traceparent header (see
+// https://www.w3.org/TR/trace-context/#traceparent-header): the version, trace
+// ID, parent ID, and trace flags. Versions after 00 may append more fields.
+var traceparentRegex = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// TraceContext returns the W3C trace context of the CI job, as given by the
+// TRACEPARENT and TRACESTATE environment variables (which CI observability
+// tools set so that the job's steps can join the pipeline's trace). It returns
+// empty strings if TRACEPARENT isn't set, and an error if it's malformed.
+func TraceContext(envs map[string]string) (traceparent string, tracestate string, err error) {
+	traceparent = strings.TrimSpace(envs["TRACEPARENT"])
+	if traceparent == "" {
+		return "", "", nil
+	}
+
+	m := traceparentRegex.FindStringSubmatch(traceparent)
+	switch {
+	case m == nil:
+		return "", "", fmt.Errorf("invalid value \"%s\" for environment variable TRACEPARENT: should be a W3C traceparent (e.g., 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01)", traceparent)
+	case m[1] == "ff":
+		return "", "", fmt.Errorf("invalid value \"%s\" for environment variable TRACEPARENT: version ff is invalid", traceparent)
+	case m[1] == "00" && m[5] != "":
+		return "", "", fmt.Errorf("invalid value \"%s\" for environment variable TRACEPARENT: version 00 has no fields after the trace flags", traceparent)
+	case m[2] == strings.Repeat("0", 32):
+		return "", "", fmt.Errorf("invalid value \"%s\" for environment variable TRACEPARENT: trace ID is all zeros", traceparent)
+	case m[3] == strings.Repeat("0", 16):
+		return "", "", fmt.Errorf("invalid value \"%s\" for environment variable TRACEPARENT: parent ID is all zeros", traceparent)
+	}
+
+	return traceparent, strings.TrimSpace(envs["TRACESTATE"]), nil
+}
+
+func (m *Metadata) initTraceContext(envs map[string]string) {
+	traceparent, tracestate, err := TraceContext(envs)
+	if err != nil {
+		m.logger.Printf("⚠️ Ignoring trace context: %v", err)
+		return
+	}
+
+	m.TraceParent = traceparent
+	m.TraceState = tracestate
+}
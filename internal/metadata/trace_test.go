@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceContext(t *testing.T) {
+	tests := []struct {
+		name            string
+		envs            map[string]string
+		wantTraceparent string
+		wantTracestate  string
+		wantErr         string
+	}{
+		{
+			name: "not set",
+			envs: map[string]string{"TRACESTATE": "vendor=value"},
+		},
+		{
+			name:            "traceparent only",
+			envs:            map[string]string{"TRACEPARENT": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name: "traceparent and tracestate",
+			envs: map[string]string{
+				"TRACEPARENT": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"TRACESTATE":  "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE",
+			},
+			wantTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantTracestate:  "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE",
+		},
+		{
+			name:            "future version with more fields",
+			envs:            map[string]string{"TRACEPARENT": "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+			wantTraceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		},
+		{
+			name:    "malformed",
+			envs:    map[string]string{"TRACEPARENT": "not-a-traceparent"},
+			wantErr: `invalid value "not-a-traceparent" for environment variable TRACEPARENT: should be a W3C traceparent (e.g., 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01)`,
+		},
+		{
+			name:    "invalid version",
+			envs:    map[string]string{"TRACEPARENT": "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantErr: `invalid value "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" for environment variable TRACEPARENT: version ff is invalid`,
+		},
+		{
+			name:    "version 00 with more fields",
+			envs:    map[string]string{"TRACEPARENT": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+			wantErr: `invalid value "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra" for environment variable TRACEPARENT: version 00 has no fields after the trace flags`,
+		},
+		{
+			name:    "zero trace ID",
+			envs:    map[string]string{"TRACEPARENT": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+			wantErr: `invalid value "00-00000000000000000000000000000000-00f067aa0ba902b7-01" for environment variable TRACEPARENT: trace ID is all zeros`,
+		},
+		{
+			name:    "zero parent ID",
+			envs:    map[string]string{"TRACEPARENT": "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+			wantErr: `invalid value "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01" for environment variable TRACEPARENT: parent ID is all zeros`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traceparent, tracestate, err := TraceContext(tt.envs)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTraceparent, traceparent)
+			assert.Equal(t, tt.wantTracestate, tracestate)
+		})
+	}
+}
+
+func TestNewMetadata_traceContext(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"TRACEPARENT":    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"TRACESTATE":     "rojo=00f067aa0ba902b7",
+	}
+	meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", newCommitResolverStub(), time.Now, logger.New())
+	require.NoError(t, err)
+
+	yaml, err := meta.MarshalYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(yaml), ":traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\n:tracestate: rojo=00f067aa0ba902b7\n")
+}
+
+func TestNewMetadata_invalidTraceContext(t *testing.T) {
+	envs := map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"TRACEPARENT":    "bogus",
+	}
+	log := logger.New()
+	meta, err := NewMetadata(context.Background(), &Version{}, envs, []string{}, "", newCommitResolverStub(), time.Now, log)
+	require.NoError(t, err)
+	assert.Empty(t, meta.TraceParent)
+	assert.Contains(t, log.Text(), `⚠️ Ignoring trace context: invalid value "bogus" for environment variable TRACEPARENT`)
+}
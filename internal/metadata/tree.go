@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// HashTree returns the SHA-1 hash of the git tree object for the files in dir,
+// computed the same way as `git add --all && git write-tree` would in a fresh
+// repository. It's for identifying the source of a build when no git clone is
+// available (e.g., in a container built from a copy of the source), so the
+// result only matches the commit's tree if dir holds exactly the committed
+// files. Files excluded by .gitignore files in dir are skipped, as are empty
+// directories and the .git directory.
+func HashTree(dir string) (string, error) {
+	h, ok, err := hashDir(dir, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("no files found in %s", dir)
+	}
+
+	return h.String(), nil
+}
+
+// A treeEntry is an entry of a git tree object.
+type treeEntry struct {
+	mode string
+	name string
+	hash plumbing.Hash
+}
+
+// hashDir returns the hash of the tree for the directory at path (whose path
+// relative to the root is given by rel), and whether the tree has any entries.
+// Git doesn't record empty trees, so they're left out of the parent tree.
+func hashDir(path string, rel []string, patterns []gitignore.Pattern) (plumbing.Hash, bool, error) {
+	patterns, err := readGitignore(path, rel, patterns)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	matcher := gitignore.NewMatcher(patterns)
+
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	var entries []treeEntry
+	for _, f := range files {
+		if f.Name() == ".git" {
+			continue
+		}
+
+		p := filepath.Join(path, f.Name())
+		r := append(append([]string(nil), rel...), f.Name())
+		if matcher.Match(r, f.IsDir()) {
+			continue
+		}
+
+		info, err := os.Lstat(p)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			h, ok, err := hashDir(p, r, patterns)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			if ok {
+				entries = append(entries, treeEntry{mode: "40000", name: f.Name(), hash: h})
+			}
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			h := plumbing.ComputeHash(plumbing.BlobObject, []byte(filepath.ToSlash(target)))
+			entries = append(entries, treeEntry{mode: "120000", name: f.Name(), hash: h})
+		case mode.IsRegular():
+			h, err := hashFile(p, info.Size())
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			m := "100644"
+			if mode&0111 != 0 {
+				m = "100755"
+			}
+			entries = append(entries, treeEntry{mode: m, name: f.Name(), hash: h})
+		}
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, false, nil
+	}
+
+	// Git sorts the entries by name, comparing the name of each subtree as if
+	// it ended with a slash
+	sortKey := func(e treeEntry) string {
+		if e.mode == "40000" {
+			return e.name + "/"
+		}
+		return e.name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%s %s\x00", e.mode, e.name)
+		buf.Write(e.hash[:])
+	}
+
+	return plumbing.ComputeHash(plumbing.TreeObject, buf.Bytes()), true, nil
+}
+
+// hashFile returns the hash of the git blob object for the file at path.
+func hashFile(path string, size int64) (plumbing.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer f.Close()
+
+	h := plumbing.NewHasher(plumbing.BlobObject, size)
+	if _, err := io.Copy(h, f); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h.Sum(), nil
+}
+
+// readGitignore appends the patterns in the .gitignore file in the directory at
+// path (if any) to patterns.
+func readGitignore(path string, rel []string, patterns []gitignore.Pattern) ([]gitignore.Pattern, error) {
+	f, err := os.Open(filepath.Join(path, ".gitignore"))
+	if os.IsNotExist(err) {
+		return patterns, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns = append([]gitignore.Pattern(nil), patterns...)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") && strings.TrimSpace(line) != "" {
+			patterns = append(patterns, gitignore.ParsePattern(line, rel))
+		}
+	}
+
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":      "hello\n",
+		"a-b":        "x",
+		"a/b/c.txt":  "x",
+		"a/junk.tmp": "z\n", // ignored
+		"logs/out":   "y\n", // ignored
+		".gitignore": "logs/\n*.tmp\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "link")))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "empty"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git", "objects"), 0755))
+
+	// The value of `git add --all && git write-tree` for the same files
+	tree, err := HashTree(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "cb9e2eb48eabcb528a896c201c05daa46c6d1f82", tree)
+}
+
+func TestHashTree_emptyDir(t *testing.T) {
+	_, err := HashTree(t.TempDir())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no files found")
+	}
+}
@@ -9,11 +9,17 @@ type Version struct {
 	Commit    string
 	Number    string
 	GoOS      string
+	GoArch    string
 	GoVersion string
 }
 
 // String returns a formatted description of the CLI version, suitable for use
 // in response to the `--version` flag.
 func (v *Version) String() string {
-	return fmt.Sprintf("BuildPulse Test Reporter %s (%s %s %s)\n", v.Number, v.GoOS, v.Commit, v.GoVersion)
+	platform := v.GoOS
+	if v.GoArch != "" {
+		platform += "/" + v.GoArch
+	}
+
+	return fmt.Sprintf("BuildPulse Test Reporter %s (%s %s %s)\n", v.Number, platform, v.Commit, v.GoVersion)
 }
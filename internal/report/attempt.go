@@ -0,0 +1,135 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Signature identifies the test suites and tests in a JUnit report, so that
+// the reports produced by successive attempts of a retried CI step can be
+// recognized: each attempt reports the same suites and tests, at a later time.
+type Signature struct {
+	// Suites lists the names of the suites in the report, sorted and without
+	// duplicates.
+	Suites []string
+
+	// Tests lists the tests in the report, each as its classname and name
+	// joined by "#", sorted and without duplicates. Reports from unrelated
+	// steps can share their suites' names (e.g., pytest names its only suite
+	// "pytest"), but not their tests.
+	Tests []string
+
+	// Timestamp is the earliest timestamp of the suites in the report, or the
+	// zero time if none of them has a timestamp.
+	Timestamp time.Time
+}
+
+// timestampLayouts lists the formats of the timestamp attribute written by
+// common JUnit reporters. Timestamps without a time zone are treated as local
+// time, which is what the reporters that omit the zone (e.g., Maven Surefire)
+// write.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// ReadSignature returns the signature of the JUnit report read from r.
+func ReadSignature(r io.Reader) (*Signature, error) {
+	d := newDecoder(r)
+
+	names := make(map[string]bool)
+	tests := make(map[string]bool)
+	sig := &Signature{}
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if ok && se.Name.Local == "testcase" {
+			classname, _ := attr(&se, "classname")
+			name, _ := attr(&se, "name")
+			tests[classname+"#"+name] = true
+			continue
+		}
+		if !ok || se.Name.Local != "testsuite" {
+			continue
+		}
+
+		name, _ := attr(&se, "name")
+		names[name] = true
+
+		value, _ := attr(&se, "timestamp")
+		if ts, ok := parseTimestamp(value); ok && (sig.Timestamp.IsZero() || ts.Before(sig.Timestamp)) {
+			sig.Timestamp = ts
+		}
+	}
+
+	for name := range names {
+		sig.Suites = append(sig.Suites, name)
+	}
+	sort.Strings(sig.Suites)
+
+	for test := range tests {
+		sig.Tests = append(sig.Tests, test)
+	}
+	sort.Strings(sig.Tests)
+
+	return sig, nil
+}
+
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if ts, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return ts, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// GroupAttempts groups the reports (keyed by path) whose signatures list the
+// same suites and the same tests, and returns each group of two or more reports
+// ordered from the earliest attempt to the latest. Reports without any suites
+// or tests aren't grouped.
+func GroupAttempts(sigs map[string]*Signature) [][]string {
+	byKey := make(map[string][]string)
+	for path, sig := range sigs {
+		if len(sig.Suites) == 0 || len(sig.Tests) == 0 {
+			continue
+		}
+
+		key := strings.Join(sig.Suites, "\x00") + "\x01" + strings.Join(sig.Tests, "\x00")
+		byKey[key] = append(byKey[key], path)
+	}
+
+	var groups [][]string
+	for _, paths := range byKey {
+		if len(paths) < 2 {
+			continue
+		}
+
+		sort.Slice(paths, func(i, j int) bool {
+			ti, tj := sigs[paths[i]].Timestamp, sigs[paths[j]].Timestamp
+			if ti.Equal(tj) {
+				return paths[i] < paths[j]
+			}
+			return ti.Before(tj)
+		})
+		groups = append(groups, paths)
+	}
+
+	// Order the groups deterministically by their first report
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	return groups
+}
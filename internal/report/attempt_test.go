@@ -0,0 +1,92 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSignature(t *testing.T) {
+	defer func(saved *time.Location) { time.Local = saved }(time.Local)
+	time.Local = time.UTC
+
+	tests := []struct {
+		name   string
+		report string
+		want   *Signature
+	}{
+		{
+			name: "multiple suites",
+			report: `<testsuites>
+  <testsuite name="b" timestamp="2020-07-11T01:05:09"><testcase name="x"/></testsuite>
+  <testsuite name="a" timestamp="2020-07-11T01:02:03Z"><testcase classname="a" name="y"/></testsuite>
+  <testsuite name="b" timestamp="bogus"><testcase name="z"/><testcase name="x"/></testsuite>
+</testsuites>`,
+			want: &Signature{
+				Suites:    []string{"a", "b"},
+				Tests:     []string{"#x", "#z", "a#y"},
+				Timestamp: time.Date(2020, 7, 11, 1, 2, 3, 0, time.UTC),
+			},
+		},
+		{
+			name:   "without timestamps",
+			report: `<testsuite name="rspec"><testcase name="x"/></testsuite>`,
+			want:   &Signature{Suites: []string{"rspec"}, Tests: []string{"#x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadSignature(strings.NewReader(tt.report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadSignature_localTime(t *testing.T) {
+	defer func(saved *time.Location) { time.Local = saved }(time.Local)
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+
+	got, err := ReadSignature(strings.NewReader(`<testsuite name="rspec" timestamp="2020-07-11T01:02:03"><testcase name="x"/></testsuite>`))
+	require.NoError(t, err)
+	assert.True(t, got.Timestamp.Equal(time.Date(2020, 7, 11, 6, 2, 3, 0, time.UTC)), "got %s", got.Timestamp)
+}
+
+func TestGroupAttempts(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2020, 7, 11, 1, minute, 0, 0, time.UTC)
+	}
+
+	groups := GroupAttempts(map[string]*Signature{
+		"retry-2/junit.xml": {Suites: []string{"a", "b"}, Tests: []string{"a#x", "b#y"}, Timestamp: at(9)},
+		"retry-1/junit.xml": {Suites: []string{"a", "b"}, Tests: []string{"a#x", "b#y"}, Timestamp: at(5)},
+		"junit.xml":         {Suites: []string{"a", "b"}, Tests: []string{"a#x", "b#y"}, Timestamp: at(2)},
+		"lint.xml":          {Suites: []string{"lint"}, Tests: []string{"lint#x"}, Timestamp: at(1)},
+		"partial.xml":       {Suites: []string{"a"}, Tests: []string{"a#x"}, Timestamp: at(3)},
+		"no-tests-1.xml":    {Suites: []string{"c"}},
+		"no-tests-2.xml":    {Suites: []string{"c"}},
+		"empty-1.xml":       {},
+		"empty-2.xml":       {},
+	})
+
+	assert.Equal(t, [][]string{{"junit.xml", "retry-1/junit.xml", "retry-2/junit.xml"}}, groups)
+}
+
+func TestGroupAttempts_distinctTests(t *testing.T) {
+	// pytest names the only suite in every report "pytest", so the reports of
+	// unrelated steps are told apart by their tests
+	sigs := make(map[string]*Signature)
+	for path, report := range map[string]string{
+		"unit.xml": `<testsuites><testsuite name="pytest"><testcase classname="tests.test_models" name="test_save"/></testsuite></testsuites>`,
+		"api.xml":  `<testsuites><testsuite name="pytest"><testcase classname="tests.test_api" name="test_get"/></testsuite></testsuites>`,
+	} {
+		sig, err := ReadSignature(strings.NewReader(report))
+		require.NoError(t, err)
+		sigs[path] = sig
+	}
+
+	assert.Empty(t, GroupAttempts(sigs))
+}
@@ -0,0 +1,142 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// declarationRegex matches the XML declaration at the start of a document.
+var declarationRegex = regexp.MustCompile(`^\s*<\?xml\b[^>]*\?>`)
+
+// encodingAttrRegex matches the encoding attribute of an XML declaration,
+// capturing its value.
+var encodingAttrRegex = regexp.MustCompile(`\bencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// peekSize is the number of bytes inspected to find the XML declaration.
+const peekSize = 1024
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// XMLEncoding returns the name of the character encoding of the XML document
+// read from r, as indicated by its byte order mark or XML declaration. Documents
+// that indicate neither are encoded as UTF-8.
+func XMLEncoding(r io.Reader) (string, error) {
+	name, _, err := detectEncoding(bufio.NewReaderSize(r, peekSize))
+	return name, err
+}
+
+// IsUTF8 returns true if the named character encoding is UTF-8 (or its subset,
+// US-ASCII); false, otherwise.
+func IsUTF8(name string) bool {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return true
+	default:
+		return false
+	}
+}
+
+// ToUTF8 copies the XML document read from r to w, transcoding it to UTF-8 and
+// updating its XML declaration accordingly.
+func ToUTF8(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(r, peekSize)
+
+	name, enc, err := detectEncoding(br)
+	if err != nil {
+		return err
+	}
+	if IsUTF8(name) {
+		_, err := io.Copy(w, br)
+		return err
+	}
+
+	decoded := bufio.NewReaderSize(transform.NewReader(br, enc.NewDecoder()), peekSize)
+
+	// The declaration must match the new encoding of the document
+	head, _ := decoded.Peek(peekSize)
+	if loc := declarationRegex.FindIndex(head); loc != nil {
+		decl := encodingAttrRegex.ReplaceAll(head[loc[0]:loc[1]], []byte(`encoding="UTF-8"`))
+		if _, err := w.Write(decl); err != nil {
+			return err
+		}
+		if _, err := decoded.Discard(loc[1]); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(w, decoded); err != nil {
+		return fmt.Errorf("unable to transcode from %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// detectEncoding returns the name of the character encoding of the XML document
+// buffered in br, along with the encoding itself, without consuming any input
+// other than a UTF-8 byte order mark.
+func detectEncoding(br *bufio.Reader) (string, encoding.Encoding, error) {
+	head, err := br.Peek(peekSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		_, err := br.Discard(len(bomUTF8))
+		return "UTF-8", encoding.Nop, err
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return "UTF-16BE", unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), nil
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return "UTF-16LE", unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	}
+
+	m := encodingAttrRegex.FindSubmatch(declarationRegex.Find(head))
+	if m == nil {
+		return "UTF-8", encoding.Nop, nil
+	}
+
+	name := string(m[1])
+	if IsUTF8(name) {
+		return name, encoding.Nop, nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	return name, enc, nil
+}
+
+// charsetReader returns a reader that transcodes input in the named character
+// encoding to UTF-8, for use as the CharsetReader of an xml.Decoder.
+func charsetReader(name string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// newDecoder returns an xml.Decoder that reads from r, transcoding documents
+// declared in character encodings other than UTF-8.
+func newDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+	return d
+}
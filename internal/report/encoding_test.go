@@ -0,0 +1,102 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXMLEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "ISO-8859-1", path: "testdata/iso-8859-1.xml", want: "ISO-8859-1"},
+		{name: "Shift_JIS", path: "testdata/shift_jis.xml", want: "Shift_JIS"},
+		{name: "UTF-16LE with byte order mark", path: "testdata/utf-16le.xml", want: "UTF-16LE"},
+		{name: "UTF-8", path: "testdata/phpunit.xml", want: "UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.path)
+			require.NoError(t, err)
+			defer f.Close()
+
+			got, err := XMLEncoding(f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestXMLEncoding_noDeclaration(t *testing.T) {
+	got, err := XMLEncoding(strings.NewReader("<testsuites/>"))
+	require.NoError(t, err)
+	assert.Equal(t, "UTF-8", got)
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{
+			name: "ISO-8859-1",
+			path: "testdata/iso-8859-1.xml",
+			want: []string{`<testcase name="crème brûlée" classname="Café">`, `<failure message="expected ½">naïve comparison</failure>`},
+		},
+		{
+			name: "Shift_JIS",
+			path: "testdata/shift_jis.xml",
+			want: []string{`<testcase name="足し算ができる" classname="計算機"/>`},
+		},
+		{
+			name: "UTF-16LE with byte order mark",
+			path: "testdata/utf-16le.xml",
+			want: []string{`<testcase name="prüft die Größe" classname="Größe"/>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.path)
+			require.NoError(t, err)
+			defer f.Close()
+
+			var out bytes.Buffer
+			err = ToUTF8(f, &out)
+			require.NoError(t, err)
+
+			assert.True(t, strings.HasPrefix(out.String(), `<?xml version="1.0" encoding="UTF-8"?>`), out.String())
+			for _, s := range tt.want {
+				assert.Contains(t, out.String(), s)
+			}
+		})
+	}
+}
+
+func TestToUTF8_unsupportedEncoding(t *testing.T) {
+	err := ToUTF8(strings.NewReader(`<?xml version="1.0" encoding="bogus"?><testsuites/>`), &bytes.Buffer{})
+	if assert.Error(t, err) {
+		assert.Equal(t, `unsupported encoding "bogus"`, err.Error())
+	}
+}
+
+func TestRewrite_nonUTF8(t *testing.T) {
+	f, err := os.Open("testdata/iso-8859-1.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var out bytes.Buffer
+	err = Rewrite(f, &out, func(se *xml.StartElement) {})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(out.String(), `<?xml version="1.0" encoding="UTF-8"?>`), out.String())
+	assert.Contains(t, out.String(), `<testcase name="crème brûlée" classname="Café">`)
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// exUnitEvent represents a line of the JSON Lines output of a `mix test
+// --formatter` JSON formatter, which writes an event for each ExUnit formatter
+// event it receives. Only the test_finished events are used, e.g.:
+//
+//	{"event": "test_finished", "test": {"module": "Elixir.MyApp.UserTest", "name": "test creates a user", "state": "failed", "time": 1520, "file": "test/my_app/user_test.exs", "line": 12, "message": "..."}}
+type exUnitEvent struct {
+	Event string      `json:"event"`
+	Test  *exUnitTest `json:"test"`
+}
+
+type exUnitTest struct {
+	Module  string  `json:"module"`
+	Name    string  `json:"name"`
+	State   string  `json:"state"` // passed (or null), failed, invalid, skipped, or excluded
+	Time    float64 `json:"time"`  // microseconds
+	File    string  `json:"file"`
+	Line    int     `json:"line"`
+	Message string  `json:"message"`
+}
+
+// FromExUnit converts the ExUnit JSON Lines events read from r into JUnit test
+// suites, with one suite per test module. Lines that aren't JSON (e.g., output
+// of the tests interleaved with the events) are skipped.
+func FromExUnit(r io.Reader) (*Testsuites, error) {
+	var modules []string
+	suites := make(map[string]*Testsuite)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var e exUnitEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("unable to parse ExUnit JSON report: %v", err)
+		}
+		if e.Event != "test_finished" || e.Test == nil {
+			continue
+		}
+
+		// Module names are atoms, which Elixir prefixes with "Elixir."
+		module := strings.TrimPrefix(e.Test.Module, "Elixir.")
+		suite, ok := suites[module]
+		if !ok {
+			suite = &Testsuite{Name: module, File: e.Test.File}
+			suites[module] = suite
+			modules = append(modules, module)
+		}
+		suite.Testcases = append(suite.Testcases, exUnitTestcase(module, e.Test))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to parse ExUnit JSON report: %v", err)
+	}
+
+	ts := &Testsuites{}
+	for _, module := range modules {
+		ts.Suites = append(ts.Suites, *suites[module])
+	}
+
+	ts.Tally()
+
+	return ts, nil
+}
+
+func exUnitTestcase(module string, t *exUnitTest) Testcase {
+	tc := Testcase{
+		Name:      t.Name,
+		Classname: module,
+		File:      t.File,
+		Time:      t.Time / 1e6,
+	}
+
+	switch t.State {
+	case "failed":
+		tc.Failure = &Failure{Message: firstLine(t.Message), Text: t.Message}
+	case "invalid":
+		// The test didn't run because setup_all failed for its module
+		tc.Error = &Failure{Message: firstLine(t.Message), Text: t.Message}
+	case "skipped", "excluded":
+		tc.Skipped = &Skipped{Message: t.Message}
+	}
+
+	return tc
+}
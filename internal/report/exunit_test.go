@@ -0,0 +1,55 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromExUnit(t *testing.T) {
+	f, err := os.Open("testdata/exunit.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ts, err := FromExUnit(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, ts.Tests)
+	assert.Equal(t, 1, ts.Failures)
+	assert.Equal(t, 1, ts.Errors)
+	assert.Equal(t, 1, ts.Skipped)
+	require.Len(t, ts.Suites, 2)
+
+	users := ts.Suites[0]
+	assert.Equal(t, "MyApp.UserTest", users.Name)
+	assert.Equal(t, "test/my_app/user_test.exs", users.File)
+	require.Len(t, users.Testcases, 2)
+
+	assert.Equal(t, "test creates a user", users.Testcases[0].Name)
+	assert.Equal(t, "MyApp.UserTest", users.Testcases[0].Classname)
+	assert.Equal(t, 0.00152, users.Testcases[0].Time)
+	assert.Nil(t, users.Testcases[0].Failure)
+
+	if assert.NotNil(t, users.Testcases[1].Failure) {
+		assert.Equal(t, "Assertion with == failed", users.Testcases[1].Failure.Message)
+		assert.Contains(t, users.Testcases[1].Failure.Text, "right: false")
+	}
+
+	mailer := ts.Suites[1]
+	assert.Equal(t, "MyApp.MailerTest", mailer.Name)
+	require.Len(t, mailer.Testcases, 2)
+	if assert.NotNil(t, mailer.Testcases[0].Skipped) {
+		assert.Equal(t, "due to @tag :skip", mailer.Testcases[0].Skipped.Message)
+	}
+	assert.NotNil(t, mailer.Testcases[1].Error)
+}
+
+func TestFromExUnit_malformed(t *testing.T) {
+	_, err := FromExUnit(strings.NewReader(`{"event": "test_finished", "test": [`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to parse ExUnit JSON report")
+	}
+}
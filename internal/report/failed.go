@@ -0,0 +1,81 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// A FailedTest identifies a test case that failed or errored in a JUnit report,
+// with enough detail for a test runner to run it again.
+type FailedTest struct {
+	Name      string
+	Classname string
+
+	// File is the test case's file attribute, or else that of the suite that
+	// contains it, or empty if neither has one.
+	File string
+}
+
+// FailedTests returns the test cases that failed or errored in the JUnit report
+// read from r, in the order they appear and without duplicates (e.g., a test
+// case that a retry plugin reports once per execution).
+func FailedTests(r io.Reader) ([]FailedTest, error) {
+	d := newDecoder(r)
+
+	var failed []FailedTest
+	seen := make(map[FailedTest]bool)
+
+	// The file attributes of the enclosing suites, innermost last
+	var suiteFiles []string
+
+	var testcase *FailedTest
+	var testcaseFailed bool
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "testsuite":
+				file, _ := attr(&t, "file")
+				if file == "" && len(suiteFiles) > 0 {
+					file = suiteFiles[len(suiteFiles)-1]
+				}
+				suiteFiles = append(suiteFiles, file)
+			case "testcase":
+				name, _ := attr(&t, "name")
+				classname, _ := attr(&t, "classname")
+				file, _ := attr(&t, "file")
+				if file == "" && len(suiteFiles) > 0 {
+					file = suiteFiles[len(suiteFiles)-1]
+				}
+				testcase = &FailedTest{Name: name, Classname: classname, File: file}
+				testcaseFailed = false
+			case "failure", "error":
+				testcaseFailed = testcase != nil
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "testsuite":
+				if len(suiteFiles) > 0 {
+					suiteFiles = suiteFiles[:len(suiteFiles)-1]
+				}
+			case "testcase":
+				if testcaseFailed && !seen[*testcase] {
+					seen[*testcase] = true
+					failed = append(failed, *testcase)
+				}
+				testcase, testcaseFailed = nil, false
+			}
+		}
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedTests(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   []FailedTest
+	}{
+		{
+			name: "failures and errors",
+			report: `<testsuites>
+  <testsuite name="spec" file="./spec/user_spec.rb">
+    <testcase classname="spec.user_spec" name="User is valid"/>
+    <testcase classname="spec.user_spec" name="User has a name">
+      <failure message="expected a name"/>
+    </testcase>
+    <testcase classname="spec.user_spec" name="User saves" file="./spec/save_spec.rb">
+      <error message="connection refused"/>
+    </testcase>
+    <testcase classname="spec.user_spec" name="User is skipped">
+      <skipped/>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+			want: []FailedTest{
+				{Name: "User has a name", Classname: "spec.user_spec", File: "./spec/user_spec.rb"},
+				{Name: "User saves", Classname: "spec.user_spec", File: "./spec/save_spec.rb"},
+			},
+		},
+		{
+			name: "nested suites",
+			report: `<testsuite name="outer" file="tests/test_math.py">
+  <testsuite name="inner">
+    <testcase classname="tests.test_math" name="test_add"><failure/></testcase>
+  </testsuite>
+  <testcase classname="tests.test_math" name="test_sub"><failure/></testcase>
+</testsuite>`,
+			want: []FailedTest{
+				{Name: "test_add", Classname: "tests.test_math", File: "tests/test_math.py"},
+				{Name: "test_sub", Classname: "tests.test_math", File: "tests/test_math.py"},
+			},
+		},
+		{
+			name: "test case reported once per execution",
+			report: `<testsuite name="com.example.AppTest">
+  <testcase name="works" classname="com.example.AppTest"><failure/></testcase>
+  <testcase name="works" classname="com.example.AppTest"><failure/></testcase>
+  <testcase name="works" classname="com.example.AppTest"/>
+</testsuite>`,
+			want: []FailedTest{
+				{Name: "works", Classname: "com.example.AppTest"},
+			},
+		},
+		{
+			name:   "no failures",
+			report: `<testsuite name="suite"><testcase name="passes"/></testsuite>`,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FailedTests(strings.NewReader(tt.report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFailedTests_malformed(t *testing.T) {
+	_, err := FailedTests(strings.NewReader(`<testsuite><testcase name="a">`))
+	assert.Error(t, err)
+}
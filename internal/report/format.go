@@ -0,0 +1,74 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// The names of the report formats that can be converted into JUnit XML.
+const (
+	FormatExUnit = "exunit"
+	FormatGoTest = "gotest"
+	FormatKarma  = "karma"
+	FormatTRX    = "trx"
+	FormatVitest = "vitest"
+)
+
+// FormatJUnit is the name of the JUnit XML format itself.
+const FormatJUnit = "junit"
+
+// Formats lists the names of the JSON report formats that can be converted
+// into JUnit XML.
+var Formats = []string{FormatExUnit, FormatGoTest, FormatKarma, FormatVitest}
+
+// AllFormats lists the names of every report format that can be submitted,
+// including JUnit XML itself.
+var AllFormats = []string{FormatJUnit, FormatTRX, FormatExUnit, FormatGoTest, FormatKarma, FormatVitest}
+
+// Converter returns the function that converts reports in the named format
+// into JUnit test suites, and whether such a function exists.
+func Converter(format string) (func(io.Reader) (*Testsuites, error), bool) {
+	switch format {
+	case FormatExUnit:
+		return FromExUnit, true
+	case FormatGoTest:
+		return FromGoTest, true
+	case FormatKarma:
+		return FromKarma, true
+	case FormatTRX:
+		return FromTRX, true
+	case FormatVitest:
+		return FromVitest, true
+	default:
+		return nil, false
+	}
+}
+
+// DetectJSONFormat inspects the shape of the JSON document read from r and
+// returns the name of the report format that produced it, or an empty string
+// if the document isn't a recognized test report.
+func DetectJSONFormat(r io.Reader) string {
+	var shape struct {
+		Action      json.RawMessage `json:"Action"`
+		Browsers    json.RawMessage `json:"browsers"`
+		Event       json.RawMessage `json:"event"`
+		Result      json.RawMessage `json:"result"`
+		TestResults json.RawMessage `json:"testResults"`
+	}
+	if err := json.NewDecoder(r).Decode(&shape); err != nil {
+		return ""
+	}
+
+	switch {
+	case shape.Action != nil:
+		return FormatGoTest
+	case shape.Event != nil:
+		return FormatExUnit
+	case shape.Browsers != nil && shape.Result != nil:
+		return FormatKarma
+	case shape.TestResults != nil:
+		return FormatVitest
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,37 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectJSONFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "exunit", path: "testdata/exunit.json", want: FormatExUnit},
+		{name: "gotest", path: "testdata/gotest.json", want: FormatGoTest},
+		{name: "karma", path: "testdata/karma.json", want: FormatKarma},
+		{name: "vitest", path: "testdata/vitest.json", want: FormatVitest},
+		{name: "composer.json", path: "testdata/php-project/composer.json", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.path)
+			require.NoError(t, err)
+			defer f.Close()
+
+			assert.Equal(t, tt.want, DetectJSONFormat(f))
+		})
+	}
+}
+
+func TestDetectJSONFormat_notJSON(t *testing.T) {
+	assert.Equal(t, "", DetectJSONFormat(strings.NewReader("<testsuites/>")))
+}
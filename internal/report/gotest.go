@@ -0,0 +1,168 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// goTestEvent represents a line of the output of `go test -json` (see
+// `go doc test2json`).
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"` // seconds
+	Output  string  `json:"Output"`
+}
+
+// goTestPackage accumulates the events for a package.
+type goTestPackage struct {
+	name   string
+	tests  []string // in the order they started
+	cases  map[string]*Testcase
+	output map[string]*strings.Builder // keyed by test name; "" for the package
+	action string                      // the package's final action (e.g., "pass" or "fail")
+}
+
+// FromGoTest converts the output of `go test -json` read from r into JUnit test
+// suites, with one suite per package. Lines that aren't JSON (e.g., build
+// errors interleaved with the events) are skipped.
+func FromGoTest(r io.Reader) (*Testsuites, error) {
+	var pkgs []*goTestPackage
+	byName := make(map[string]*goTestPackage)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var e goTestEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("unable to parse go test JSON report: %v", err)
+		}
+		if e.Package == "" {
+			continue
+		}
+
+		pkg, ok := byName[e.Package]
+		if !ok {
+			pkg = &goTestPackage{
+				name:   e.Package,
+				cases:  make(map[string]*Testcase),
+				output: make(map[string]*strings.Builder),
+			}
+			byName[e.Package] = pkg
+			pkgs = append(pkgs, pkg)
+		}
+		pkg.add(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to parse go test JSON report: %v", err)
+	}
+
+	ts := &Testsuites{}
+	for _, pkg := range pkgs {
+		ts.Suites = append(ts.Suites, pkg.suite())
+	}
+
+	ts.Tally()
+
+	return ts, nil
+}
+
+func (p *goTestPackage) add(e goTestEvent) {
+	out, ok := p.output[e.Test]
+	if !ok {
+		out = &strings.Builder{}
+		p.output[e.Test] = out
+	}
+
+	if e.Test == "" {
+		switch e.Action {
+		case "output":
+			out.WriteString(e.Output)
+		case "pass", "fail", "skip":
+			p.action = e.Action
+		}
+		return
+	}
+
+	tc, ok := p.cases[e.Test]
+	if !ok {
+		tc = &Testcase{Name: e.Test, Classname: p.name}
+		p.cases[e.Test] = tc
+		p.tests = append(p.tests, e.Test)
+	}
+
+	switch e.Action {
+	case "output":
+		out.WriteString(e.Output)
+	case "pass":
+		tc.Time = e.Elapsed
+	case "fail":
+		tc.Time = e.Elapsed
+		text := out.String()
+		tc.Failure = &Failure{Message: goTestFailureMessage(text), Text: text}
+	case "skip":
+		tc.Time = e.Elapsed
+		tc.Skipped = &Skipped{Message: goTestFailureMessage(out.String())}
+	}
+}
+
+func (p *goTestPackage) suite() Testsuite {
+	suite := Testsuite{Name: p.name}
+	for _, name := range p.tests {
+		suite.Testcases = append(suite.Testcases, *p.cases[name])
+	}
+
+	// A package that fails without any failing tests (e.g., because it doesn't
+	// build, or a test panics the binary) has nothing to attribute the
+	// failure to, so report it against the package itself.
+	if p.action == "fail" && !suite.hasFailure() {
+		text := p.output[""].String()
+		message := goTestFailureMessage(text)
+		if message == "" {
+			message = firstLine(text) // e.g., "FAIL	example.com/app [build failed]"
+		}
+		suite.Testcases = append(suite.Testcases, Testcase{
+			Name:      p.name,
+			Classname: p.name,
+			Error:     &Failure{Message: message, Text: text},
+		})
+	}
+
+	return suite
+}
+
+func (s *Testsuite) hasFailure() bool {
+	for _, tc := range s.Testcases {
+		if tc.Failure != nil || tc.Error != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// goTestFailureMessage returns the first line of a test's output that isn't
+// one of the status lines written by `go test` (e.g., "=== RUN" or "--- FAIL").
+func goTestFailureMessage(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", line == "FAIL", line == "PASS",
+			strings.HasPrefix(line, "=== "), strings.HasPrefix(line, "--- "),
+			strings.HasPrefix(line, "FAIL\t"), strings.HasPrefix(line, "ok  \t"):
+			continue
+		}
+		return line
+	}
+
+	return ""
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromGoTest(t *testing.T) {
+	f, err := os.Open("testdata/gotest.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ts, err := FromGoTest(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, ts.Tests)
+	assert.Equal(t, 1, ts.Failures)
+	assert.Equal(t, 1, ts.Errors)
+	assert.Equal(t, 1, ts.Skipped)
+	require.Len(t, ts.Suites, 2)
+
+	math := ts.Suites[0]
+	assert.Equal(t, "example.com/app/math", math.Name)
+	require.Len(t, math.Testcases, 3)
+	assert.Equal(t, "TestDivide", math.Testcases[1].Name)
+	assert.Equal(t, "example.com/app/math", math.Testcases[1].Classname)
+	assert.Equal(t, 0.02, math.Testcases[1].Time)
+	if assert.NotNil(t, math.Testcases[1].Failure) {
+		assert.Equal(t, "math_test.go:21: Divide(1, 0) = 0; want error", math.Testcases[1].Failure.Message)
+		assert.Contains(t, math.Testcases[1].Failure.Text, "--- FAIL: TestDivide")
+	}
+	if assert.NotNil(t, math.Testcases[2].Skipped) {
+		assert.Equal(t, "math_test.go:30: not implemented", math.Testcases[2].Skipped.Message)
+	}
+
+	// Packages that fail without a failing test are reported as errors
+	broken := ts.Suites[1]
+	require.Len(t, broken.Testcases, 1)
+	if assert.NotNil(t, broken.Testcases[0].Error) {
+		assert.Equal(t, "FAIL\texample.com/app/broken [build failed]", broken.Testcases[0].Error.Message)
+	}
+}
+
+func TestFromGoTest_malformed(t *testing.T) {
+	_, err := FromGoTest(strings.NewReader(`{"Action": "run", "Package": `))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to parse go test JSON report")
+	}
+}
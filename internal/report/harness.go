@@ -0,0 +1,115 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// harnessVersionProperties maps the names of the properties that record the
+// version of a test harness (or its runtime) in a JUnit report to the name of
+// the harness. go-junit-report and gotestsum record go.version, and Maven
+// Surefire records the JVM's system properties. The others are conventions for
+// properties recorded by the test suite itself (e.g., with pytest's
+// record_testsuite_property fixture).
+var harnessVersionProperties = map[string]string{
+	"go.version":     "go",
+	"java.version":   "java",
+	"maven.version":  "maven",
+	"pytest.version": "pytest",
+	"pytest_version": "pytest",
+	"python.version": "python",
+	"python_version": "python",
+	"rspec.version":  "rspec",
+	"rspec_version":  "rspec",
+	"ruby.version":   "ruby",
+	"ruby_version":   "ruby",
+}
+
+// ReadHarnessVersions returns the versions of the test harnesses recorded in
+// the properties of the JUnit report read from r, keyed by harness name (e.g.,
+// "go" or "pytest"). If a property is repeated, the first value is used.
+func ReadHarnessVersions(r io.Reader) (map[string]string, error) {
+	d := newDecoder(r)
+
+	versions := make(map[string]string)
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "property" {
+			continue
+		}
+
+		name, _ := attr(&se, "name")
+		harness, ok := harnessVersionProperties[strings.ToLower(name)]
+		if !ok || versions[harness] != "" {
+			continue
+		}
+
+		value, _ := attr(&se, "value")
+		if v := normalizeVersion(value); v != "" {
+			versions[harness] = v
+		}
+	}
+
+	return versions, nil
+}
+
+// normalizeVersion extracts the version number from the value of a version
+// property (e.g., "1.21.5" from "go1.21.5 linux/amd64").
+func normalizeVersion(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.TrimPrefix(fields[0], "go"), "v")
+}
+
+// A harnessProbe finds the version of a test harness declared in a file at the
+// root of a repository.
+type harnessProbe struct {
+	harness string
+	file    string
+	regex   *regexp.Regexp // the first capture group is the version
+}
+
+var harnessProbes = []harnessProbe{
+	{harness: "go", file: "go.mod", regex: regexp.MustCompile(`(?m)^toolchain go(\S+)`)},
+	{harness: "minitest", file: "Gemfile.lock", regex: regexp.MustCompile(`(?m)^    minitest \(([^)]+)\)`)},
+	{harness: "rspec", file: "Gemfile.lock", regex: regexp.MustCompile(`(?m)^    rspec-core \(([^)]+)\)`)},
+}
+
+// ProbeHarnessVersions returns the versions of the test harnesses declared in
+// the lockfiles and module files at the root of the given directory (e.g.,
+// rspec-core in Gemfile.lock, or the toolchain directive in go.mod), keyed by
+// harness name. Files that don't exist are skipped.
+func ProbeHarnessVersions(root string) map[string]string {
+	versions := make(map[string]string)
+	files := make(map[string][]byte)
+
+	for _, p := range harnessProbes {
+		data, ok := files[p.file]
+		if !ok {
+			data, _ = os.ReadFile(filepath.Join(root, p.file))
+			files[p.file] = data
+		}
+
+		if m := p.regex.FindSubmatch(data); m != nil {
+			versions[p.harness] = string(m[1])
+		}
+	}
+
+	return versions
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHarnessVersions(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   map[string]string
+	}{
+		{
+			name: "gotestsum",
+			report: `<testsuites>
+  <testsuite name="example.com/some/pkg">
+    <properties>
+      <property name="go.version" value="go1.21.5 linux/amd64"></property>
+    </properties>
+    <testcase classname="example.com/some/pkg" name="TestSomething"/>
+  </testsuite>
+  <testsuite name="example.com/some/other/pkg">
+    <properties>
+      <property name="go.version" value="go1.20.0 linux/amd64"></property>
+    </properties>
+  </testsuite>
+</testsuites>`,
+			want: map[string]string{"go": "1.21.5"},
+		},
+		{
+			name: "maven surefire",
+			report: `<testsuite name="com.example.AppTest">
+  <properties>
+    <property name="java.version" value="17.0.2"/>
+    <property name="maven.version" value="3.9.6"/>
+    <property name="os.version" value="6.5.0"/>
+  </properties>
+</testsuite>`,
+			want: map[string]string{"java": "17.0.2", "maven": "3.9.6"},
+		},
+		{
+			name: "recorded by the test suite",
+			report: `<testsuites>
+  <testsuite name="pytest">
+    <properties>
+      <property name="pytest_version" value="7.4.3"/>
+      <property name="Python_Version" value="3.12.1"/>
+    </properties>
+  </testsuite>
+</testsuites>`,
+			want: map[string]string{"pytest": "7.4.3", "python": "3.12.1"},
+		},
+		{
+			name:   "no properties",
+			report: `<testsuite name="rspec"><testcase name="works"/></testsuite>`,
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadHarnessVersions(strings.NewReader(tt.report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProbeHarnessVersions(t *testing.T) {
+	dir := t.TempDir()
+	gemfileLock := "GEM\n  remote: https://rubygems.org/\n  specs:\n    minitest (5.20.0)\n    rspec-core (3.12.2)\n      rspec-support (~> 3.12.0)\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Gemfile.lock"), []byte(gemfileLock), 0600))
+	gomod := "module example.com/some/module\n\ngo 1.21\n\ntoolchain go1.21.5\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0600))
+
+	got := ProbeHarnessVersions(dir)
+	assert.Equal(t, map[string]string{"go": "1.21.5", "minitest": "5.20.0", "rspec": "3.12.2"}, got)
+
+	assert.Empty(t, ProbeHarnessVersions(t.TempDir()))
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"encoding/xml"
+)
+
+// Testsuites represents the root element of a JUnit XML report.
+type Testsuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []Testsuite `xml:"testsuite"`
+}
+
+// Testsuite represents a <testsuite> element in a JUnit XML report.
+type Testsuite struct {
+	Name       string      `xml:"name,attr"`
+	Tests      int         `xml:"tests,attr"`
+	Failures   int         `xml:"failures,attr"`
+	Errors     int         `xml:"errors,attr"`
+	Skipped    int         `xml:"skipped,attr"`
+	Time       float64     `xml:"time,attr"`
+	Timestamp  string      `xml:"timestamp,attr,omitempty"`
+	Hostname   string      `xml:"hostname,attr,omitempty"`
+	File       string      `xml:"file,attr,omitempty"`
+	Properties *Properties `xml:"properties"`
+	Testcases  []Testcase  `xml:"testcase"`
+	SystemOut  string      `xml:"system-out,omitempty"`
+	SystemErr  string      `xml:"system-err,omitempty"`
+}
+
+// Properties represents a <properties> element in a JUnit XML report.
+type Properties struct {
+	Property []Property `xml:"property"`
+}
+
+// Property represents a <property> element in a JUnit XML report.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Testcase represents a <testcase> element in a JUnit XML report.
+type Testcase struct {
+	Name      string   `xml:"name,attr"`
+	Classname string   `xml:"classname,attr,omitempty"`
+	File      string   `xml:"file,attr,omitempty"`
+	Time      float64  `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Error     *Failure `xml:"error,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+	SystemOut string   `xml:"system-out,omitempty"`
+	SystemErr string   `xml:"system-err,omitempty"`
+}
+
+// Failure represents a <failure> or <error> element in a JUnit XML report.
+type Failure struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Skipped represents a <skipped> element in a JUnit XML report.
+type Skipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// Tally recomputes the test counts and durations of ts (and of each of its
+// suites) from the test cases they contain.
+func (ts *Testsuites) Tally() {
+	ts.Tests, ts.Failures, ts.Errors, ts.Skipped, ts.Time = 0, 0, 0, 0, 0
+
+	for i := range ts.Suites {
+		s := &ts.Suites[i]
+		s.Tests, s.Failures, s.Errors, s.Skipped, s.Time = 0, 0, 0, 0, 0
+
+		for _, tc := range s.Testcases {
+			s.Tests++
+			s.Time += tc.Time
+			switch {
+			case tc.Failure != nil:
+				s.Failures++
+			case tc.Error != nil:
+				s.Errors++
+			case tc.Skipped != nil:
+				s.Skipped++
+			}
+		}
+
+		ts.Tests += s.Tests
+		ts.Failures += s.Failures
+		ts.Errors += s.Errors
+		ts.Skipped += s.Skipped
+		ts.Time += s.Time
+	}
+}
+
+// Marshal serializes ts into a JUnit XML document.
+func Marshal(ts *Testsuites) ([]byte, error) {
+	out, err := xml.MarshalIndent(ts, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
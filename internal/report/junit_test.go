@@ -0,0 +1,33 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	ts := &Testsuites{
+		Suites: []Testsuite{
+			{
+				Name: "some-suite",
+				Testcases: []Testcase{
+					{Name: "passes", Classname: "SomeClass", Time: 0.5},
+					{Name: "fails", Classname: "SomeClass", Time: 0.25, Failure: &Failure{Message: "boom", Text: "trace"}},
+				},
+			},
+		},
+	}
+	ts.Tally()
+
+	out, err := Marshal(ts)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(out), `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, string(out), `<testsuites tests="2" failures="1" errors="0" skipped="0" time="0.75">`)
+	assert.Contains(t, string(out), `<testsuite name="some-suite" tests="2" failures="1" errors="0" skipped="0" time="0.75">`)
+	assert.Contains(t, string(out), `<failure message="boom">trace</failure>`)
+	assert.NotContains(t, string(out), "<properties>")
+}
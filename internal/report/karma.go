@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// karmaReport represents the document written by karma-json-reporter.
+type karmaReport struct {
+	Browsers map[string]karmaBrowser `json:"browsers"`
+	Result   map[string][]karmaSpec  `json:"result"`
+}
+
+type karmaBrowser struct {
+	Name string `json:"name"`
+}
+
+type karmaSpec struct {
+	Description string   `json:"description"`
+	Suite       []string `json:"suite"`
+	Success     bool     `json:"success"`
+	Skipped     bool     `json:"skipped"`
+	Pending     bool     `json:"pending"`
+	Disabled    bool     `json:"disabled"`
+	Time        float64  `json:"time"` // milliseconds
+	Log         []string `json:"log"`
+}
+
+// FromKarma converts the Karma JSON reporter document read from r into JUnit
+// test suites, with one suite per browser.
+func FromKarma(r io.Reader) (*Testsuites, error) {
+	var rep karmaReport
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return nil, fmt.Errorf("unable to parse Karma report: %v", err)
+	}
+
+	// Map iteration order is random, so sort the browsers for stable output
+	ids := make([]string, 0, len(rep.Result))
+	for id := range rep.Result {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	ts := &Testsuites{}
+	for _, id := range ids {
+		name := rep.Browsers[id].Name
+		if name == "" {
+			name = id
+		}
+
+		suite := Testsuite{Name: name}
+		for _, spec := range rep.Result[id] {
+			suite.Testcases = append(suite.Testcases, karmaTestcase(spec))
+		}
+		ts.Suites = append(ts.Suites, suite)
+	}
+
+	ts.Tally()
+
+	return ts, nil
+}
+
+func karmaTestcase(spec karmaSpec) Testcase {
+	tc := Testcase{
+		Name:      spec.Description,
+		Classname: strings.Join(spec.Suite, " "),
+		Time:      spec.Time / 1000,
+	}
+
+	switch {
+	case spec.Skipped || spec.Pending || spec.Disabled:
+		tc.Skipped = &Skipped{}
+	case !spec.Success:
+		text := strings.Join(spec.Log, "\n")
+		tc.Failure = &Failure{Message: firstLine(text), Text: text}
+	}
+
+	return tc
+}
+
+// firstLine returns the first non-blank line of s, without surrounding
+// whitespace.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,46 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromKarma(t *testing.T) {
+	f, err := os.Open("testdata/karma.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ts, err := FromKarma(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, ts.Tests)
+	assert.Equal(t, 1, ts.Failures)
+	assert.Equal(t, 1, ts.Skipped)
+	require.Len(t, ts.Suites, 1)
+
+	suite := ts.Suites[0]
+	assert.Equal(t, "Chrome Headless 120.0.0.0 (Linux x86_64)", suite.Name)
+	require.Len(t, suite.Testcases, 4)
+
+	assert.Equal(t, "adds two numbers", suite.Testcases[0].Name)
+	assert.Equal(t, "Calculator add", suite.Testcases[0].Classname)
+	assert.Equal(t, 0.004, suite.Testcases[0].Time)
+
+	if assert.NotNil(t, suite.Testcases[2].Failure) {
+		assert.Equal(t, "Error: Expected Infinity to be NaN.", suite.Testcases[2].Failure.Message)
+		assert.Contains(t, suite.Testcases[2].Failure.Text, "calculator.spec.ts:21:29")
+	}
+
+	assert.NotNil(t, suite.Testcases[3].Skipped)
+}
+
+func TestFromKarma_malformed(t *testing.T) {
+	_, err := FromKarma(strings.NewReader(`{"result": [`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to parse Karma report")
+	}
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// NestedJUnit returns the test suites of the JUnit XML documents embedded in
+// the text of the XML document read from r (typically in a CDATA section of a
+// wrapper report's <system-out>), or nil if there are none. Some tools wrap
+// whole reports that way, which otherwise look like a single test case that
+// passed. Text that looks like a JUnit document but doesn't parse as one is
+// ignored.
+func NestedJUnit(r io.Reader) (*Testsuites, error) {
+	d := newDecoder(r)
+
+	var nested *Testsuites
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		text, ok := tok.(xml.CharData)
+		if !ok {
+			continue
+		}
+
+		suites, ok := parseEmbeddedJUnit(string(text))
+		if !ok {
+			continue
+		}
+		if nested == nil {
+			nested = &Testsuites{}
+		}
+		nested.Suites = append(nested.Suites, suites...)
+	}
+
+	if nested != nil {
+		nested.Tally()
+	}
+
+	return nested, nil
+}
+
+// parseEmbeddedJUnit returns the test suites of the JUnit document in text, and
+// whether text holds one (with a root element of <testsuites> or <testsuite>).
+func parseEmbeddedJUnit(text string) ([]Testsuite, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "<") || !strings.Contains(text, "<testsuite") {
+		return nil, false
+	}
+
+	root, ok := rootElement(text)
+	if !ok {
+		return nil, false
+	}
+
+	switch root {
+	case "testsuites":
+		var ts Testsuites
+		if err := newDecoder(strings.NewReader(text)).Decode(&ts); err != nil {
+			return nil, false
+		}
+		return ts.Suites, true
+	case "testsuite":
+		var s Testsuite
+		if err := newDecoder(strings.NewReader(text)).Decode(&s); err != nil {
+			return nil, false
+		}
+		return []Testsuite{s}, true
+	}
+
+	return nil, false
+}
+
+// rootElement returns the local name of the root element of the XML document in
+// text, and whether it has one.
+func rootElement(text string) (string, bool) {
+	d := newDecoder(strings.NewReader(text))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", false
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, true
+		}
+	}
+}
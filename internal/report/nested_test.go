@@ -0,0 +1,59 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNestedJUnit(t *testing.T) {
+	f, err := os.Open("testdata/nested.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	got, err := NestedJUnit(f)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+
+	require.Len(t, got.Suites, 2)
+	assert.Equal(t, "LoginTests", got.Suites[0].Name)
+	assert.Equal(t, 2, got.Suites[0].Tests)
+	assert.Equal(t, 1, got.Suites[0].Failures)
+	assert.Equal(t, "expected error banner", got.Suites[0].Testcases[1].Failure.Message)
+	assert.Equal(t, "CartTests", got.Suites[1].Name)
+	assert.Equal(t, 1, got.Suites[1].Skipped)
+
+	assert.Equal(t, 3, got.Tests)
+	assert.Equal(t, 1, got.Failures)
+	assert.Equal(t, 1, got.Skipped)
+}
+
+func TestNestedJUnit_none(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "plain report",
+			input: `<testsuites><testsuite name="a"><testcase name="b"><system-out>ok</system-out></testcase></testsuite></testsuites>`,
+		},
+		{
+			name:  "markup that isn't JUnit",
+			input: `<testsuites><testsuite name="a"><testcase name="b"><system-out><![CDATA[<html><body>testsuite</body></html>]]></system-out></testcase></testsuite></testsuites>`,
+		},
+		{
+			name:  "truncated report",
+			input: `<testsuites><testsuite name="a"><testcase name="b"><system-out><![CDATA[<testsuite name="c"><testcase name="d">]]></system-out></testcase></testsuite></testsuites>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NestedJUnit(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Nil(t, got)
+		})
+	}
+}
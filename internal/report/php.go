@@ -0,0 +1,198 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A PHPNormalizer rewrites PHPUnit and Pest JUnit reports so that the file
+// paths they contain are relative to the root of the repository.
+type PHPNormalizer struct {
+	root     string
+	autoload []psr4Mapping
+}
+
+// psr4Mapping maps a namespace prefix to the directories containing the
+// classes in that namespace.
+type psr4Mapping struct {
+	prefix string
+	dirs   []string
+}
+
+// NewPHPNormalizer returns a PHPNormalizer for the repository located at root.
+//
+// If root contains a composer.json file, its PSR-4 autoload mappings are used
+// to resolve the file for each test class whose file isn't named in the report.
+func NewPHPNormalizer(root string) (*PHPNormalizer, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	autoload, err := composerAutoload(filepath.Join(abs, "composer.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PHPNormalizer{root: abs, autoload: autoload}, nil
+}
+
+// Normalize copies the JUnit report read from r to w, rewriting the file paths
+// for each test suite and test case along the way.
+func (n *PHPNormalizer) Normalize(r io.Reader, w io.Writer) error {
+	return Rewrite(r, w, func(se *xml.StartElement) {
+		if se.Name.Local != "testsuite" && se.Name.Local != "testcase" {
+			return
+		}
+
+		if file, ok := attr(se, "file"); ok {
+			setAttr(se, "file", n.relativePath(file))
+			return
+		}
+
+		if file := n.resolveClass(phpClassName(se)); file != "" {
+			setAttr(se, "file", file)
+		}
+	})
+}
+
+// relativePath translates the given (typically absolute) path into a path
+// relative to the root of the repository. Pest appends the test description to
+// the path (e.g., "tests/SomeTest.php::it works"), which is preserved.
+func (n *PHPNormalizer) relativePath(file string) string {
+	path, suffix := file, ""
+	if i := strings.Index(file, "::"); i >= 0 {
+		path, suffix = file[:i], file[i:]
+	}
+
+	if !filepath.IsAbs(path) {
+		return file
+	}
+
+	rel, err := filepath.Rel(n.root, path)
+	if err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel) + suffix
+	}
+
+	// The tests may have run at a different location than the reporter (e.g.,
+	// inside a container), so look for the longest trailing portion of the path
+	// that exists in the repository.
+	parts := strings.Split(strings.ReplaceAll(path, `\`, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		candidate := strings.Join(parts[i:], "/")
+		if isFile(filepath.Join(n.root, filepath.FromSlash(candidate))) {
+			return candidate + suffix
+		}
+	}
+
+	return file
+}
+
+// resolveClass returns the path (relative to the root of the repository) of the
+// file that defines the given fully qualified class name according to the PSR-4
+// autoload conventions, or an empty string if the file can't be found.
+func (n *PHPNormalizer) resolveClass(class string) string {
+	class = strings.TrimPrefix(class, `\`)
+	if class == "" {
+		return ""
+	}
+
+	for _, m := range n.autoload {
+		if !strings.HasPrefix(class, m.prefix) {
+			continue
+		}
+
+		rest := strings.ReplaceAll(strings.TrimPrefix(class, m.prefix), `\`, "/") + ".php"
+		for _, dir := range m.dirs {
+			candidate := strings.TrimPrefix(filepath.ToSlash(filepath.Join(dir, rest)), "./")
+			if isFile(filepath.Join(n.root, filepath.FromSlash(candidate))) {
+				return candidate
+			}
+		}
+	}
+
+	return ""
+}
+
+// phpClassName returns the fully qualified name of the class for the given
+// testsuite or testcase element, or an empty string if it can't be determined.
+func phpClassName(se *xml.StartElement) string {
+	if se.Name.Local == "testsuite" {
+		name, _ := attr(se, "name")
+		if strings.Contains(name, `\`) {
+			return name
+		}
+		return ""
+	}
+
+	if class, ok := attr(se, "class"); ok {
+		return class
+	}
+
+	// PHPUnit 10 dropped the class attribute, leaving only the classname
+	// attribute, which uses dots as namespace separators.
+	classname, _ := attr(se, "classname")
+	return strings.ReplaceAll(classname, ".", `\`)
+}
+
+// composerAutoload returns the PSR-4 autoload mappings (including the ones for
+// development) declared in the named composer.json file, ordered from the most
+// specific namespace prefix to the least specific. It returns no mappings if
+// the file does not exist.
+func composerAutoload(path string) ([]psr4Mapping, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var composer struct {
+		Autoload struct {
+			PSR4 map[string]json.RawMessage `json:"psr-4"`
+		} `json:"autoload"`
+		AutoloadDev struct {
+			PSR4 map[string]json.RawMessage `json:"psr-4"`
+		} `json:"autoload-dev"`
+	}
+	if err := json.Unmarshal(data, &composer); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+
+	var mappings []psr4Mapping
+	for _, psr4 := range []map[string]json.RawMessage{composer.Autoload.PSR4, composer.AutoloadDev.PSR4} {
+		for prefix, raw := range psr4 {
+			// Each prefix maps to either a single directory or a list of them
+			var dirs []string
+			var dir string
+			if err := json.Unmarshal(raw, &dir); err == nil {
+				dirs = []string{dir}
+			} else if err := json.Unmarshal(raw, &dirs); err != nil {
+				return nil, fmt.Errorf("unable to parse %s: invalid psr-4 entry for %q", path, prefix)
+			}
+
+			mappings = append(mappings, psr4Mapping{prefix: prefix, dirs: dirs})
+		}
+	}
+
+	sort.SliceStable(mappings, func(i, j int) bool {
+		if len(mappings[i].prefix) != len(mappings[j].prefix) {
+			return len(mappings[i].prefix) > len(mappings[j].prefix)
+		}
+		return mappings[i].prefix < mappings[j].prefix
+	})
+
+	return mappings, nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPHPNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		expected []string
+	}{
+		{
+			name:    "PHPUnit",
+			fixture: "testdata/phpunit.xml",
+			expected: []string{
+				`<testsuite name="Tests\Unit\UserTest" file="tests/Unit/UserTest.php"`,
+				`<testcase name="testCreatesUser" class="Tests\Unit\UserTest" classname="Tests.Unit.UserTest" file="tests/Unit/UserTest.php" line="12"`,
+				// Resolved via the composer autoload conventions
+				`<testsuite name="Tests\Unit\OrderTest" tests="1" assertions="1" errors="0" failures="0" skipped="0" time="0.002" file="tests/Unit/OrderTest.php">`,
+				`<testcase name="testTotals" classname="Tests.Unit.OrderTest" assertions="1" time="0.002" file="tests/Unit/OrderTest.php">`,
+				// Paths in failure messages are left as-is
+				`/home/runner/work/some-app/some-app/tests/Unit/UserTest.php:22</failure>`,
+			},
+		},
+		{
+			name:    "Pest",
+			fixture: "testdata/pest.xml",
+			expected: []string{
+				`<testsuite name="Tests\Feature\ExampleTest" file="tests/Feature/ExampleTest.php"`,
+				`file="tests/Feature/ExampleTest.php::it returns a successful response"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewPHPNormalizer("testdata/php-project")
+			require.NoError(t, err)
+
+			f, err := os.Open(tt.fixture)
+			require.NoError(t, err)
+			defer f.Close()
+
+			var out bytes.Buffer
+			err = n.Normalize(f, &out)
+			require.NoError(t, err)
+
+			for _, s := range tt.expected {
+				assert.Contains(t, out.String(), s)
+			}
+		})
+	}
+}
+
+func TestPHPNormalizer_relativePath(t *testing.T) {
+	root, err := filepath.Abs("testdata/php-project")
+	require.NoError(t, err)
+
+	n, err := NewPHPNormalizer("testdata/php-project")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "within root", path: filepath.Join(root, "tests/Unit/UserTest.php"), want: "tests/Unit/UserTest.php"},
+		{name: "within other root", path: "/app/tests/Unit/UserTest.php", want: "tests/Unit/UserTest.php"},
+		{name: "relative", path: "tests/Unit/UserTest.php", want: "tests/Unit/UserTest.php"},
+		{name: "unknown", path: "/app/tests/Unit/BogusTest.php", want: "/app/tests/Unit/BogusTest.php"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, n.relativePath(tt.path))
+		})
+	}
+}
+
+func TestNewPHPNormalizer_withoutComposerFile(t *testing.T) {
+	n, err := NewPHPNormalizer(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, n.autoload)
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// The names of the retry plugins that can be detected from the structure of a
+// JUnit report.
+const (
+	RetryPluginGradle   = "gradle-test-retry"
+	RetryPluginRSpec    = "rspec-retry"
+	RetryPluginSurefire = "maven-surefire"
+)
+
+// A RetryPlugin describes a plugin that retries failed tests inside the test
+// runner, so that a passing test in the report may have failed beforehand.
+type RetryPlugin struct {
+	Name string
+
+	// MaxRetries is the maximum number of times the plugin retries a failed
+	// test, or zero if the setting can't be determined.
+	MaxRetries int
+}
+
+// rspecRetryMarker is the prefix of the message rspec-retry prints each time
+// it retries an example (e.g., "RSpec::Retry: 2nd try ./spec/foo_spec.rb:3").
+var rspecRetryMarker = []byte("RSpec::Retry:")
+
+// DetectRetryPlugin inspects the JUnit report read from r and returns the retry
+// plugin that was in use when the report was produced, or nil if there's no
+// evidence of one.
+//
+// Maven Surefire records reruns in flakyFailure and rerunFailure elements (and
+// the rerunFailingTestsCount setting in the suite properties), rspec-retry
+// announces each retry in the captured output, and the Gradle test-retry plugin
+// reports each execution of a retried test as a separate test case.
+func DetectRetryPlugin(r io.Reader) (*RetryPlugin, error) {
+	d := newDecoder(r)
+
+	var surefire, rspec, gradle bool
+	var maxRetries int
+	var inOutput bool
+	var seen, failed map[string]bool
+	var testcase string
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "testsuite":
+				seen, failed = make(map[string]bool), make(map[string]bool)
+			case "testcase":
+				classname, _ := attr(&t, "classname")
+				name, _ := attr(&t, "name")
+				testcase = classname + "\x00" + name
+				if seen[testcase] && failed[testcase] {
+					gradle = true
+				}
+				if seen != nil {
+					seen[testcase] = true
+				}
+			case "failure", "error":
+				if failed != nil {
+					failed[testcase] = true
+				}
+			case "flakyFailure", "flakyError", "rerunFailure", "rerunError":
+				surefire = true
+			case "property":
+				name, _ := attr(&t, "name")
+				if name == "rerunFailingTestsCount" || name == "surefire.rerunFailingTestsCount" {
+					value, _ := attr(&t, "value")
+					if n, err := strconv.Atoi(value); err == nil && n > 0 {
+						surefire = true
+						maxRetries = n
+					}
+				}
+			case "system-out", "system-err":
+				inOutput = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == "system-out" || t.Name.Local == "system-err" {
+				inOutput = false
+			}
+		case xml.CharData:
+			if inOutput && bytes.Contains(t, rspecRetryMarker) {
+				rspec = true
+			}
+		}
+	}
+
+	switch {
+	case surefire:
+		return &RetryPlugin{Name: RetryPluginSurefire, MaxRetries: maxRetries}, nil
+	case rspec:
+		return &RetryPlugin{Name: RetryPluginRSpec}, nil
+	case gradle:
+		return &RetryPlugin{Name: RetryPluginGradle}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// gradleMaxRetriesRegex matches the maxRetries setting of the Gradle
+// test-retry plugin in both the Groovy and Kotlin DSLs (e.g.,
+// "maxRetries = 3" or "maxRetries.set(3)").
+var gradleMaxRetriesRegex = regexp.MustCompile(`maxRetries\s*(?:=|\.set\()\s*(\d+)`)
+
+// GradleMaxRetries returns the maxRetries setting of the Gradle test-retry
+// plugin declared in the build script at the root of the given directory, or
+// zero if the setting can't be found.
+func GradleMaxRetries(root string) int {
+	for _, name := range []string{"build.gradle", "build.gradle.kts"} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+
+		if m := gradleMaxRetriesRegex.FindSubmatch(data); m != nil {
+			n, _ := strconv.Atoi(string(m[1]))
+			return n
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRetryPlugin(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   *RetryPlugin
+	}{
+		{
+			name: "maven surefire",
+			report: `<testsuite name="com.example.AppTest">
+  <properties>
+    <property name="surefire.rerunFailingTestsCount" value="2"/>
+  </properties>
+  <testcase name="works" classname="com.example.AppTest">
+    <flakyFailure message="expected true" type="java.lang.AssertionError"/>
+  </testcase>
+</testsuite>`,
+			want: &RetryPlugin{Name: RetryPluginSurefire, MaxRetries: 2},
+		},
+		{
+			name: "rspec-retry",
+			report: `<testsuite name="rspec">
+  <testcase classname="spec.models.user_spec" name="User is valid" file="./spec/models/user_spec.rb"/>
+  <system-out><![CDATA[RSpec::Retry: 2nd try ./spec/models/user_spec.rb:4
+]]></system-out>
+</testsuite>`,
+			want: &RetryPlugin{Name: RetryPluginRSpec},
+		},
+		{
+			name: "gradle test-retry",
+			report: `<testsuite name="com.example.AppTest">
+  <testcase name="works()" classname="com.example.AppTest">
+    <failure message="expected true" type="org.opentest4j.AssertionFailedError"/>
+  </testcase>
+  <testcase name="works()" classname="com.example.AppTest"/>
+</testsuite>`,
+			want: &RetryPlugin{Name: RetryPluginGradle},
+		},
+		{
+			name: "repeated test without failure",
+			report: `<testsuite name="com.example.AppTest">
+  <testcase name="works()" classname="com.example.AppTest"/>
+  <testcase name="works()" classname="com.example.AppTest"/>
+</testsuite>`,
+			want: nil,
+		},
+		{
+			name:   "no retry plugin",
+			report: `<testsuites><testsuite name="a"><testcase name="b"/></testsuite></testsuites>`,
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectRetryPlugin(strings.NewReader(tt.report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGradleMaxRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		script string
+		want   int
+	}{
+		{
+			name:   "groovy",
+			file:   "build.gradle",
+			script: "test {\n    retry {\n        maxRetries = 3\n    }\n}\n",
+			want:   3,
+		},
+		{
+			name:   "kotlin",
+			file:   "build.gradle.kts",
+			script: "tasks.test {\n    retry {\n        maxRetries.set(2)\n    }\n}\n",
+			want:   2,
+		},
+		{
+			name:   "not configured",
+			file:   "build.gradle",
+			script: "test {\n    useJUnitPlatform()\n}\n",
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.script), 0644)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, GradleMaxRetries(dir))
+		})
+	}
+}
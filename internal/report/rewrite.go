@@ -0,0 +1,102 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Rewrite copies the XML document read from r to w, passing each start element
+// to fn so that its name or attributes can be modified along the way.
+func Rewrite(r io.Reader, w io.Writer, fn func(*xml.StartElement)) error {
+	d := newDecoder(r)
+	e := xml.NewEncoder(w)
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		tok = normalizeToken(tok)
+		if t, ok := tok.(xml.StartElement); ok {
+			fn(&t)
+			tok = t
+		}
+
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+
+	return e.Flush()
+}
+
+// normalizeToken prepares a token read with RawToken for the encoder: it folds
+// namespace prefixes into names, and updates the XML declaration to match the
+// UTF-8 output.
+func normalizeToken(tok xml.Token) xml.Token {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		return flattenStartElement(t)
+	case xml.EndElement:
+		t.Name = flattenName(t.Name)
+		return t
+	case xml.ProcInst:
+		// The decoder transcodes the document to UTF-8, so the declaration
+		// must say as much.
+		if t.Target == "xml" {
+			t.Inst = encodingAttrRegex.ReplaceAll(t.Inst, []byte(`encoding="UTF-8"`))
+			return t
+		}
+	}
+
+	return tok
+}
+
+// flattenStartElement returns a copy of se in which namespace prefixes are
+// folded into the local names of the element and its attributes. RawToken
+// reports prefixes in the Space field, which the encoder would otherwise
+// mistake for a namespace URL.
+func flattenStartElement(se xml.StartElement) xml.StartElement {
+	attrs := make([]xml.Attr, len(se.Attr))
+	for i, a := range se.Attr {
+		attrs[i] = xml.Attr{Name: flattenName(a.Name), Value: a.Value}
+	}
+
+	return xml.StartElement{Name: flattenName(se.Name), Attr: attrs}
+}
+
+func flattenName(n xml.Name) xml.Name {
+	if n.Space == "" {
+		return n
+	}
+
+	return xml.Name{Local: n.Space + ":" + n.Local}
+}
+
+// attr returns the value of the named attribute of se, and whether it exists.
+func attr(se *xml.StartElement, name string) (string, bool) {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// setAttr sets the value of the named attribute of se, adding the attribute
+// if it doesn't already exist.
+func setAttr(se *xml.StartElement, name string, value string) {
+	for i, a := range se.Attr {
+		if a.Name.Local == name {
+			se.Attr[i].Value = value
+			return
+		}
+	}
+
+	se.Attr = append(se.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
@@ -0,0 +1,3 @@
+<?php
+
+it('returns a successful response', function () {});
@@ -0,0 +1,102 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ReadSuiteTimes returns the duration in seconds of each top-level test suite
+// in the JUnit report read from r, keyed by suite name. Suites nested within
+// another suite are counted as part of the outer suite, and the durations of
+// suites with the same name are summed.
+func ReadSuiteTimes(r io.Reader) (map[string]float64, error) {
+	d := newDecoder(r)
+
+	times := make(map[string]float64)
+	depth := 0
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "testsuite" {
+				continue
+			}
+			depth++
+			if depth > 1 {
+				continue
+			}
+
+			name, _ := attr(&t, "name")
+			value, _ := attr(&t, "time")
+			secs, err := strconv.ParseFloat(value, 64)
+			if err != nil || secs < 0 {
+				// Count suites without a valid duration, but as taking no time
+				secs = 0
+			}
+			times[name] += secs
+		case xml.EndElement:
+			if t.Name.Local == "testsuite" {
+				depth--
+			}
+		}
+	}
+
+	return times, nil
+}
+
+// A ShardPlan is a proposed assignment of test suites to a shard.
+type ShardPlan struct {
+	Seconds float64  `json:"seconds"`
+	Suites  []string `json:"suites"`
+}
+
+// Rebalance proposes an assignment of the suites (keyed by name, with their
+// durations in seconds) to n shards that minimizes the duration of the slowest
+// shard, using the longest-processing-time-first heuristic: each suite, from
+// the slowest to the fastest, is assigned to the shard with the least total
+// duration so far.
+func Rebalance(suiteTimes map[string]float64, n int) []ShardPlan {
+	if n < 1 {
+		return nil
+	}
+
+	names := make([]string, 0, len(suiteTimes))
+	for name := range suiteTimes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := suiteTimes[names[i]], suiteTimes[names[j]]
+		if ti == tj {
+			return names[i] < names[j]
+		}
+		return ti > tj
+	})
+
+	plans := make([]ShardPlan, n)
+	for _, name := range names {
+		least := 0
+		for i := range plans {
+			if plans[i].Seconds < plans[least].Seconds {
+				least = i
+			}
+		}
+		plans[least].Seconds += suiteTimes[name]
+		plans[least].Suites = append(plans[least].Suites, name)
+	}
+
+	for i := range plans {
+		sort.Strings(plans[i].Suites)
+	}
+
+	return plans
+}
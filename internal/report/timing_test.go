@@ -0,0 +1,56 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSuiteTimes(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="Outer" time="12.5">
+    <testsuite name="Inner" time="10.0">
+      <testcase name="test_a" time="10.0"/>
+    </testsuite>
+  </testsuite>
+  <testsuite name="Other" time="3"/>
+  <testsuite name="Other" time="1.5"/>
+  <testsuite name="Untimed"/>
+  <testsuite name="Malformed" time="soon"/>
+</testsuites>`
+
+	times, err := ReadSuiteTimes(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"Outer":     12.5,
+		"Other":     4.5,
+		"Untimed":   0,
+		"Malformed": 0,
+	}, times)
+}
+
+func TestReadSuiteTimes_malformed(t *testing.T) {
+	_, err := ReadSuiteTimes(strings.NewReader(`<testsuite name="Unterminated">`))
+	assert.Error(t, err)
+}
+
+func TestRebalance(t *testing.T) {
+	suites := map[string]float64{
+		"A": 60,
+		"B": 35,
+		"C": 30,
+		"D": 20,
+		"E": 10,
+	}
+
+	plans := Rebalance(suites, 2)
+	assert.Equal(t, []ShardPlan{
+		{Seconds: 80, Suites: []string{"A", "D"}},
+		{Seconds: 75, Suites: []string{"B", "C", "E"}},
+	}, plans)
+
+	assert.Nil(t, Rebalance(suites, 0))
+}
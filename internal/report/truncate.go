@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// OriginalSizeAttr is the attribute that records the size in bytes of the text
+// of a <failure> or <error> element before TruncateFailures truncated it.
+const OriginalSizeAttr = "original-size"
+
+// TruncateFailures copies the XML document read from r to w, truncating the
+// text and the message attribute of each <failure> and <error> element that
+// are longer than 2*keep bytes (e.g., megabyte stack dumps). The first and last
+// keep bytes are retained, with a marker giving the number of bytes removed in
+// between, and the original size of truncated text is recorded in the
+// original-size attribute. It returns the number of elements truncated.
+func TruncateFailures(r io.Reader, w io.Writer, keep int) (int, error) {
+	d := newDecoder(r)
+	e := xml.NewEncoder(w)
+
+	var truncated int
+	var held []xml.Token // the tokens of the failure or error being read
+	var depth int
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		tok = xml.CopyToken(normalizeToken(tok))
+
+		if held == nil {
+			if se, ok := tok.(xml.StartElement); ok && (se.Name.Local == "failure" || se.Name.Local == "error") {
+				held, depth = []xml.Token{se}, 1
+				continue
+			}
+			if err := e.EncodeToken(tok); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		held = append(held, tok)
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		if depth > 0 {
+			continue
+		}
+
+		var ok bool
+		if held, ok = truncateElement(held, keep); ok {
+			truncated++
+		}
+		for _, t := range held {
+			if err := e.EncodeToken(t); err != nil {
+				return 0, err
+			}
+		}
+		held = nil
+	}
+
+	return truncated, e.Flush()
+}
+
+// truncateElement truncates the message attribute and the text of the element
+// whose tokens are given, from its start element to its end element. It
+// returns the resulting tokens, and whether anything was truncated. Text is
+// left alone if the element has child elements.
+func truncateElement(toks []xml.Token, keep int) ([]xml.Token, bool) {
+	se := toks[0].(xml.StartElement)
+	var changed bool
+
+	if msg, ok := attr(&se, "message"); ok {
+		if s, ok := truncateMiddle(msg, keep); ok {
+			setAttr(&se, "message", s)
+			changed = true
+		}
+	}
+
+	var text []byte
+	for _, t := range toks[1 : len(toks)-1] {
+		cd, ok := t.(xml.CharData)
+		if !ok {
+			if _, ok := t.(xml.StartElement); ok {
+				toks[0] = se
+				return toks, changed
+			}
+			continue
+		}
+		text = append(text, cd...)
+	}
+
+	if s, ok := truncateMiddle(string(text), keep); ok {
+		setAttr(&se, OriginalSizeAttr, strconv.Itoa(len(text)))
+		toks = []xml.Token{se, xml.CharData(s), toks[len(toks)-1]}
+		return toks, true
+	}
+
+	toks[0] = se
+	return toks, changed
+}
+
+// truncateMiddle returns s with all but its first and last keep bytes replaced
+// by a marker, and whether s was long enough to be truncated. The cuts are
+// moved to the nearest character boundaries, so multi-byte characters are kept
+// whole.
+func truncateMiddle(s string, keep int) (string, bool) {
+	if len(s) <= 2*keep {
+		return s, false
+	}
+
+	head := keep
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	tail := len(s) - keep
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+
+	return fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s", s[:head], tail-head, s[tail:]), true
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateFailures(t *testing.T) {
+	dump := "Traceback:\n" + strings.Repeat("frame\n", 100) + "RuntimeError: boom"
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="suite">
+    <testcase name="passes"/>
+    <testcase name="fails"><failure message="` + strings.Repeat("m", 50) + `" type="RuntimeError">` + dump + `</failure></testcase>
+    <testcase name="errors"><error message="short">short</error></testcase>
+  </testsuite>
+</testsuites>`
+
+	var out strings.Builder
+	n, err := TruncateFailures(strings.NewReader(input), &out, 16)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var ts Testsuites
+	require.NoError(t, xml.Unmarshal([]byte(out.String()), &ts))
+	cases := ts.Suites[0].Testcases
+
+	failure := cases[1].Failure
+	require.NotNil(t, failure)
+	assert.Equal(t, "mmmmmmmmmmmmmmmm\n[... 18 bytes truncated ...]\nmmmmmmmmmmmmmmmm", failure.Message)
+	assert.Equal(t, "RuntimeError", failure.Type)
+	assert.Equal(t, "Traceback:\nframe\n[... 597 bytes truncated ...]\nntimeError: boom", failure.Text)
+	assert.Contains(t, out.String(), `original-size="629"`)
+
+	// Short failures are kept as is
+	assert.Equal(t, "short", cases[2].Error.Text)
+	assert.NotContains(t, out.String(), `original-size="5"`)
+}
+
+func TestTruncateFailures_multibyte(t *testing.T) {
+	input := `<testsuite><testcase name="x"><failure>` + strings.Repeat("é", 20) + `</failure></testcase></testsuite>`
+
+	var out strings.Builder
+	n, err := TruncateFailures(strings.NewReader(input), &out, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// The cuts fall between characters, keeping fewer bytes rather than
+	// splitting a character
+	assert.Contains(t, out.String(), ">éé\n[... 32 bytes truncated ...]\néé<")
+}
+
+func TestTruncateFailures_childElements(t *testing.T) {
+	input := `<testsuite><testcase name="x"><failure>` + strings.Repeat("a", 40) + `<detail/></failure></testcase></testsuite>`
+
+	var out strings.Builder
+	n, err := TruncateFailures(strings.NewReader(input), &out, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Contains(t, out.String(), strings.Repeat("a", 40))
+}
+
+func TestTruncateFailures_malformed(t *testing.T) {
+	_, err := TruncateFailures(strings.NewReader("<testsuite><testcase></testsuite>"), io.Discard, 5)
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trxTestRun represents the root element of a Visual Studio test results
+// (TRX) file, as produced by `dotnet test --logger trx`.
+type trxTestRun struct {
+	XMLName         xml.Name        `xml:"TestRun"`
+	Times           trxTimes        `xml:"Times"`
+	Results         []trxResult     `xml:"Results>UnitTestResult"`
+	TestDefinitions []trxDefinition `xml:"TestDefinitions>UnitTest"`
+}
+
+type trxTimes struct {
+	Start string `xml:"start,attr"`
+}
+
+type trxResult struct {
+	TestID       string      `xml:"testId,attr"`
+	TestName     string      `xml:"testName,attr"`
+	ComputerName string      `xml:"computerName,attr"`
+	Duration     string      `xml:"duration,attr"`
+	StartTime    string      `xml:"startTime,attr"`
+	Outcome      string      `xml:"outcome,attr"`
+	ResultType   string      `xml:"resultType,attr"`
+	Output       trxOutput   `xml:"Output"`
+	InnerResults []trxResult `xml:"InnerResults>UnitTestResult"`
+}
+
+type trxOutput struct {
+	StdOut     string `xml:"StdOut"`
+	StdErr     string `xml:"StdErr"`
+	Message    string `xml:"ErrorInfo>Message"`
+	StackTrace string `xml:"ErrorInfo>StackTrace"`
+}
+
+type trxDefinition struct {
+	ID         string        `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Storage    string        `xml:"storage,attr"`
+	TestMethod trxTestMethod `xml:"TestMethod"`
+}
+
+type trxTestMethod struct {
+	ClassName string `xml:"className,attr"`
+	Name      string `xml:"name,attr"`
+}
+
+// FromTRX converts the Visual Studio test results (TRX) document read from r
+// into JUnit test suites, with one suite per test assembly.
+//
+// Test case names are taken from each result's display name (rather than the
+// name of the underlying test method) so that data-driven tests (e.g., xUnit
+// theories and MSTest data rows) retain a distinct name for each data set.
+func FromTRX(r io.Reader) (*Testsuites, error) {
+	var run trxTestRun
+	if err := newDecoder(r).Decode(&run); err != nil {
+		return nil, fmt.Errorf("unable to parse TRX report: %v", err)
+	}
+
+	defs := make(map[string]trxDefinition)
+	for _, d := range run.TestDefinitions {
+		defs[d.ID] = d
+	}
+
+	ts := &Testsuites{}
+	suiteIndex := make(map[string]int)
+
+	var add func(result trxResult, parent trxDefinition)
+	add = func(result trxResult, parent trxDefinition) {
+		def, ok := defs[result.TestID]
+		if !ok {
+			def = parent
+		}
+
+		// Data-driven MSTest results nest the individual data rows beneath a
+		// parent result; report the rows rather than the aggregate.
+		if len(result.InnerResults) > 0 {
+			for _, inner := range result.InnerResults {
+				add(inner, def)
+			}
+			return
+		}
+
+		suiteName := trxSuiteName(def)
+		i, ok := suiteIndex[suiteName]
+		if !ok {
+			i = len(ts.Suites)
+			suiteIndex[suiteName] = i
+			ts.Suites = append(ts.Suites, Testsuite{
+				Name:      suiteName,
+				Timestamp: trxTimestamp(run.Times.Start),
+				Hostname:  result.ComputerName,
+			})
+		}
+
+		ts.Suites[i].Testcases = append(ts.Suites[i].Testcases, trxTestcase(result, def))
+	}
+
+	for _, result := range run.Results {
+		add(result, trxDefinition{})
+	}
+
+	ts.Tally()
+
+	return ts, nil
+}
+
+func trxTestcase(result trxResult, def trxDefinition) Testcase {
+	name := result.TestName
+	if name == "" {
+		name = def.Name
+	}
+
+	tc := Testcase{
+		Name:      name,
+		Classname: def.TestMethod.ClassName,
+		Time:      trxDuration(result.Duration),
+		SystemOut: result.Output.StdOut,
+		SystemErr: result.Output.StdErr,
+	}
+
+	switch result.Outcome {
+	case "Passed", "PassedButRunAborted", "Warning":
+	case "Failed", "Error", "Timeout", "Aborted":
+		tc.Failure = &Failure{
+			Message: strings.TrimSpace(result.Output.Message),
+			Text:    result.Output.StackTrace,
+		}
+	default: // e.g., NotExecuted, Inconclusive, NotRunnable, Disconnected
+		tc.Skipped = &Skipped{Message: strings.TrimSpace(result.Output.Message)}
+	}
+
+	return tc
+}
+
+// trxSuiteName returns the name of the test assembly (e.g., "Some.Tests.dll")
+// that contains the given test.
+func trxSuiteName(def trxDefinition) string {
+	if def.Storage == "" {
+		return "dotnet"
+	}
+
+	// The storage path uses the separator of the machine that ran the tests,
+	// which isn't necessarily the machine running the reporter.
+	return path.Base(strings.ReplaceAll(def.Storage, `\`, "/"))
+}
+
+// trxDuration converts a TRX duration (e.g., "00:00:01.2345678") into seconds.
+func trxDuration(s string) float64 {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+
+	var total float64
+	for i, unit := range []float64{3600, 60, 1} {
+		v, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return 0
+		}
+		total += v * unit
+	}
+
+	return total
+}
+
+// trxTimestamp converts a TRX timestamp into the format used by JUnit reports.
+func trxTimestamp(s string) string {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return ""
+	}
+
+	return t.UTC().Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromTRX(t *testing.T) {
+	f, err := os.Open("testdata/example.trx")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ts, err := FromTRX(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, ts.Tests)
+	assert.Equal(t, 1, ts.Failures)
+	assert.Equal(t, 1, ts.Skipped)
+	require.Len(t, ts.Suites, 2)
+
+	xunit := ts.Suites[0]
+	assert.Equal(t, "calculator.tests.dll", xunit.Name)
+	assert.Equal(t, "2020-07-11T01:02:03", xunit.Timestamp)
+	assert.Equal(t, "build-agent", xunit.Hostname)
+	require.Len(t, xunit.Testcases, 4)
+
+	// Data-driven tests retain the display name of each data set
+	assert.Equal(t, "Calculator.Tests.AdditionTests.AddsPairs(a: 1, b: 2)", xunit.Testcases[1].Name)
+	assert.Equal(t, "Calculator.Tests.AdditionTests.AddsPairs(a: 2, b: 2)", xunit.Testcases[2].Name)
+	assert.Equal(t, "Calculator.Tests.AdditionTests", xunit.Testcases[2].Classname)
+	assert.Equal(t, 1.5, xunit.Testcases[2].Time)
+	if assert.NotNil(t, xunit.Testcases[2].Failure) {
+		assert.Equal(t, "Assert.Equal() Failure\nExpected: 4\nActual:   5", xunit.Testcases[2].Failure.Message)
+		assert.Contains(t, xunit.Testcases[2].Failure.Text, "AdditionTests.cs:line 21")
+	}
+	assert.Equal(t, "computing 2 + 2", xunit.Testcases[2].SystemOut)
+
+	if assert.NotNil(t, xunit.Testcases[3].Skipped) {
+		assert.Equal(t, "Not yet implemented", xunit.Testcases[3].Skipped.Message)
+	}
+
+	// MSTest data rows are reported individually instead of as the aggregate
+	mstest := ts.Suites[1]
+	assert.Equal(t, "calculator.mstests.dll", mstest.Name)
+	require.Len(t, mstest.Testcases, 2)
+	assert.Equal(t, "SubtractsRows (Data Row 0)", mstest.Testcases[0].Name)
+	assert.Equal(t, "SubtractsRows (Data Row 1)", mstest.Testcases[1].Name)
+	assert.Equal(t, "Calculator.MSTests.SubtractionTests", mstest.Testcases[1].Classname)
+}
+
+func TestFromTRX_malformed(t *testing.T) {
+	_, err := FromTRX(strings.NewReader("<TestRun><Results>"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to parse TRX report")
+	}
+}
@@ -0,0 +1,62 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// A JUnitSummary counts what a JUnit XML report holds.
+type JUnitSummary struct {
+	Suites int // <testsuite> elements, including nested ones
+	Cases  int // <testcase> elements
+}
+
+// ValidateJUnit reads the JUnit XML report from r and returns a summary of its
+// contents. It returns an error if the report isn't well-formed XML, or if it
+// doesn't have exactly one root element, <testsuites> or <testsuite>.
+func ValidateJUnit(r io.Reader) (*JUnitSummary, error) {
+	d := newDecoder(r)
+
+	summary := &JUnitSummary{}
+	var root string
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if root != "" {
+					return nil, fmt.Errorf("more than one root element (<%s> and <%s>)", root, t.Name.Local)
+				}
+				root = t.Name.Local
+				if root != "testsuites" && root != "testsuite" {
+					return nil, fmt.Errorf("root element is <%s>, not <testsuites> or <testsuite>", root)
+				}
+			}
+			depth++
+
+			switch t.Name.Local {
+			case "testsuite":
+				summary.Suites++
+			case "testcase":
+				summary.Cases++
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	if root == "" {
+		return nil, fmt.Errorf("no root element")
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJUnit(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want *JUnitSummary
+		err  string
+	}{
+		{
+			name: "testsuites",
+			xml:  `<?xml version="1.0"?><testsuites><testsuite name="a"><testcase name="1"/><testcase name="2"/></testsuite><testsuite name="b"><testsuite name="c"><testcase name="3"/></testsuite></testsuite></testsuites>`,
+			want: &JUnitSummary{Suites: 3, Cases: 3},
+		},
+		{
+			name: "testsuite",
+			xml:  `<testsuite name="a"><testcase name="1"><failure message="boom"/></testcase></testsuite>`,
+			want: &JUnitSummary{Suites: 1, Cases: 1},
+		},
+		{
+			name: "unclosed element",
+			xml:  "<testsuite name=\"a\">\n<testcase name=\"1\">\n</testsuite>",
+			err:  "XML syntax error on line 3: element <testcase> closed by </testsuite>",
+		},
+		{
+			name: "other root element",
+			xml:  `<TestRun><Results/></TestRun>`,
+			err:  "root element is <TestRun>, not <testsuites> or <testsuite>",
+		},
+		{
+			name: "several root elements",
+			xml:  `<testsuite name="a"></testsuite><testsuite name="b"></testsuite>`,
+			err:  "more than one root element (<testsuite> and <testsuite>)",
+		},
+		{
+			name: "empty",
+			xml:  "",
+			err:  "no root element",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateJUnit(strings.NewReader(tt.xml))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
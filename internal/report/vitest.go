@@ -0,0 +1,83 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// vitestReport represents the document written by Vitest's JSON reporter,
+// which follows the shape of Jest's --json output.
+type vitestReport struct {
+	TestResults []vitestFile `json:"testResults"`
+}
+
+type vitestFile struct {
+	Name             string            `json:"name"`
+	Status           string            `json:"status"`
+	Message          string            `json:"message"`
+	AssertionResults []vitestAssertion `json:"assertionResults"`
+}
+
+type vitestAssertion struct {
+	AncestorTitles  []string `json:"ancestorTitles"`
+	Title           string   `json:"title"`
+	Status          string   `json:"status"`
+	Duration        float64  `json:"duration"` // milliseconds
+	FailureMessages []string `json:"failureMessages"`
+}
+
+// FromVitest converts the Vitest JSON document read from r into JUnit test
+// suites, with one suite per test file.
+func FromVitest(r io.Reader) (*Testsuites, error) {
+	var rep vitestReport
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return nil, fmt.Errorf("unable to parse Vitest report: %v", err)
+	}
+
+	ts := &Testsuites{}
+	for _, file := range rep.TestResults {
+		suite := Testsuite{Name: file.Name, File: file.Name}
+
+		for _, a := range file.AssertionResults {
+			suite.Testcases = append(suite.Testcases, vitestTestcase(a, file.Name))
+		}
+
+		// A file that fails to load (e.g., due to a syntax error) has no
+		// assertion results, so report the failure against the file itself.
+		if len(file.AssertionResults) == 0 && file.Status == "failed" {
+			suite.Testcases = append(suite.Testcases, Testcase{
+				Name:  file.Name,
+				File:  file.Name,
+				Error: &Failure{Message: firstLine(file.Message), Text: file.Message},
+			})
+		}
+
+		ts.Suites = append(ts.Suites, suite)
+	}
+
+	ts.Tally()
+
+	return ts, nil
+}
+
+func vitestTestcase(a vitestAssertion, file string) Testcase {
+	tc := Testcase{
+		Name:      a.Title,
+		Classname: strings.Join(a.AncestorTitles, " "),
+		File:      file,
+		Time:      a.Duration / 1000,
+	}
+
+	switch a.Status {
+	case "passed":
+	case "failed":
+		text := strings.Join(a.FailureMessages, "\n")
+		tc.Failure = &Failure{Message: firstLine(text), Text: text}
+	default: // e.g., skipped, pending, todo, disabled
+		tc.Skipped = &Skipped{}
+	}
+
+	return tc
+}
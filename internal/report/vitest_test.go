@@ -0,0 +1,51 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromVitest(t *testing.T) {
+	f, err := os.Open("testdata/vitest.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ts, err := FromVitest(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, ts.Tests)
+	assert.Equal(t, 1, ts.Failures)
+	assert.Equal(t, 1, ts.Errors)
+	assert.Equal(t, 1, ts.Skipped)
+	require.Len(t, ts.Suites, 3)
+
+	math := ts.Suites[0]
+	assert.Equal(t, "/home/runner/work/some-app/some-app/src/math.test.ts", math.Name)
+	require.Len(t, math.Testcases, 3)
+	assert.Equal(t, "adds floats", math.Testcases[1].Name)
+	assert.Equal(t, "math sum", math.Testcases[1].Classname)
+	assert.Equal(t, "/home/runner/work/some-app/some-app/src/math.test.ts", math.Testcases[1].File)
+	assert.Equal(t, 0.003, math.Testcases[1].Time)
+	if assert.NotNil(t, math.Testcases[1].Failure) {
+		assert.Equal(t, "AssertionError: expected 0.30000000000000004 to be 0.3 // Object.is equality", math.Testcases[1].Failure.Message)
+	}
+	assert.NotNil(t, math.Testcases[2].Skipped)
+
+	// Files that fail to load are reported as errors
+	broken := ts.Suites[2]
+	require.Len(t, broken.Testcases, 1)
+	if assert.NotNil(t, broken.Testcases[0].Error) {
+		assert.Contains(t, broken.Testcases[0].Error.Message, "Failed to load url ./missing")
+	}
+}
+
+func TestFromVitest_malformed(t *testing.T) {
+	_, err := FromVitest(strings.NewReader(`{"testResults": {`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to parse Vitest report")
+	}
+}
@@ -0,0 +1,444 @@
+// Package s3test provides an in-process S3-compatible server for testing code
+// that uploads to S3, without recording HTTP interactions against the real S3.
+//
+// The server implements the subset of the S3 API used for uploads (PutObject
+// and multipart uploads, plus HeadBucket) with path-style addressing. It stores
+// each object along with the headers of the request that created it, so tests
+// can inspect the content type, user-defined metadata, tags, ACL, and
+// server-side encryption settings of the upload.
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Object is an object stored by the server.
+type Object struct {
+	Bucket string
+	Key    string
+	Body   []byte
+
+	// Header holds the headers of the request that created the object (i.e.,
+	// the PutObject or CreateMultipartUpload request).
+	Header http.Header
+}
+
+// Metadata returns the user-defined metadata of o (i.e., the X-Amz-Meta-*
+// headers), keyed by lowercase name.
+func (o *Object) Metadata() map[string]string {
+	m := make(map[string]string)
+	for name, values := range o.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-meta-") && len(values) > 0 {
+			m[strings.TrimPrefix(name, "x-amz-meta-")] = values[0]
+		}
+	}
+
+	return m
+}
+
+// A Server is an in-process S3-compatible server.
+type Server struct {
+	// URL is the endpoint of the server (e.g., "http://127.0.0.1:12345"),
+	// which must be used with path-style addressing.
+	URL string
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	buckets    map[string]bool
+	accessKeys map[string]bool
+	objects    map[string]*Object
+	uploads    map[string]*upload
+	failures   []failure
+	noACLs     bool
+	stall      time.Duration
+	requests   int
+	nextID     int
+}
+
+// An upload is a multipart upload in progress.
+type upload struct {
+	object *Object
+	parts  map[int][]byte
+}
+
+// A failure is an error response that the server has been told to return.
+type failure struct {
+	status int
+	code   string
+}
+
+// NewServer starts and returns a new Server with the given buckets. The caller
+// should call Close when finished, to shut it down.
+func NewServer(buckets ...string) *Server {
+	s := newServer(buckets)
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s
+}
+
+// NewTLSServer starts and returns a new Server with the given buckets, using
+// TLS. The AWS SDK refuses to send server-side encryption keys over plain
+// HTTP, so uploads with SSE-C need a TLS server and the client returned by
+// Client.
+func NewTLSServer(buckets ...string) *Server {
+	s := newServer(buckets)
+	s.server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s
+}
+
+func newServer(buckets []string) *Server {
+	s := &Server{
+		buckets:    make(map[string]bool),
+		accessKeys: make(map[string]bool),
+		objects:    make(map[string]*Object),
+		uploads:    make(map[string]*upload),
+	}
+	for _, b := range buckets {
+		s.buckets[b] = true
+	}
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns an HTTP client configured for making requests to the server.
+// For a TLS server, it trusts the server's certificate.
+func (s *Server) Client() *http.Client {
+	return s.server.Client()
+}
+
+// CertificatePEM returns the certificate of a TLS server in PEM format (e.g.,
+// for use as AWS_CA_BUNDLE), or nil for a server that doesn't use TLS.
+func (s *Server) CertificatePEM() []byte {
+	cert := s.server.Certificate()
+	if cert == nil {
+		return nil
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// AllowAccessKeys restricts the server to requests signed with one of the
+// given access key IDs; other requests fail with InvalidAccessKeyId. By
+// default, requests with any access key ID are allowed. Signatures aren't
+// verified.
+func (s *Server) AllowAccessKeys(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.accessKeys[id] = true
+	}
+}
+
+// DisallowACLs makes the server reject requests that set an ACL with
+// AccessControlListNotSupported, like a bucket whose Object Ownership is set
+// to BucketOwnerEnforced.
+func (s *Server) DisallowACLs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.noACLs = true
+}
+
+// FailNext makes the server respond to the next n requests with the given
+// HTTP status and S3 error code (e.g., 500 and "InternalError").
+func (s *Server) FailNext(n int, status int, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status: status, code: code})
+	}
+}
+
+// Stall makes the server wait d before responding to each request (or until
+// the client gives up on the request), standing in for a stalled connection.
+func (s *Server) Stall(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stall = d
+}
+
+// Object returns the object with the given key in the given bucket, or nil if
+// there's no such object.
+func (s *Server) Object(bucket string, key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.objects[bucket+"/"+key]
+}
+
+// Objects returns the keys of the objects in the given bucket, in sorted
+// order.
+func (s *Server) Objects(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for _, o := range s.objects {
+		if o.Bucket == bucket {
+			keys = append(keys, o.Key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Requests returns the number of requests the server has received, including
+// those that failed.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// credentialRegex extracts the access key ID from a Signature Version 4
+// Authorization header.
+var credentialRegex = regexp.MustCompile(`Credential=([^/]+)/`)
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	// The body is read before stalling, since the request's context isn't done
+	// when the client gives up until the body has been read
+	body, err := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	stall := s.stall
+	s.mu.Unlock()
+	if stall > 0 {
+		select {
+		case <-time.After(stall):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		writeError(w, f.status, f.code, "Injected failure")
+		return
+	}
+
+	if len(s.accessKeys) > 0 {
+		m := credentialRegex.FindStringSubmatch(r.Header.Get("Authorization"))
+		if m == nil || !s.accessKeys[m[1]] {
+			writeError(w, http.StatusForbidden, "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records.")
+			return
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !s.buckets[bucket] {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	if key == "" && r.Method == http.MethodHead {
+		// HeadBucket, which checks that the bucket exists and is accessible
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if key == "" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "Bucket operations are not supported")
+		return
+	}
+
+	if s.noACLs && r.Header.Get("X-Amz-Acl") != "" {
+		writeError(w, http.StatusBadRequest, "AccessControlListNotSupported", "The bucket does not allow ACLs")
+		return
+	}
+
+	if err := checkSSECustomerKey(r.Header); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		s.uploadPart(w, query, body)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, bucket, key, body)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.createMultipartUpload(w, r, bucket, key)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		s.completeMultipartUpload(w, query, body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s is not supported", r.Method))
+	}
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket string, key string, body []byte) {
+	s.objects[bucket+"/"+key] = &Object{
+		Bucket: bucket,
+		Key:    key,
+		Body:   body,
+		Header: r.Header.Clone(),
+	}
+
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.uploads[id] = &upload{
+		object: &Object{Bucket: bucket, Key: key, Header: r.Header.Clone()},
+		parts:  make(map[int][]byte),
+	}
+
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadID string `xml:"UploadId"`
+	}{Bucket: bucket, Key: key, UploadID: id})
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, query url.Values, body []byte) {
+	u, ok := s.uploads[query.Get("uploadId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist")
+		return
+	}
+
+	n, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || n < 1 {
+		writeError(w, http.StatusBadRequest, "InvalidArgument", "Invalid part number")
+		return
+	}
+	u.parts[n] = body
+
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, query url.Values, body []byte) {
+	id := query.Get("uploadId")
+	u, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist")
+		return
+	}
+
+	var req struct {
+		Parts []struct {
+			PartNumber int
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	var data bytes.Buffer
+	for _, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("Part %d was not uploaded", p.PartNumber))
+			return
+		}
+		data.Write(part)
+	}
+
+	u.object.Body = data.Bytes()
+	s.objects[u.object.Bucket+"/"+u.object.Key] = u.object
+	delete(s.uploads, id)
+
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: u.object.Bucket, Key: u.object.Key, ETag: etag(u.object.Body)})
+}
+
+// checkSSECustomerKey returns an error if the server-side encryption with
+// customer-provided keys (SSE-C) headers are present but inconsistent.
+func checkSSECustomerKey(h http.Header) error {
+	algorithm := h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm")
+	key := h.Get("X-Amz-Server-Side-Encryption-Customer-Key")
+	keyMD5 := h.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5")
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return nil
+	}
+
+	if algorithm != "AES256" {
+		return fmt.Errorf("unsupported SSE-C algorithm %q", algorithm)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return fmt.Errorf("SSE-C key must be a base64-encoded 256-bit key")
+	}
+
+	sum := md5.Sum(raw)
+	if keyMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+		return fmt.Errorf("SSE-C key MD5 does not match the key")
+	}
+
+	return nil
+}
+
+func etag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append([]byte(xml.Header), data...))
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, message)
+}
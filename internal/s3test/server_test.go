@@ -0,0 +1,129 @@
+package s3test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUploader(t *testing.T, server *Server, accessKeyID string) *s3manager.Uploader {
+	// The SDK would otherwise load the CA bundle from the environment into the
+	// client, in place of the trust in the TLS server's certificate
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	sess, err := session.NewSession(
+		aws.NewConfig().
+			WithCredentials(credentials.NewStaticCredentials(accessKeyID, "some-secret-access-key", "")).
+			WithRegion("us-east-1").
+			WithEndpoint(server.URL).
+			WithS3ForcePathStyle(true).
+			WithHTTPClient(server.Client()),
+	)
+	require.NoError(t, err)
+
+	return s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		u.PartSize = s3manager.MinUploadPartSize
+	})
+}
+
+func TestServer_putObject(t *testing.T) {
+	server := NewServer("some-bucket")
+	defer server.Close()
+
+	_, err := newUploader(t, server, "some-access-key-id").Upload(&s3manager.UploadInput{
+		Bucket:      aws.String("some-bucket"),
+		Key:         aws.String("some/key.gz"),
+		Body:        bytes.NewReader([]byte("some data")),
+		ContentType: aws.String("application/gzip"),
+		Metadata:    aws.StringMap(map[string]string{"reporter-version": "v1.2.3"}),
+		Tagging:     aws.String("env=test"),
+	})
+	require.NoError(t, err)
+
+	obj := server.Object("some-bucket", "some/key.gz")
+	require.NotNil(t, obj)
+	assert.Equal(t, []byte("some data"), obj.Body)
+	assert.Equal(t, "application/gzip", obj.Header.Get("Content-Type"))
+	assert.Equal(t, "env=test", obj.Header.Get("X-Amz-Tagging"))
+	assert.Equal(t, map[string]string{"reporter-version": "v1.2.3"}, obj.Metadata())
+}
+
+func TestServer_multipartUpload(t *testing.T) {
+	server := NewTLSServer("some-bucket")
+	defer server.Close()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), int(s3manager.MinUploadPartSize*2+1024)/16)
+	_, err := newUploader(t, server, "some-access-key-id").Upload(&s3manager.UploadInput{
+		Bucket:               aws.String("some-bucket"),
+		Key:                  aws.String("some/key.gz"),
+		Body:                 bytes.NewReader(data),
+		SSECustomerAlgorithm: aws.String("AES256"),
+		SSECustomerKey:       aws.String(strings.Repeat("k", 32)),
+	})
+	require.NoError(t, err)
+
+	obj := server.Object("some-bucket", "some/key.gz")
+	require.NotNil(t, obj)
+	assert.Equal(t, data, obj.Body)
+	assert.Equal(t, "AES256", obj.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"))
+	assert.Equal(t, 5, server.Requests()) // create, three parts, complete
+}
+
+func TestServer_errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*Server)
+		input *s3manager.UploadInput
+		code  string
+	}{
+		{
+			name:  "unknown bucket",
+			setup: func(*Server) {},
+			input: &s3manager.UploadInput{Bucket: aws.String("some-bogus-bucket"), Key: aws.String("key")},
+			code:  "NoSuchBucket",
+		},
+		{
+			name:  "unknown access key",
+			setup: func(s *Server) { s.AllowAccessKeys("some-other-access-key-id") },
+			input: &s3manager.UploadInput{Bucket: aws.String("some-bucket"), Key: aws.String("key")},
+			code:  "InvalidAccessKeyId",
+		},
+		{
+			name:  "ACLs disallowed",
+			setup: func(s *Server) { s.DisallowACLs() },
+			input: &s3manager.UploadInput{Bucket: aws.String("some-bucket"), Key: aws.String("key"), ACL: aws.String("bucket-owner-full-control")},
+			code:  "AccessControlListNotSupported",
+		},
+		{
+			name:  "injected failure",
+			setup: func(s *Server) { s.FailNext(1, http.StatusForbidden, "AccessDenied") },
+			input: &s3manager.UploadInput{Bucket: aws.String("some-bucket"), Key: aws.String("key")},
+			code:  "AccessDenied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer("some-bucket")
+			defer server.Close()
+			tt.setup(server)
+
+			tt.input.Body = bytes.NewReader([]byte("some data"))
+			_, err := newUploader(t, server, "some-access-key-id").Upload(tt.input)
+
+			var aerr awserr.Error
+			if assert.ErrorAs(t, err, &aerr) {
+				assert.Equal(t, tt.code, aerr.Code())
+			}
+			assert.Empty(t, server.Objects("some-bucket"))
+		})
+	}
+}
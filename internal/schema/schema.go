@@ -0,0 +1,49 @@
+// Package schema provides the JSON Schemas of the files that describe an
+// uploaded bundle, so that tools that consume bundles (e.g., for compliance)
+// can validate them mechanically.
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+// The names of the schemas.
+const (
+	// Metadata is the schema of buildpulse.yml, the metadata about the build.
+	Metadata = "metadata"
+
+	// CoverageDelta is the schema of the deltas that stand in for coverage
+	// files uploaded with -coverage-baseline.
+	CoverageDelta = "coverage-delta"
+
+	// Manifest is the schema of manifest.json, the list of the files in a
+	// bundle signed with -sign-key or -sign-keyless.
+	Manifest = "manifest"
+)
+
+// Names lists the names of the schemas.
+var Names = []string{Metadata, CoverageDelta, Manifest}
+
+//go:embed *.schema.json
+var files embed.FS
+
+// Get returns the named schema, as a JSON document.
+func Get(name string) ([]byte, error) {
+	if !contains(Names, name) {
+		return nil, fmt.Errorf("invalid value \"%s\" for schema name: supported values are: %s", name, strings.Join(Names, ", "))
+	}
+
+	return files.ReadFile(name + ".schema.json")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildpulse/test-reporter/internal/delta"
+	"github.com/buildpulse/test-reporter/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonSchema struct {
+	Type       interface{}            `json:"type"`
+	Const      interface{}            `json:"const"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+}
+
+func load(t *testing.T, name string) *jsonSchema {
+	t.Helper()
+
+	data, err := Get(name)
+	require.NoError(t, err)
+
+	var s jsonSchema
+	require.NoError(t, json.Unmarshal(data, &s))
+	return &s
+}
+
+// schemaType returns the JSON Schema type of values of the given Go type.
+func schemaType(typ reflect.Type) string {
+	if typ == reflect.TypeOf(time.Time{}) {
+		return "string"
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return schemaType(typ.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Uint:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Slice:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// fields returns the fields of the given struct type, keyed by their names as
+// given by the struct tag with the given key. Inline fields are flattened.
+func fields(typ reflect.Type, key string) map[string]reflect.StructField {
+	all := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, ok := f.Tag.Lookup(key)
+		if !ok || tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if opts == "inline" {
+			for k, v := range fields(f.Type, key) {
+				all[k] = v
+			}
+			continue
+		}
+		all[name] = f
+	}
+
+	return all
+}
+
+func TestGet_metadata(t *testing.T) {
+	s := load(t, Metadata)
+
+	for name, f := range fields(reflect.TypeOf(metadata.Metadata{}), "yaml") {
+		prop, ok := s.Properties[name]
+		if !assert.True(t, ok, "schema is missing field %s", name) {
+			continue
+		}
+		assert.Equal(t, schemaType(f.Type), prop.Type, "type of field %s", name)
+
+		omitempty := strings.Contains(f.Tag.Get("yaml"), ",omitempty")
+		assert.Equal(t, !omitempty, contains(s.Required, name), "whether field %s is required", name)
+	}
+
+	for name := range s.Properties {
+		assert.Contains(t, fields(reflect.TypeOf(metadata.Metadata{}), "yaml"), name, "schema has unknown field")
+	}
+}
+
+func TestGet_metadataSchemaVersion(t *testing.T) {
+	s := load(t, Metadata)
+
+	require.Contains(t, s.Properties, ":schema_version")
+	assert.Equal(t, float64(metadata.SchemaVersion), s.Properties[":schema_version"].Const)
+}
+
+func TestGet_coverageDelta(t *testing.T) {
+	s := load(t, CoverageDelta)
+
+	for name := range fields(reflect.TypeOf(delta.Delta{}), "json") {
+		assert.Contains(t, s.Properties, name)
+	}
+	assert.Len(t, s.Properties, len(fields(reflect.TypeOf(delta.Delta{}), "json")))
+}
+
+func TestGet_unknown(t *testing.T) {
+	_, err := Get("receipt")
+	assert.EqualError(t, err, `invalid value "receipt" for schema name: supported values are: metadata, coverage-delta, manifest`)
+}
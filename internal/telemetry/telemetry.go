@@ -0,0 +1,111 @@
+// Package telemetry sends anonymous health pings about submissions (e.g.,
+// whether they failed, and at which stage) to BuildPulse, so that the
+// maintainers can prioritize fixes. Pings are sent only if the user opts in
+// with BUILDPULSE_TELEMETRY=on, and never identify the account, repository,
+// commit, or build.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The values supported by the BUILDPULSE_TELEMETRY environment variable.
+const (
+	On  = "on"
+	Off = "off"
+)
+
+var supportedValues = []string{On, Off}
+
+// Path is the path of the endpoint of the BuildPulse API that receives pings.
+const Path = "/reporter/telemetry"
+
+// timeout bounds the time spent sending a ping, so that an unreachable API
+// doesn't delay the build.
+const timeout = 2 * time.Second
+
+// The outcomes of a submission.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// The failure classes, which name the stage of the submission that failed.
+const (
+	FailureVersionCheck = "version_check"
+	FailureBundle       = "bundle"
+	FailureCompress     = "compress"
+	FailureUpload       = "upload"
+)
+
+// A Ping reports the outcome of a submission. It holds everything that's
+// sent; there are no other fields.
+type Ping struct {
+	ReporterVersion string `json:"reporter_version"`        // e.g., v0.30.1
+	GoOS            string `json:"go_os"`                   // e.g., linux
+	CIProvider      string `json:"ci_provider"`             // e.g., github-actions
+	Outcome         string `json:"outcome"`                 // success or failure
+	FailureClass    string `json:"failure_class,omitempty"` // the stage that failed, for failures
+	DurationMillis  int64  `json:"duration_ms"`             // the whole submission
+	BundleMillis    int64  `json:"bundle_duration_ms"`      // gathering metadata and preparing the bundle
+	UploadMillis    int64  `json:"upload_duration_ms"`      // uploading the bundle
+}
+
+// Enabled reports whether the user opted in to telemetry. It returns an error
+// if BUILDPULSE_TELEMETRY is set to an unsupported value.
+func Enabled(envs map[string]string) (bool, error) {
+	switch value := envs["BUILDPULSE_TELEMETRY"]; value {
+	case On:
+		return true, nil
+	case Off, "":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid value \"%s\" for environment variable BUILDPULSE_TELEMETRY: supported values are: %s", value, strings.Join(supportedValues, ", "))
+	}
+}
+
+// MarshalIndent returns p as indented JSON, as shown by `telemetry show`.
+func (p *Ping) MarshalIndent() ([]byte, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Send posts p to the BuildPulse API at apiURL using client.
+func Send(client *http.Client, apiURL string, userAgent string, p *Ping) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+Path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
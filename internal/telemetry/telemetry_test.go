@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   bool
+		errMsg string
+	}{
+		{value: "", want: false},
+		{value: "off", want: false},
+		{value: "on", want: true},
+		{value: "true", errMsg: `invalid value "true" for environment variable BUILDPULSE_TELEMETRY: supported values are: on, off`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Enabled(map[string]string{"BUILDPULSE_TELEMETRY": tt.value})
+			if tt.errMsg != "" {
+				assert.EqualError(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/reporter/telemetry", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "buildpulse-test-reporter/v1.2.3", r.Header.Get("User-Agent"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	ping := &Ping{
+		ReporterVersion: "v1.2.3",
+		GoOS:            "linux",
+		CIProvider:      "github-actions",
+		Outcome:         OutcomeFailure,
+		FailureClass:    FailureUpload,
+		DurationMillis:  1500,
+		BundleMillis:    300,
+		UploadMillis:    1100,
+	}
+	err := Send(http.DefaultClient, server.URL, "buildpulse-test-reporter/v1.2.3", ping)
+	require.NoError(t, err)
+
+	// The payload is exactly the documented fields
+	assert.Equal(t, map[string]interface{}{
+		"reporter_version":   "v1.2.3",
+		"go_os":              "linux",
+		"ci_provider":        "github-actions",
+		"outcome":            "failure",
+		"failure_class":      "upload",
+		"duration_ms":        float64(1500),
+		"bundle_duration_ms": float64(300),
+		"upload_duration_ms": float64(1100),
+	}, got)
+}
+
+func TestSend_unexpectedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := Send(http.DefaultClient, server.URL, "buildpulse-test-reporter/v1.2.3", &Ping{})
+	assert.EqualError(t, err, "unexpected response: 404 Not Found")
+}